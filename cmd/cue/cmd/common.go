@@ -31,6 +31,7 @@ import (
 	"cuelang.org/go/cue/ast"
 	"cuelang.org/go/cue/build"
 	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
 	"cuelang.org/go/cue/load"
 	"cuelang.org/go/cue/parser"
 	"cuelang.org/go/cue/token"
@@ -137,8 +138,9 @@ type buildPlan struct {
 	// flags.
 	imported []*ast.File
 
-	expressions []ast.Expr // only evaluate these expressions within results
-	schema      ast.Expr   // selects schema in instance for orphaned values
+	expressions []expression // only evaluate these expressions within results
+	schema      ast.Expr     // selects schema in instance for orphaned values
+	schemaFiles []*ast.File  // files of the instance holding the schema
 
 	// orphan placement flags.
 	perFile    bool
@@ -317,9 +319,24 @@ func (i *streamingIterator) err() error {
 	return i.e
 }
 
+// An expression is an expression passed with --expression or a query
+// passed with --query.
+type expression struct {
+	expr  ast.Expr
+	query *cue.Query
+}
+
+func (e expression) String() string {
+	if e.query != nil {
+		return e.query.String()
+	}
+	b, _ := format.Node(e.expr)
+	return string(b)
+}
+
 type expressionIter struct {
 	iter iterator
-	expr []ast.Expr
+	expr []expression
 	i    int
 }
 
@@ -346,7 +363,18 @@ func (i *expressionIter) value() cue.Value {
 		return i.iter.value()
 	}
 	v := i.iter.value()
-	return v.Context().BuildExpr(i.expr[i.i],
+	e := i.expr[i.i]
+	if e.query != nil {
+		// The values selected by a query are reported as a struct that maps
+		// the path of each value to the value.
+		w := v.Context().CompileString("{}")
+		iter := v.LookupAll(*e.query)
+		for iter.Next() {
+			w = w.FillPath(cue.MakePath(cue.Str(iter.Path().String())), iter.Value())
+		}
+		return w
+	}
+	return v.Context().BuildExpr(e.expr,
 		cue.Scope(v),
 		cue.InferBuiltins(true),
 		cue.ImportPath(i.iter.id()),
@@ -374,6 +402,9 @@ func newBuildPlan(cmd *Command, args []string, cfg *config) (p *buildPlan, err e
 	}
 	if cfg.loadCfg == nil {
 		cfg.loadCfg = defaultConfig.loadCfg
+		if cmd.loadCfg != nil {
+			cfg.loadCfg = cmd.loadCfg
+		}
 	}
 	cfg.loadCfg.Stdin = cmd.InOrStdin()
 
@@ -392,7 +423,7 @@ func newBuildPlan(cmd *Command, args []string, cfg *config) (p *buildPlan, err e
 	}
 	cfg.reFile = re
 
-	if err := setTags(cmd.Flags(), cfg.loadCfg); err != nil {
+	if err := setLoadFlags(cmd.Flags(), cfg.loadCfg); err != nil {
 		return nil, err
 	}
 
@@ -403,12 +434,15 @@ func (p *buildPlan) matchFile(file string) bool {
 	return p.cfg.reFile.MatchString(file)
 }
 
-func setTags(f *pflag.FlagSet, cfg *load.Config) error {
+// setLoadFlags configures cfg with the injection and sandbox flags.
+func setLoadFlags(f *pflag.FlagSet, cfg *load.Config) error {
 	tags, _ := f.GetStringArray(string(flagInject))
 	cfg.Tags = tags
 	if b, _ := f.GetBool(string(flagInjectVars)); b {
 		cfg.TagVars = load.DefaultTagVars()
 	}
+	cfg.Sandbox, _ = f.GetBool(string(flagSandbox))
+	cfg.AllowImports, _ = f.GetStringArray(string(flagAllowImport))
 	return nil
 }
 
@@ -449,12 +483,19 @@ func (p *buildPlan) getDecoders(b *build.Instance) (schemas, values []*decoderIn
 		}
 		switch f.Encoding {
 		case build.Protobuf, build.YAML, build.JSON, build.JSONL,
+			build.JSONC, build.JSON5, build.CBOR, build.MsgPack, build.HCL,
 			build.Text, build.Binary:
 			if f.Interpretation == build.ProtobufJSON {
 				// Need a schema.
 				values = append(values, &decoderInfo{f, nil})
 				continue
 			}
+		case build.XML, build.CSV, build.TSV,
+			build.DotEnv, build.Properties, build.INI:
+			// Needs to be decoded after any schema, which determines how
+			// untyped values are interpreted.
+			values = append(values, &decoderInfo{f, nil})
+			continue
 		case build.TextProto:
 			if p.importing {
 				return schemas, values, errors.Newf(token.NoPos,
@@ -564,9 +605,20 @@ func parseArgs(cmd *Command, args []string, cfg *config) (p *buildPlan, err erro
 
 	if b := p.orphanInstance; b != nil {
 		schemas, values, err := p.getDecoders(b)
-		for _, d := range append(schemas, values...) {
-			defer d.close()
-		}
+		plan := p
+		defer func() {
+			// The decoders of orphaned files are closed by the iterator
+			// that streams their values.
+			streamed := map[*decoderInfo]bool{}
+			for _, d := range plan.orphaned {
+				streamed[d] = true
+			}
+			for _, d := range append(schemas, values...) {
+				if !streamed[d] {
+					d.close()
+				}
+			}
+		}()
 		if err != nil {
 			return nil, err
 		}
@@ -625,6 +677,7 @@ func parseArgs(cmd *Command, args []string, cfg *config) (p *buildPlan, err erro
 				return nil, err
 			}
 			p.instance = inst
+			p.schemaFiles = schema.Files
 			p.encConfig.Schema = inst.Value()
 			if p.schema != nil {
 				v := cmd.ctx.BuildExpr(p.schema,
@@ -693,7 +746,14 @@ func (b *buildPlan) parseFlags() (err error) {
 		if err != nil {
 			return err
 		}
-		b.expressions = append(b.expressions, expr)
+		b.expressions = append(b.expressions, expression{expr: expr})
+	}
+	for _, s := range flagQuery.StringArray(b.cmd) {
+		q := cue.ParseQuery(s)
+		if err := q.Err(); err != nil {
+			return err
+		}
+		b.expressions = append(b.expressions, expression{query: &q})
 	}
 	if s := flagSchema.String(b.cmd); s != "" {
 		b.schema, err = parser.ParseExpr("--schema", s)
@@ -706,7 +766,7 @@ func (b *buildPlan) parseFlags() (err error) {
 		b.cfg.fileFilter = s
 	}
 	b.encConfig = &encoding.Config{
-		Force:         flagForce.Bool(b.cmd),
+		Force:         flagForce.Bool(b.cmd) || b.cmd.overwrite,
 		Mode:          b.cfg.outMode,
 		Stdin:         b.cmd.InOrStdin(),
 		Stdout:        b.cmd.OutOrStdout(),
@@ -772,7 +832,7 @@ func buildTools(cmd *Command, args []string) (*cue.Instance, error) {
 		Tools: true,
 	}
 	f := cmd.cmd.Flags()
-	if err := setTags(f, cfg); err != nil {
+	if err := setLoadFlags(f, cfg); err != nil {
 		return nil, err
 	}
 
@@ -29,6 +29,7 @@ import (
 
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
 	itask "cuelang.org/go/internal/task"
 	"cuelang.org/go/internal/value"
 	_ "cuelang.org/go/pkg/tool/cli" // Register tasks
@@ -123,6 +124,13 @@ outer:
 }
 
 func doTasks(cmd *Command, typ, command string, root *cue.Instance) error {
+	if flagSandbox.Bool(cmd) {
+		// Tasks may be defined without importing a tool package, so
+		// disallowing those imports is not sufficient.
+		err := errors.Newf(token.NoPos,
+			"cannot run command %q in sandbox mode: tasks perform I/O", command)
+		exitOnErr(cmd, err, true)
+	}
 	cfg := &flow.Config{
 		Root:           cue.MakePath(cue.Str(commandSection), cue.Str(command)),
 		InferTasks:     true,
@@ -0,0 +1,112 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/docgen"
+)
+
+func newDocCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doc [packages]",
+		Short: "generate reference documentation for definitions",
+		Long: `doc generates reference documentation for the definitions of the given
+packages from their doc comments.
+
+The documentation of each definition lists its fields, including the
+fields of nested structs and pattern constraints. For each field it shows
+the type or constraint as exported by CUE, the default, whether the
+field is required, the allowed values of fields that are a disjunction of
+literals, and the doc comment of the field. References to definitions of
+the documented packages link to their documentation.
+
+The documentation is written as a single Markdown document, or as an HTML
+page with --format html. The format defaults to HTML if the output file has
+an .html extension.
+
+Examples:
+
+  $ cue doc ./schema > schema.md
+  $ cue doc ./... -o reference.html
+`,
+		RunE: mkRunE(c, runDoc),
+	}
+
+	cmd.Flags().String(string(flagFormat), "",
+		`output format: "markdown" or "html"`)
+	cmd.Flags().StringP(string(flagOutFile), "o", "",
+		"filename or - for stdout")
+	cmd.Flags().BoolP(string(flagForce), "f", false,
+		"force overwriting existing files")
+
+	return cmd
+}
+
+const flagFormat flagName = "format"
+
+func runDoc(cmd *Command, args []string) error {
+	dst := flagOutFile.String(cmd)
+	format := flagFormat.String(cmd)
+	if format == "" {
+		switch filepath.Ext(dst) {
+		case ".html", ".htm":
+			format = "html"
+		default:
+			format = "markdown"
+		}
+	}
+	write := docgen.WriteMarkdown
+	switch format {
+	case "markdown", "md":
+	case "html":
+		write = docgen.WriteHTML
+	default:
+		return errors.Newf(token.NoPos, "unknown format %q", format)
+	}
+
+	binst := loadFromArgs(cmd, args, nil)
+	if binst == nil {
+		return nil
+	}
+	insts := buildInstances(cmd, binst, false)
+
+	var pkgs []*docgen.Package
+	for i, inst := range insts {
+		pkgs = append(pkgs, docgen.Extract(binst[i], inst.Value()))
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf, pkgs); err != nil {
+		return err
+	}
+
+	if dst == "" || dst == "-" {
+		_, err := cmd.OutOrStdout().Write(buf.Bytes())
+		return err
+	}
+	if _, err := os.Stat(dst); err == nil && !flagForce.Bool(cmd) {
+		return fmt.Errorf("error writing %q: file already exists", dst)
+	}
+	return os.WriteFile(dst, buf.Bytes(), 0666)
+}
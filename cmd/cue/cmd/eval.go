@@ -39,6 +39,14 @@ Printing is skipped if validation fails.
 The --expression flag is used to evaluate an expression within the
 configuration file, instead of the entire configuration file itself.
 
+The --query flag selects values with a query, which is a path that may
+contain wildcards or patterns, such as deployments.*.spec.replicas,
+items[*].name or [=~"^prod-"]. The values it selects are printed as a
+struct that maps the path of each value to the value.
+
+The --watch flag keeps eval running and prints the configuration again,
+or the errors, each time one of the input files changes.
+
 Examples:
 
   $ cat <<EOF > foo.cue
@@ -49,7 +57,7 @@ Examples:
   "a"
   "c"
 `,
-		RunE: mkRunE(c, runEval),
+		RunE: mkRunE(c, watchable(runEval)),
 	}
 
 	addOutFlags(cmd.Flags(), true)
@@ -57,6 +65,9 @@ Examples:
 	addInjectionFlags(cmd.Flags(), false)
 
 	cmd.Flags().StringArrayP(string(flagExpression), "e", nil, "evaluate this expression only")
+	cmd.Flags().StringArray(string(flagQuery), nil, "evaluate the values selected by this query only")
+	cmd.Flags().Bool(string(flagWatch), false,
+		"rerun when the input files change")
 
 	cmd.Flags().BoolP(string(flagConcrete), "c", false,
 		"require the evaluation to be concrete")
@@ -141,8 +152,7 @@ func runEval(cmd *Command, args []string) error {
 		}
 
 		if len(b.expressions) > 1 {
-			b, _ := format.Node(b.expressions[i%len(b.expressions)])
-			id = string(b)
+			id = b.expressions[i%len(b.expressions)].String()
 		}
 
 		if !flagIgnore.Bool(cmd) {
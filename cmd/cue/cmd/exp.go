@@ -0,0 +1,160 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/internal/encoding"
+	"cuelang.org/go/internal/filetypes"
+	"cuelang.org/go/tools/example"
+)
+
+func newExpCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exp <cmd> [arguments]",
+		Short: "experimental commands",
+		Long: `exp groups commands which are still in development.
+
+Their interface and behavior may change in future releases.
+`,
+		RunE: mkRunE(c, func(cmd *Command, args []string) error {
+			stderr := cmd.Stderr()
+			if len(args) == 0 {
+				fmt.Fprintln(stderr, "exp must be run as one of its subcommands")
+			} else {
+				fmt.Fprintf(stderr, "exp must be run as one of its subcommands: unknown subcommand %q\n", args[0])
+			}
+			fmt.Fprintln(stderr, "Run 'cue help exp' for known subcommands.")
+			os.Exit(1) // TODO: get rid of this
+			return nil
+		}),
+	}
+
+	cmd.AddCommand(newGenExampleCmd(c))
+	return cmd
+}
+
+func newGenExampleCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gen-example <expr> [inputs]",
+		Short: "generate example data from a schema",
+		Long: `gen-example generates data that is an instance of the schema
+denoted by the given expression, typically a definition.
+
+Where the schema does not determine a concrete value, gen-example uses
+defaults, picks the first disjunct that yields a valid value, picks numbers
+satisfying bounds, picks strings matching regular expressions, and adds
+list elements and struct fields as needed by validators like list.MinItems
+and struct.MinFields. Optional fields are omitted unless --optional is set.
+
+With -n, gen-example generates the given number of randomized instances as a
+stream. The random choices are determined by --seed, which defaults to a
+seed based on the current time. If --optional is also set, each optional
+field is included at random.
+
+Examples:
+
+  $ cat <<EOF > schema.cue
+  #Service: {
+      name:     =~"^[a-z]+$"
+      port:     int & >=1024 & <65536
+      protocol: *"tcp" | "udp"
+  }
+  EOF
+
+  $ cue exp gen-example '#Service' schema.cue
+  {
+      "name": "a",
+      "port": 1024,
+      "protocol": "tcp"
+  }
+
+  $ cue exp gen-example '#Service' schema.cue -n 3 --seed 1 --out yaml
+`,
+		RunE: mkRunE(c, runGenExample),
+	}
+
+	addOutFlags(cmd.Flags(), true)
+	addInjectionFlags(cmd.Flags(), false)
+
+	cmd.Flags().IntP(string(flagCount), "n", 0,
+		"generate this number of randomized instances")
+	cmd.Flags().Int(string(flagSeed), 0,
+		"seed for randomized instances (default based on the current time)")
+	cmd.Flags().Bool(string(flagInclOptional), false,
+		"include optional fields")
+
+	return cmd
+}
+
+const (
+	flagCount        flagName = "count"
+	flagSeed         flagName = "seed"
+	flagInclOptional flagName = "optional"
+)
+
+func runGenExample(cmd *Command, args []string) error {
+	if len(args) == 0 {
+		return errors.New("no expression specified")
+	}
+	expr, err := parser.ParseExpr("expression", args[0])
+	if err != nil {
+		return err
+	}
+
+	b, err := parseArgs(cmd, args[1:], &config{outMode: filetypes.Export})
+	exitOnErr(cmd, err, true)
+	b.expressions = []expression{{expr: expr}}
+
+	cfg := &example.Config{Optional: flagInclOptional.Bool(cmd)}
+	n := flagCount.Int(cmd)
+	if n > 0 || cmd.Flags().Changed(string(flagSeed)) {
+		seed := int64(flagSeed.Int(cmd))
+		if !cmd.Flags().Changed(string(flagSeed)) {
+			seed = time.Now().UnixNano()
+		}
+		cfg.Rand = rand.New(rand.NewSource(seed))
+	}
+	if n > 0 {
+		b.encConfig.Stream = true
+	} else {
+		n = 1
+	}
+
+	enc, err := encoding.NewEncoder(b.outFile, b.encConfig)
+	exitOnErr(cmd, err, true)
+	defer enc.Close()
+
+	iter := b.instances()
+	defer iter.close()
+	for iter.scan() {
+		v := iter.value()
+		for i := 0; i < n; i++ {
+			x, err := example.Generate(v, cfg)
+			exitOnErr(cmd, err, true)
+			exitOnErr(cmd, enc.Encode(x), true)
+		}
+	}
+	exitOnErr(cmd, iter.err(), true)
+	return nil
+}
@@ -15,8 +15,21 @@
 package cmd
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
 	"github.com/spf13/cobra"
 
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/cue/token"
 	"cuelang.org/go/internal/encoding"
 	"cuelang.org/go/internal/filetypes"
 )
@@ -75,6 +88,38 @@ ancestor directories, up to the module root, belonging to the same package.
 If the package is not explicitly defined by the '-p' flag, it must be uniquely
 defined by the files in the current directory.
 
+The --expression flag exports the value of an expression instead of the
+entire configuration. The --query flag exports the values selected by a
+query, which is a path that may contain wildcards or patterns, such as
+deployments.*.spec.replicas, items[*].name or [=~"^prod-"], as a struct
+that maps the path of each value to the value.
+
+The --outdir flag writes each field of the exported struct, or each element
+of the exported list, to its own file in the given directory instead of
+writing a single stream. Combine it with --expression to select the value
+to split. The name of each file is, in order of precedence,
+
+  - the argument of a @file(name) attribute on the field,
+  - the value of the CUE expression given with --filename, evaluated
+    within the field or element, as in '"\(kind)-\(metadata.name).yaml"',
+  - the name of the field or the index of the element.
+
+Names without an extension get that of the --out encoding, or .json by
+default, and the encoding of each file is otherwise derived from its
+extension. Names may contain slashes to create subdirectories. Existing
+files are only overwritten with --force. The files written to the
+directory are recorded in its .cue-export file. The --prune flag removes
+the files recorded by an earlier export that were not written again, so
+that files for removed values do not linger; other files are never
+removed. It cannot be used if the directory is a module root or contains
+the current directory.
+
+  $ cue export ./k8s -e deployments --outdir out --filename '"\(metadata.name).yaml"' --prune -f
+
+The --watch flag keeps export running and exports the configuration again,
+or prints the errors, each time one of the input files changes. Output
+files written by a previous export are overwritten.
+
 
 Formats
 The following formats are recognized:
@@ -87,9 +132,32 @@ text    output as raw text
 
 yaml    output as YAML
                 Outputs any CUE value.
+
+xml     output as XML
+                The evaluated value must be a struct with a single
+                field, which is mapped to the root element.
+
+csv     output as CSV (or tsv for TSV)
+                The evaluated value must be a list of structs or a
+                list of lists with scalar values.
+
+cbor    output as CBOR (or msgpack for MessagePack)
+                Outputs any CUE value. Bytes map to byte strings.
+
+hcl     output as HCL native syntax
+                The evaluated value must be a struct. Top-level
+                Terraform block types, such as resource, and fields
+                marked @hcl(block) are written as blocks. Strings of
+                the form "${expr}" are written as expressions.
+
+dotenv  output as dotenv (or properties or ini)
+                The evaluated value must be a struct with scalar
+                values. INI sections are written for struct fields.
+                Use the nest=true tag, as in --out ini+nest=true,
+                to write nested structs as dotted keys.
 `,
 
-		RunE: mkRunE(c, runExport),
+		RunE: mkRunE(c, watchable(runExport)),
 	}
 
 	addOutFlags(cmd.Flags(), true)
@@ -98,6 +166,15 @@ yaml    output as YAML
 
 	cmd.Flags().Bool(string(flagEscape), false, "use HTML escaping")
 	cmd.Flags().StringArrayP(string(flagExpression), "e", nil, "export this expression only")
+	cmd.Flags().StringArray(string(flagQuery), nil, "export the values selected by this query only")
+	cmd.Flags().Bool(string(flagWatch), false,
+		"rerun when the input files change")
+	cmd.Flags().String(string(flagOutDir), "",
+		"write each field or element to its own file in this directory")
+	cmd.Flags().String(string(flagFilename), "",
+		"CUE expression for the file names used with --outdir")
+	cmd.Flags().Bool(string(flagPrune), false,
+		"remove stale files from the --outdir directory")
 
 	return cmd
 }
@@ -106,6 +183,16 @@ func runExport(cmd *Command, args []string) error {
 	b, err := parseArgs(cmd, args, &config{outMode: filetypes.Export})
 	exitOnErr(cmd, err, true)
 
+	if dir := flagOutDir.String(cmd); dir != "" {
+		err := exportDir(cmd, b, dir)
+		exitOnErr(cmd, err, true)
+		return nil
+	}
+	if flagFilename.String(cmd) != "" || flagPrune.Bool(cmd) {
+		return errors.Newf(token.NoPos,
+			"--filename and --prune can only be used with --outdir")
+	}
+
 	enc, err := encoding.NewEncoder(b.outFile, b.encConfig)
 	exitOnErr(cmd, err, true)
 	defer enc.Close()
@@ -120,3 +207,261 @@ func runExport(cmd *Command, args []string) error {
 	exitOnErr(cmd, iter.err(), true)
 	return nil
 }
+
+// An outFile is a value to be written to a file of an --outdir directory.
+type outFile struct {
+	name      string // relative to the directory
+	qualifier string // encoding qualifier, if the extension is from --out
+	val       cue.Value
+}
+
+// exportDir writes the fields or elements of the exported values to files
+// in dir. No files are written if any of the file names cannot be
+// determined or, without --force, any of the files exists.
+func exportDir(cmd *Command, b *buildPlan, dir string) error {
+	if flagOutFile.String(cmd) != "" {
+		return errors.Newf(token.NoPos,
+			"cannot use --outdir with --outfile")
+	}
+	var nameExpr ast.Expr
+	if s := flagFilename.String(cmd); s != "" {
+		expr, err := parser.ParseExpr("--filename", s)
+		if err != nil {
+			return err
+		}
+		nameExpr = expr
+	}
+	prune := flagPrune.Bool(cmd)
+	if prune {
+		if err := checkPruneDir(dir); err != nil {
+			return err
+		}
+	}
+	qualifier, ext := "", ".json"
+	if out := flagOut.String(cmd); out != "" {
+		qualifier = out + ":"
+		ext = encodingExt(b.outFile.Encoding)
+	}
+
+	var files []outFile
+	seen := map[string]bool{}
+	add := func(v cue.Value, label string) error {
+		name := label
+		if a := v.Attribute("file"); a.Err() == nil {
+			s, err := a.String(0)
+			if err != nil {
+				return err
+			}
+			name = s
+		} else if nameExpr != nil {
+			x := v.Context().BuildExpr(nameExpr,
+				cue.Scope(v),
+				cue.InferBuiltins(true))
+			s, err := x.String()
+			if err != nil {
+				return errors.Wrapf(err, token.NoPos,
+					"invalid file name for %s", v.Path())
+			}
+			name = s
+		}
+		q := ""
+		if filepath.Ext(name) == "" {
+			name += ext
+			q = qualifier
+		}
+		name = filepath.Clean(filepath.FromSlash(name))
+		if !isLocalName(name) {
+			return errors.Newf(token.NoPos,
+				"file name %q for %s is outside of the output directory", name, v.Path())
+		}
+		if name == manifestFile {
+			return errors.Newf(token.NoPos,
+				"file name %q for %s is reserved", name, v.Path())
+		}
+		if seen[name] {
+			return errors.Newf(token.NoPos,
+				"duplicate file name %q for %s", name, v.Path())
+		}
+		seen[name] = true
+		files = append(files, outFile{name: name, qualifier: q, val: v})
+		return nil
+	}
+
+	iter := b.instances()
+	defer iter.close()
+	for iter.scan() {
+		v := iter.value()
+		switch v.IncompleteKind() {
+		case cue.StructKind:
+			fields, err := v.Fields()
+			if err != nil {
+				return err
+			}
+			for fields.Next() {
+				if err := add(fields.Value(), fields.Selector().Unquoted()); err != nil {
+					return err
+				}
+			}
+		case cue.ListKind:
+			elems, err := v.List()
+			if err != nil {
+				return err
+			}
+			for i := 0; elems.Next(); i++ {
+				if err := add(elems.Value(), strconv.Itoa(i)); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := v.Err(); err != nil {
+				return err
+			}
+			return errors.Newf(token.NoPos,
+				"--outdir requires a struct or list, found %v", v.IncompleteKind())
+		}
+	}
+	if err := iter.err(); err != nil {
+		return err
+	}
+
+	if !b.encConfig.Force {
+		for _, f := range files {
+			path := filepath.Join(dir, f.name)
+			if _, err := os.Stat(path); err == nil {
+				return errors.Wrapf(os.ErrExist, token.NoPos,
+					"error writing %q", path)
+			}
+		}
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		bf, err := filetypes.ParseFile(f.qualifier+path, filetypes.Export)
+		if err != nil {
+			return err
+		}
+		cfg := *b.encConfig
+		cfg.Stream = false
+		enc, err := encoding.NewEncoder(bf, &cfg)
+		if err != nil {
+			return err
+		}
+		err = enc.Encode(f.val)
+		if cerr := enc.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	written, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+	for name := range written {
+		switch {
+		case seen[name]:
+		case prune:
+			err := os.Remove(filepath.Join(dir, name))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			delete(written, name)
+		default:
+			// Keep track of stale files to allow pruning them later.
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				delete(written, name)
+			}
+		}
+	}
+	for name := range seen {
+		written[name] = true
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeManifest(dir, written)
+}
+
+// manifestFile is the file of an --outdir directory that records the files
+// written to it, which are the only ones that --prune removes.
+const manifestFile = ".cue-export"
+
+// isLocalName reports whether the cleaned file name refers to a file within
+// the directory relative to which it is interpreted.
+func isLocalName(name string) bool {
+	return !filepath.IsAbs(name) && name != ".." &&
+		!strings.HasPrefix(name, ".."+string(filepath.Separator))
+}
+
+// checkPruneDir reports an error if dir is a directory in which pruning
+// could remove files that are not generated, such as a module root or a
+// directory containing the current directory.
+func checkPruneDir(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(abs, cwd); err == nil && isLocalName(rel) {
+		return errors.Newf(token.NoPos,
+			"cannot use --prune: --outdir %q contains the current directory", dir)
+	}
+	if _, err := os.Stat(filepath.Join(abs, "cue.mod")); err == nil {
+		return errors.Newf(token.NoPos,
+			"cannot use --prune: --outdir %q is a module root", dir)
+	}
+	return nil
+}
+
+// readManifest returns the names of the files recorded in the manifest of
+// dir, if any.
+func readManifest(dir string) (map[string]bool, error) {
+	names := map[string]bool{}
+	b, err := ioutil.ReadFile(filepath.Join(dir, manifestFile))
+	if os.IsNotExist(err) {
+		return names, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if line == "" {
+			continue
+		}
+		name := filepath.Clean(filepath.FromSlash(line))
+		if !isLocalName(name) || name == manifestFile {
+			return nil, errors.Newf(token.NoPos,
+				"invalid file name %q in %s", line, filepath.Join(dir, manifestFile))
+		}
+		names[name] = true
+	}
+	return names, nil
+}
+
+// writeManifest records names as the files written to dir.
+func writeManifest(dir string, names map[string]bool) error {
+	a := make([]string, 0, len(names))
+	for name := range names {
+		a = append(a, filepath.ToSlash(name)+"\n")
+	}
+	sort.Strings(a)
+	return ioutil.WriteFile(filepath.Join(dir, manifestFile), []byte(strings.Join(a, "")), 0644)
+}
+
+// encodingExt returns the file extension for encoding e.
+func encodingExt(e build.Encoding) string {
+	switch e {
+	case build.Text:
+		return ".txt"
+	case build.DotEnv:
+		return ".env"
+	}
+	return "." + string(e)
+}
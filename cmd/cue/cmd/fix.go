@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"bytes"
 	"io/fs"
 	"io/ioutil"
 	"os"
@@ -27,6 +28,7 @@ import (
 	"cuelang.org/go/cue/load"
 	"cuelang.org/go/cue/token"
 	"cuelang.org/go/tools/fix"
+	"github.com/pkg/diff"
 	"github.com/spf13/cobra"
 )
 
@@ -39,26 +41,68 @@ After you update to a new CUE release, fix helps make the necessary changes
 to your program.
 
 Without any packages, fix applies to all files within a module.
+
+The -r flag additionally applies a rewrite rule of the form
+
+  pattern -> replacement
+
+where pattern and replacement are CUE expressions. Single-character
+lowercase identifiers serve as wildcards: in the pattern they match any
+expression, which is then substituted for the same identifier in the
+replacement. A package may be referred to by its quoted import path, as in
+"example.com/schema".#Foo, to match it regardless of the name under which
+it is imported. Imports of packages referred to this way in the
+replacement are added, and imports that are no longer used are removed.
+Other names in the replacement must refer to declarations, such as fields
+or imported packages, that are visible where a match is replaced; matches
+for which they are not are reported as errors and left unchanged. The flag
+may be repeated, in which case the rules are applied in order.
+
+The -n flag prints the changes as a diff instead of writing the files.
+
+Examples:
+
+  $ cue fix -r 'x & x -> x' ./...
+
+  $ cue fix -n -r '"example.com/old".#Foo & x -> "example.com/new".#Bar & x'
 `,
 		RunE: mkRunE(c, runFixAll),
 	}
 
 	cmd.Flags().BoolP(string(flagForce), "f", false,
 		"rewrite even when there are errors")
+	cmd.Flags().StringArrayP(string(flagRewrite), "r", nil,
+		"rewrite rule of the form 'pattern -> replacement'")
+	cmd.Flags().BoolP(string(flagDryrun), "n", false,
+		"print the changes as a diff instead of writing files")
 
 	return cmd
 }
 
+const flagRewrite flagName = "rewrite"
+
 func runFixAll(cmd *Command, args []string) error {
 	dir, err := os.Getwd()
 	if err != nil {
 		return err
 	}
+	cwd := dir
 
 	var opts []fix.Option
 	if flagSimplify.Bool(cmd) {
 		opts = append(opts, fix.Simplify())
 	}
+	rules, err := cmd.Flags().GetStringArray(string(flagRewrite))
+	if err != nil {
+		return err
+	}
+	for _, s := range rules {
+		r, err := fix.ParseRule(s)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, fix.Rewrite(r))
+	}
 
 	if len(args) == 0 {
 		args = []string{"./..."}
@@ -107,6 +151,23 @@ func runFixAll(cmd *Command, args []string) error {
 				errs = errors.Append(errs, errors.Promote(err, "format"))
 			}
 
+			orig, err := ioutil.ReadFile(f.Filename)
+			if err == nil && bytes.Equal(orig, b) {
+				continue
+			}
+
+			if flagDryrun.Bool(cmd) {
+				name := f.Filename
+				if rel, err := filepath.Rel(cwd, name); err == nil {
+					name = rel
+				}
+				err = diff.Text(name+".orig", name, orig, b, cmd.OutOrStdout())
+				if err != nil {
+					errs = errors.Append(errs, errors.Promote(err, "diff"))
+				}
+				continue
+			}
+
 			err = ioutil.WriteFile(f.Filename, b, 0644)
 			if err != nil {
 				errs = errors.Append(errs, errors.Promote(err, "write"))
@@ -34,8 +34,11 @@ const (
 	flagPackage       flagName = "package"
 	flagInject        flagName = "inject"
 	flagInjectVars    flagName = "inject-vars"
+	flagSandbox       flagName = "sandbox"
+	flagAllowImport   flagName = "allow-import"
 
 	flagExpression  flagName = "expression"
+	flagQuery       flagName = "query"
 	flagSchema      flagName = "schema"
 	flagEscape      flagName = "escape"
 	flagGlob        flagName = "name"
@@ -50,6 +53,10 @@ const (
 	flagWithContext flagName = "with-context"
 	flagOut         flagName = "out"
 	flagOutFile     flagName = "outfile"
+	flagWatch       flagName = "watch"
+	flagOutDir      flagName = "outdir"
+	flagFilename    flagName = "filename"
+	flagPrune       flagName = "prune"
 )
 
 func addOutFlags(f *pflag.FlagSet, allowNonCUE bool) {
@@ -74,6 +81,10 @@ func addGlobalFlags(f *pflag.FlagSet) {
 	f.BoolP(string(flagVerbose), "v", false,
 		"print information about progress")
 	f.BoolP(string(flagAllErrors), "E", false, "print all available errors")
+	f.Bool(string(flagSandbox), false,
+		"disallow tool packages, tag variables and imports from outside the module")
+	f.StringArray(string(flagAllowImport), nil,
+		"import path prefix that may be imported with --sandbox")
 }
 
 func addOrphanFlags(f *pflag.FlagSet) {
@@ -103,6 +114,11 @@ func (f flagName) Bool(cmd *Command) bool {
 	return v
 }
 
+func (f flagName) Int(cmd *Command) int {
+	v, _ := cmd.Flags().GetInt(string(f))
+	return v
+}
+
 func (f flagName) String(cmd *Command) string {
 	v, _ := cmd.Flags().GetString(string(f))
 	return v
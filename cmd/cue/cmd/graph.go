@@ -0,0 +1,119 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/graph"
+)
+
+func newGraphCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "graph (--imports | --refs path) [packages]",
+		Short: "print the import or reference graph of packages",
+		Long: `graph prints the import graph of packages or the reference graph of a value.
+
+With --imports, graph prints the packages and their transitive imports,
+with an edge from each package to each package it imports.
+
+With --refs, graph prints the references of the value at the given path
+and all the values it contains, with an edge from each value to each value
+it refers to. Fields also refer to what the values they are unified with
+refer to, such as the fields of a definition. The path may be empty to
+show the references of the whole package. Values in other packages are
+named by their import path followed by a dot and their path, as in
+example.com/k8s.#Deployment.
+
+The graph is printed in the DOT language of Graphviz, or as a mermaid
+flowchart or JSON object with --format mermaid or --format json.
+
+Examples:
+
+  $ cue graph --imports ./... | dot -Tsvg > imports.svg
+  $ cue graph --refs services --format mermaid
+  $ cue graph --refs '' --format json
+`,
+		RunE: mkRunE(c, runGraph),
+	}
+
+	addInjectionFlags(cmd.Flags(), false)
+
+	cmd.Flags().Bool(string(flagImports), false,
+		"print the import graph of the packages")
+	cmd.Flags().String(string(flagRefs), "",
+		"print the reference graph of the value at the given path")
+	cmd.Flags().String(string(flagFormat), "dot",
+		`output format: "dot", "mermaid" or "json"`)
+
+	return cmd
+}
+
+const (
+	flagImports flagName = "imports"
+	flagRefs    flagName = "refs"
+)
+
+func runGraph(cmd *Command, args []string) error {
+	refs := cmd.Flags().Changed(string(flagRefs))
+	if flagImports.Bool(cmd) == refs {
+		return errors.Newf(token.NoPos, "exactly one of --imports or --refs is required")
+	}
+
+	var write func(*graph.Graph) error
+	w := cmd.OutOrStdout()
+	switch format := flagFormat.String(cmd); format {
+	case "dot":
+		write = func(g *graph.Graph) error { return g.WriteDOT(w) }
+	case "mermaid":
+		write = func(g *graph.Graph) error { return g.WriteMermaid(w) }
+	case "json":
+		write = func(g *graph.Graph) error { return g.WriteJSON(w) }
+	default:
+		return errors.Newf(token.NoPos, "unknown format %q", format)
+	}
+
+	b, err := newBuildPlan(cmd, args, &config{})
+	exitOnErr(cmd, err, true)
+	binst := loadFromArgs(cmd, args, b.cfg.loadCfg)
+	if binst == nil {
+		return nil
+	}
+	for _, inst := range binst {
+		exitOnErr(cmd, inst.Err, true)
+	}
+
+	if !refs {
+		return write(graph.Imports(binst))
+	}
+
+	if len(binst) != 1 {
+		return errors.Newf(token.NoPos,
+			"--refs requires a single package, found %d", len(binst))
+	}
+	v := buildInstances(cmd, binst, false)[0].Value()
+	p := cue.ParsePath(flagRefs.String(cmd))
+	if err := p.Err(); err != nil {
+		return err
+	}
+	v = v.LookupPath(p)
+	if !v.Exists() {
+		return errors.Newf(token.NoPos, "path %q not found", p)
+	}
+	return write(graph.References(v))
+}
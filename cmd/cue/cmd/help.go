@@ -32,6 +32,28 @@ func newHelpTopics(c *Command) []*cobra.Command {
 		filetypeHelp,
 		injectHelp,
 		commandsHelp,
+		newTagsHelp(c),
+	}
+}
+
+// forwardHelpArgs makes the help command of root pass any arguments that
+// follow a help topic to the help function of that topic, as with
+// 'cue help tags ./pkg'.
+func forwardHelpArgs(root *cobra.Command) {
+	root.InitDefaultHelpCmd()
+	for _, help := range root.Commands() {
+		if help.Name() != "help" {
+			continue
+		}
+		run := help.Run
+		help.Run = func(cmd *cobra.Command, args []string) {
+			topic, rest, err := root.Find(args)
+			if err == nil && topic != root && !topic.Runnable() && len(rest) > 0 {
+				topic.HelpFunc()(topic, rest)
+				return
+			}
+			run(cmd, args)
+		}
 	}
 }
 
@@ -180,6 +202,9 @@ var filetypeHelp = &cobra.Command{
     Tag         Extensions      Description
     cue         .cue            CUE source files.
     json        .json           JSON files.
+    jsonc       .jsonc          JSON with comments and trailing
+                                commas (input only).
+    json5       .json5          JSON5 files (input only).
     yaml        .yaml/.yml      YAML files.
     jsonl       .jsonl/.ldjson  Line-separated JSON values.
     jsonschema                  JSON Schema.
@@ -187,6 +212,25 @@ var filetypeHelp = &cobra.Command{
 	pb                          Use Protobuf mappings (e.g. json+pb)
     textproto    .textproto     Text-based protocol buffers.
     proto        .proto         Protocol Buffer definitions.
+    xml         .xml            XML files. Attributes map to fields
+                                prefixed with '$', character data
+                                to '$text'.
+    csv         .csv            Comma-separated values; one value per
+                                row. Use csv+header=false for files
+                                without a header row.
+    tsv         .tsv            Tab-separated values, like csv.
+    cbor        .cbor           CBOR (RFC 8949) binary data.
+    msgpack     .msgpack/.mpk   MessagePack binary data.
+    hcl         .hcl/.tf        HCL native syntax, as used by
+                .tfvars         Terraform. Blocks map to nested
+                                fields keyed by their labels.
+    dotenv      .env            Environment variable assignments.
+    properties  .properties     Java properties files.
+    ini         .ini            INI files. Sections map to structs.
+                                For these three formats, use the
+                                tag nest=true, as in
+                                properties+nest=true, to map dotted
+                                keys to nested fields.
     go           .go            Go source files.
     text         .txt           Raw text file; the evaluated value
                                 must be of type string.
@@ -194,7 +238,8 @@ var filetypeHelp = &cobra.Command{
                                 must be of type string or bytes.
 
 OpenAPI, JSON Schema and Protocol Buffer definitions are
-always interpreted as schema. YAML and JSON are always
+always interpreted as schema. YAML, JSON, JSONC, JSON5, XML,
+CSV, CBOR, MessagePack, HCL, dotenv, properties and INI are always
 interpreted as data. CUE and Go are interpreted as schema by
 default, but may be selected to operate in data mode.
 
@@ -234,6 +279,8 @@ defaults as requested.
                 not require any evaluation.
     graph       Like data, but allow references.
     schema      Export data and definitions.
+    docs        Include comments in the output, for instance
+                to export CUE comments as YAML comments.
 
 Many commands also support the --out and --outfile/-o flags.
 The --out flag specifies the output type using a qualifier
@@ -252,6 +299,10 @@ $ cue def -o openapi+yaml:foo.openapi
 # Print the data for the current package as YAML.
 $ cue export --out=yaml
 
+# Print the data for the current package as YAML, including
+# comments.
+$ cue export --out=yaml+docs
+
 # Print the string value of the "name" field as a string.
 $ cue export -e name --out=text
 
@@ -355,6 +406,9 @@ The following variables are supported:
    username   current username
    hostname   current hostname
    rand       a random 128-bit integer
+
+It is an error to set a tag that is not declared in any file of a build.
+Run 'cue help tags' to list the tags declared in a package.
 `,
 }
 
@@ -37,6 +37,7 @@ import (
 	"cuelang.org/go/encoding/json"
 	"cuelang.org/go/encoding/protobuf"
 	"cuelang.org/go/internal"
+	"cuelang.org/go/internal/infer"
 	"cuelang.org/go/internal/third_party/yaml"
 )
 
@@ -222,6 +223,33 @@ Examples:
   }]
 
 
+Inferring a schema
+
+The --infer flag infers a schema from the data files, rather than converting
+them. All objects of all files are taken as samples for a single definition
+#Schema, which is written to standard output or to the file specified with
+-o. The schema describes the kinds of values, marks fields that are not
+present in all samples as optional, infers the element types of lists, and
+uses a disjunction of values for strings with few distinct values of which at
+least one is repeated. Structs with field names that are not identifiers, or
+with fields that vary between samples without any field present in all of
+them, are considered maps and are described with a pattern constraint.
+
+Example:
+  $ cat <<EOF > events.jsonl
+  {"id": 1, "kind": "click", "tags": ["a"]}
+  {"id": 2, "kind": "view"}
+  {"id": 3, "kind": "click", "tags": []}
+  EOF
+
+  $ cue import --infer events.jsonl
+  #Schema: {
+      id:   int
+      kind: "click" | "view"
+      tags?: [...string]
+  }
+
+
 Embedded data files
 
 The --recursive or -R flag enables the parsing of fields that are string
@@ -260,10 +288,13 @@ Example:
 	cmd.Flags().Bool(string(flagDryrun), false, "only run simulation")
 	cmd.Flags().BoolP(string(flagRecursive), "R", false, "recursively parse string values")
 	cmd.Flags().StringArray(string(flagExt), nil, "match files with these extensions")
+	cmd.Flags().Bool(string(flagInfer), false, "infer a schema from the data files")
 
 	return cmd
 }
 
+const flagInfer flagName = "infer"
+
 // TODO: factor out rooting of orphaned files.
 
 func runImport(cmd *Command, args []string) (err error) {
@@ -312,6 +343,18 @@ func runImport(cmd *Command, args []string) (err error) {
 		c.fileFilter = `\.(` + strings.Join(extensions, "|") + `)$`
 	}
 
+	if flagInfer.Bool(cmd) {
+		switch mode {
+		case "proto", "auto", "openapi", "jsonschema":
+			return errors.Newf(token.NoPos, "cannot use --infer in %s mode", mode)
+		}
+		// Interpret all files as data.
+		c.interpretation = ""
+		err = inferMode(cmd, args, c)
+		exitOnErr(cmd, err, true)
+		return nil
+	}
+
 	b, err := parseArgs(cmd, args, c)
 	exitOnErr(cmd, err, true)
 
@@ -433,6 +476,68 @@ func genericMode(cmd *Command, b *buildPlan) error {
 	return nil
 }
 
+// inferMode infers a schema from all objects of the data files and writes it
+// as a single definition.
+func inferMode(cmd *Command, args []string, c *config) error {
+	for _, f := range []flagName{flagPath, flagList, flagFiles, flagWithContext, flagRecursive} {
+		if cmd.Flags().Changed(string(f)) {
+			return errors.Newf(token.NoPos, "cannot combine --%s with --%s", flagInfer, f)
+		}
+	}
+
+	b, err := newBuildPlan(cmd, args, c)
+	if err != nil {
+		return err
+	}
+	builds := loadFromArgs(cmd, args, c.loadCfg)
+	if builds == nil {
+		return errors.Newf(token.NoPos, "invalid args")
+	}
+
+	inf := infer.New(nil)
+	for _, inst := range builds {
+		if inst.Err != nil {
+			return inst.Err
+		}
+		schemas, values, err := b.getDecoders(inst)
+		all := append(schemas, values...)
+		for _, d := range all {
+			defer d.close()
+		}
+		if err != nil {
+			return err
+		}
+		for _, di := range all {
+			d := di.dec(b)
+			for ; !d.Done(); d.Next() {
+				v := cmd.ctx.BuildExpr(internal.ToExpr(d.File()))
+				if err := inf.Add(v); err != nil {
+					return err
+				}
+			}
+			if err := d.Err(); err != nil {
+				return err
+			}
+		}
+	}
+
+	f := &ast.File{
+		Filename: "-",
+		Decls: []ast.Decl{&ast.Field{
+			Label: ast.NewIdent("#Schema"),
+			Value: inf.Schema(),
+		}},
+	}
+	if pkg := b.encConfig.PkgName; pkg != "" {
+		internal.SetPackage(f, pkg, false)
+	}
+	cueFile, err := getFilename(b, f, "", flagForce.Bool(cmd))
+	if cueFile == "" {
+		return err
+	}
+	return writeFile(b, f, cueFile)
+}
+
 func getFilename(b *buildPlan, f *ast.File, root string, force bool) (filename string, err error) {
 	cueFile := f.Filename
 	if out := flagOutFile.String(b.cmd); out != "" {
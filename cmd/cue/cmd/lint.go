@@ -0,0 +1,237 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast/astutil"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/load"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/tools/lint"
+)
+
+func newLintCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lint [packages]",
+		Short: "report likely mistakes in CUE files",
+		Long: `lint reports likely mistakes in the CUE files of the given packages.
+
+The following checks are run:
+
+  unused      unused imports, let bindings and hidden definitions, and
+              unused definitions of packages that no other package of
+              the module imports
+  shadow      declarations that shadow declarations in enclosing scopes
+  tags        fields that are only invalid when a tag is set, evaluated for
+              each shorthand of a @tag attribute and each value of a
+              boolean tag
+  redundant   repeated operands of a unification, as in int & >0 & >0
+  defaults    default markers outside of disjunctions, as in [*1, 2]
+  deprecated  deprecated syntax that cue fix rewrites
+
+The --checks flag selects the checks to run, as a comma-separated list.
+
+A problem can be suppressed with a comment of the form
+
+  // lint:ignore check[,check] reason
+
+on the line of the problem or the line above it.
+
+The --json flag prints a JSON object for each problem instead:
+
+  {
+    "Pos": "schema.cue:3:5",
+    "Check": "unused",
+    "Message": "let x declared and not used"
+  }
+
+lint exits with a non-zero status if any problems are reported.
+
+Examples:
+
+  $ cue lint ./...
+  schema.cue:3:5: let x declared and not used (unused)
+
+  $ cue lint --checks unused,shadow --json ./schema
+`,
+		RunE: mkRunE(c, runLint),
+	}
+
+	addInjectionFlags(cmd.Flags(), false)
+
+	cmd.Flags().String(string(flagChecks), "",
+		"comma-separated list of checks to run")
+	cmd.Flags().Bool(string(flagJSON), false,
+		"print a JSON object for each problem")
+
+	return cmd
+}
+
+const flagChecks flagName = "checks"
+
+// A lintDiagnostic is the JSON representation of a problem printed by
+// lint --json.
+type lintDiagnostic struct {
+	Pos     string `json:",omitempty"`
+	Check   string
+	Message string
+}
+
+func runLint(cmd *Command, args []string) error {
+	b, err := newBuildPlan(cmd, args, &config{})
+	exitOnErr(cmd, err, true)
+
+	cfg := &lint.Config{}
+	if s := flagChecks.String(cmd); s != "" {
+		for _, name := range strings.Split(s, ",") {
+			a := lint.Lookup(strings.TrimSpace(name))
+			if a == nil {
+				return errors.Newf(token.NoPos, "unknown check %q", name)
+			}
+			cfg.Analyzers = append(cfg.Analyzers, a)
+		}
+	}
+
+	binst := load.Instances(args, b.cfg.loadCfg)
+	var errs errors.Error
+	for _, inst := range binst {
+		if inst.Err != nil {
+			errs = errors.Append(errs, inst.Err)
+		}
+	}
+	exitOnErr(cmd, errs, true)
+
+	// Determine the imports of the modules of the packages, so that unused
+	// definitions can be reported for packages that are not imported.
+	imports := map[string]map[string]bool{}
+	for _, inst := range binst {
+		if inst.Module == "" || imports[inst.Root] != nil {
+			continue
+		}
+		imports[inst.Root], err = moduleImports(inst.Root)
+		exitOnErr(cmd, err, true)
+	}
+
+	cwd, _ := os.Getwd()
+	w := cmd.OutOrStdout()
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	e.SetEscapeHTML(false)
+	for _, inst := range binst {
+		cfg.Load = func(tags []string) (cue.Value, error) {
+			lcfg := *b.cfg.loadCfg
+			lcfg.Dir = inst.Dir
+			lcfg.Package = inst.PkgName
+			lcfg.Tags = append(append([]string(nil), lcfg.Tags...), tags...)
+			tinst := load.Instances([]string{"."}, &lcfg)[0]
+			if tinst.Err != nil {
+				return cue.Value{}, tinst.Err
+			}
+			return cuecontext.New().BuildInstance(tinst), nil
+		}
+		cfg.NotImported = inst.Module == "" || !isImported(imports[inst.Root], inst)
+		for _, d := range lint.Files(inst.Files, cfg) {
+			cmd.hasErr = true
+			pos := ""
+			if d.Pos.IsValid() {
+				p := d.Pos.Position()
+				if rel, err := filepath.Rel(cwd, p.Filename); err == nil && !strings.HasPrefix(rel, "..") {
+					p.Filename = rel
+				}
+				pos = p.String()
+			}
+			if !flagJSON.Bool(cmd) {
+				if pos != "" {
+					pos += ": "
+				}
+				fmt.Fprintf(w, "%s%s (%s)\n", pos, d.Message, d.Analyzer)
+				continue
+			}
+			if err := e.Encode(lintDiagnostic{
+				Pos:     pos,
+				Check:   d.Analyzer,
+				Message: d.Message,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// moduleImports returns the import paths imported by the CUE files of the
+// module rooted at root, excluding those of its dependencies.
+func moduleImports(root string) (map[string]bool, error) {
+	imports := map[string]bool{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case d.IsDir():
+			name := d.Name()
+			if path != root && (name == "cue.mod" || strings.HasPrefix(name, ".") ||
+				strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		case filepath.Ext(path) != ".cue":
+			return nil
+		}
+		f, err := parser.ParseFile(path, nil, parser.ImportsOnly)
+		if err != nil {
+			return err
+		}
+		for _, spec := range f.Imports {
+			if s, err := strconv.Unquote(spec.Path.Value); err == nil {
+				imports[s] = true
+			}
+		}
+		return nil
+	})
+	return imports, err
+}
+
+// isImported reports whether inst is imported with any of the given import
+// paths.
+func isImported(imports map[string]bool, inst *build.Instance) bool {
+	path := inst.ImportPath
+	if i := strings.LastIndexByte(path, ':'); i >= 0 {
+		path = path[:i]
+	}
+	for s := range imports {
+		if i := strings.LastIndexByte(s, ':'); i >= 0 {
+			if s[:i] == path && s[i+1:] == inst.PkgName {
+				return true
+			}
+		} else if s == path && astutil.ImportPathName(s) == inst.PkgName {
+			return true
+		}
+	}
+	return false
+}
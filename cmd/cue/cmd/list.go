@@ -0,0 +1,285 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/load"
+)
+
+func newListCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list [packages]",
+		Short: "list packages and their files",
+		Long: `list lists the packages named by the given patterns, one import path per
+line, as determined by the same rules that other commands use to load them.
+
+The --json flag causes list to print a JSON object for each package
+instead:
+
+  {
+    "Dir": "/home/user/app/schema",  // directory of the package
+    "ImportPath": "example.com/app/schema",
+    "Name": "schema",                // package name
+    "Module": "example.com/app",
+    "Root": "/home/user/app",        // module root
+    "CUEFiles": ["schema.cue"],      // CUE files of the package
+    "DataFiles": ["data.json"],      // data files in the directory
+    "TestFiles": ["schema_test.cue"],
+    "ToolFiles": ["schema_tool.cue"],
+    "IgnoredFiles": [{               // files excluded from the package
+      "Name": "dev.cue",
+      "Reason": "@if(dev) did not match"
+    }],
+    "InvalidFiles": [...],           // files that could not be loaded
+    "Imports": ["strings"],          // direct imports
+    "Deps": ["strings"],             // all transitive dependencies
+    "Error": "...",                  // error loading the package
+    "DepsErrors": ["..."]            // errors loading dependencies
+  }
+
+File names are relative to the directory of the package. Test and tool
+files are listed separately, and their imports are not included in Imports
+and Deps, as they are only loaded by cue cmd and for testing.
+
+By default, list fails if a package cannot be loaded. The -e flag reports
+such errors in the Error and DepsErrors fields of the JSON output instead.
+
+Examples:
+
+  $ cue list ./...
+  example.com/app/k8s
+  example.com/app/schema
+
+  $ cue list --json -t env=prod ./schema
+`,
+		RunE: mkRunE(c, runList),
+	}
+
+	addInjectionFlags(cmd.Flags(), false)
+
+	cmd.Flags().Bool(string(flagJSON), false,
+		"print a JSON object for each package")
+	cmd.Flags().BoolP(string(flagListErrors), "e", false,
+		"report errors in the output instead of failing")
+
+	return cmd
+}
+
+const (
+	flagJSON       flagName = "json"
+	flagListErrors flagName = "errors"
+)
+
+// A listPackage is the JSON representation of a package printed by
+// list --json. Its fields follow the conventions of go list.
+type listPackage struct {
+	Dir        string
+	ImportPath string `json:",omitempty"`
+	Name       string `json:",omitempty"`
+	Module     string `json:",omitempty"`
+	Root       string `json:",omitempty"`
+
+	CUEFiles     []string   `json:",omitempty"`
+	DataFiles    []string   `json:",omitempty"`
+	TestFiles    []string   `json:",omitempty"`
+	ToolFiles    []string   `json:",omitempty"`
+	IgnoredFiles []listFile `json:",omitempty"`
+	InvalidFiles []listFile `json:",omitempty"`
+	UnknownFiles []string   `json:",omitempty"`
+
+	Imports []string `json:",omitempty"`
+	Deps    []string `json:",omitempty"`
+
+	Error      string   `json:",omitempty"`
+	DepsErrors []string `json:",omitempty"`
+}
+
+type listFile struct {
+	Name   string
+	Reason string `json:",omitempty"`
+}
+
+func runList(cmd *Command, args []string) error {
+	b, err := newBuildPlan(cmd, args, &config{})
+	exitOnErr(cmd, err, true)
+
+	binst := load.Instances(args, b.cfg.loadCfg)
+
+	reportErrs := flagListErrors.Bool(cmd)
+	if !reportErrs {
+		var errs errors.Error
+		for _, inst := range binst {
+			if inst.Err != nil {
+				errs = errors.Append(errs, inst.Err)
+			}
+		}
+		exitOnErr(cmd, errs, true)
+	}
+
+	w := cmd.OutOrStdout()
+	if !flagJSON.Bool(cmd) {
+		for _, inst := range binst {
+			fmt.Fprintln(w, listName(inst))
+		}
+		return nil
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	e.SetEscapeHTML(false)
+	for _, inst := range binst {
+		if err := e.Encode(newListPackage(inst)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listName returns the name by which list identifies an instance.
+func listName(inst *build.Instance) string {
+	switch {
+	case inst.ImportPath != "":
+		return inst.ImportPath
+	case inst.DisplayPath != "":
+		return inst.DisplayPath
+	}
+	return inst.Dir
+}
+
+func newListPackage(inst *build.Instance) *listPackage {
+	p := &listPackage{
+		Dir:        inst.Dir,
+		ImportPath: inst.ImportPath,
+		Name:       inst.PkgName,
+		Module:     inst.Module,
+		Root:       inst.Root,
+	}
+	rel := func(f *build.File) string {
+		if f.Filename == "-" || inst.Dir == "" {
+			return f.Filename
+		}
+		if r, err := filepath.Rel(inst.Dir, f.Filename); err == nil {
+			return filepath.ToSlash(r)
+		}
+		return f.Filename
+	}
+	reason := func(f *build.File) string {
+		if f.ExcludeReason == nil {
+			return ""
+		}
+		return f.ExcludeReason.Error()
+	}
+
+	for _, f := range inst.BuildFiles {
+		switch {
+		case f.Encoding != build.CUE:
+			p.DataFiles = append(p.DataFiles, rel(f))
+		case isTestFile(f):
+			p.TestFiles = append(p.TestFiles, rel(f))
+		case isToolFile(f):
+			p.ToolFiles = append(p.ToolFiles, rel(f))
+		default:
+			p.CUEFiles = append(p.CUEFiles, rel(f))
+		}
+	}
+	for _, f := range inst.OrphanedFiles {
+		p.DataFiles = append(p.DataFiles, rel(f))
+	}
+	for _, f := range inst.IgnoredFiles {
+		// Test and tool files are loaded in other modes only.
+		switch r := reason(f); {
+		case isTestFile(f) && strings.HasSuffix(r, "excluded in non-test mode"):
+			p.TestFiles = append(p.TestFiles, rel(f))
+		case isToolFile(f) && strings.HasSuffix(r, "excluded in non-cmd mode"):
+			p.ToolFiles = append(p.ToolFiles, rel(f))
+		default:
+			p.IgnoredFiles = append(p.IgnoredFiles, listFile{rel(f), r})
+		}
+	}
+	for _, f := range inst.InvalidFiles {
+		p.InvalidFiles = append(p.InvalidFiles, listFile{rel(f), reason(f)})
+	}
+	for _, f := range inst.UnknownFiles {
+		p.UnknownFiles = append(p.UnknownFiles, rel(f))
+	}
+
+	p.Imports = inst.ImportPaths
+	p.Deps = listDeps(inst)
+
+	if inst.Err != nil {
+		p.Error = inst.Err.Error()
+	}
+	seen := map[*build.Instance]bool{}
+	var addErrs func(inst *build.Instance)
+	addErrs = func(inst *build.Instance) {
+		for _, imp := range inst.Imports {
+			if seen[imp] {
+				continue
+			}
+			seen[imp] = true
+			if imp.Err != nil {
+				p.DepsErrors = append(p.DepsErrors, imp.Err.Error())
+			}
+			addErrs(imp)
+		}
+	}
+	addErrs(inst)
+	return p
+}
+
+// listDeps returns the sorted import paths of the transitive dependencies
+// of an instance, including builtin packages.
+func listDeps(inst *build.Instance) []string {
+	deps := map[string]bool{}
+	seen := map[*build.Instance]bool{}
+	var add func(inst *build.Instance)
+	add = func(inst *build.Instance) {
+		for _, p := range inst.ImportPaths {
+			deps[p] = true
+		}
+		for _, imp := range inst.Imports {
+			if !seen[imp] {
+				seen[imp] = true
+				add(imp)
+			}
+		}
+	}
+	add(inst)
+
+	a := make([]string, 0, len(deps))
+	for p := range deps {
+		a = append(a, p)
+	}
+	sort.Strings(a)
+	return a
+}
+
+func isTestFile(f *build.File) bool {
+	return strings.HasSuffix(f.Filename, "_test.cue")
+}
+
+func isToolFile(f *build.File) bool {
+	return strings.HasSuffix(f.Filename, "_tool.cue")
+}
@@ -0,0 +1,181 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/diff"
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/load"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/tools/refactor"
+)
+
+func newRefactorCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "refactor <cmd> [arguments]",
+		Short: "refactor packages of a module",
+		Long: `Refactor rewrites the packages of a module.
+
+The available refactorings are documented in the respective subcommands.
+`,
+		RunE: mkRunE(c, func(cmd *Command, args []string) error {
+			stderr := cmd.Stderr()
+			if len(args) == 0 {
+				fmt.Fprintln(stderr, "refactor must be run as one of its subcommands")
+			} else {
+				fmt.Fprintf(stderr, "refactor must be run as one of its subcommands: unknown subcommand %q\n", args[0])
+			}
+			fmt.Fprintln(stderr, "Run 'cue help refactor' for known subcommands.")
+			os.Exit(1) // TODO: get rid of this
+			return nil
+		}),
+	}
+	cmd.AddCommand(newRenameCmd(c))
+	return cmd
+}
+
+func newRenameCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rename <package>.<field> <name>",
+		Short: "rename a top-level field and its references",
+		Long: `Rename renames a top-level field of a package, typically a definition,
+and updates all references to it in the packages of the module.
+
+The field is given as the package, an import path or directory, followed by
+a dot and the field name. References within the package and selectors on
+imports of the package are updated, including selectors through let
+bindings, aliases and fields referring to an import, and quoted labels and
+selectors. All files are considered, regardless of build tags.
+
+Rename refuses to rename if the new name is already declared at the top
+level of the package, or if a declaration of the new name in an enclosing
+scope would hide the field from one of its references.
+
+The -n flag prints the changes as a diff instead of writing the files.
+
+Examples:
+
+  $ cue refactor rename example.com/schema.#Service '#Deployment'
+
+  $ cue refactor rename -n ./schema.#Service '#Deployment'
+`,
+		RunE: mkRunE(c, runRename),
+	}
+
+	cmd.Flags().BoolP(string(flagDryrun), "n", false,
+		"print the changes as a diff instead of writing files")
+
+	return cmd
+}
+
+func runRename(cmd *Command, args []string) error {
+	if len(args) != 2 {
+		return errors.Newf(token.NoPos,
+			"rename requires a field and a new name: cue refactor rename <package>.<field> <name>")
+	}
+	i := strings.LastIndexByte(args[0], '.')
+	if i < 0 || i == len(args[0])-1 || strings.ContainsRune(args[0][i:], '/') {
+		return errors.Newf(token.NoPos, "invalid field %q: must be of the form <package>.<field>", args[0])
+	}
+	pkg, name := args[0][:i], args[0][i+1:]
+	if pkg == "" {
+		pkg = "."
+	}
+
+	cfg := &load.Config{
+		Tests:       true,
+		Tools:       true,
+		AllCUEFiles: true,
+	}
+	binst := load.Instances([]string{pkg}, cfg)
+	if len(binst) != 1 {
+		return errors.Newf(token.NoPos, "%s must refer to a single package", pkg)
+	}
+	target := binst[0]
+	if target.Err != nil {
+		return target.Err
+	}
+
+	// Load all packages of the module, failing if any cannot be loaded so
+	// that no references are missed.
+	dir := target.Root
+	if target.Module == "" {
+		dir = target.Dir
+	}
+	lcfg := *cfg
+	lcfg.Dir = dir
+	instances := []*build.Instance{target}
+	var errs errors.Error
+	for _, inst := range load.Instances([]string{"./..."}, &lcfg) {
+		if inst.Err != nil {
+			errs = errors.Append(errs, inst.Err)
+		}
+		instances = append(instances, inst)
+	}
+	if errs != nil {
+		return errs
+	}
+
+	edits, err := refactor.Rename(instances, target.ImportPath, name, args[1])
+	if err != nil {
+		return err
+	}
+
+	byFile := map[string][]refactor.Edit{}
+	var files []string
+	for _, e := range edits {
+		if byFile[e.Filename] == nil {
+			files = append(files, e.Filename)
+		}
+		byFile[e.Filename] = append(byFile[e.Filename], e)
+	}
+
+	cwd, _ := os.Getwd()
+	for _, filename := range files {
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		b, err := refactor.Apply(src, byFile[filename])
+		if err != nil {
+			return err
+		}
+
+		if flagDryrun.Bool(cmd) {
+			name := filename
+			if rel, err := filepath.Rel(cwd, name); err == nil {
+				name = rel
+			}
+			if err := diff.Text(name+".orig", name, src, b, cmd.OutOrStdout()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := ioutil.WriteFile(filename, b, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
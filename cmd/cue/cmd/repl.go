@@ -0,0 +1,108 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/load"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/repl"
+)
+
+func newReplCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "repl [package]",
+		Short: "evaluate expressions interactively",
+		Long: `repl loads a package once and evaluates the expressions entered on
+each line in its scope, as cue eval -e would.
+
+Lines starting with a colon are commands:
+
+  :load [packages]         load packages, or reload the current ones
+  :def [expr]              print the schema of a value
+  :export format [expr]    export a value, as in cue export --out format
+  :explain expr            explain where a value comes from
+  :help                    list the commands
+  :quit                    exit
+
+The :explain command prints the value, its default and any errors, and
+the expressions that were unified to obtain it with their positions.
+
+In a terminal, lines can be edited, previous lines are available with the
+up and down keys and the names of fields are completed with the tab key.
+The history is kept in the cue directory of the user's configuration
+directory. If no package is given and the current directory has no CUE
+files, expressions are evaluated in an empty scope.
+
+Examples:
+
+  $ cue repl ./schema
+  > #Service.port
+  int & >0
+  > :export yaml services.web
+`,
+		RunE: mkRunE(c, runRepl),
+	}
+
+	addInjectionFlags(cmd.Flags(), false)
+
+	return cmd
+}
+
+func runRepl(cmd *Command, args []string) error {
+	b, err := newBuildPlan(cmd, args, &config{})
+	exitOnErr(cmd, err, true)
+	cwd, _ := os.Getwd()
+
+	cfg := &repl.Config{
+		Args:   args,
+		Dir:    cwd,
+		Stderr: cmd.OutOrStderr(),
+		Load: func(args []string) (cue.Value, error) {
+			binst := load.Instances(args, b.cfg.loadCfg)
+			if len(binst) != 1 {
+				return cue.Value{}, errors.Newf(token.NoPos,
+					"repl requires a single package, found %d", len(binst))
+			}
+			inst := binst[0]
+			// Use a new context for each load, so that the values of
+			// previous loads can be garbage collected.
+			ctx := cuecontext.New()
+			if len(args) == 0 && len(inst.BuildFiles) == 0 {
+				return ctx.CompileString("{}"), nil
+			}
+			if inst.Err != nil {
+				return cue.Value{}, inst.Err
+			}
+			// Errors in the package are reported when evaluating the
+			// affected expressions, so that they can be explored.
+			return ctx.BuildInstance(inst), nil
+		},
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		cfg.HistoryFile = filepath.Join(dir, "cue", "repl_history")
+	}
+
+	r, err := repl.New(cfg)
+	exitOnErr(cmd, err, true)
+	return r.Run(cmd.InOrStdin(), cmd.OutOrStdout())
+}
@@ -25,6 +25,7 @@ import (
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/cuecontext"
 	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/load"
 	"cuelang.org/go/cue/token"
 	"cuelang.org/go/internal/core/adt"
 	"cuelang.org/go/internal/encoding"
@@ -126,12 +127,20 @@ For more information on writing CUE configuration files see cuelang.org.`,
 		newCompletionCmd(c),
 		newEvalCmd(c),
 		newDefCmd(c),
+		newDocCmd(c),
 		newExportCmd(c),
+		newExpCmd(c),
 		newFixCmd(c),
 		newFmtCmd(c),
 		newGetCmd(c),
+		newGraphCmd(c),
 		newImportCmd(c),
+		newLintCmd(c),
+		newListCmd(c),
 		newModCmd(c),
+		newRefactorCmd(c),
+		newReplCmd(c),
+		newServeCmd(c),
 		newTrimCmd(c),
 		newVersionCmd(c),
 		newVetCmd(c),
@@ -146,6 +155,7 @@ For more information on writing CUE configuration files see cuelang.org.`,
 	for _, sub := range subCommands {
 		cmd.AddCommand(sub)
 	}
+	forwardHelpArgs(cmd)
 
 	return c
 }
@@ -194,6 +204,14 @@ type Command struct {
 
 	ctx *cue.Context
 
+	// loadCfg, if set, is used instead of the default configuration for
+	// loading instances, as done by --watch.
+	loadCfg *load.Config
+
+	// overwrite allows overwriting existing output files, such as the
+	// files written by a previous run of --watch.
+	overwrite bool
+
 	hasErr bool
 }
 
@@ -0,0 +1,190 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/load"
+	"cuelang.org/go/internal/serve"
+)
+
+func newServeCmd(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve [packages]",
+		Short: "serve validation and evaluation over HTTP",
+		Long: `serve loads the given packages and serves HTTP requests to validate
+and export data using the values they define, typically definitions.
+
+The server handles the following requests:
+
+  POST /validate/<path>   validate the body against the value at path
+  POST /export/<path>     return the unification of the body with the
+                          value at path as JSON
+  GET  /definitions       list the definitions of each package
+  GET  /healthz           report whether the packages were loaded
+
+The path is a CUE path, such as #Service or k8s.#Deployment. As # must be
+escaped in URLs, the # of a definition may be omitted: /validate/Service
+selects #Service if the package has no field Service. If more than one
+package is served, the package query parameter selects a package by its
+import path.
+
+The body of a request is JSON, unless its Content-Type header indicates YAML.
+Validation responds with {"valid": true}, or with status 422 and an object
+listing the errors. Each error has a message and, where available, the path
+of the value and the positions in the CUE files and request that caused it:
+
+  {
+    "valid": false,
+    "errors": [{
+      "message": "invalid value 80 (out of bound >=1024)",
+      "path": "port",
+      "positions": [
+        {"filename": "schema.cue", "line": 3, "column": 12},
+        {"filename": "request.json", "line": 1, "column": 25}
+      ]
+    }]
+  }
+
+The --admission flag enables Kubernetes validating and mutating admission
+webhooks, using the admission.k8s.io/v1 AdmissionReview protocol:
+
+  POST /admission/validate  deny objects that are not valid
+  POST /admission/mutate    deny objects that are not valid, and add
+                            defaults to valid objects with a JSON Patch
+
+The flag specifies the path of a value mapping API versions and kinds to
+the values that objects of that kind must satisfy:
+
+  admission: "apps/v1": Deployment: #Deployment
+  admission: v1: Service:           #Service
+
+Objects of kinds not in the mapping are allowed unchanged. Denied requests
+report the CUE errors in the status message of the response. As the
+Kubernetes API server only calls webhooks over HTTPS, use --tls-cert and
+--tls-key to serve HTTPS.
+
+The packages are reloaded when their files change. If reloading fails, the
+server continues to serve the previously loaded packages, and /healthz
+reports the error.
+
+Examples:
+
+  $ cue serve ./schema --listen :8080 &
+  $ curl -H 'Content-Type: application/json' \
+      -d '{"name": "web", "port": 8080}' localhost:8080/validate/Service
+  {
+    "valid": true
+  }
+
+  $ cue serve ./policy --admission admission --listen :8443 \
+      --tls-cert tls.crt --tls-key tls.key
+`,
+		RunE: mkRunE(c, runServe),
+	}
+
+	addInjectionFlags(cmd.Flags(), false)
+
+	cmd.Flags().String(string(flagListen), "localhost:8080",
+		"address on which to listen for requests")
+	cmd.Flags().String(string(flagAdmission), "",
+		"path of the mapping from API versions and kinds to values for Kubernetes admission requests")
+	cmd.Flags().String(string(flagTLSCert), "",
+		"certificate file for serving HTTPS")
+	cmd.Flags().String(string(flagTLSKey), "",
+		"private key file for serving HTTPS")
+
+	return cmd
+}
+
+const (
+	flagListen    flagName = "listen"
+	flagAdmission flagName = "admission"
+	flagTLSCert   flagName = "tls-cert"
+	flagTLSKey    flagName = "tls-key"
+)
+
+func runServe(cmd *Command, args []string) error {
+	certFile, keyFile := flagTLSCert.String(cmd), flagTLSKey.String(cmd)
+	if (certFile == "") != (keyFile == "") {
+		return errors.New("--tls-cert and --tls-key must be used together")
+	}
+
+	b, err := newBuildPlan(cmd, args, &config{})
+	if err != nil {
+		return err
+	}
+	cwd, _ := os.Getwd()
+
+	var watcher *fileWatcher
+	loadInstances := func() ([]serve.Instance, error) {
+		binst := load.Instances(args, b.cfg.loadCfg)
+		if watcher == nil {
+			watcher = newFileWatcher(binst)
+		} else {
+			watcher.update(binst)
+		}
+
+		// Use a new context for each load, so that the values of previous
+		// loads can be garbage collected.
+		ctx := cuecontext.New()
+		var a []serve.Instance
+		for _, inst := range binst {
+			if inst.Err != nil {
+				return nil, inst.Err
+			}
+			v := ctx.BuildInstance(inst)
+			if err := v.Err(); err != nil {
+				return nil, err
+			}
+			a = append(a, serve.Instance{ID: inst.ID(), Value: v})
+		}
+		return a, nil
+	}
+
+	s, err := serve.New(&serve.Config{
+		Load:      loadInstances,
+		Dir:       cwd,
+		Admission: flagAdmission.String(cmd),
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			watcher.wait()
+			if err := s.Reload(); err != nil {
+				exitOnErr(cmd, err, false)
+				continue
+			}
+			fmt.Fprintln(cmd.OutOrStderr(), "reloaded")
+		}
+	}()
+
+	addr := flagListen.String(cmd)
+	fmt.Fprintf(cmd.OutOrStderr(), "serving on %s\n", addr)
+	if certFile != "" {
+		return http.ListenAndServeTLS(addr, certFile, keyFile, s)
+	}
+	return http.ListenAndServe(addr, s)
+}
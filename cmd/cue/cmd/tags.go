@@ -0,0 +1,211 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/load"
+)
+
+func newTagsHelp(c *Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tags",
+		Short: "list the tags of a package that can be set with -t",
+		Long: `The tags topic lists the tags that can be set with the --inject/-t flag
+for the given packages, or for the package in the current directory if
+none are given:
+
+	cue help tags [packages]
+
+Without packages, this help text is shown if the current directory does
+not hold a single package.
+
+For each tag, it lists the type of its values, its shorthands, the tag
+variable injected when the tag is not set, and each field marked with the
+tag, along with the constraint and documentation of the field. It also
+lists the files that are only included in a build if the condition of
+their @if attribute is met. All files are considered, regardless of the
+tags that are set.
+
+See 'cue help injection' for how tags are declared and set.
+
+Example:
+
+	$ cue help tags ./deploy
+	tag env
+		type:       string
+		shorthands: dev, prod
+		field:      env (deploy/deploy.cue:4:1)
+		constraint: *"dev" | "prod"
+		doc:        The environment to deploy to.
+
+	@if(debug)
+		deploy/debug.cue
+`,
+	}
+	cmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		c.Command = cmd
+		if len(args) == 0 {
+			// List the tags of the package in the current directory, if
+			// any, and explain the topic otherwise.
+			binst := load.Instances(nil, &load.Config{Tools: true, AllCUEFiles: true})
+			if len(binst) != 1 || binst[0].Err != nil {
+				c.root.HelpFunc()(cmd, args)
+				return
+			}
+			exitOnErr(c, printTags(cmd.OutOrStdout(), binst[0]), true)
+			return
+		}
+		binst := load.Instances(args, &load.Config{Tools: true, AllCUEFiles: true})
+		for i, inst := range binst {
+			exitOnErr(c, inst.Err, true)
+			w := cmd.OutOrStdout()
+			if len(binst) > 1 {
+				if i > 0 {
+					fmt.Fprintln(w)
+				}
+				fmt.Fprintf(w, "package %s\n\n", inst.ImportPath)
+			}
+			exitOnErr(c, printTags(w, inst), true)
+		}
+	})
+	return cmd
+}
+
+// printTags lists the tags of inst and the files of inst with an @if
+// attribute.
+func printTags(w io.Writer, inst *build.Instance) error {
+	tags, err := load.Tags(inst)
+	if err != nil {
+		return err
+	}
+
+	cwd, _ := os.Getwd()
+	relPath := func(filename string) string {
+		if rel, err := filepath.Rel(cwd, filename); err == nil {
+			return filepath.ToSlash(rel)
+		}
+		return filename
+	}
+
+	paths := fieldPaths(inst.Files)
+	byKey := map[string][]load.TagInfo{}
+	var keys []string
+	for _, t := range tags {
+		if byKey[t.Key] == nil {
+			keys = append(keys, t.Key)
+		}
+		byKey[t.Key] = append(byKey[t.Key], t)
+	}
+
+	for i, key := range keys {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "tag %s\n", key)
+		for j, t := range byKey[key] {
+			if j == 0 {
+				printTagLine(w, "type", t.Kind.String())
+				if len(t.Shorthands) > 0 {
+					printTagLine(w, "shorthands", strings.Join(t.Shorthands, ", "))
+				}
+			}
+			if t.Var != "" {
+				printTagLine(w, "var", t.Var)
+			}
+			pos := t.Field.Pos().Position()
+			printTagLine(w, "field", fmt.Sprintf("%s (%s:%d:%d)",
+				paths[t.Field], relPath(pos.Filename), pos.Line, pos.Column))
+			if b, err := format.Node(t.Field.Value); err == nil {
+				printTagLine(w, "constraint", string(b))
+			}
+			for _, cg := range ast.Comments(t.Field) {
+				if cg.Doc {
+					printTagLine(w, "doc", strings.TrimSpace(cg.Text()))
+				}
+			}
+		}
+	}
+
+	conds := map[string][]string{}
+	var order []string
+	for _, f := range inst.Files {
+		for _, d := range f.Decls {
+			a, ok := d.(*ast.Attribute)
+			if !ok {
+				continue
+			}
+			if key, body := a.Split(); key == "if" {
+				if conds[body] == nil {
+					order = append(order, body)
+				}
+				conds[body] = append(conds[body], relPath(f.Filename))
+			}
+		}
+	}
+	for i, body := range order {
+		if i > 0 || len(keys) > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "@if(%s)\n", body)
+		for _, file := range conds[body] {
+			fmt.Fprintf(w, "\t%s\n", file)
+		}
+	}
+	return nil
+}
+
+// printTagLine prints a property of a tag, indenting the continuation lines
+// of multi-line values.
+func printTagLine(w io.Writer, name, value string) {
+	const indent = "\t            "
+	value = strings.ReplaceAll(value, "\n", "\n"+indent)
+	fmt.Fprintf(w, "\t%-11s %s\n", name+":", value)
+}
+
+// fieldPaths returns the paths of all fields in files.
+func fieldPaths(files []*ast.File) map[*ast.Field]string {
+	paths := map[*ast.Field]string{}
+	var path []string
+	for _, f := range files {
+		ast.Walk(f, func(n ast.Node) bool {
+			if x, ok := n.(*ast.Field); ok {
+				name, _, _ := ast.LabelName(x.Label)
+				if !ast.IsValidIdent(name) {
+					name = strconv.Quote(name)
+				}
+				path = append(path, name)
+				paths[x] = strings.Join(path, ".")
+			}
+			return true
+		}, func(n ast.Node) {
+			if _, ok := n.(*ast.Field); ok {
+				path = path[:len(path)-1]
+			}
+		})
+	}
+	return paths
+}
@@ -15,11 +15,19 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"sync"
+
 	"github.com/spf13/cobra"
 	"golang.org/x/text/message"
 
 	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
 	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal"
+	"cuelang.org/go/internal/coverage"
 )
 
 const vetDoc = `vet validates CUE and other data files
@@ -35,7 +43,16 @@ currently supported:
 
   Format       Extensions
 	JSON       .json .jsonl .ndjson
+	JSONC      .jsonc .json5
 	YAML       .yaml .yml
+	XML        .xml
+	CSV        .csv .tsv (validate each row)
+	CBOR       .cbor
+	MsgPack    .msgpack .mpk
+	HCL        .hcl .tf .tfvars
+	DOTENV     .env
+	PROPERTIES .properties
+	INI        .ini
 	TEXT       .txt  (validate a single string value)
 
 To activate this mode, the non-cue files must be explicitly mentioned on the
@@ -45,6 +62,27 @@ In this mode, each file will be verified against a CUE constraint. If the files
 contain multiple objects (such as using --- in YAML), they will all be verified
 individually.
 
+Each object is validated independently against the constraints, which are
+only evaluated once, and is released as soon as it has been checked. This
+allows large streams, such as JSON Lines files, to be checked using a bounded
+amount of memory. The -j flag sets the number of objects that are validated
+in parallel. Errors are reported in the order in which the objects appear.
+The --max-errors flag stops validation after the given number of objects
+have failed. If more than one object was checked and any failed, or if the
+-v flag is given, a summary of the number of objects checked and failed is
+printed at the end.
+
+The --coverage flag prints a report of which constraints of the CUE files
+were exercised by the data: for each field, optional field, pattern
+constraint, disjunct, bound and list element type, it shows how many values
+it accepted and rejected, or that it was never exercised. The --coverprofile
+flag writes the same information to a file in the LCOV format, in which the
+disjuncts of a disjunction are reported as branches, for use with coverage
+tools.
+
+The --watch flag keeps vet running and validates again each time one of
+the CUE or data files changes.
+
 By default, each file is checked against the root of the loaded CUE files.
 The -d can be used to only verify files against the result of an expression
 evaluated within the CUE files. This can be useful if the CUE files contain
@@ -58,15 +96,29 @@ Examples:
   # Check files against a particular expression
   cue vet translations/*.yaml foo.cue -d '#Translation'
 
+  # Check a large stream of events using 8 workers, stopping after
+  # 10 invalid events
+  cue vet -j 8 --max-errors 10 schema.cue -d '#Event' events.jsonl
+
+  # Show which constraints of #Translation the test data exercises
+  cue vet --coverage translations/*.yaml foo.cue -d '#Translation'
+
 If more than one expression is given, all must match all values.
 `
 
+const (
+	flagJobs         flagName = "jobs"
+	flagMaxErrors    flagName = "max-errors"
+	flagCoverage     flagName = "coverage"
+	flagCoverProfile flagName = "coverprofile"
+)
+
 func newVetCmd(c *Command) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "vet",
 		Short: "validate data",
 		Long:  vetDoc,
-		RunE:  mkRunE(c, doVet),
+		RunE:  mkRunE(c, watchable(doVet)),
 	}
 
 	addOrphanFlags(cmd.Flags())
@@ -74,6 +126,16 @@ func newVetCmd(c *Command) *cobra.Command {
 
 	cmd.Flags().BoolP(string(flagConcrete), "c", false,
 		"require the evaluation to be concrete")
+	cmd.Flags().IntP(string(flagJobs), "j", 1,
+		"number of data objects to validate in parallel")
+	cmd.Flags().Int(string(flagMaxErrors), 0,
+		"stop after this many data objects failed to validate (0 for no limit)")
+	cmd.Flags().Bool(string(flagCoverage), false,
+		"report which constraints were exercised by the data files")
+	cmd.Flags().String(string(flagCoverProfile), "",
+		"write an LCOV coverage profile of the constraints to this file")
+	cmd.Flags().Bool(string(flagWatch), false,
+		"rerun when the input files change")
 
 	return cmd
 }
@@ -99,6 +161,10 @@ func doVet(cmd *Command, args []string) error {
 		vetFiles(cmd, b)
 		return nil
 	}
+	if flagCoverage.Bool(cmd) || flagCoverProfile.String(cmd) != "" {
+		exitOnErr(cmd, errors.Newf(token.NoPos,
+			"--coverage and --coverprofile require data files"), true)
+	}
 
 	shown := false
 
@@ -138,21 +204,168 @@ func doVet(cmd *Command, args []string) error {
 	return nil
 }
 
+// vetFiles validates the objects of the data files, each independently
+// against the schema.
 func vetFiles(cmd *Command, b *buildPlan) {
 	// Use -r type root, instead of -e
 
-	if !b.encConfig.Schema.Exists() {
+	schema := b.encConfig.Schema
+	if !schema.Exists() {
 		exitOnErr(cmd, errors.New("data files specified without a schema"), true)
 	}
+	jobs := flagJobs.Int(cmd)
+	if jobs < 1 {
+		exitOnErr(cmd, errors.Newf(token.NoPos,
+			"invalid value %d for -j/--jobs: must be at least 1", jobs), true)
+	}
+	maxErrors := flagMaxErrors.Int(cmd)
 
-	iter := b.instances()
-	defer iter.close()
-	for iter.scan() {
-		v := iter.value()
+	var cov *coverage.Profile
+	profile := flagCoverProfile.String(cmd)
+	if flagCoverage.Bool(cmd) || profile != "" {
+		cov = coverage.New(b.schemaFiles...)
+	}
 
-		// Always concrete when checking against concrete files.
-		err := v.Validate(cue.Concrete(true))
+	// Evaluate the schema fully before it is shared among workers, which
+	// then only read it.
+	_ = schema.Validate()
+
+	type object struct {
+		expr ast.Expr
+		err  chan error
+	}
+	type result struct {
+		err      chan error
+		isObject bool // false for decoding errors
+	}
+	work := make(chan object)
+	results := make(chan result, jobs)
+	stop := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for n := 0; n < jobs; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for x := range work {
+				x.err <- vetObject(cmd.ctx, schema, x.expr, cov)
+			}
+		}()
+	}
+
+	// Decode the objects in order, queuing a result for each so that errors
+	// are reported in the same order. The number of queued results bounds
+	// the number of objects held in memory.
+	go func() {
+		defer close(results)
+		defer close(work)
+
+		queue := func(r result) bool {
+			select {
+			case results <- r:
+				return true
+			case <-stop:
+				return false
+			}
+		}
+		for _, di := range b.orphaned {
+			d := di.dec(b)
+			for ; !d.Done(); d.Next() {
+				x := object{internal.ToExpr(d.File()), make(chan error, 1)}
+				if !queue(result{x.err, true}) {
+					d.Close()
+					return
+				}
+				work <- x
+			}
+			d.Close()
+			if err := d.Err(); err != nil {
+				c := make(chan error, 1)
+				c <- err
+				if !queue(result{c, false}) {
+					return
+				}
+			}
+		}
+	}()
+
+	checked, failed := 0, 0
+	stopped := false
+	for r := range results {
+		err := <-r.err
+		if r.isObject {
+			checked++
+		}
+		if err == nil {
+			continue
+		}
 		exitOnErr(cmd, err, false)
+		if !r.isObject {
+			continue
+		}
+		failed++
+		if failed == maxErrors {
+			stopped = true
+			close(stop)
+			for range results {
+			}
+			break
+		}
+	}
+	wg.Wait()
+
+	if checked > 1 && failed > 0 || flagVerbose.Bool(cmd) {
+		// A summary without failures is informational and must not cause a
+		// non-zero exit code.
+		w := cmd.Stderr()
+		if failed == 0 {
+			w = cmd.OutOrStderr()
+		}
+		if stopped {
+			fmt.Fprintf(w,
+				"checked %d objects, %d failed; stopped after reaching --max-errors\n",
+				checked, failed)
+		} else {
+			fmt.Fprintf(w, "checked %d objects, %d failed\n", checked, failed)
+		}
 	}
-	exitOnErr(cmd, iter.err(), false)
+
+	if cov != nil {
+		writeCoverage(cmd, cov, profile)
+	}
+}
+
+func writeCoverage(cmd *Command, cov *coverage.Profile, profile string) {
+	cwd, _ := os.Getwd()
+	cfg := &coverage.Config{Cwd: cwd}
+	if flagCoverage.Bool(cmd) {
+		err := cov.WriteText(cmd.OutOrStdout(), cfg)
+		exitOnErr(cmd, err, true)
+	}
+	if profile != "" {
+		f, err := os.Create(profile)
+		exitOnErr(cmd, err, true)
+		err = cov.WriteLCOV(f, cfg)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		exitOnErr(cmd, err, true)
+	}
+}
+
+// vetObject validates a single data object against the schema. The object is
+// not registered with ctx, so that it can be garbage collected once checked.
+// If cov is not nil, it records the constraints exercised by the object.
+func vetObject(ctx *cue.Context, schema cue.Value, x ast.Expr, cov *coverage.Profile) error {
+	data := ctx.BuildExpr(x)
+	if err := data.Err(); err != nil {
+		return err
+	}
+	v := data.Unify(schema) // TODO(required fields): don't merge in schema
+	if cov != nil {
+		cov.Add(data, v)
+	}
+
+	// Always concrete when checking against concrete files.
+	return v.Validate(cue.Concrete(true))
 }
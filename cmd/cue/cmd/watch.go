@@ -0,0 +1,395 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/ast/astutil"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/load"
+	"cuelang.org/go/cue/token"
+)
+
+// watchInterval is the interval at which a fileWatcher checks for changes.
+var watchInterval = time.Second
+
+// watchDebounce is the time a fileWatcher waits for further changes after
+// detecting a change, so that a burst of changes, such as an editor saving
+// several files, results in a single reload.
+var watchDebounce = 200 * time.Millisecond
+
+// maxWatchRuns, if positive, is the number of runs after which watching
+// stops. It is used for testing.
+var maxWatchRuns = 0
+
+// clearScreen moves the cursor to the top left and clears the terminal.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// watchable returns a runFunction that runs f once or, if the --watch flag is
+// set, each time the files of the instances named by the arguments change.
+//
+// In watch mode, the screen is cleared before each rerun if the output is
+// written to a terminal, and errors are reported without terminating the
+// command. Files that did not change since the previous run are not parsed
+// again. Output files written by a successful run may be overwritten by the
+// next one.
+func watchable(f runFunction) runFunction {
+	return func(cmd *Command, args []string) error {
+		if !flagWatch.Bool(cmd) {
+			return f(cmd, args)
+		}
+		for _, a := range args {
+			if a == "-" {
+				return errors.Newf(token.NoPos,
+					"cannot use --watch when reading from standard input")
+			}
+		}
+
+		// The commands that support watching load their instances with
+		// the default configuration.
+		base := *defaultConfig.loadCfg
+		base.ParseFile = newParseCache(base.ParseFile).parseFile
+		defer func() {
+			cmd.loadCfg = nil
+			cmd.overwrite = false
+		}()
+
+		clear := flagOutFile.String(cmd) == "" && isTerminal(cmd.OutOrStdout())
+
+		var w *fileWatcher
+		for run := 1; ; run++ {
+			if run > 1 && clear {
+				io.WriteString(cmd.OutOrStdout(), clearScreen)
+			}
+
+			// Load the instances before running f, so that changes made
+			// while f runs trigger another run.
+			cfg := base
+			if err := setLoadFlags(cmd.Flags(), &cfg); err != nil {
+				return err
+			}
+			cmd.loadCfg = &cfg
+			binst := load.Instances(args, &cfg)
+			if w == nil {
+				w = newFileWatcher(binst)
+			} else {
+				w.update(binst)
+			}
+
+			// Use a new context for each run, so that the values of
+			// previous runs can be garbage collected.
+			cmd.ctx = cuecontext.New()
+			switch err := runOnce(cmd, args, f); err {
+			case nil:
+				// Later runs may overwrite the files written by this one.
+				cmd.overwrite = true
+			case ErrPrintedError:
+			default:
+				exitOnErr(cmd, err, false)
+			}
+
+			if run == maxWatchRuns {
+				return nil
+			}
+			w.wait()
+		}
+	}
+}
+
+// runOnce runs f, returning the error that terminated it, if any.
+func runOnce(cmd *Command, args []string, f runFunction) (err error) {
+	defer recoverError(&err)
+	return f(cmd, args)
+}
+
+// isTerminal reports whether w is an interactive terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// A parseCache parses files, reusing the syntax trees of files whose
+// contents did not change since they were last parsed.
+//
+// The loader and evaluator modify the syntax trees they are given, for
+// instance by injecting tags and by resolving references across the files
+// of a package. The cache therefore keeps an unmodified copy of each tree
+// and hands out a new copy each time the file is requested.
+type parseCache struct {
+	parse func(name string, src interface{}) (*ast.File, error)
+
+	mu    sync.Mutex
+	files map[string]parsedFile
+}
+
+type parsedFile struct {
+	src  []byte
+	file *ast.File
+}
+
+func newParseCache(parse func(name string, src interface{}) (*ast.File, error)) *parseCache {
+	return &parseCache{parse: parse, files: map[string]parsedFile{}}
+}
+
+// parseFile has the signature of load.Config.ParseFile.
+func (c *parseCache) parseFile(name string, src interface{}) (*ast.File, error) {
+	var b []byte
+	switch x := src.(type) {
+	case []byte:
+		b = x
+	case string:
+		b = []byte(x)
+	case io.Reader:
+		var err error
+		if b, err = io.ReadAll(x); err != nil {
+			return nil, err
+		}
+	default:
+		return c.parse(name, src)
+	}
+
+	c.mu.Lock()
+	p, ok := c.files[name]
+	c.mu.Unlock()
+	if ok && bytes.Equal(p.src, b) {
+		return copyFile(p.file), nil
+	}
+
+	f, err := c.parse(name, b)
+	if err == nil {
+		c.mu.Lock()
+		c.files[name] = parsedFile{b, copyFile(f)}
+		c.mu.Unlock()
+	}
+	return f, err
+}
+
+// copyFile returns a deep copy of f, with identifiers resolved as in a newly
+// parsed file.
+func copyFile(f *ast.File) *ast.File {
+	c := &astCopier{nodes: map[uintptr]reflect.Value{}}
+	x := c.copy(reflect.ValueOf(f)).Interface().(*ast.File)
+	astutil.Resolve(x, func(token.Pos, string, ...interface{}) {})
+	return x
+}
+
+// An astCopier copies syntax trees. Nodes referred to from several places,
+// like the import specs of a file, are copied once.
+type astCopier struct {
+	nodes map[uintptr]reflect.Value
+}
+
+var (
+	identType = reflect.TypeOf(ast.Ident{})
+	fileType  = reflect.TypeOf(ast.File{})
+)
+
+func (c *astCopier) copy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return v
+		}
+		if x, ok := c.nodes[v.Pointer()]; ok {
+			return x
+		}
+		t := v.Elem().Type()
+		x := reflect.New(t)
+		c.nodes[v.Pointer()] = x
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			switch {
+			case sf.PkgPath != "":
+				// Unexported fields only hold comments, which are copied
+				// below.
+				continue
+			case t == identType && (sf.Name == "Node" || sf.Name == "Scope"),
+				t == fileType && sf.Name == "Unresolved":
+				// Resolution is redone for the copy.
+				continue
+			}
+			x.Elem().Field(i).Set(c.copy(v.Elem().Field(i)))
+		}
+		if n, ok := v.Interface().(ast.Node); ok {
+			if cgs := ast.Comments(n); len(cgs) > 0 {
+				copied := make([]*ast.CommentGroup, len(cgs))
+				for i, cg := range cgs {
+					copied[i] = c.copy(reflect.ValueOf(cg)).Interface().(*ast.CommentGroup)
+				}
+				ast.SetComments(x.Interface().(ast.Node), copied)
+			}
+		}
+		return x
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		x := reflect.New(v.Type()).Elem()
+		x.Set(c.copy(v.Elem()))
+		return x
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		x := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			x.Index(i).Set(c.copy(v.Index(i)))
+		}
+		return x
+	}
+	return v
+}
+
+// A fileWatcher detects changes to the files of build instances by
+// periodically comparing the modification times and sizes of the files in
+// the directories of the instances and their imports.
+type fileWatcher struct {
+	dirs  []string
+	files []string
+	state string
+}
+
+// newFileWatcher returns a fileWatcher for the given instances and files,
+// such as data files.
+func newFileWatcher(insts []*build.Instance, files ...string) *fileWatcher {
+	w := &fileWatcher{}
+	w.update(insts, files...)
+	return w
+}
+
+// update sets the instances and files to watch, which may have changed since
+// the previous load, and records their current state.
+func (w *fileWatcher) update(insts []*build.Instance, files ...string) {
+	dirs := map[string]bool{}
+	seen := map[*build.Instance]bool{}
+	var add func(inst *build.Instance)
+	add = func(inst *build.Instance) {
+		if seen[inst] {
+			return
+		}
+		seen[inst] = true
+		// Package files may be in ancestor directories up to the module
+		// root.
+		for dir := inst.Dir; dir != ""; dir = filepath.Dir(dir) {
+			dirs[dir] = true
+			if inst.Root == "" || dir == inst.Root ||
+				!strings.HasPrefix(dir, inst.Root) || dir == filepath.Dir(dir) {
+				break
+			}
+		}
+		if inst.Root != "" {
+			dirs[filepath.Join(inst.Root, "cue.mod")] = true
+		}
+		for _, f := range inst.BuildFiles {
+			if f.Filename != "-" {
+				files = append(files, f.Filename)
+			}
+		}
+		// Data files are only used if named on the command line. Other
+		// data files in the directory, such as output files, are ignored.
+		for _, f := range inst.OrphanedFiles {
+			if inst.User && f.Filename != "-" {
+				files = append(files, f.Filename)
+			}
+		}
+		for _, imp := range inst.Imports {
+			add(imp)
+		}
+	}
+	for _, inst := range insts {
+		add(inst)
+	}
+
+	w.dirs = w.dirs[:0]
+	for d := range dirs {
+		w.dirs = append(w.dirs, d)
+	}
+	sort.Strings(w.dirs)
+	w.files = files
+	w.state = w.fingerprint()
+}
+
+// changed reports whether any of the watched files changed since the last
+// call to changed or update.
+func (w *fileWatcher) changed() bool {
+	s := w.fingerprint()
+	if s == w.state {
+		return false
+	}
+	w.state = s
+	return true
+}
+
+// wait blocks until a watched file changes and no further changes are made
+// for the debounce period.
+func (w *fileWatcher) wait() {
+	for !w.changed() {
+		time.Sleep(watchInterval)
+	}
+	for {
+		time.Sleep(watchDebounce)
+		if !w.changed() {
+			return
+		}
+	}
+}
+
+func (w *fileWatcher) fingerprint() string {
+	var b strings.Builder
+	stat := func(path string, fi os.FileInfo) {
+		fmt.Fprintf(&b, "%s %d %d\n", path, fi.ModTime().UnixNano(), fi.Size())
+	}
+	for _, dir := range w.dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".cue") {
+				continue
+			}
+			if fi, err := e.Info(); err == nil {
+				stat(filepath.Join(dir, e.Name()), fi)
+			}
+		}
+	}
+	for _, f := range w.files {
+		if fi, err := os.Stat(f); err == nil {
+			stat(f, fi)
+		} else {
+			fmt.Fprintf(&b, "%s missing\n", f)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/parser"
+)
+
+func TestParseCache(t *testing.T) {
+	n := 0
+	c := newParseCache(func(name string, src interface{}) (*ast.File, error) {
+		n++
+		return parser.ParseFile(name, src, parser.ParseComments)
+	})
+
+	testCases := []struct {
+		name  string
+		src   string
+		parse bool
+	}{
+		{"a.cue", "a: 1", true},
+		{"a.cue", "a: 1", false},
+		{"b.cue", "a: 1", true},
+		{"a.cue", "a: 2", true},
+		{"a.cue", "a: 2", false},
+		{"t.cue", "// doc\na: string @tag(a)", true},
+		{"t.cue", "// doc\na: string @tag(a)", false},
+		{"r.cue", "x: y\nz: x", true},
+		{"r.cue", "x: y\nz: x", false},
+	}
+	var prev *ast.File
+	for i, tc := range testCases {
+		before := n
+		f, err := c.parseFile(tc.name, strings.NewReader(tc.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := n > before; got != tc.parse {
+			t.Errorf("%d: parsed %s: got %v; want %v", i, tc.name, got, tc.parse)
+		}
+		if !tc.parse {
+			if f == prev {
+				t.Errorf("%d: %s: syntax tree shared with previous result", i, tc.name)
+			}
+			want, _ := format.Node(prev)
+			got, _ := format.Node(f)
+			if !bytes.Equal(got, want) {
+				t.Errorf("%d: %s: got\n%s\nwant\n%s", i, tc.name, got, want)
+			}
+			fresh, _ := parser.ParseFile(tc.name, tc.src)
+			if len(f.Unresolved) != len(fresh.Unresolved) {
+				t.Errorf("%d: %s: unresolved identifiers not reset: %v", i, tc.name, f.Unresolved)
+			}
+		}
+
+		// Simulate the modifications made by the loader and evaluator, which
+		// must not affect later results.
+		f.Unresolved = append(f.Unresolved, ast.NewIdent("y"))
+		ast.Walk(f, func(n ast.Node) bool {
+			if x, ok := n.(*ast.Ident); ok && x.Node == nil {
+				x.Node = ast.NewIdent("bound")
+			}
+			return true
+		}, nil)
+		prev = f
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatch(t *testing.T) {
+	defer func(interval, debounce time.Duration, runs int) {
+		watchInterval, watchDebounce, maxWatchRuns = interval, debounce, runs
+	}(watchInterval, watchDebounce, maxWatchRuns)
+	watchInterval = 10 * time.Millisecond
+	watchDebounce = 10 * time.Millisecond
+	maxWatchRuns = 2
+
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.cue")
+	b := filepath.Join(dir, "b.cue")
+	write := func(name, src string) {
+		if err := os.WriteFile(name, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(a, "package p\n\nx: y\n")
+	write(b, "package p\n\ny: 1\n")
+
+	c, err := New([]string{"eval", "--watch", a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &syncBuffer{}
+	c.SetOutput(out)
+	done := make(chan error, 1)
+	go func() { done <- c.Run(context.Background()) }()
+
+	wait := func(cond func() bool) {
+		for start := time.Now(); !cond(); time.Sleep(10 * time.Millisecond) {
+			if time.Since(start) > 10*time.Second {
+				t.Fatalf("timeout; output:\n%s", out)
+			}
+		}
+	}
+	wait(func() bool { return strings.Contains(out.String(), "x: 1") })
+
+	// The reference in the unchanged file a.cue is resolved anew.
+	write(b, "package p\n\nz: 22\n")
+	var runErr error
+	wait(func() bool {
+		select {
+		case runErr = <-done:
+			return true
+		default:
+			return false
+		}
+	})
+	if runErr != ErrPrintedError {
+		t.Errorf("got error %v; want %v", runErr, ErrPrintedError)
+	}
+
+	got := out.String()
+	if strings.Contains(got, clearScreen) {
+		t.Error("screen cleared for output that is not a terminal")
+	}
+	want := "x: 1\ny: 1\n" + `x: reference "y" not found:` + "\n"
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("got:\n%s\nwant prefix:\n%s", got, want)
+	}
+}
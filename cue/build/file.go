@@ -35,6 +35,8 @@ type Encoding string
 const (
 	CUE         Encoding = "cue"
 	JSON        Encoding = "json"
+	JSONC       Encoding = "jsonc"
+	JSON5       Encoding = "json5"
 	YAML        Encoding = "yaml"
 	JSONL       Encoding = "jsonl"
 	Text        Encoding = "text"
@@ -42,6 +44,15 @@ const (
 	Protobuf    Encoding = "proto"
 	TextProto   Encoding = "textproto"
 	BinaryProto Encoding = "pb"
+	XML         Encoding = "xml"
+	CSV         Encoding = "csv"
+	TSV         Encoding = "tsv"
+	CBOR        Encoding = "cbor"
+	MsgPack     Encoding = "msgpack"
+	HCL         Encoding = "hcl"
+	DotEnv      Encoding = "dotenv"
+	Properties  Encoding = "properties"
+	INI         Encoding = "ini"
 
 	// TODO:
 	// TOML
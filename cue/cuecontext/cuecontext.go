@@ -24,8 +24,32 @@ import (
 // Option controls a build context.
 type Option interface{ buildOption() }
 
+type option func(r *runtime.Runtime)
+
+func (option) buildOption() {}
+
+// Sandbox restricts the packages that may be imported by the values built
+// with the Context, so that configurations of untrusted origin can be
+// evaluated hermetically. It is an error to import a tool package, such as
+// tool/exec or tool/http, or a package that is not part of the module of
+// the importing package and is not listed in allowImports. An entry of
+// allowImports also allows the packages below it.
+//
+// See the Sandbox field of load.Config to apply the same restrictions when
+// loading instances.
+func Sandbox(allowImports ...string) Option {
+	return option(func(r *runtime.Runtime) {
+		r.SetSandbox(&runtime.Sandbox{AllowImports: allowImports})
+	})
+}
+
 // New creates a new Context.
 func New(options ...Option) *cue.Context {
 	r := runtime.New()
+	for _, o := range options {
+		if o, ok := o.(option); ok {
+			o(r)
+		}
+	}
 	return (*cue.Context)(r)
 }
@@ -0,0 +1,97 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cuecontext_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/load"
+)
+
+func TestSandbox(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cue.mod/module.cue":                  `module: "mod.test/a"`,
+		"cue.mod/pkg/example.com/lib/lib.cue": "package lib\nx: 1",
+		"b/b.cue":                             "package b\nx: 1",
+		"own/own.cue":                         "package own\nimport \"mod.test/a/b\"\nx: b.x",
+		"lib/lib.cue":                         "package lib\nimport \"example.com/lib\"\nx: lib.x",
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	compile := func(src string) func(ctx *cue.Context) cue.Value {
+		return func(ctx *cue.Context) cue.Value { return ctx.CompileString(src) }
+	}
+	build := func(pkg string) func(ctx *cue.Context) cue.Value {
+		return func(ctx *cue.Context) cue.Value {
+			// The instances are loaded without restrictions.
+			inst := load.Instances([]string{pkg}, &load.Config{Dir: dir})[0]
+			return ctx.BuildInstance(inst)
+		}
+	}
+
+	testCases := []struct {
+		name  string
+		value func(ctx *cue.Context) cue.Value
+		allow []string
+		err   string
+	}{{
+		name:  "builtin",
+		value: compile(`import "strings", x: strings.ToUpper("a")`),
+	}, {
+		name:  "tool",
+		value: compile(`import "tool/exec", x: exec.Run`),
+		err:   `import of "tool/exec" not allowed in sandbox mode: tool packages perform I/O`,
+	}, {
+		name:  "main module",
+		value: build("./own"),
+	}, {
+		name:  "external",
+		value: build("./lib"),
+		err:   `import of "example.com/lib" not allowed in sandbox mode`,
+	}, {
+		name:  "allowed",
+		value: build("./lib"),
+		allow: []string{"example.com/lib"},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.value(cuecontext.New(cuecontext.Sandbox(tc.allow...))).Err()
+			switch {
+			case err == nil && tc.err != "":
+				t.Errorf("got no error; want %q", tc.err)
+			case err != nil && (tc.err == "" || !strings.Contains(err.Error(), tc.err)):
+				t.Errorf("error: got %v; want %q", err, tc.err)
+			}
+
+			// Without the option, there are no restrictions.
+			if err := tc.value(cuecontext.New()).Err(); err != nil {
+				t.Errorf("without sandbox: %v", err)
+			}
+		})
+	}
+}
@@ -266,6 +266,30 @@ func TestNodes(t *testing.T) {
 		version: "foo"
 	}
 }`,
+	}, {
+		name: "doc comment at start of file",
+		in: func() ast.Node {
+			st := ast.NewStruct("a", ast.NewString("foo"), "b", ast.NewLit(token.INT, "1"))
+			ast.AddComment(st.Elts[0], internal.NewComment(true, "FOO"))
+			return &ast.File{Decls: st.Elts}
+		}(),
+		out: `// FOO
+a: "foo"
+b: 1
+`,
+	}, {
+		name: "parsed doc comment at start of file",
+		in: func() ast.Node {
+			f, err := parser.ParseFile("", "// FOO\na: \"foo\"\nb: 1\n", parser.ParseComments)
+			if err != nil {
+				panic(err) // error in test
+			}
+			return f
+		}(),
+		out: `// FOO
+a: "foo"
+b: 1
+`,
 	}}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -227,6 +227,26 @@ type Config struct {
 	// Use DefaultTagVars to get a pre-loaded map with supported values.
 	TagVars map[string]TagVar
 
+	// Sandbox restricts a configuration to what can be evaluated
+	// hermetically, so that configurations of untrusted origin can be loaded
+	// safely. If set, it is an error to
+	//
+	//    - import a tool package, such as tool/exec or tool/http,
+	//    - import a package that is not part of the main module and is
+	//      not listed in AllowImports, or
+	//    - inject tag variables, as they depend on the environment.
+	//
+	// These errors are reported when loading the instances. Use the
+	// cuecontext.Sandbox option to also restrict the imports of values
+	// built with a cue.Context.
+	Sandbox bool
+
+	// AllowImports lists the import paths of packages outside of the main
+	// module that may be imported when Sandbox is set. An entry also allows
+	// the packages below it, so that "example.com/lib" allows
+	// "example.com/lib/strings".
+	AllowImports []string
+
 	// Include all files, regardless of tags.
 	AllCUEFiles bool
 
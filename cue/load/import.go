@@ -30,6 +30,7 @@ import (
 	"cuelang.org/go/cue/parser"
 	"cuelang.org/go/cue/token"
 	"cuelang.org/go/internal"
+	"cuelang.org/go/internal/core/runtime"
 	"cuelang.org/go/internal/filetypes"
 )
 
@@ -244,6 +245,10 @@ func (l *loader) loadFunc() build.LoadFunc {
 				errors.Newf(pos, "relative import paths not allowed (%q)", path))
 		}
 
+		if err := cfg.checkSandbox(pos, path); err != nil {
+			return cfg.newErrInstance(pos, impPath, err)
+		}
+
 		// is it a builtin?
 		if strings.IndexByte(strings.Split(path, "/")[0], '.') == -1 {
 			if l.cfg.StdRoot != "" {
@@ -260,6 +265,16 @@ func (l *loader) loadFunc() build.LoadFunc {
 	}
 }
 
+// checkSandbox reports an error if the package with the given import path
+// may not be imported in sandbox mode.
+func (c *Config) checkSandbox(pos token.Pos, path string) errors.Error {
+	if !c.Sandbox {
+		return nil
+	}
+	s := &runtime.Sandbox{AllowImports: c.AllowImports}
+	return s.CheckImport(pos, path, c.Module)
+}
+
 func rewriteFiles(p *build.Instance, root string, isLocal bool) {
 	p.Root = root
 
@@ -16,7 +16,9 @@ package load
 
 import (
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"cuelang.org/go/cue/build"
@@ -87,3 +89,94 @@ func TestLocalDirectory(t *testing.T) {
 		t.Fatalf("DisplayPath=%q, want %q", p.DisplayPath, ".")
 	}
 }
+
+func TestSandbox(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cue.mod/module.cue":                    `module: "mod.test/a"`,
+		"cue.mod/pkg/example.com/lib/lib.cue":   "package lib\nx: 1",
+		"cue.mod/pkg/example.com/lib/sub/s.cue": "package sub\nx: 1",
+		"cue.mod/pkg/example.com/other/o.cue":   "package other\nx: 1",
+		"b/b.cue":                               "package b\nx: 1",
+		"own/own.cue":                           "package own\nimport \"mod.test/a/b\"\nx: b.x",
+		"std/std.cue":                           "package std\nimport \"strings\"\nx: strings.ToUpper(\"a\")",
+		"tool/tool.cue":                         "package tool\nimport \"tool/exec\"\nx: exec.Run",
+		"lib/lib.cue":                           "package lib\nimport \"example.com/lib/sub\"\nx: sub.x",
+		"other/other.cue":                       "package other\nimport \"example.com/other\"\nx: other.x",
+		"vars/vars.cue":                         "package vars\nhost: string @tag(host,var=hostname)",
+		"vars/cue.mod/module.cue":               `module: "mod.test/vars"`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		pkg   string
+		allow []string
+		vars  bool
+		err   string
+	}{{
+		pkg: "./own",
+	}, {
+		pkg: "./std",
+	}, {
+		pkg: "./tool",
+		err: `import of "tool/exec" not allowed in sandbox mode: tool packages perform I/O`,
+	}, {
+		pkg: "./other",
+		err: `import of "example.com/other" not allowed in sandbox mode`,
+	}, {
+		pkg: "./lib",
+		err: `import of "example.com/lib/sub" not allowed in sandbox mode`,
+	}, {
+		pkg:   "./lib",
+		allow: []string{"example.com/lib"},
+	}, {
+		pkg:   "./other",
+		allow: []string{"example.com/lib"},
+		err:   `import of "example.com/other" not allowed in sandbox mode`,
+	}, {
+		pkg:  "./vars",
+		vars: true,
+		err:  "tag variable 'hostname' not allowed in sandbox mode",
+	}, {
+		pkg: "./vars",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.pkg, func(t *testing.T) {
+			cfg := &Config{
+				Dir:          dir,
+				Sandbox:      true,
+				AllowImports: tc.allow,
+			}
+			if tc.pkg == "./vars" {
+				cfg.Dir = filepath.Join(dir, "vars")
+				tc.pkg = "."
+			}
+			if tc.vars {
+				cfg.TagVars = DefaultTagVars()
+			}
+			inst := Instances([]string{tc.pkg}, cfg)[0]
+			err := inst.Err
+			if err == nil {
+				for _, imp := range inst.Imports {
+					if err = imp.Err; err != nil {
+						break
+					}
+				}
+			}
+			switch {
+			case err == nil && tc.err != "":
+				t.Errorf("got no error; want %q", tc.err)
+			case err != nil && (tc.err == "" || !strings.Contains(err.Error(), tc.err)):
+				t.Errorf("error: got %v; want %q", err, tc.err)
+			}
+		})
+	}
+}
@@ -127,6 +127,47 @@ type tag struct {
 	field *ast.Field
 }
 
+// A TagInfo describes a field that is marked with a @tag attribute, into
+// which values can be injected with the -t flag.
+type TagInfo struct {
+	// Key is the name of the tag, as used in -t key=value.
+	Key string
+
+	// Kind is the kind of the values accepted by the tag.
+	Kind cue.Kind
+
+	// Shorthands lists the values that may be injected with -t value.
+	Shorthands []string
+
+	// Var is the name of the tag variable that is injected if the tag is
+	// not set, or "" if there is none.
+	Var string
+
+	// Field is the field into which the values are injected.
+	Field *ast.Field
+}
+
+// Tags returns the tags declared in the files of inst, in the order in which
+// they appear. Files excluded from inst by an @if attribute are not
+// considered, unless inst was loaded with Config.AllCUEFiles.
+func Tags(inst *build.Instance) ([]TagInfo, error) {
+	tags, errs := findTags(inst)
+	if errs != nil {
+		return nil, errs
+	}
+	a := make([]TagInfo, len(tags))
+	for i, t := range tags {
+		a[i] = TagInfo{
+			Key:        t.key,
+			Kind:       t.kind,
+			Shorthands: t.shorthands,
+			Var:        t.vars,
+			Field:      t.field,
+		}
+	}
+	return a, nil
+}
+
 func parseTag(pos token.Pos, body string) (t *tag, err errors.Error) {
 	t = &tag{}
 	t.kind = cue.StringKind
@@ -281,6 +322,10 @@ func injectTags(tags []string, l *loader) errors.Error {
 			if t.hasReplacement || t.vars == "" {
 				continue
 			}
+			if l.cfg.Sandbox {
+				return errors.Newf(token.NoPos,
+					"tag variable '%s' not allowed in sandbox mode", t.vars)
+			}
 			x, ok := vars[t.vars]
 			if !ok {
 				tv, ok := l.cfg.TagVars[t.vars]
@@ -16,7 +16,9 @@ package load
 
 import (
 	"bytes"
+	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"cuelang.org/go/cue/ast"
@@ -121,3 +123,37 @@ func TestTags(t *testing.T) {
 		})
 	}
 }
+
+func TestTagInfo(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		Dir: dir,
+		Overlay: map[string]Source{
+			filepath.Join(dir, "foo.cue"): FromString(`
+			env:   *"dev" | string @tag(env,short=dev|prod)
+			debug: bool            @tag(debug,type=bool)
+			a: host: string        @tag(host,var=hostname)
+			`),
+		},
+	}
+	b := Instances([]string{"foo.cue"}, cfg)[0]
+	tags, err := Tags(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, tag := range tags {
+		name, _, _ := ast.LabelName(tag.Field.Label)
+		got = append(got, fmt.Sprintf("%s %v %v %q %s",
+			tag.Key, tag.Kind, tag.Shorthands, tag.Var, name))
+	}
+	want := []string{
+		`env string [dev prod] "" env`,
+		`debug bool [] "" debug`,
+		`host string [] "hostname" host`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
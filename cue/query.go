@@ -15,6 +15,14 @@
 package cue
 
 import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/scanner"
+	"cuelang.org/go/cue/token"
 	"cuelang.org/go/internal/core/adt"
 )
 
@@ -82,3 +90,290 @@ outer:
 	}
 	return makeValue(v.idx, n, parent)
 }
+
+// A Query is a path in which selectors may match more than one value. Apart
+// from the selectors of a Path, a query may contain
+//
+//	a.*        any regular field of a struct or element of a list
+//	a[*]       the same as a.*
+//	a[=~"re"]  any regular field whose name matches the regular expression
+//	a[!~"re"]  any regular field whose name does not match it
+//
+// For instance, deployments.*.spec.replicas selects the replicas of all
+// deployments, items[*].name the names of all items of a list, and
+// [=~"^prod-"] all top-level fields whose names start with prod-.
+type Query struct {
+	sels []querySelector
+	err  errors.Error
+}
+
+type querySelector struct {
+	// sel is the selector for an exact match. It is not used if any is
+	// true.
+	sel Selector
+
+	// any indicates a wildcard or, if re is not nil, a pattern.
+	any    bool
+	re     *regexp.Regexp
+	negate bool
+}
+
+// matches reports whether a regular field or list element with selector
+// sel is selected by the wildcard or pattern s.
+func (s querySelector) matches(sel Selector) bool {
+	if s.re == nil {
+		return true
+	}
+	if !sel.IsString() {
+		return false
+	}
+	return s.re.MatchString(sel.Unquoted()) != s.negate
+}
+
+func (s querySelector) String() string {
+	switch {
+	case !s.any:
+		return s.sel.String()
+	case s.re == nil:
+		return "*"
+	case s.negate:
+		return "[!~" + strconv.Quote(s.re.String()) + "]"
+	}
+	return "[=~" + strconv.Quote(s.re.String()) + "]"
+}
+
+// ParseQuery parses a query. Any error resulting from this conversion can be
+// obtained by calling Err on the result.
+//
+// A query without wildcards or patterns is parsed as by ParsePath.
+func ParseQuery(s string) Query {
+	var q Query
+	fail := func(pos token.Pos, format string, args ...interface{}) Query {
+		return Query{err: errors.Newf(pos, "invalid query %q: "+format,
+			append([]interface{}{s}, args...)...)}
+	}
+
+	var sc scanner.Scanner
+	src := []byte(s)
+	sc.Init(token.NewFile("query", -1, len(src)), src, func(pos token.Pos, msg string, args []interface{}) {
+		if q.err == nil {
+			q.err = errors.Newf(pos, msg, args...)
+		}
+	}, 0)
+	next := func() (token.Pos, token.Token, string) {
+		pos, tok, lit := sc.Scan()
+		if tok == token.COMMA && lit == "\n" {
+			// Automatically inserted at the end of the input.
+			tok = token.EOF
+		}
+		return pos, tok, lit
+	}
+
+	pos, tok, lit := next()
+	if tok == token.EOF {
+		return Query{}
+	}
+	for first := true; ; first = false {
+		var sel querySelector
+		switch {
+		case tok == token.LBRACK:
+			pos, tok, lit = next()
+			switch tok {
+			case token.MUL:
+				sel.any = true
+			case token.MAT, token.NMAT:
+				sel.negate = tok == token.NMAT
+				pos, tok, lit = next()
+				if tok != token.STRING {
+					return fail(pos, "expected string after %s", "=~ or !~")
+				}
+				str, err := strconv.Unquote(lit)
+				if err != nil {
+					return fail(pos, "invalid string %s", lit)
+				}
+				if sel.re, err = regexp.Compile(str); err != nil {
+					return fail(pos, "%v", err)
+				}
+				sel.any = true
+			case token.INT, token.STRING:
+				sel.sel = basicLitSelector(&ast.BasicLit{Kind: tok, Value: lit})
+			default:
+				return fail(pos, "unexpected %s in index", tokenString(tok, lit))
+			}
+			if pos, tok, lit = next(); tok != token.RBRACK {
+				return fail(pos, "expected ]")
+			}
+
+		case !first && tok != token.PERIOD:
+			return fail(pos, "unexpected %s", tokenString(tok, lit))
+
+		default:
+			if !first {
+				pos, tok, lit = next()
+			}
+			switch {
+			case tok == token.MUL:
+				sel.any = true
+			case tok == token.IDENT, tok.IsKeyword():
+				sel.sel = Label(ast.NewIdent(lit))
+			case tok == token.STRING:
+				sel.sel = basicLitSelector(&ast.BasicLit{Kind: tok, Value: lit})
+			default:
+				return fail(pos, "unexpected %s", tokenString(tok, lit))
+			}
+		}
+		if q.err != nil {
+			return Query{err: q.err}
+		}
+		if err, ok := sel.sel.sel.(pathError); ok && !sel.any {
+			return Query{err: err.Error}
+		}
+		q.sels = append(q.sels, sel)
+
+		if pos, tok, lit = next(); tok == token.EOF {
+			break
+		}
+	}
+	return q
+}
+
+func tokenString(tok token.Token, lit string) string {
+	if lit != "" && tok != token.COMMA {
+		return lit
+	}
+	return tok.String()
+}
+
+// Err reports errors that occurred when parsing the query.
+func (q Query) Err() error {
+	if q.err == nil {
+		return nil
+	}
+	return q.err
+}
+
+// String reports the CUE representation of q.
+func (q Query) String() string {
+	if q.err != nil {
+		return "_|_"
+	}
+	b := &strings.Builder{}
+	for i, s := range q.sels {
+		switch str := s.String(); {
+		case s.re != nil, !s.any && s.sel.Type() == IndexLabel:
+			if s.re == nil {
+				str = "[" + str + "]"
+			}
+			b.WriteString(str)
+		default:
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(str)
+		}
+	}
+	return b.String()
+}
+
+// IsPath reports whether q has no wildcards or patterns, and thus selects at
+// most one value.
+func (q Query) IsPath() bool {
+	for _, s := range q.sels {
+		if s.any {
+			return false
+		}
+	}
+	return true
+}
+
+// A QueryIterator iterates over the values selected by a query.
+type QueryIterator struct {
+	matches []queryMatch
+	i       int
+	err     error
+}
+
+type queryMatch struct {
+	v    Value
+	path []Selector
+}
+
+// LookupAll returns an iterator over the values selected by q relative to v,
+// in the order of the fields and elements of the values they are selected
+// from. Fields that do not exist in some of the values are skipped.
+func (v Value) LookupAll(q Query) *QueryIterator {
+	iter := &QueryIterator{i: -1, err: q.Err()}
+	if iter.err != nil || !v.Exists() {
+		return iter
+	}
+
+	cur := []queryMatch{{v: v}}
+	for _, s := range q.sels {
+		var next []queryMatch
+		add := func(m queryMatch, w Value, sel Selector) {
+			path := append(m.path[:len(m.path):len(m.path)], sel)
+			next = append(next, queryMatch{v: w, path: path})
+		}
+		for _, m := range cur {
+			if !s.any {
+				if w := m.v.LookupPath(MakePath(s.sel)); w.Exists() {
+					add(m, w, s.sel)
+				}
+				continue
+			}
+			switch m.v.IncompleteKind() {
+			case StructKind:
+				fields, err := m.v.Fields()
+				if err != nil {
+					continue
+				}
+				for fields.Next() {
+					if sel := fields.Selector(); s.matches(sel) {
+						add(m, fields.Value(), sel)
+					}
+				}
+			case ListKind:
+				if s.re != nil {
+					continue
+				}
+				elems, err := m.v.List()
+				if err != nil {
+					continue
+				}
+				for i := 0; elems.Next(); i++ {
+					add(m, elems.Value(), Index(i))
+				}
+			}
+		}
+		cur = next
+	}
+	iter.matches = cur
+	return iter
+}
+
+// Next advances the iterator to the next value and reports whether there
+// was one.
+func (i *QueryIterator) Next() bool {
+	if i.i+1 >= len(i.matches) {
+		i.i = len(i.matches)
+		return false
+	}
+	i.i++
+	return true
+}
+
+// Value returns the current value.
+func (i *QueryIterator) Value() Value {
+	return i.matches[i.i].v
+}
+
+// Path returns the path of the current value relative to the value on which
+// LookupAll was called. Unlike the query, it contains no wildcards.
+func (i *QueryIterator) Path() Path {
+	return MakePath(i.matches[i.i].path...)
+}
+
+// Err reports the error of the query, if any.
+func (i *QueryIterator) Err() error {
+	return i.err
+}
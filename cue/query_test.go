@@ -16,6 +16,8 @@ package cue_test
 
 import (
 	"bytes"
+	"fmt"
+	"strings"
 	"testing"
 
 	"cuelang.org/go/cue"
@@ -134,6 +136,123 @@ func TestLookupPath(t *testing.T) {
 	}
 }
 
+func TestLookupAll(t *testing.T) {
+	r := &cue.Runtime{}
+
+	const in = `
+	deployments: {
+		web: spec: replicas: 3
+		db: spec: {}
+		api: spec: replicas: 2
+	}
+	items: [{name: "a"}, {id: 1}, {name: "c"}]
+	"prod-web": 1
+	"dev-web": 2
+	"prod-db": 3
+	#def: 4
+	_hidden: 5
+	opt?: 6
+	`
+
+	testCases := []struct {
+		query string
+		out   string
+		err   string
+	}{{
+		query: "deployments.*.spec.replicas",
+		out:   "deployments.web.spec.replicas: 3\ndeployments.api.spec.replicas: 2\n",
+	}, {
+		query: "items[*].name",
+		out:   "items[0].name: \"a\"\nitems[2].name: \"c\"\n",
+	}, {
+		query: "items.*.id",
+		out:   "items[1].id: 1\n",
+	}, {
+		query: `[=~"^prod-"]`,
+		out:   "\"prod-web\": 1\n\"prod-db\": 3\n",
+	}, {
+		query: `deployments[!~"^w"].spec.replicas`,
+		out:   "deployments.api.spec.replicas: 2\n",
+	}, {
+		query: `items[=~"a"]`,
+		out:   "",
+	}, {
+		query: "deployments.db.spec",
+		out:   "deployments.db.spec: {}\n",
+	}, {
+		query: "#def",
+		out:   "#def: 4\n",
+	}, {
+		query: "deployments.nope.*",
+		out:   "",
+	}, {
+		query: `[=~"^[_#o]"]`,
+		out:   "",
+	}, {
+		query: "a.[0]",
+		err:   `invalid query "a.[0]": unexpected [`,
+	}, {
+		query: `[=~"("]`,
+		err:   "missing closing )",
+	}, {
+		query: "[=~1]",
+		err:   "expected string after =~ or !~",
+	}, {
+		query: "_hidden",
+		err:   "hidden label _hidden not allowed",
+	}}
+	v := compileT(t, r, in)
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			iter := v.LookupAll(cue.ParseQuery(tc.query))
+			b := &strings.Builder{}
+			for iter.Next() {
+				fmt.Fprintf(b, "%v: %v\n", iter.Path(), iter.Value())
+			}
+			if got := b.String(); got != tc.out {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.out)
+			}
+			err := iter.Err()
+			switch {
+			case err == nil && tc.err != "":
+				t.Errorf("got no error; want %q", tc.err)
+			case err != nil && (tc.err == "" || !strings.Contains(err.Error(), tc.err)):
+				t.Errorf("error: got %v; want %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestQueryString(t *testing.T) {
+	testCases := []struct {
+		in, out string
+		isPath  bool
+	}{
+		{"a.b", "a.b", true},
+		{"a[0].#b", "a[0].#b", true},
+		{`"a-b".c`, `"a-b".c`, true},
+		{`a["b"]`, "a.b", true},
+		{"a[*].b", "a.*.b", false},
+		{"*", "*", false},
+		{`a[=~"^x"].b`, `a[=~"^x"].b`, false},
+		{`[!~"x"]`, `[!~"x"]`, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			q := cue.ParseQuery(tc.in)
+			if err := q.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if got := q.String(); got != tc.out {
+				t.Errorf("got %q; want %q", got, tc.out)
+			}
+			if got := q.IsPath(); got != tc.isPath {
+				t.Errorf("IsPath: got %v; want %v", got, tc.isPath)
+			}
+		})
+	}
+}
+
 func compileT(t *testing.T, r *cue.Runtime, s string) cue.Value {
 	t.Helper()
 	inst, err := r.Compile("", s)
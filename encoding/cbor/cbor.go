@@ -0,0 +1,564 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cbor converts CBOR, the Concise Binary Object Representation
+// defined in RFC 8949, to and from CUE.
+//
+// Byte strings map to CUE bytes and text strings to CUE strings. Integers
+// outside the 64-bit range map to the bignum tags 2 and 3, so that they keep
+// their precision. Maps map to structs; integer keys are converted to their
+// decimal representation, other non-string keys are not supported. Other
+// tags are ignored when decoding. Infinity and NaN cannot be represented in
+// CUE and result in an error.
+//
+// A stream of values is decoded as a CBOR sequence, as defined in RFC 8742.
+package cbor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"unicode/utf8"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/literal"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/encoding/scalar"
+)
+
+// Major types.
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+const (
+	tagPosBignum = 2
+	tagNegBignum = 3
+
+	simpleFalse     = 20
+	simpleTrue      = 21
+	simpleNull      = 22
+	simpleUndefined = 23
+
+	infoIndefinite = 31
+	breakCode      = 0xff
+
+	// maxDepth limits the nesting of arrays, maps and tags.
+	maxDepth = 1000
+)
+
+// Encode returns the CBOR encoding of v, which must be concrete.
+func Encode(v cue.Value) ([]byte, error) {
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		return nil, err
+	}
+	e := &encoder{}
+	if err := e.encode(v); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) head(major byte, n uint64) {
+	major <<= 5
+	var b [9]byte
+	switch {
+	case n < 24:
+		e.buf.WriteByte(major | byte(n))
+		return
+	case n <= math.MaxUint8:
+		b[0], b[1] = major|24, byte(n)
+		e.buf.Write(b[:2])
+	case n <= math.MaxUint16:
+		b[0] = major | 25
+		binary.BigEndian.PutUint16(b[1:], uint16(n))
+		e.buf.Write(b[:3])
+	case n <= math.MaxUint32:
+		b[0] = major | 26
+		binary.BigEndian.PutUint32(b[1:], uint32(n))
+		e.buf.Write(b[:5])
+	default:
+		b[0] = major | 27
+		binary.BigEndian.PutUint64(b[1:], n)
+		e.buf.Write(b[:9])
+	}
+}
+
+func (e *encoder) encode(v cue.Value) error {
+	switch v.Kind() {
+	case cue.NullKind:
+		e.head(majorSimple, simpleNull)
+
+	case cue.BoolKind:
+		b, err := v.Bool()
+		if err != nil {
+			return err
+		}
+		if b {
+			e.head(majorSimple, simpleTrue)
+		} else {
+			e.head(majorSimple, simpleFalse)
+		}
+
+	case cue.IntKind:
+		n, err := v.Int(nil)
+		if err != nil {
+			return err
+		}
+		major, tag := byte(majorUint), uint64(tagPosBignum)
+		if n.Sign() < 0 {
+			// Negative integers are encoded as -1-n.
+			n.Not(n)
+			major, tag = majorNegInt, tagNegBignum
+		}
+		if n.IsUint64() {
+			e.head(major, n.Uint64())
+			break
+		}
+		e.head(majorTag, tag)
+		b := n.Bytes()
+		e.head(majorBytes, uint64(len(b)))
+		e.buf.Write(b)
+
+	case cue.FloatKind:
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		var b [9]byte
+		b[0] = majorSimple<<5 | 27
+		binary.BigEndian.PutUint64(b[1:], math.Float64bits(f))
+		e.buf.Write(b[:])
+
+	case cue.StringKind:
+		s, err := v.String()
+		if err != nil {
+			return err
+		}
+		e.head(majorText, uint64(len(s)))
+		e.buf.WriteString(s)
+
+	case cue.BytesKind:
+		b, err := v.Bytes()
+		if err != nil {
+			return err
+		}
+		e.head(majorBytes, uint64(len(b)))
+		e.buf.Write(b)
+
+	case cue.ListKind:
+		iter, err := v.List()
+		if err != nil {
+			return err
+		}
+		var a []cue.Value
+		for iter.Next() {
+			a = append(a, iter.Value())
+		}
+		e.head(majorArray, uint64(len(a)))
+		for _, x := range a {
+			if err := e.encode(x); err != nil {
+				return err
+			}
+		}
+
+	case cue.StructKind:
+		iter, err := v.Fields()
+		if err != nil {
+			return err
+		}
+		var names []string
+		var a []cue.Value
+		for iter.Next() {
+			names = append(names, iter.Label())
+			a = append(a, iter.Value())
+		}
+		e.head(majorMap, uint64(len(a)))
+		for i, x := range a {
+			e.head(majorText, uint64(len(names[i])))
+			e.buf.WriteString(names[i])
+			if err := e.encode(x); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return errors.Newf(v.Pos(), "cbor: cannot encode value of kind %s", v.Kind())
+	}
+	return nil
+}
+
+// A Decoder converts a CBOR sequence to CUE.
+type Decoder struct {
+	path string
+	r    *bufio.Reader
+	file *token.File
+
+	off   int
+	depth int
+}
+
+// NewDecoder configures a CBOR decoder. The path is used to associate
+// position information with each node, which reflects the byte offset within
+// the input.
+func NewDecoder(path string, src io.Reader) *Decoder {
+	return &Decoder{
+		path: path,
+		r:    bufio.NewReader(src),
+		file: token.NewFile(path, -1, math.MaxInt32),
+	}
+}
+
+// Extract converts the next value in the input to a CUE ast. It returns io.EOF
+// if the input has been exhausted.
+func (d *Decoder) Extract() (ast.Expr, error) {
+	if _, err := d.r.Peek(1); err == io.EOF {
+		return nil, io.EOF
+	}
+	return d.decode()
+}
+
+func (d *Decoder) pos(off int) token.Pos {
+	return d.file.Pos(off, token.NoRelPos)
+}
+
+func (d *Decoder) errorf(off int, format string, args ...interface{}) error {
+	return errors.Newf(d.pos(off), "invalid CBOR for file %q: "+format,
+		append([]interface{}{d.path}, args...)...)
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err == io.EOF {
+		return 0, d.errorf(d.off, "unexpected end of input")
+	}
+	d.off++
+	return b, err
+}
+
+func (d *Decoder) readFull(n uint64) ([]byte, error) {
+	// Avoid large allocations for bogus lengths by growing the buffer as
+	// the data comes in.
+	if n > math.MaxInt32 {
+		return nil, d.errorf(d.off, "length %d too large", n)
+	}
+	buf := &bytes.Buffer{}
+	m, err := io.CopyN(buf, d.r, int64(n))
+	d.off += int(m)
+	if err == io.EOF {
+		return nil, d.errorf(d.off, "unexpected end of input")
+	}
+	return buf.Bytes(), err
+}
+
+// head reads an initial byte and its argument. The argument is the raw
+// additional information for indefinite lengths and simple values.
+func (d *Decoder) head() (major, info byte, arg uint64, err error) {
+	b, err := d.readByte()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b>>5, b&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		p, err := d.readFull(1 << (info - 24))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		for _, c := range p {
+			arg = arg<<8 | uint64(c)
+		}
+		return major, info, arg, nil
+	case info == infoIndefinite:
+		return major, info, 0, nil
+	}
+	return 0, 0, 0, d.errorf(d.off-1, "invalid additional information %d", info)
+}
+
+func (d *Decoder) decode() (ast.Expr, error) {
+	start := d.off
+	pos := d.pos(start)
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if info == infoIndefinite {
+		switch major {
+		case majorUint, majorNegInt, majorTag:
+			return nil, d.errorf(start, "invalid indefinite length")
+		case majorSimple:
+			return nil, d.errorf(start, "unexpected break")
+		}
+	}
+
+	switch major {
+	case majorUint:
+		return intLit(pos, new(big.Int).SetUint64(arg)), nil
+
+	case majorNegInt:
+		n := new(big.Int).SetUint64(arg)
+		return intLit(pos, n.Not(n)), nil
+
+	case majorBytes, majorText:
+		b, err := d.str(major, info, arg)
+		if err != nil {
+			return nil, err
+		}
+		if major == majorBytes {
+			return &ast.BasicLit{
+				ValuePos: pos,
+				Kind:     token.STRING,
+				Value:    literal.Bytes.Quote(string(b)),
+			}, nil
+		}
+		if !utf8.Valid(b) {
+			return nil, d.errorf(start, "invalid UTF-8 in text string")
+		}
+		return &ast.BasicLit{
+			ValuePos: pos,
+			Kind:     token.STRING,
+			Value:    literal.String.Quote(string(b)),
+		}, nil
+
+	case majorArray:
+		if err := d.push(start); err != nil {
+			return nil, err
+		}
+		defer d.pop()
+		list := &ast.ListLit{Lbrack: pos}
+		for i := uint64(0); info == infoIndefinite || i < arg; i++ {
+			if info == infoIndefinite {
+				if done, err := d.isBreak(); done || err != nil {
+					return list, err
+				}
+			}
+			x, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			list.Elts = append(list.Elts, x)
+		}
+		return list, nil
+
+	case majorMap:
+		if err := d.push(start); err != nil {
+			return nil, err
+		}
+		defer d.pop()
+		s := &ast.StructLit{Lbrace: pos}
+		for i := uint64(0); info == infoIndefinite || i < arg; i++ {
+			if info == infoIndefinite {
+				if done, err := d.isBreak(); done || err != nil {
+					return s, err
+				}
+			}
+			label, err := d.label()
+			if err != nil {
+				return nil, err
+			}
+			x, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			s.Elts = append(s.Elts, &ast.Field{Label: label, Value: x})
+		}
+		return s, nil
+
+	case majorTag:
+		if err := d.push(start); err != nil {
+			return nil, err
+		}
+		defer d.pop()
+		if arg != tagPosBignum && arg != tagNegBignum {
+			return d.decode()
+		}
+		major, info, n, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if major != majorBytes {
+			return nil, d.errorf(start, "bignum must be a byte string")
+		}
+		b, err := d.str(major, info, n)
+		if err != nil {
+			return nil, err
+		}
+		x := new(big.Int).SetBytes(b)
+		if arg == tagNegBignum {
+			x.Not(x)
+		}
+		return intLit(pos, x), nil
+	}
+
+	// majorSimple
+	var f float64
+	switch info {
+	case simpleFalse:
+		return &ast.BasicLit{ValuePos: pos, Kind: token.FALSE, Value: "false"}, nil
+	case simpleTrue:
+		return &ast.BasicLit{ValuePos: pos, Kind: token.TRUE, Value: "true"}, nil
+	case simpleNull, simpleUndefined:
+		return &ast.BasicLit{ValuePos: pos, Kind: token.NULL, Value: "null"}, nil
+	case 25:
+		f = float16(uint16(arg))
+	case 26:
+		f = float64(math.Float32frombits(uint32(arg)))
+	case 27:
+		f = math.Float64frombits(arg)
+	default:
+		return nil, d.errorf(start, "unsupported simple value %d", arg)
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, d.errorf(start, "cannot represent %v in CUE", f)
+	}
+	return floatLit(pos, f), nil
+}
+
+func (d *Decoder) push(off int) error {
+	d.depth++
+	if d.depth > maxDepth {
+		return d.errorf(off, "exceeded max depth of %d", maxDepth)
+	}
+	return nil
+}
+
+func (d *Decoder) pop() { d.depth-- }
+
+// isBreak reports whether the next byte is a break code, consuming it if so.
+func (d *Decoder) isBreak() (bool, error) {
+	b, err := d.r.Peek(1)
+	if err == io.EOF {
+		return false, d.errorf(d.off, "unexpected end of input")
+	}
+	if err != nil || b[0] != breakCode {
+		return false, err
+	}
+	d.r.ReadByte()
+	d.off++
+	return true, nil
+}
+
+// str reads the contents of a byte or text string, which may consist of
+// chunks if its length is indefinite.
+func (d *Decoder) str(major, info byte, n uint64) ([]byte, error) {
+	if info != infoIndefinite {
+		return d.readFull(n)
+	}
+	var b []byte
+	for {
+		if done, err := d.isBreak(); done || err != nil {
+			return b, err
+		}
+		off := d.off
+		m, info, n, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m != major || info == infoIndefinite {
+			return nil, d.errorf(off, "invalid chunk in indefinite-length string")
+		}
+		p, err := d.readFull(n)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, p...)
+	}
+}
+
+func (d *Decoder) label() (ast.Label, error) {
+	start := d.off
+	pos := d.pos(start).WithRel(token.Newline)
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case major == majorText:
+		b, err := d.str(major, info, arg)
+		if err != nil {
+			return nil, err
+		}
+		return scalar.Label(string(b), pos), nil
+	case major == majorUint && info != infoIndefinite:
+		return scalar.Label(strconv.FormatUint(arg, 10), pos), nil
+	case major == majorNegInt && info != infoIndefinite:
+		n := new(big.Int).SetUint64(arg)
+		return scalar.Label(n.Not(n).String(), pos), nil
+	}
+	return nil, d.errorf(start, "unsupported map key of major type %d", major)
+}
+
+func intLit(pos token.Pos, n *big.Int) ast.Expr {
+	if n.Sign() >= 0 {
+		return &ast.BasicLit{ValuePos: pos, Kind: token.INT, Value: n.String()}
+	}
+	return &ast.UnaryExpr{
+		OpPos: pos,
+		Op:    token.SUB,
+		X:     &ast.BasicLit{Kind: token.INT, Value: new(big.Int).Neg(n).String()},
+	}
+}
+
+func floatLit(pos token.Pos, f float64) ast.Expr {
+	neg := math.Signbit(f)
+	s := strconv.FormatFloat(math.Abs(f), 'g', -1, 64)
+	if !bytes.ContainsAny([]byte(s), ".e") {
+		s += ".0"
+	}
+	lit := &ast.BasicLit{ValuePos: pos, Kind: token.FLOAT, Value: s}
+	if !neg {
+		return lit
+	}
+	lit.ValuePos = token.NoPos
+	return &ast.UnaryExpr{OpPos: pos, Op: token.SUB, X: lit}
+}
+
+// float16 converts an IEEE 754 half-precision float to a float64.
+func float16(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(mant+1024, exp-25)
+}
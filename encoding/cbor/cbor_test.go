@@ -0,0 +1,156 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/encoding/cbor"
+)
+
+// Most test vectors are taken from Appendix A of RFC 8949.
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{"00", "0"},
+		{"1903e8", "1000"},
+		{"1bffffffffffffffff", "18446744073709551615"},
+		{"c249010000000000000000", "18446744073709551616"},
+		{"3bffffffffffffffff", "-18446744073709551616"},
+		{"c349010000000000000000", "-18446744073709551617"},
+		{"20", "-1"},
+		{"f93c00", "1.0"},
+		{"f97bff", "65504.0"},
+		{"fa47c35000", "100000.0"},
+		{"fb7e37e43c8800759c", "1e+300"},
+		{"fbc010666666666666", "-4.1"},
+		{"f4", "false"},
+		{"f6", "null"},
+		{"4401020304", `'\x01\x02\x03\x04'`},
+		{"6449455446", `"IETF"`},
+		{"7f657374726561646d696e67ff", `"streaming"`},
+		{"83010203", "[1, 2, 3]"},
+		{"9f018202039f0405ffff", "[1, [2, 3], [4, 5]]"},
+		{"a201020304", "{\n\t\"1\": 2\n\t\"3\": 4\n}"},
+		{"bf61610161629f0203ffff", "{\n\ta: 1\n\tb: [2, 3]\n}"},
+		{"c074323031332d30332d32315432303a30343a30305a", `"2013-03-21T20:04:00Z"`},
+		{"0102", "1\n2"},
+
+		{"f97c00", `invalid CBOR for file "test": cannot represent +Inf in CUE`},
+		{"62", `invalid CBOR for file "test": unexpected end of input`},
+		{"a1f401", `invalid CBOR for file "test": unsupported map key of major type 7`},
+		{"1f", `invalid CBOR for file "test": invalid indefinite length`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			b, err := hex.DecodeString(tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := cbor.NewDecoder("test", bytes.NewReader(b))
+			var a []string
+			for {
+				expr, err := d.Extract()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					a = append(a, errors.Details(err, nil))
+					break
+				}
+				out, err := format.Node(expr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				a = append(a, string(out))
+			}
+			got := strings.Join(a, "\n")
+			if !strings.HasPrefix(got, tc.want) {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{"0", "00"},
+		{"1000000", "1a000f4240"},
+		{"18446744073709551616", "c249010000000000000000"},
+		{"-18446744073709551617", "c349010000000000000000"},
+		{"-1000", "3903e7"},
+		{"1.5", "fb3ff8000000000000"},
+		{"true", "f5"},
+		{"null", "f6"},
+		{`'\x01\x02'`, "420102"},
+		{`"ü"`, "62c3bc"},
+		{`[1, [2, 3]]`, "8201820203"},
+		{`{a: 1, b: [2, 3], #c: 4, _d: 5}`, "a26161016162820203"},
+		{`{a: int}`, "error"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			v := cuecontext.New().CompileString(tc.in)
+			b, err := cbor.Encode(v)
+			got := hex.EncodeToString(b)
+			if err != nil {
+				got = "error"
+			}
+			if got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	const in = `{
+	name: "web"
+	port: 8080
+	ratio: -0.25
+	data: 'AB'
+	tags: ["a", "b"]
+	"a-b": {x: null}
+}`
+	ctx := cuecontext.New()
+	v := ctx.CompileString(in)
+	b, err := cbor.Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expr, err := cbor.NewDecoder("test", bytes.NewReader(b)).Extract()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := ctx.BuildExpr(expr)
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !v.Equals(w) {
+		t.Errorf("got %v; want %v", w, v)
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package csv converts CSV and TSV to and from CUE.
+//
+// A CSV file is decoded as a stream of values, one for each row. By default,
+// the first row is interpreted as a header defining the field names, and each
+// subsequent row maps to a struct with a field for each column. Without a
+// header, each row maps to a list of values.
+//
+// Without a schema, all values map to strings. With a schema describing a
+// row, values for which the schema does not allow a string are converted to
+// numbers, booleans or null, as appropriate.
+//
+// Encoding is the reverse: a list of structs maps to a table with a header
+// holding the union of all field names, while a list of lists maps to a table
+// without a header. All values must be scalars.
+package csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/encoding/scalar"
+)
+
+// Config defines options for decoding and encoding CSV.
+type Config struct {
+	// Comma is the field delimiter. It defaults to ','. Use '\t' for TSV.
+	Comma rune
+
+	// NoHeader indicates that the first row does not define field names.
+	// Rows are then mapped to lists instead of structs.
+	NoHeader bool
+
+	// Schema, if it exists, describes a single row. It is used to convert
+	// values to kinds other than string. It is only used for decoding.
+	Schema cue.Value
+}
+
+func (c *Config) comma() rune {
+	if c == nil || c.Comma == 0 {
+		return ','
+	}
+	return c.Comma
+}
+
+// A Decoder converts CSV rows to CUE.
+type Decoder struct {
+	path   string
+	r      *csv.Reader
+	header []string
+	cfg    Config
+}
+
+// NewDecoder configures a CSV decoder. The path is used to associate position
+// information with each node. The config may be nil.
+func NewDecoder(path string, src io.Reader, c *Config) *Decoder {
+	d := &Decoder{path: path, r: csv.NewReader(src)}
+	if c != nil {
+		d.cfg = *c
+	}
+	d.r.Comma = c.comma()
+	d.r.ReuseRecord = true
+	return d
+}
+
+// Extract converts the next row to a CUE ast. It returns io.EOF if the input
+// has been exhausted.
+func (d *Decoder) Extract() (ast.Expr, error) {
+	record, err := d.read()
+	if err != nil {
+		return nil, err
+	}
+	if d.header == nil && !d.cfg.NoHeader {
+		if err := d.checkHeader(record); err != nil {
+			return nil, err
+		}
+		d.header = append([]string(nil), record...)
+		if record, err = d.read(); err != nil {
+			return nil, err
+		}
+	}
+
+	pos := d.positions(record)
+
+	if d.cfg.NoHeader {
+		list := &ast.ListLit{Lbrack: pos[0]}
+		for i, text := range record {
+			schema := scalar.LookupIndex(d.cfg.Schema, i)
+			list.Elts = append(list.Elts, scalar.Decode(text, pos[i], schema))
+		}
+		return list, nil
+	}
+
+	s := &ast.StructLit{Lbrace: pos[0]}
+	for i, text := range record {
+		name := d.header[i]
+		schema := scalar.Lookup(d.cfg.Schema, name)
+		s.Elts = append(s.Elts, &ast.Field{
+			Label: scalar.Label(name, pos[i].WithRel(token.Newline)),
+			Value: scalar.Decode(text, pos[i], schema),
+		})
+	}
+	return s, nil
+}
+
+// checkHeader reports an error if the header defines a field name more than
+// once, as the fields of a row would otherwise conflict.
+func (d *Decoder) checkHeader(header []string) error {
+	seen := map[string]int{}
+	for i, name := range header {
+		if j, ok := seen[name]; ok {
+			return errors.Newf(d.positions(header)[i],
+				"duplicate column %q in CSV header of file %q: columns %d and %d",
+				name, d.path, j+1, i+1)
+		}
+		seen[name] = i
+	}
+	return nil
+}
+
+func (d *Decoder) read() ([]string, error) {
+	record, err := d.r.Read()
+	switch x := err.(type) {
+	case nil:
+	case *csv.ParseError:
+		pos := d.filePos(x.Line, x.Column)
+		return nil, errors.Wrapf(x.Err, pos, "invalid CSV for file %q", d.path)
+	default:
+		return nil, err
+	}
+	return record, nil
+}
+
+// positions returns the position of each field in the last read record.
+//
+// Rather than keeping track of the line offsets of the entire input, which
+// would grow with the input, a small file is allocated for each line, with
+// line information mapping it to the right location in the input.
+func (d *Decoder) positions(record []string) []token.Pos {
+	type span struct{ line, col int }
+	spans := make([]span, len(record))
+	width := map[int]int{}
+	for i := range record {
+		line, col := d.r.FieldPos(i)
+		spans[i] = span{line, col}
+		if col > width[line] {
+			width[line] = col
+		}
+	}
+	files := map[int]*token.File{}
+	pos := make([]token.Pos, len(record))
+	for i, s := range spans {
+		f := files[s.line]
+		if f == nil {
+			f = newLineFile(d.path, s.line, width[s.line])
+			files[s.line] = f
+		}
+		pos[i] = f.Pos(s.col-1, token.NoRelPos)
+	}
+	if len(pos) == 0 {
+		pos = append(pos, token.NoPos)
+	}
+	return pos
+}
+
+func (d *Decoder) filePos(line, col int) token.Pos {
+	if col < 1 {
+		col = 1
+	}
+	return newLineFile(d.path, line, col).Pos(col-1, token.NoRelPos)
+}
+
+func newLineFile(path string, line, width int) *token.File {
+	f := token.NewFile(path, -1, width+1)
+	f.AddLineInfo(0, path, line)
+	return f
+}
+
+// Encode returns the CSV encoding of v, which must be a list of structs or a
+// list of lists. Only the Comma and NoHeader options of c are used. The config
+// may be nil.
+func Encode(v cue.Value, c *Config) ([]byte, error) {
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		return nil, err
+	}
+	if v.Kind() != cue.ListKind {
+		return nil, errors.Newf(v.Pos(),
+			"csv: cannot encode value of kind %s: must be a list of rows", v.Kind())
+	}
+	rows, err := listValues(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var header []string
+	isStruct := len(rows) > 0 && rows[0].Kind() == cue.StructKind
+	if isStruct {
+		index := map[string]bool{}
+		for _, r := range rows {
+			if r.Kind() != cue.StructKind {
+				continue // reported below
+			}
+			iter, err := r.Fields()
+			if err != nil {
+				return nil, err
+			}
+			for iter.Next() {
+				if name := iter.Label(); !index[name] {
+					index[name] = true
+					header = append(header, name)
+				}
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	w.Comma = c.comma()
+	if isStruct && (c == nil || !c.NoHeader) {
+		if err := w.Write(header); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, r := range rows {
+		var record []string
+		switch {
+		case isStruct && r.Kind() == cue.StructKind:
+			record = make([]string, len(header))
+			for i, name := range header {
+				f := r.LookupPath(cue.MakePath(cue.Str(name)))
+				if !f.Exists() {
+					continue
+				}
+				if record[i], err = encodeScalar(f); err != nil {
+					return nil, err
+				}
+			}
+
+		case !isStruct && r.Kind() == cue.ListKind:
+			a, err := listValues(r)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range a {
+				s, err := encodeScalar(f)
+				if err != nil {
+					return nil, err
+				}
+				record = append(record, s)
+			}
+
+		default:
+			return nil, errors.Newf(r.Pos(),
+				"csv: %v: rows must be all structs or all lists, found %s",
+				r.Path(), r.Kind())
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func listValues(v cue.Value) ([]cue.Value, error) {
+	iter, err := v.List()
+	if err != nil {
+		return nil, err
+	}
+	var a []cue.Value
+	for iter.Next() {
+		a = append(a, iter.Value())
+	}
+	return a, nil
+}
+
+func encodeScalar(v cue.Value) (string, error) {
+	switch v.Kind() {
+	case cue.StructKind, cue.ListKind:
+		return "", errors.Newf(v.Pos(),
+			"csv: %v: cannot encode %s in a table cell", v.Path(), v.Kind())
+	}
+	return scalar.Encode(v)
+}
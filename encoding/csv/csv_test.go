@@ -0,0 +1,174 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csv_test
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/encoding/csv"
+)
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		name   string
+		in     string
+		cfg    csv.Config
+		schema string
+		want   string
+	}{{
+		name: "header",
+		in:   "name,age\nfoo,1\nbar,2\n",
+		want: `{
+	name: "foo"
+	age:  "1"
+}
+{
+	name: "bar"
+	age:  "2"
+}
+`,
+	}, {
+		name:   "schema",
+		in:     "name,age,ok,\"first name\"\nfoo,1,true,x\n",
+		schema: `{name: string, age: int, ok: bool, "first name": string}`,
+		want: `{
+	name:         "foo"
+	age:          1
+	ok:           true
+	"first name": "x"
+}
+`,
+	}, {
+		name: "no header",
+		in:   "a\tb\nc\td\n",
+		cfg:  csv.Config{Comma: '\t', NoHeader: true},
+		want: `["a", "b"]
+["c", "d"]
+`,
+	}, {
+		name:   "no header with schema",
+		in:     "a,1\n",
+		cfg:    csv.Config{NoHeader: true},
+		schema: `[string, ...int]`,
+		want: `["a", 1]
+`,
+	}, {
+		name: "wrong number of fields",
+		in:   "a,b\n1,2\n3\n",
+		want: `{
+	a: "1"
+	b: "2"
+}
+error: invalid CSV for file "test.csv": wrong number of fields:
+    test.csv:3:1
+
+`,
+	}, {
+		name: "duplicate header",
+		in:   "a,b,a\n1,2,3\n",
+		want: `error: duplicate column "a" in CSV header of file "test.csv": columns 1 and 3:
+    test.csv:1:5
+
+`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := tc.cfg
+			if tc.schema != "" {
+				cfg.Schema = cuecontext.New().CompileString(tc.schema)
+			}
+			d := csv.NewDecoder("test.csv", strings.NewReader(tc.in), &cfg)
+			b := &strings.Builder{}
+			for {
+				expr, err := d.Extract()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					fmt.Fprintf(b, "error: %s\n", errors.Details(err, nil))
+					break
+				}
+				out, err := format.Node(expr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				fmt.Fprintf(b, "%s\n", out)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		cfg  *csv.Config
+		want string
+		err  string
+	}{{
+		name: "structs",
+		in:   `[{a: 1, b: "x,y"}, {b: true, c: null}]`,
+		want: "a,b,c\n1,\"x,y\",\n,true,\n",
+	}, {
+		name: "lists",
+		in:   `[[1, 2], ["a"]]`,
+		cfg:  &csv.Config{Comma: '\t'},
+		want: "1\t2\na\n",
+	}, {
+		name: "no header",
+		in:   `[{a: 1}]`,
+		cfg:  &csv.Config{NoHeader: true},
+		want: "1\n",
+	}, {
+		name: "nested",
+		in:   `[{a: b: 1}]`,
+		err:  "csv: [0].a: cannot encode struct in a table cell",
+	}, {
+		name: "mixed",
+		in:   `[{a: 1}, [1]]`,
+		err:  "csv: [1]: rows must be all structs or all lists, found list",
+	}, {
+		name: "not a list",
+		in:   `{a: 1}`,
+		err:  "must be a list of rows",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := cuecontext.New().CompileString(tc.in)
+			b, err := csv.Encode(v, tc.cfg)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("got error %v; want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tc.want {
+				t.Errorf("\ngot:\n%q\nwant:\n%q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dotenv converts dotenv files, which define environment variables,
+// to and from CUE.
+//
+// Each line of a dotenv file holds an assignment of the form KEY=value,
+// optionally prefixed with "export". Values may be unquoted, in which case a
+// trailing comment is removed, single-quoted, in which case they are taken
+// literally, or double-quoted, in which case the escape sequences \n, \r, \t,
+// \", \\ and \$ are interpreted. Quoted values may span multiple lines.
+// Variable references are not expanded.
+//
+// Without a schema, all values map to strings. With a schema, values for
+// which the schema does not allow a string are converted to numbers, booleans
+// or null, as appropriate. With the Nest option, dotted keys map to nested
+// fields.
+//
+// Encoding is the reverse. All values must be scalars and structs are only
+// allowed with the Nest option.
+package dotenv
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/internal/encoding/flat"
+)
+
+// Config defines options for decoding and encoding dotenv files.
+type Config struct {
+	// Nest indicates that keys are split at dots to map to nested fields.
+	Nest bool
+
+	// Schema, if it exists, is used to convert values to kinds other than
+	// string. It is only used for decoding.
+	Schema cue.Value
+}
+
+// A Decoder converts a dotenv file to CUE.
+type Decoder struct {
+	path string
+	r    io.Reader
+	cfg  Config
+	done bool
+}
+
+// NewDecoder configures a decoder. The path is used to associate position
+// information with each node. The config may be nil.
+func NewDecoder(path string, src io.Reader, c *Config) *Decoder {
+	d := &Decoder{path: path, r: src}
+	if c != nil {
+		d.cfg = *c
+	}
+	return d
+}
+
+// Extract converts the input to a CUE struct. As the input holds a single
+// value, it returns io.EOF on any subsequent call.
+func (d *Decoder) Extract() (ast.Expr, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	d.done = true
+	b, err := io.ReadAll(d.r)
+	if err != nil {
+		return nil, err
+	}
+	s := flat.NewSource("dotenv", d.path, b)
+
+	var entries []flat.Entry
+	err = s.Lines(func(off int, line []byte) (int, error) {
+		text := strings.TrimLeft(string(line), " \t")
+		off += len(line) - len(text)
+		switch {
+		case text == "":
+			return -1, nil
+		case text[0] == '#':
+			s.AddComment(off, text[1:])
+			return -1, nil
+		}
+		comments := s.Doc(off)
+		if t := strings.TrimPrefix(text, "export"); t != text && strings.TrimLeft(t, " \t") != t {
+			t = strings.TrimLeft(t, " \t")
+			off += len(text) - len(t)
+			text = t
+		}
+		eq := strings.IndexByte(text, '=')
+		if eq < 0 {
+			return 0, s.Errorf(off, "expected '=' after key")
+		}
+		key := strings.TrimRight(text[:eq], " \t")
+		if !isKey(key) {
+			return 0, s.Errorf(off, "invalid key %q", key)
+		}
+		valueOff := off + eq + 1
+		for valueOff < len(s.Data) && (s.Data[valueOff] == ' ' || s.Data[valueOff] == '\t') {
+			valueOff++
+		}
+		value, next, err := parseValue(s, valueOff)
+		if err != nil {
+			return 0, err
+		}
+		path := []string{key}
+		if d.cfg.Nest {
+			path = strings.Split(key, ".")
+		}
+		entries = append(entries, flat.Entry{
+			Path:     path,
+			Value:    value,
+			Pos:      s.Pos(off),
+			ValuePos: s.ValuePos(valueOff),
+			Comments: comments,
+		})
+		s.End(next - 1)
+		return next, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Build(entries, d.cfg.Schema)
+}
+
+// parseValue parses the value starting at off. It returns the value and the
+// offset of the next line.
+func parseValue(s *flat.Source, off int) (value string, next int, err error) {
+	data := s.Data
+	lineEnd := func(i int) int {
+		for i < len(data) && data[i] != '\n' {
+			i++
+		}
+		return i
+	}
+	if off >= len(data) || data[off] != '"' && data[off] != '\'' {
+		end := lineEnd(off)
+		v := strings.TrimRight(string(data[off:end]), "\r")
+		if i := strings.Index(v, " #"); i >= 0 {
+			v = v[:i]
+		} else if i := strings.Index(v, "\t#"); i >= 0 {
+			v = v[:i]
+		}
+		if strings.HasPrefix(v, "#") {
+			v = ""
+		}
+		return strings.TrimRight(v, " \t"), end + 1, nil
+	}
+
+	quote := data[off]
+	var b strings.Builder
+	i := off + 1
+	for ; ; i++ {
+		if i >= len(data) {
+			return "", 0, s.Errorf(off, "quoted value not terminated")
+		}
+		c := data[i]
+		if c == quote {
+			break
+		}
+		if c != '\\' || quote == '\'' || i+1 >= len(data) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch c := data[i]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\\', '$':
+			b.WriteByte(c)
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		}
+	}
+	end := lineEnd(i)
+	rest := strings.TrimSpace(string(data[i+1 : end]))
+	if rest != "" && rest[0] != '#' {
+		return "", 0, s.Errorf(i+1, "unexpected text after quoted value")
+	}
+	return b.String(), end + 1, nil
+}
+
+// isKey reports whether s is a valid key.
+func isKey(s string) bool {
+	for i, r := range s {
+		switch {
+		case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case i > 0 && ('0' <= r && r <= '9' || r == '.' || r == '-'):
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Encode returns the dotenv encoding of v, which must be a struct. Only the
+// Nest option of c is used. The config may be nil.
+func Encode(v cue.Value, c *Config) ([]byte, error) {
+	depth := 0
+	if c != nil && c.Nest {
+		depth = -1
+	}
+	pairs, err := flat.Flatten(v, depth, "dotenv")
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, p := range pairs {
+		key := strings.Join(p.Path, ".")
+		if !isKey(key) {
+			return nil, errors.Newf(v.Pos(), "dotenv: invalid key %q", key)
+		}
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(quote(p.Value))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// quote returns s, double-quoted if it contains any characters that would
+// otherwise be interpreted.
+func quote(s string) string {
+	if !strings.ContainsAny(s, " \t\r\n\"'#$\\`") {
+		return s
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '"', '\\', '$':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,167 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotenv_test
+
+import (
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/encoding/dotenv"
+	"cuelang.org/go/internal"
+)
+
+func TestExtract(t *testing.T) {
+	testCases := []struct {
+		name   string
+		in     string
+		nest   bool
+		schema string
+		want   string
+	}{{
+		name: "values",
+		in: `# App settings.
+
+# Listen port.
+export PORT=8080 # http
+NAME="my \"app\"
+v2"
+RAW='a $b\n'
+EMPTY=
+`,
+		want: `// App settings.
+
+// Listen port.
+PORT:  "8080"
+NAME:  "my \"app\"\nv2"
+RAW:   "a $b\\n"
+EMPTY: ""
+`,
+	}, {
+		name:   "schema",
+		in:     "PORT=8080\nDEBUG=true\nHOST=1.2",
+		schema: "PORT: int, DEBUG: bool, HOST: string",
+		want: `PORT:  8080
+DEBUG: true
+HOST:  "1.2"
+`,
+	}, {
+		name: "nest",
+		nest: true,
+		in:   "db.host=localhost\ndb.port=5432\n",
+		want: `db: {
+	host: "localhost"
+	port: "5432"
+}
+`,
+	}, {
+		name: "override",
+		in:   "A=1\nA=2\n",
+		want: "A: \"2\"\n",
+	}, {
+		name: "conflict",
+		nest: true,
+		in:   "a=1\na.b=2\n",
+		want: `invalid dotenv for file "test": key a.b conflicts with an earlier key:
+    test:2:1
+`,
+	}, {
+		name: "missing equals",
+		in:   "A=1\nB\n",
+		want: `invalid dotenv for file "test": expected '=' after key:
+    test:2:1
+`,
+	}, {
+		name: "unterminated",
+		in:   `A="x`,
+		want: `invalid dotenv for file "test": quoted value not terminated:
+    test:1:3
+`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var schema cue.Value
+			if tc.schema != "" {
+				schema = cuecontext.New().CompileString(tc.schema)
+			}
+			cfg := &dotenv.Config{Nest: tc.nest, Schema: schema}
+			expr, err := dotenv.NewDecoder("test", strings.NewReader(tc.in), cfg).Extract()
+			var got string
+			if err != nil {
+				got = errors.Details(err, nil)
+			} else {
+				b, err := format.Node(internal.ToFile(expr))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = string(b)
+			}
+			if got != tc.want {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		nest bool
+		want string
+	}{{
+		name: "values",
+		in:   `{PORT: 8080, DEBUG: true, NAME: "my app", PATH: "a\nb$c", NONE: null}`,
+		want: `PORT=8080
+DEBUG=true
+NAME="my app"
+PATH="a\nb\$c"
+NONE=
+`,
+	}, {
+		name: "nest",
+		nest: true,
+		in:   `db: {host: "h", port: 1}`,
+		want: "db.host=h\ndb.port=1\n",
+	}, {
+		name: "nested struct",
+		in:   `db: host: "h"`,
+		want: "dotenv: cannot encode nested struct db as a flat key",
+	}, {
+		name: "list",
+		in:   `a: [1]`,
+		want: "dotenv: cannot encode a: values of kind list are not supported",
+	}, {
+		name: "invalid key",
+		in:   `"a b": 1`,
+		want: `dotenv: invalid key "a b"`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := cuecontext.New().CompileString(tc.in)
+			b, err := dotenv.Encode(v, &dotenv.Config{Nest: tc.nest})
+			got := string(b)
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tc.want {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
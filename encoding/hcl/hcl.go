@@ -0,0 +1,1405 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hcl converts the native syntax of HCL, the HashiCorp configuration
+// language used by Terraform, to and from CUE.
+//
+// An attribute maps to a field. A block maps to a field named after the block
+// type, with a nested field for each of its labels, holding the block body.
+// For instance,
+//
+//	resource "aws_instance" "web" {
+//	  ami = "ami-123"
+//	}
+//
+// maps to
+//
+//	resource: aws_instance: web: ami: "ami-123"
+//
+// Blocks with the same type and labels that occur more than once within a
+// body map to a list.
+//
+// Literal values, tuples and objects map to their CUE counterparts. Any other
+// expression, such as a reference or a function call, is retained as a
+// string of the form "${expr}", following the convention of the JSON syntax
+// of Terraform. Likewise, quoted strings and heredocs are templates: their
+// interpolation sequences are retained verbatim.
+//
+// When encoding, a field of the top-level struct is written as a block if its
+// name is one of the top-level block types of Terraform, such as resource or
+// variable, using the number of labels defined for that type. Other fields
+// are written as attributes, unless marked with the attribute @hcl(block).
+// This attribute is placed on the field holding the block body; any fields
+// between the block type and that field are its labels. Extract adds this
+// attribute where needed to retain the distinction between blocks and
+// attributes. A string of the form "${expr}" is written as the expression
+// expr.
+package hcl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/literal"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/encoding/scalar"
+)
+
+// terraformBlocks maps the top-level block types of Terraform to their number
+// of labels.
+var terraformBlocks = map[string]int{
+	"terraform": 0,
+	"locals":    0,
+	"moved":     0,
+	"import":    0,
+	"removed":   0,
+	"provider":  1,
+	"variable":  1,
+	"output":    1,
+	"module":    1,
+	"check":     1,
+	"resource":  2,
+	"data":      2,
+	"ephemeral": 2,
+}
+
+// Extract parses HCL data to a CUE expression, using path for position
+// information.
+func Extract(path string, data []byte) (ast.Expr, error) {
+	d := &decoder{path: path, src: data, spaceEnd: -1}
+	return d.parse()
+}
+
+// A Decoder converts HCL input to CUE.
+type Decoder struct {
+	path string
+	r    io.Reader
+	done bool
+}
+
+// NewDecoder configures a decoder. The path is used to associate position
+// information with each node.
+func NewDecoder(path string, src io.Reader) *Decoder {
+	return &Decoder{path: path, r: src}
+}
+
+// Extract converts the input to a CUE ast. As the input holds a single body,
+// it returns io.EOF on any subsequent call.
+func (d *Decoder) Extract() (ast.Expr, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	d.done = true
+	b, err := io.ReadAll(d.r)
+	if err != nil {
+		return nil, err
+	}
+	return Extract(d.path, b)
+}
+
+type decoder struct {
+	path string
+	src  []byte
+	file *token.File
+
+	off int // current offset
+
+	// nest is the bracket nesting depth within an expression. Newlines are
+	// only significant at depth 0.
+	nest int
+
+	last     int // end of the last token
+	spaceEnd int // end of the last skipped white space
+
+	prevLine int  // line on which the last token or comment ended
+	open     bool // last token opened an object, tuple or block
+
+	// comments holds the comments that have been scanned, but not yet
+	// attached to a node.
+	comments []comment
+}
+
+type comment struct {
+	c    *ast.Comment
+	line int // line on which the comment starts
+	end  int // line on which the comment ends
+}
+
+// A block records a block parsed within a body.
+type block struct {
+	typ    string
+	labels int
+	key    string     // type and labels identifying the block
+	outer  *ast.Field // field for the block type
+	inner  *ast.Field // field holding the block body
+}
+
+// bailout is used to abort parsing upon the first error.
+type bailout struct{ err errors.Error }
+
+func (d *decoder) parse() (expr ast.Expr, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			b, ok := r.(bailout)
+			if !ok {
+				panic(r)
+			}
+			expr, err = nil, b.err
+		}
+	}()
+
+	d.file = token.NewFile(d.path, -1, len(d.src))
+	d.file.SetLinesForContent(d.src)
+	if len(d.src) >= 3 && string(d.src[:3]) == "\ufeff" {
+		d.off = 3
+	}
+
+	s := &ast.StructLit{}
+	if cg := d.body(s, 0, true); cg != nil {
+		s.AddComment(cg)
+	}
+	if len(s.Elts) > 0 {
+		if cgs := ast.Comments(s.Elts[0]); len(cgs) > 0 {
+			// The comment will start the file.
+			c := cgs[0].List[0]
+			c.Slash = c.Slash.WithRel(token.NoRelPos)
+		}
+	}
+	return s, nil
+}
+
+func (d *decoder) errorf(off int, format string, args ...interface{}) {
+	err := errors.Newf(d.file.Pos(off, token.NoRelPos),
+		"invalid HCL for file %q: %s", d.path, fmt.Sprintf(format, args...))
+	panic(bailout{err})
+}
+
+// describe returns a description of the character at the current offset for
+// use in error messages.
+func (d *decoder) describe() string {
+	if d.off >= len(d.src) {
+		return "end of input"
+	}
+	r, _ := utf8.DecodeRune(d.src[d.off:])
+	return strconv.QuoteRune(r)
+}
+
+func (d *decoder) line(off int) int {
+	return d.file.Position(d.file.Pos(off, token.NoRelPos)).Line
+}
+
+// pos returns the position for a token at the given offset, with a relative
+// position reflecting its placement relative to the previous token.
+func (d *decoder) pos(off int) token.Pos {
+	line := d.line(off)
+	rel := token.Blank
+	switch {
+	case d.prevLine == 0:
+		rel = token.NoRelPos
+	case line-d.prevLine >= 2:
+		rel = token.NewSection
+	case line-d.prevLine == 1:
+		rel = token.Newline
+	case d.open:
+		rel = token.NoRelPos
+	}
+	d.prevLine = line
+	d.open = false
+	return d.file.Pos(off, rel)
+}
+
+func (d *decoder) peek() byte {
+	if d.off >= len(d.src) {
+		return 0
+	}
+	return d.src[d.off]
+}
+
+func (d *decoder) hasPrefix(s string) bool {
+	return bytes.HasPrefix(d.src[d.off:], []byte(s))
+}
+
+func (d *decoder) expect(ch byte) int {
+	if d.peek() != ch {
+		d.errorf(d.off, "expected %q, found %s", ch, d.describe())
+	}
+	d.off++
+	return d.off - 1
+}
+
+// skipSpace skips white space and comments. Newlines are only skipped if
+// newlines is true. Comments are recorded when not within brackets.
+func (d *decoder) skipSpace(newlines bool) {
+	if d.off != d.spaceEnd {
+		d.last = d.off
+	}
+	defer func() { d.spaceEnd = d.off }()
+
+	for d.off < len(d.src) {
+		switch d.src[d.off] {
+		case ' ', '\t', '\r':
+			d.off++
+			continue
+		case '\n':
+			if !newlines {
+				return
+			}
+			d.off++
+			continue
+		case '#', '/':
+		default:
+			return
+		}
+
+		start := d.off
+		switch {
+		case d.hasPrefix("#"), d.hasPrefix("//"):
+			end := d.off
+			for end < len(d.src) && d.src[end] != '\n' {
+				end++
+			}
+			text := string(d.src[d.off:end])
+			text = strings.TrimPrefix(text, "#")
+			text = strings.TrimPrefix(text, "//")
+			d.addComment(start, start, strings.TrimRight(text, "\r"))
+			d.off = end
+
+		case d.hasPrefix("/*"):
+			i := bytes.Index(d.src[d.off+2:], []byte("*/"))
+			if i < 0 {
+				d.errorf(start, "comment not terminated")
+			}
+			end := d.off + 2 + i
+			lines := strings.Split(string(d.src[d.off+2:end]), "\n")
+			for i, text := range lines {
+				text = strings.TrimRight(text, "\r \t")
+				if i > 0 {
+					// Strip the decoration commonly used for block comments.
+					text = strings.TrimLeft(text, " \t")
+					text = strings.TrimPrefix(text, "*")
+				}
+				if strings.TrimSpace(text) == "" &&
+					(i == 0 || i == len(lines)-1) && len(lines) > 1 {
+					continue
+				}
+				d.addComment(start, end, text)
+			}
+			d.off = end + 2
+
+		default:
+			return
+		}
+	}
+}
+
+func (d *decoder) addComment(start, end int, text string) {
+	if d.nest > 0 {
+		return
+	}
+	if text != "" && text[0] != ' ' && text[0] != '\t' {
+		text = " " + text
+	}
+	c := comment{
+		c:    &ast.Comment{Slash: d.pos(start), Text: "//" + text},
+		line: d.line(start),
+		end:  d.line(end),
+	}
+	d.prevLine = c.end
+	d.comments = append(d.comments, c)
+}
+
+// takeComments returns all pending comments as a single group.
+func (d *decoder) takeComments() *ast.CommentGroup {
+	if len(d.comments) == 0 {
+		return nil
+	}
+	cg := &ast.CommentGroup{}
+	for _, c := range d.comments {
+		cg.List = append(cg.List, c.c)
+	}
+	cg.Doc = d.comments[len(d.comments)-1].end+1 >= d.line(d.off)
+	d.comments = d.comments[:0]
+	return cg
+}
+
+// attachLineComment attaches the pending comment on the given line, if any,
+// to n as a line comment. This is only done if no other token follows on that
+// line, as a line comment extends to the end of the line.
+func (d *decoder) attachLineComment(n ast.Node, line int) {
+	if len(d.comments) == 0 || d.comments[0].line != line ||
+		d.off < len(d.src) && d.comments[0].end == d.line(d.off) {
+		return
+	}
+	n.AddComment(&ast.CommentGroup{
+		Line:     true,
+		Position: 10,
+		List:     []*ast.Comment{d.comments[0].c},
+	})
+	d.comments = d.comments[1:]
+}
+
+// elements parses the elements of a body or object up to the closing
+// character, or the end of input if close is 0, calling elem to parse each
+// element. Elements are separated by newlines and, if commas is true, by
+// commas. It returns any comments that could not be attached to an element.
+func (d *decoder) elements(close byte, commas bool, elem func() ast.Decl) *ast.CommentGroup {
+	var last ast.Decl
+	for {
+		d.skipSpace(true)
+		if d.off >= len(d.src) {
+			if close != 0 {
+				d.errorf(d.off, "expected %q, found end of input", close)
+			}
+			break
+		}
+		if d.peek() == close {
+			break
+		}
+		doc := d.takeComments()
+		n := elem()
+		if doc != nil {
+			ast.AddComment(n, doc)
+		}
+
+		d.skipSpace(false)
+		line := d.line(d.last)
+		switch ch := d.peek(); {
+		case ch == ',' && commas:
+			d.off++
+		case ch == '\n', ch == close, d.off >= len(d.src):
+		case commas:
+			d.errorf(d.off, "expected ',' or newline, found %s", d.describe())
+		default:
+			d.errorf(d.off, "expected newline, found %s", d.describe())
+		}
+		d.skipSpace(true)
+		if f, ok := n.(*ast.Field); ok {
+			d.attachLineComment(f.Value, line)
+		} else {
+			d.attachLineComment(n, line)
+		}
+		last = n
+	}
+	foot := d.takeComments()
+	if foot != nil && last != nil {
+		foot.Position = 100
+		ast.AddComment(last, foot)
+		foot = nil
+	}
+	return foot
+}
+
+// body parses the attributes and blocks of a body into s.
+func (d *decoder) body(s *ast.StructLit, close byte, top bool) *ast.CommentGroup {
+	var blocks []*block
+	cg := d.elements(close, false, func() ast.Decl {
+		f, b := d.bodyItem()
+		if b != nil {
+			blocks = append(blocks, b)
+		}
+		s.Elts = append(s.Elts, f)
+		return f
+	})
+
+	// Merge repeated blocks into a list.
+	groups := map[string][]*block{}
+	for _, b := range blocks {
+		groups[b.key] = append(groups[b.key], b)
+	}
+	drop := map[ast.Decl]bool{}
+	for _, b := range blocks {
+		g := groups[b.key]
+		if g[0] != b {
+			continue
+		}
+		if len(g) > 1 {
+			l := &ast.ListLit{}
+			for i, x := range g {
+				body := x.inner.Value
+				if b, ok := body.(*ast.StructLit); ok {
+					rel := token.Blank
+					if i == 0 {
+						rel = token.NoRelPos
+					}
+					b.Lbrace = b.Lbrace.WithRel(rel)
+				}
+				if i > 0 {
+					drop[x.outer] = true
+					ast.SetComments(body, append(ast.Comments(x.outer), ast.Comments(body)...))
+				}
+				l.Elts = append(l.Elts, body)
+			}
+			b.inner.Value = l
+		}
+		if n, ok := terraformBlocks[b.typ]; !top || !ok || n != b.labels {
+			b.inner.Attrs = append(b.inner.Attrs, &ast.Attribute{Text: "@hcl(block)"})
+		}
+	}
+	if len(drop) > 0 {
+		elts := s.Elts[:0]
+		for _, e := range s.Elts {
+			if !drop[e] {
+				elts = append(elts, e)
+			}
+		}
+		s.Elts = elts
+	}
+	return cg
+}
+
+// bodyItem parses an attribute or block. For blocks, it also returns the
+// block information.
+func (d *decoder) bodyItem() (*ast.Field, *block) {
+	start := d.off
+	pos := d.pos(start)
+	if !isIdentStart(d.peek()) {
+		d.errorf(d.off, "expected attribute or block, found %s", d.describe())
+	}
+	name := d.ident()
+	d.skipSpace(false)
+	if d.peek() == '=' && !d.hasPrefix("==") {
+		d.off++
+		d.skipSpace(false)
+		return &ast.Field{Label: scalar.Label(name, pos), Value: d.value()}, nil
+	}
+
+	names := []string{name}
+	poss := []token.Pos{pos}
+	for d.peek() != '{' {
+		switch ch := d.peek(); {
+		case ch == '"':
+			poss = append(poss, d.pos(d.off))
+			names = append(names, d.quoted())
+		case isIdentStart(ch):
+			poss = append(poss, d.pos(d.off))
+			names = append(names, d.ident())
+		default:
+			d.errorf(d.off, "expected '=', block label or '{', found %s", d.describe())
+		}
+		d.skipSpace(false)
+	}
+
+	body := &ast.StructLit{Lbrace: d.pos(d.off)}
+	d.off++
+	d.open = true
+	if cg := d.body(body, '}', false); cg != nil {
+		cg.Position = 1
+		body.AddComment(cg)
+	}
+	body.Rbrace = d.pos(d.off)
+	if body.Rbrace.RelPos() == token.Blank {
+		body.Rbrace = body.Rbrace.WithRel(token.NoRelPos)
+	}
+	d.expect('}')
+
+	n := len(names)
+	inner := &ast.Field{Label: scalar.Label(names[n-1], poss[n-1]), Value: body}
+	outer := inner
+	for i := n - 2; i >= 0; i-- {
+		outer = &ast.Field{
+			Label: scalar.Label(names[i], poss[i]),
+			Value: &ast.StructLit{Elts: []ast.Decl{outer}},
+		}
+	}
+	return outer, &block{
+		typ:    name,
+		labels: n - 1,
+		key:    strings.Join(names, "\x00"),
+		outer:  outer,
+		inner:  inner,
+	}
+}
+
+// value parses an expression. Expressions that cannot be represented as a
+// CUE literal are retained as a string of the form "${expr}".
+func (d *decoder) value() ast.Expr {
+	start := d.off
+	prevLine, open := d.prevLine, d.open
+	x := d.cond()
+	d.skipSpace(d.nest > 0)
+	if x == nil {
+		d.prevLine, d.open = prevLine, open
+		pos := d.pos(start)
+		raw := strings.TrimSpace(string(d.src[start:d.last]))
+		x = &ast.BasicLit{
+			ValuePos: pos,
+			Kind:     token.STRING,
+			Value:    literal.String.Quote("${" + raw + "}"),
+		}
+	}
+	d.prevLine = d.line(d.last)
+	return x
+}
+
+// The functions below parse expressions. They return nil for expressions
+// that are not literal.
+
+func (d *decoder) cond() ast.Expr {
+	x := d.binary()
+	d.skipSpace(d.nest > 0)
+	if d.peek() != '?' {
+		return x
+	}
+	d.off++
+	d.skipSpace(d.nest > 0)
+	d.cond()
+	d.skipSpace(d.nest > 0)
+	d.expect(':')
+	d.skipSpace(d.nest > 0)
+	d.cond()
+	return nil
+}
+
+var binaryOps = []string{
+	"||", "&&", "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "%",
+}
+
+func (d *decoder) binary() ast.Expr {
+	x := d.unary()
+	for {
+		d.skipSpace(d.nest > 0)
+		op := ""
+		for _, s := range binaryOps {
+			if d.hasPrefix(s) {
+				op = s
+				break
+			}
+		}
+		if op == "" {
+			return x
+		}
+		d.off += len(op)
+		d.skipSpace(d.nest > 0)
+		d.unary()
+		x = nil
+	}
+}
+
+func (d *decoder) unary() ast.Expr {
+	ch := d.peek()
+	if ch != '-' && ch != '!' {
+		return d.postfix()
+	}
+	start := d.off
+	d.off++
+	d.skipSpace(d.nest > 0)
+	x := d.unary()
+	lit, ok := x.(*ast.BasicLit)
+	if !ok || ch != '-' || lit.Kind != token.INT && lit.Kind != token.FLOAT {
+		return nil
+	}
+	opPos := d.file.Pos(start, lit.ValuePos.RelPos())
+	lit.ValuePos = lit.ValuePos.WithRel(token.NoRelPos)
+	return &ast.UnaryExpr{OpPos: opPos, Op: token.SUB, X: lit}
+}
+
+func (d *decoder) postfix() ast.Expr {
+	x := d.primary()
+	for {
+		switch d.peek() {
+		case '.':
+			d.off++
+			switch ch := d.peek(); {
+			case ch == '*':
+				d.off++
+			case '0' <= ch && ch <= '9':
+				for '0' <= d.peek() && d.peek() <= '9' {
+					d.off++
+				}
+			case isIdentStart(ch):
+				d.ident()
+			default:
+				d.errorf(d.off, "expected attribute name, found %s", d.describe())
+			}
+		case '[':
+			d.off++
+			d.nest++
+			d.skipSpace(true)
+			if d.peek() == '*' {
+				d.off++
+			} else {
+				d.cond()
+			}
+			d.skipSpace(true)
+			d.expect(']')
+			d.nest--
+		default:
+			return x
+		}
+		x = nil
+	}
+}
+
+func (d *decoder) primary() ast.Expr {
+	switch ch := d.peek(); {
+	case '0' <= ch && ch <= '9':
+		return d.number()
+
+	case ch == '"':
+		pos := d.pos(d.off)
+		return &ast.BasicLit{
+			ValuePos: pos,
+			Kind:     token.STRING,
+			Value:    literal.String.Quote(d.quoted()),
+		}
+
+	case d.hasPrefix("<<"):
+		return d.heredoc()
+
+	case ch == '[':
+		return d.tuple()
+
+	case ch == '{':
+		return d.object()
+
+	case ch == '(':
+		d.off++
+		d.nest++
+		d.skipSpace(true)
+		d.cond()
+		d.skipSpace(true)
+		d.expect(')')
+		d.nest--
+		return nil
+
+	case isIdentStart(ch):
+		pos := d.pos(d.off)
+		switch name := d.ident(); name {
+		case "true":
+			return &ast.BasicLit{ValuePos: pos, Kind: token.TRUE, Value: name}
+		case "false":
+			return &ast.BasicLit{ValuePos: pos, Kind: token.FALSE, Value: name}
+		case "null":
+			return &ast.BasicLit{ValuePos: pos, Kind: token.NULL, Value: name}
+		}
+		if d.peek() == '(' {
+			// Function call.
+			d.off++
+			d.skipBalanced(')')
+		}
+		return nil
+	}
+	d.errorf(d.off, "unexpected %s", d.describe())
+	return nil
+}
+
+func (d *decoder) number() ast.Expr {
+	start := d.off
+	pos := d.pos(start)
+	digits := func() {
+		for '0' <= d.peek() && d.peek() <= '9' {
+			d.off++
+		}
+	}
+	tok := token.INT
+	digits()
+	if d.peek() == '.' && d.off+1 < len(d.src) &&
+		'0' <= d.src[d.off+1] && d.src[d.off+1] <= '9' {
+		tok = token.FLOAT
+		d.off++
+		digits()
+	}
+	if ch := d.peek(); ch == 'e' || ch == 'E' {
+		tok = token.FLOAT
+		d.off++
+		if ch := d.peek(); ch == '+' || ch == '-' {
+			d.off++
+		}
+		if ch := d.peek(); ch < '0' || ch > '9' {
+			d.errorf(start, "invalid number %s", d.src[start:d.off])
+		}
+		digits()
+	}
+	return &ast.BasicLit{ValuePos: pos, Kind: tok, Value: string(d.src[start:d.off])}
+}
+
+// forExpr reports whether the bracketed expression that was just opened is
+// a for expression and skips it if so.
+func (d *decoder) forExpr(close byte) bool {
+	d.skipSpace(true)
+	if !d.hasPrefix("for") || d.off+3 >= len(d.src) ||
+		!unicode.IsSpace(rune(d.src[d.off+3])) {
+		return false
+	}
+	d.skipBalanced(close)
+	return true
+}
+
+func (d *decoder) tuple() ast.Expr {
+	l := &ast.ListLit{Lbrack: d.pos(d.off)}
+	d.off++
+	d.nest++
+	defer func() { d.nest-- }()
+	d.open = true
+	if d.forExpr(']') {
+		return nil
+	}
+	literal := true
+	for {
+		d.skipSpace(true)
+		if d.peek() == ']' {
+			break
+		}
+		x := d.value()
+		if x == nil {
+			literal = false
+		}
+		l.Elts = append(l.Elts, x)
+		d.skipSpace(true)
+		switch d.peek() {
+		case ',':
+			d.off++
+		case ']':
+		default:
+			d.errorf(d.off, "expected ',' or ']', found %s", d.describe())
+		}
+	}
+	l.Rbrack = d.pos(d.off)
+	if l.Rbrack.RelPos() == token.Blank {
+		l.Rbrack = l.Rbrack.WithRel(token.NoRelPos)
+	}
+	d.off++
+	if !literal {
+		return nil
+	}
+	return l
+}
+
+func (d *decoder) object() ast.Expr {
+	s := &ast.StructLit{Lbrace: d.pos(d.off)}
+	d.off++
+
+	nest := d.nest
+	d.nest++
+	if d.forExpr('}') {
+		d.nest = nest
+		return nil
+	}
+	// Object items are separated by newlines.
+	d.nest = 0
+	literal := true
+	cg := d.elements('}', true, func() ast.Decl {
+		f, ok := d.objectItem()
+		literal = literal && ok
+		s.Elts = append(s.Elts, f)
+		return f
+	})
+	d.nest = nest
+	if cg != nil {
+		cg.Position = 1
+		s.AddComment(cg)
+	}
+	s.Rbrace = d.pos(d.off)
+	d.off++
+	if !literal {
+		return nil
+	}
+	return s
+}
+
+// objectItem parses an item of an object. It reports false if the key is not
+// a literal.
+func (d *decoder) objectItem() (*ast.Field, bool) {
+	start := d.off
+	pos := d.pos(start)
+	name := ""
+	ok := true
+	switch ch := d.peek(); {
+	case isIdentStart(ch):
+		name = d.ident()
+	case ch == '"':
+		name = d.quoted()
+		ok = !strings.Contains(string(d.src[start:d.off]), "${")
+	}
+	d.skipSpace(false)
+	if name == "" || !d.isAssign() {
+		d.off = start
+		d.binary()
+		d.skipSpace(false)
+		ok = false
+	}
+	if !d.isAssign() {
+		d.errorf(d.off, "expected '=' or ':', found %s", d.describe())
+	}
+	d.off++
+	d.skipSpace(false)
+	return &ast.Field{Label: scalar.Label(name, pos), Value: d.value()}, ok
+}
+
+func (d *decoder) isAssign() bool {
+	switch d.peek() {
+	case ':':
+		return true
+	case '=':
+		return !d.hasPrefix("==") && !d.hasPrefix("=>")
+	}
+	return false
+}
+
+// skipBalanced skips up to and including the given closing bracket, skipping
+// any nested brackets and strings.
+func (d *decoder) skipBalanced(close byte) {
+	start := d.off
+	d.nest++
+	defer func() { d.nest-- }()
+	stack := []byte{close}
+	for len(stack) > 0 {
+		d.skipSpace(true)
+		if d.off >= len(d.src) {
+			d.errorf(start, "expected %q, found end of input", stack[len(stack)-1])
+		}
+		switch ch := d.src[d.off]; ch {
+		case '"':
+			d.quoted()
+			continue
+		case '(':
+			stack = append(stack, ')')
+		case '[':
+			stack = append(stack, ']')
+		case '{':
+			stack = append(stack, '}')
+		case ')', ']', '}':
+			if ch != stack[len(stack)-1] {
+				d.errorf(d.off, "unexpected %s", d.describe())
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if d.hasPrefix("<<") {
+				d.heredoc()
+				continue
+			}
+		}
+		d.off++
+	}
+}
+
+func isIdentStart(ch byte) bool {
+	return ch == '_' || 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' ||
+		ch >= utf8.RuneSelf
+}
+
+// ident scans an identifier. This includes the namespace separators of
+// provider-defined functions.
+func (d *decoder) ident() string {
+	start := d.off
+	for d.off < len(d.src) {
+		if d.hasPrefix("::") && d.off+2 < len(d.src) && isIdentStart(d.src[d.off+2]) {
+			d.off += 2
+			continue
+		}
+		r, size := utf8.DecodeRune(d.src[d.off:])
+		if r != '_' && r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		d.off += size
+	}
+	return string(d.src[start:d.off])
+}
+
+// quoted scans a quoted template and returns its contents with escape
+// sequences interpreted. Interpolation and directive sequences are retained
+// verbatim.
+func (d *decoder) quoted() string {
+	start := d.off
+	d.off++
+	var b strings.Builder
+	for {
+		if d.off >= len(d.src) {
+			d.errorf(start, "string literal not terminated")
+		}
+		switch ch := d.src[d.off]; ch {
+		case '"':
+			d.off++
+			return b.String()
+		case '\n':
+			d.errorf(d.off, "newline in string")
+		case '\\':
+			d.escape(&b)
+		case '$', '%':
+			switch {
+			case d.hasPrefix(string([]byte{ch, ch, '{'})):
+				b.Write(d.src[d.off : d.off+3])
+				d.off += 3
+			case d.hasPrefix(string([]byte{ch, '{'})):
+				i := d.off
+				d.interpolation()
+				b.Write(d.src[i:d.off])
+			default:
+				b.WriteByte(ch)
+				d.off++
+			}
+		default:
+			b.WriteByte(ch)
+			d.off++
+		}
+	}
+}
+
+func (d *decoder) escape(b *strings.Builder) {
+	start := d.off
+	d.off++
+	ch := d.peek()
+	d.off++
+	switch ch {
+	case 'n':
+		b.WriteByte('\n')
+	case 'r':
+		b.WriteByte('\r')
+	case 't':
+		b.WriteByte('\t')
+	case '"', '\\':
+		b.WriteByte(ch)
+	case 'u', 'U':
+		n := 4
+		if ch == 'U' {
+			n = 8
+		}
+		if d.off+n > len(d.src) {
+			d.errorf(start, "invalid escape sequence in string")
+		}
+		v, err := strconv.ParseUint(string(d.src[d.off:d.off+n]), 16, 32)
+		if err != nil || !utf8.ValidRune(rune(v)) {
+			d.errorf(start, "invalid escape sequence in string")
+		}
+		d.off += n
+		b.WriteRune(rune(v))
+	default:
+		d.errorf(start, "invalid escape sequence in string")
+	}
+}
+
+// interpolation skips an interpolation or directive sequence within a
+// template.
+func (d *decoder) interpolation() {
+	start := d.off
+	d.off += 2
+	depth := 1
+	for depth > 0 {
+		if d.off >= len(d.src) {
+			d.errorf(start, "template interpolation not terminated")
+		}
+		switch d.src[d.off] {
+		case '"':
+			d.quoted()
+			continue
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+		d.off++
+	}
+}
+
+// heredoc scans a heredoc template.
+func (d *decoder) heredoc() ast.Expr {
+	start := d.off
+	pos := d.pos(start)
+	d.off += 2
+	indent := d.peek() == '-'
+	if indent {
+		d.off++
+	}
+	marker := d.ident()
+	if marker == "" {
+		d.errorf(start, "invalid heredoc marker")
+	}
+	if d.peek() == '\r' {
+		d.off++
+	}
+	d.expect('\n')
+
+	var lines []string
+	for {
+		if d.off >= len(d.src) {
+			d.errorf(start, "heredoc not terminated")
+		}
+		end := d.off
+		for end < len(d.src) && d.src[end] != '\n' {
+			end++
+		}
+		line := strings.TrimRight(string(d.src[d.off:end]), "\r")
+		if strings.TrimSpace(line) == marker {
+			d.off = end
+			break
+		}
+		lines = append(lines, line)
+		d.off = end + 1
+	}
+
+	if indent {
+		// Remove the common leading white space.
+		prefix := -1
+		for _, l := range lines {
+			if strings.TrimSpace(l) == "" {
+				continue
+			}
+			n := len(l) - len(strings.TrimLeft(l, " \t"))
+			if prefix < 0 || n < prefix {
+				prefix = n
+			}
+		}
+		for i, l := range lines {
+			if len(l) >= prefix && prefix > 0 {
+				lines[i] = l[prefix:]
+			} else {
+				lines[i] = strings.TrimLeft(l, " \t")
+			}
+		}
+	}
+	s := ""
+	if len(lines) > 0 {
+		s = strings.Join(lines, "\n") + "\n"
+	}
+	return &ast.BasicLit{
+		ValuePos: pos,
+		Kind:     token.STRING,
+		Value:    literal.String.Quote(s),
+	}
+}
+
+// Encode converts a CUE struct to HCL.
+func Encode(v cue.Value) ([]byte, error) {
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		return nil, err
+	}
+	if k := v.Kind(); k != cue.StructKind {
+		return nil, errors.Newf(v.Pos(), "hcl: top-level value must be a struct, found %s", k)
+	}
+	var buf bytes.Buffer
+	if err := encodeBody(&buf, v, "", true); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+const indentation = "  "
+
+// An attr is an attribute or object item that is to be written.
+type attr struct {
+	name  string
+	value string
+}
+
+// encodeBody writes the fields of v as the attributes and blocks of a body.
+// Consecutive attributes are aligned. Blocks are separated by blank lines.
+func encodeBody(w *bytes.Buffer, v cue.Value, indent string, top bool) error {
+	iter, err := v.Fields()
+	if err != nil {
+		return err
+	}
+	var attrs []attr
+	sep := false
+	flush := func() {
+		if len(attrs) > 0 {
+			if sep {
+				w.WriteByte('\n')
+			}
+			writeAttrs(w, attrs, indent)
+			attrs = attrs[:0]
+			sep = true
+		}
+	}
+	for iter.Next() {
+		name := iter.Label()
+		f := iter.Value()
+		if n, ok := blockLabels(name, f, top); ok {
+			flush()
+			err := encodeBlocks(w, f, name, nil, n, indent, &sep)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !isIdent(name) {
+			return errors.Newf(f.Pos(), "hcl: invalid attribute name %q", name)
+		}
+		s, err := encodeExpr(f, indent)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, attr{name, s})
+	}
+	flush()
+	return nil
+}
+
+func writeAttrs(w *bytes.Buffer, attrs []attr, indent string) {
+	width := 0
+	for _, a := range attrs {
+		if n := utf8.RuneCountInString(a.name); n > width {
+			width = n
+		}
+	}
+	for _, a := range attrs {
+		pad := width - utf8.RuneCountInString(a.name)
+		fmt.Fprintf(w, "%s%s%s = %s\n", indent, a.name, strings.Repeat(" ", pad), a.value)
+	}
+}
+
+// blockLabels reports whether the field with the given name and value is to
+// be written as a block and, if so, its number of labels.
+func blockLabels(name string, v cue.Value, top bool) (int, bool) {
+	if n, ok := terraformBlocks[name]; ok && top {
+		switch v.Kind() {
+		case cue.StructKind, cue.ListKind:
+			return n, true
+		}
+	}
+	return markedBlock(v, 0)
+}
+
+// maxLabels limits the depth at which markedBlock searches for @hcl(block)
+// attributes.
+const maxLabels = 4
+
+// markedBlock reports whether v or one of its descendants is marked with
+// @hcl(block) and at which depth.
+func markedBlock(v cue.Value, depth int) (int, bool) {
+	a := v.Attribute("hcl")
+	if ok, _ := a.Flag(0, "block"); ok {
+		return depth, true
+	}
+	if depth >= maxLabels || v.Kind() != cue.StructKind {
+		return 0, false
+	}
+	iter, _ := v.Fields()
+	for iter.Next() {
+		if n, ok := markedBlock(iter.Value(), depth+1); ok {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// encodeBlocks writes v as blocks of the given type with n labels.
+func encodeBlocks(w *bytes.Buffer, v cue.Value, typ string, labels []string, n int, indent string, sep *bool) error {
+	if len(labels) < n {
+		if v.Kind() != cue.StructKind {
+			return errors.Newf(v.Pos(),
+				"hcl: cannot encode %s as labels of block %s", v.Kind(), typ)
+		}
+		iter, err := v.Fields()
+		if err != nil {
+			return err
+		}
+		for iter.Next() {
+			l := append(labels[:len(labels):len(labels)], iter.Label())
+			err := encodeBlocks(w, iter.Value(), typ, l, n, indent, sep)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if v.Kind() == cue.ListKind {
+		iter, err := v.List()
+		if err != nil {
+			return err
+		}
+		for iter.Next() {
+			err := encodeBlocks(w, iter.Value(), typ, labels, n, indent, sep)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if v.Kind() != cue.StructKind {
+		return errors.Newf(v.Pos(), "hcl: cannot encode %s as block %s", v.Kind(), typ)
+	}
+	if !isIdent(typ) {
+		return errors.Newf(v.Pos(), "hcl: invalid block type %q", typ)
+	}
+
+	if *sep {
+		w.WriteByte('\n')
+	}
+	*sep = true
+	w.WriteString(indent)
+	w.WriteString(typ)
+	for _, l := range labels {
+		w.WriteByte(' ')
+		w.WriteString(quote(l))
+	}
+	var body bytes.Buffer
+	if err := encodeBody(&body, v, indent+indentation, false); err != nil {
+		return err
+	}
+	if body.Len() == 0 {
+		w.WriteString(" {}\n")
+		return nil
+	}
+	w.WriteString(" {\n")
+	w.Write(body.Bytes())
+	w.WriteString(indent)
+	w.WriteString("}\n")
+	return nil
+}
+
+// encodeExpr returns the HCL expression for v.
+func encodeExpr(v cue.Value, indent string) (string, error) {
+	switch v.Kind() {
+	case cue.StructKind:
+		iter, err := v.Fields()
+		if err != nil {
+			return "", err
+		}
+		var attrs []attr
+		for iter.Next() {
+			name := iter.Label()
+			if !isIdent(name) {
+				name = quote(name)
+			}
+			s, err := encodeExpr(iter.Value(), indent+indentation)
+			if err != nil {
+				return "", err
+			}
+			attrs = append(attrs, attr{name, s})
+		}
+		if len(attrs) == 0 {
+			return "{}", nil
+		}
+		var w bytes.Buffer
+		w.WriteString("{\n")
+		writeAttrs(&w, attrs, indent+indentation)
+		w.WriteString(indent)
+		w.WriteString("}")
+		return w.String(), nil
+
+	case cue.ListKind:
+		iter, err := v.List()
+		if err != nil {
+			return "", err
+		}
+		var elems []string
+		multiline := false
+		for iter.Next() {
+			s, err := encodeExpr(iter.Value(), indent+indentation)
+			if err != nil {
+				return "", err
+			}
+			multiline = multiline || strings.Contains(s, "\n")
+			elems = append(elems, s)
+		}
+		if !multiline {
+			return "[" + strings.Join(elems, ", ") + "]", nil
+		}
+		var w bytes.Buffer
+		w.WriteString("[\n")
+		for _, s := range elems {
+			fmt.Fprintf(&w, "%s%s%s,\n", indent, indentation, s)
+		}
+		w.WriteString(indent)
+		w.WriteString("]")
+		return w.String(), nil
+
+	case cue.StringKind:
+		s, err := v.String()
+		if err != nil {
+			return "", err
+		}
+		if x, ok := interpolation(s); ok {
+			return x, nil
+		}
+		return quote(s), nil
+
+	case cue.NullKind, cue.BoolKind, cue.IntKind, cue.FloatKind, cue.NumberKind:
+		b, err := v.MarshalJSON()
+		return string(b), err
+	}
+	return "", errors.Newf(v.Pos(), "hcl: cannot encode value of kind %s", v.Kind())
+}
+
+// interpolation returns the expression x if s is of the form "${x}".
+func interpolation(s string) (string, bool) {
+	if !strings.HasPrefix(s, "${") || !strings.HasSuffix(s, "}") {
+		return "", false
+	}
+	depth := 1
+	for i := 2; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			// Skip nested strings.
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				if i != len(s)-1 {
+					return "", false
+				}
+				x := strings.TrimSpace(s[2:i])
+				return x, x != ""
+			}
+		}
+	}
+	return "", false
+}
+
+// quote returns s as a quoted HCL template. Interpolation sequences in s are
+// retained.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// isIdent reports whether s is a valid HCL identifier.
+func isIdent(s string) bool {
+	for i, r := range s {
+		switch {
+		case unicode.IsLetter(r), r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == '-'):
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,252 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hcl_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/encoding/hcl"
+	"cuelang.org/go/internal"
+)
+
+func TestExtract(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		want string
+	}{{
+		name: "attributes",
+		in: `# Settings.
+region = "eu-west-1" # default
+count  = 3
+ratio  = -0.5
+on     = true
+zones  = ["a", "b"]
+tags   = { Name = "web", "a.b": 1 }
+`,
+		want: `// Settings.
+region: "eu-west-1" // default
+count:  3
+ratio:  -0.5
+on:     true
+zones: ["a", "b"]
+tags: {Name: "web", "a.b": 1}
+`,
+	}, {
+		name: "expressions",
+		in: `a = var.x
+b = "web-${count.index}"
+c = lookup(var.m, "k", null)
+d = [for s in var.list : upper(s)]
+e = [1, local.y]
+f = x ? 1 : 2
+g = "$${literal}"
+`,
+		want: `a: "${var.x}"
+b: "web-${count.index}"
+c: "${lookup(var.m, \"k\", null)}"
+d: "${[for s in var.list : upper(s)]}"
+e: [1, "${local.y}"]
+f: "${x ? 1 : 2}"
+g: "$${literal}"
+`,
+	}, {
+		name: "heredoc",
+		in: `script = <<-EOT
+    echo "a"
+      echo b
+  EOT
+`,
+		want: `script: "echo \"a\"\n  echo b\n"
+`,
+	}, {
+		name: "blocks",
+		in: `resource "aws_instance" "web" {
+  ami = "ami-123"
+
+  lifecycle {
+    create_before_destroy = true
+  }
+}
+
+dynamic "ingress" {
+  port = 80
+}
+dynamic "ingress" {
+  port = 443
+}
+`,
+		want: `resource: aws_instance: web: {
+	ami: "ami-123"
+
+	lifecycle: {
+		create_before_destroy: true
+	} @hcl(block)
+}
+
+dynamic: {
+	ingress: [{
+		port: 80
+	}, {
+		port: 443
+	}] @hcl(block)
+}
+`,
+	}, {
+		name: "unterminated block",
+		in:   "a {\n  b = 1\n",
+		want: `invalid HCL for file "test": expected '}', found end of input:
+    test:2:9
+`,
+	}, {
+		name: "missing newline",
+		in:   `a = 1 b = 2`,
+		want: `invalid HCL for file "test": expected newline, found 'b':
+    test:1:7
+`,
+	}, {
+		name: "bad escape",
+		in:   `a = "\q"`,
+		want: `invalid HCL for file "test": invalid escape sequence in string:
+    test:1:6
+`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := hcl.NewDecoder("test", strings.NewReader(tc.in))
+			expr, err := d.Extract()
+			var got string
+			if err != nil {
+				got = errors.Details(err, nil)
+			} else {
+				b, err := format.Node(internal.ToFile(expr))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = string(b)
+				if _, err := d.Extract(); err != io.EOF {
+					t.Errorf("got %v; want io.EOF", err)
+				}
+			}
+			if got != tc.want {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		want string
+	}{{
+		name: "terraform",
+		in: `
+		variable: region: default: "eu-west-1"
+		resource: aws_instance: web: {
+			ami:           "${data.aws_ami.ubuntu.id}"
+			instance_type: "t3.micro"
+			tags: {Name: "web", "a.b": "x\n"}
+			ports: [80, 443]
+			ingress: [{port: 80}, {port: 443}] @hcl(block)
+			lifecycle: {} @hcl(block)
+		}
+		`,
+		want: `variable "region" {
+  default = "eu-west-1"
+}
+
+resource "aws_instance" "web" {
+  ami           = data.aws_ami.ubuntu.id
+  instance_type = "t3.micro"
+  tags          = {
+    Name  = "web"
+    "a.b" = "x\n"
+  }
+  ports         = [80, 443]
+
+  ingress {
+    port = 80
+  }
+
+  ingress {
+    port = 443
+  }
+
+  lifecycle {}
+}
+`,
+	}, {
+		name: "marked labels",
+		in: `
+		job: docs: {
+			group: [{count: 1}] @hcl(block)
+			meta: list: [{a: 1}, {b: "${x}-y"}]
+		} @hcl(block)
+		`,
+		want: `job "docs" {
+  group {
+    count = 1
+  }
+
+  meta = {
+    list = [
+      {
+        a = 1
+      },
+      {
+        b = "${x}-y"
+      },
+    ]
+  }
+}
+`,
+	}, {
+		name: "not a struct",
+		in:   `[1]`,
+		want: "hcl: top-level value must be a struct, found list",
+	}, {
+		name: "invalid attribute name",
+		in:   `"a b": 1`,
+		want: `hcl: invalid attribute name "a b"`,
+	}, {
+		name: "invalid labels",
+		in:   `resource: x: 1`,
+		want: "hcl: cannot encode int as labels of block resource",
+	}, {
+		name: "incomplete",
+		in:   `a: int`,
+		want: "a: incomplete value int",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := cuecontext.New().CompileString(tc.in)
+			b, err := hcl.Encode(v)
+			got := string(b)
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tc.want {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ini converts INI files to and from CUE.
+//
+// Each key of a section maps to a field of a struct named after the section.
+// Keys preceding the first section map to top-level fields. Keys are
+// separated from their values by '=' or ':'. Lines starting with ';' or '#'
+// are comments. Surrounding white space is removed from keys and values, as
+// are the double quotes of a quoted value.
+//
+// Without a schema, all values map to strings. With a schema, values for
+// which the schema does not allow a string are converted to numbers, booleans
+// or null, as appropriate. With the Nest option, dotted section names and
+// keys map to nested fields.
+//
+// Encoding is the reverse: scalar fields of the top-level struct are written
+// before the first section, and each struct field maps to a section. All
+// values must be scalars and structs within sections are only allowed with
+// the Nest option. Strings may not span multiple lines.
+package ini
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/internal/encoding/flat"
+)
+
+// Config defines options for decoding and encoding INI files.
+type Config struct {
+	// Nest indicates that section names and keys are split at dots to map
+	// to nested fields.
+	Nest bool
+
+	// Schema, if it exists, is used to convert values to kinds other than
+	// string. It is only used for decoding.
+	Schema cue.Value
+}
+
+// A Decoder converts an INI file to CUE.
+type Decoder struct {
+	path string
+	r    io.Reader
+	cfg  Config
+	done bool
+}
+
+// NewDecoder configures a decoder. The path is used to associate position
+// information with each node. The config may be nil.
+func NewDecoder(path string, src io.Reader, c *Config) *Decoder {
+	d := &Decoder{path: path, r: src}
+	if c != nil {
+		d.cfg = *c
+	}
+	return d
+}
+
+// Extract converts the input to a CUE struct. As the input holds a single
+// value, it returns io.EOF on any subsequent call.
+func (d *Decoder) Extract() (ast.Expr, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	d.done = true
+	b, err := io.ReadAll(d.r)
+	if err != nil {
+		return nil, err
+	}
+	s := flat.NewSource("INI", d.path, b)
+
+	split := func(name string) []string {
+		if d.cfg.Nest {
+			return strings.Split(name, ".")
+		}
+		return []string{name}
+	}
+
+	var entries []flat.Entry
+	var section []string
+	err = s.Lines(func(off int, line []byte) (int, error) {
+		text := strings.TrimSpace(string(line))
+		off += len(line) - len(strings.TrimLeft(string(line), " \t"))
+		switch {
+		case text == "":
+			return -1, nil
+		case text[0] == ';', text[0] == '#':
+			s.AddComment(off, text[1:])
+			return -1, nil
+		}
+		comments := s.Doc(off)
+		pos := s.Pos(off)
+
+		if text[0] == '[' {
+			end := strings.IndexByte(text, ']')
+			if end < 0 {
+				return 0, s.Errorf(off, "expected ']' after section name")
+			}
+			name := strings.TrimSpace(text[1:end])
+			if name == "" {
+				return 0, s.Errorf(off, "empty section name")
+			}
+			section = split(name)
+			entries = append(entries, flat.Entry{
+				Path:     section,
+				Section:  true,
+				Pos:      pos,
+				Comments: comments,
+			})
+			return -1, nil
+		}
+
+		i := strings.IndexAny(text, "=:")
+		if i < 0 {
+			return 0, s.Errorf(off, "expected '=' or ':' after key")
+		}
+		key := strings.TrimSpace(text[:i])
+		if key == "" {
+			return 0, s.Errorf(off, "empty key")
+		}
+		value := strings.TrimSpace(text[i+1:])
+		valueOff := off + strings.Index(text, value)
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		path := append(section[:len(section):len(section)], split(key)...)
+		entries = append(entries, flat.Entry{
+			Path:     path,
+			Value:    value,
+			Pos:      pos,
+			ValuePos: s.ValuePos(valueOff),
+			Comments: comments,
+		})
+		return -1, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Build(entries, d.cfg.Schema)
+}
+
+// Encode returns the INI encoding of v, which must be a struct. Only the
+// Nest option of c is used. The config may be nil.
+func Encode(v cue.Value, c *Config) ([]byte, error) {
+	depth := 1
+	if c != nil && c.Nest {
+		depth = -1
+	}
+	pairs, err := flat.Flatten(v, depth, "ini")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	write := func(key, value string) error {
+		if key == "" || strings.ContainsAny(key, "=:[\n\r") ||
+			strings.TrimSpace(key) != key || key[0] == ';' || key[0] == '#' {
+			return errors.Newf(v.Pos(), "ini: invalid key %q", key)
+		}
+		if strings.ContainsAny(value, "\n\r") {
+			return errors.Newf(v.Pos(),
+				"ini: cannot encode multi-line string for key %q", key)
+		}
+		if strings.TrimSpace(value) != value ||
+			len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = `"` + value + `"`
+		}
+		buf.WriteString(key)
+		buf.WriteString(" = ")
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return nil
+	}
+
+	// Write top-level values first, followed by sections in order of
+	// appearance.
+	var sections []string
+	bySection := map[string][]flat.Pair{}
+	for _, p := range pairs {
+		if len(p.Path) == 1 {
+			if err := write(p.Path[0], p.Value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		name := p.Path[0]
+		if _, ok := bySection[name]; !ok {
+			sections = append(sections, name)
+		}
+		bySection[name] = append(bySection[name], p)
+	}
+	for _, name := range sections {
+		if strings.ContainsAny(name, "]\n\r") || strings.TrimSpace(name) != name {
+			return nil, errors.Newf(v.Pos(), "ini: invalid section name %q", name)
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString("[" + name + "]\n")
+		for _, p := range bySection[name] {
+			if err := write(strings.Join(p.Path[1:], "."), p.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ini_test
+
+import (
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/encoding/ini"
+	"cuelang.org/go/internal"
+)
+
+func TestExtract(t *testing.T) {
+	testCases := []struct {
+		name   string
+		in     string
+		nest   bool
+		schema string
+		want   string
+	}{{
+		name: "sections",
+		in: `; Global settings.
+name = app
+
+# Database.
+[database]
+host = localhost
+port: 5432
+quoted = " x "
+
+[server.http]
+port=80
+`,
+		want: `// Global settings.
+name: "app"
+
+// Database.
+database: {
+	host:   "localhost"
+	port:   "5432"
+	quoted: " x "
+}
+
+"server.http": {
+	port: "80"
+}
+`,
+	}, {
+		name:   "schema nest",
+		nest:   true,
+		in:     "[server.http]\nport=80\n[server]\nname=x\n",
+		schema: "server: http: port: int",
+		want: `server: {
+	http: {
+		port: 80
+	}
+	name: "x"
+}
+`,
+	}, {
+		name: "repeated section",
+		in:   "[a]\nx=1\n[b]\n[a]\ny=2\n",
+		want: `a: {
+	x: "1"
+	y: "2"
+}
+b: {}
+`,
+	}, {
+		name: "missing bracket",
+		in:   "[a\n",
+		want: `invalid INI for file "test": expected ']' after section name:
+    test:1:1
+`,
+	}, {
+		name: "missing equals",
+		in:   "[a]\nkey\n",
+		want: `invalid INI for file "test": expected '=' or ':' after key:
+    test:2:1
+`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var schema cue.Value
+			if tc.schema != "" {
+				schema = cuecontext.New().CompileString(tc.schema)
+			}
+			cfg := &ini.Config{Nest: tc.nest, Schema: schema}
+			expr, err := ini.NewDecoder("test", strings.NewReader(tc.in), cfg).Extract()
+			var got string
+			if err != nil {
+				got = errors.Details(err, nil)
+			} else {
+				b, err := format.Node(internal.ToFile(expr))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = string(b)
+			}
+			if got != tc.want {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		nest bool
+		want string
+	}{{
+		name: "sections",
+		in: `{
+			database: {host: "localhost", port: 5432, pad: " x "}
+			name: "app"
+			server: debug: false
+		}`,
+		want: `name = app
+
+[database]
+host = localhost
+port = 5432
+pad = " x "
+
+[server]
+debug = false
+`,
+	}, {
+		name: "nest",
+		nest: true,
+		in:   `server: http: port: 80`,
+		want: "[server]\nhttp.port = 80\n",
+	}, {
+		name: "nested struct",
+		in:   `server: http: port: 80`,
+		want: "ini: cannot encode nested struct server.http as a flat key",
+	}, {
+		name: "multi-line",
+		in:   `a: "x\ny"`,
+		want: `ini: cannot encode multi-line string for key "a"`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := cuecontext.New().CompileString(tc.in)
+			b, err := ini.Encode(v, &ini.Config{Nest: tc.nest})
+			got := string(b)
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tc.want {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
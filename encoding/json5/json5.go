@@ -0,0 +1,666 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package json5 converts JSON5 and JSONC, JSON with comments, to CUE.
+//
+// JSONC extends JSON with line and block comments and allows trailing commas
+// in objects and arrays. JSON5 additionally allows unquoted object keys,
+// single-quoted strings, hexadecimal numbers, numbers with a leading or
+// trailing decimal point or an explicit plus sign, and more escape sequences
+// in strings.
+//
+// Comments are retained as CUE comments. Block comments are converted to line
+// comments. The JSON5 values Infinity and NaN cannot be represented in CUE
+// and result in an error.
+package json5
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/literal"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/encoding/scalar"
+)
+
+// Config defines options for decoding.
+type Config struct {
+	// JSONC restricts the input to JSON with comments and trailing commas,
+	// rejecting the other extensions defined by JSON5.
+	JSONC bool
+}
+
+// Extract parses JSON5 or JSONC data to a CUE expression, using path for
+// position information. The config may be nil.
+func Extract(path string, data []byte, c *Config) (ast.Expr, error) {
+	d := &decoder{path: path, src: data}
+	if c != nil {
+		d.cfg = *c
+	}
+	return d.parse()
+}
+
+// A Decoder converts JSON5 or JSONC input to CUE.
+type Decoder struct {
+	path string
+	r    io.Reader
+	cfg  *Config
+	done bool
+}
+
+// NewDecoder configures a decoder. The path is used to associate position
+// information with each node. The config may be nil.
+func NewDecoder(path string, src io.Reader, c *Config) *Decoder {
+	return &Decoder{path: path, r: src, cfg: c}
+}
+
+// Extract converts the input to a CUE ast. As the input holds a single value,
+// it returns io.EOF on any subsequent call.
+func (d *Decoder) Extract() (ast.Expr, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	d.done = true
+	b, err := io.ReadAll(d.r)
+	if err != nil {
+		return nil, err
+	}
+	return Extract(d.path, b, d.cfg)
+}
+
+type decoder struct {
+	path string
+	src  []byte
+	cfg  Config
+	file *token.File
+
+	off      int  // current offset
+	prevLine int  // line on which the last token or comment ended
+	open     bool // last token opened an object or array
+
+	// comments holds the comments that have been scanned, but not yet
+	// attached to a node.
+	comments []comment
+}
+
+type comment struct {
+	c    *ast.Comment
+	line int // line on which the comment starts
+	end  int // line on which the comment ends
+}
+
+// bailout is used to abort parsing upon the first error.
+type bailout struct{ err errors.Error }
+
+func (d *decoder) format() string {
+	if d.cfg.JSONC {
+		return "JSONC"
+	}
+	return "JSON5"
+}
+
+func (d *decoder) parse() (expr ast.Expr, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			b, ok := r.(bailout)
+			if !ok {
+				panic(r)
+			}
+			expr, err = nil, b.err
+		}
+	}()
+
+	d.file = token.NewFile(d.path, -1, len(d.src))
+	d.file.SetLinesForContent(d.src)
+	if len(d.src) >= 3 && string(d.src[:3]) == "\ufeff" {
+		d.off = 3
+	}
+
+	d.skipSpace()
+	head := d.takeComments()
+	expr = d.value()
+	d.skipSpace()
+	if d.off < len(d.src) {
+		d.errorf(d.off, "unexpected %s after top-level value", d.describe())
+	}
+	foot := d.takeComments()
+
+	// Attach comments to the first and last fields, if any, so that they are
+	// retained when the struct is converted to a file.
+	first, last := ast.Node(expr), ast.Node(expr)
+	if s, ok := expr.(*ast.StructLit); ok && len(s.Elts) > 0 {
+		first, last = s.Elts[0], s.Elts[len(s.Elts)-1]
+		if cgs := ast.Comments(first); len(cgs) > 0 {
+			// The comment will start the file.
+			c := cgs[0].List[0]
+			c.Slash = c.Slash.WithRel(token.NoRelPos)
+		}
+	}
+	if head != nil {
+		ast.SetComments(first, append([]*ast.CommentGroup{head}, ast.Comments(first)...))
+	}
+	if foot != nil {
+		foot.Position = 100
+		ast.AddComment(last, foot)
+	}
+	return expr, nil
+}
+
+func (d *decoder) errorf(off int, format string, args ...interface{}) {
+	err := errors.Newf(d.file.Pos(off, token.NoRelPos),
+		"invalid %s for file %q: %s",
+		d.format(), d.path, fmt.Sprintf(format, args...))
+	panic(bailout{err})
+}
+
+// describe returns a description of the character at the current offset for
+// use in error messages.
+func (d *decoder) describe() string {
+	if d.off >= len(d.src) {
+		return "end of input"
+	}
+	r, _ := utf8.DecodeRune(d.src[d.off:])
+	return strconv.QuoteRune(r)
+}
+
+func (d *decoder) line(off int) int {
+	return d.file.Position(d.file.Pos(off, token.NoRelPos)).Line
+}
+
+// pos returns the position for a token at the given offset, with a relative
+// position reflecting its placement relative to the previous token.
+func (d *decoder) pos(off int) token.Pos {
+	line := d.line(off)
+	rel := token.Blank
+	switch {
+	case d.prevLine == 0:
+		rel = token.NoRelPos
+	case line-d.prevLine >= 2:
+		rel = token.NewSection
+	case line-d.prevLine == 1:
+		rel = token.Newline
+	case d.open:
+		rel = token.NoRelPos
+	}
+	d.prevLine = line
+	d.open = false
+	return d.file.Pos(off, rel)
+}
+
+func (d *decoder) peek() byte {
+	if d.off >= len(d.src) {
+		return 0
+	}
+	return d.src[d.off]
+}
+
+func (d *decoder) expect(ch byte) int {
+	if d.peek() != ch {
+		d.errorf(d.off, "expected %q, found %s", ch, d.describe())
+	}
+	d.off++
+	return d.off - 1
+}
+
+// skipSpace skips whitespace and records any comments.
+func (d *decoder) skipSpace() {
+	for d.off < len(d.src) {
+		switch d.src[d.off] {
+		case ' ', '\t', '\n', '\r':
+			d.off++
+			continue
+		case '/':
+		default:
+			if d.cfg.JSONC {
+				return
+			}
+			r, size := utf8.DecodeRune(d.src[d.off:])
+			if !unicode.IsSpace(r) && r != '\ufeff' {
+				return
+			}
+			d.off += size
+			continue
+		}
+
+		start := d.off
+		switch {
+		case strings.HasPrefix(string(d.src[d.off:]), "//"):
+			end := d.off
+			for end < len(d.src) && d.src[end] != '\n' {
+				end++
+			}
+			text := strings.TrimRight(string(d.src[d.off+2:end]), "\r")
+			d.addComment(start, start, text)
+			d.off = end
+
+		case strings.HasPrefix(string(d.src[d.off:]), "/*"):
+			i := strings.Index(string(d.src[d.off+2:]), "*/")
+			if i < 0 {
+				d.errorf(start, "comment not terminated")
+			}
+			end := d.off + 2 + i
+			lines := strings.Split(string(d.src[d.off+2:end]), "\n")
+			for i, text := range lines {
+				text = strings.TrimRight(text, "\r \t")
+				if i > 0 {
+					// Strip the decoration commonly used for block comments.
+					text = strings.TrimLeft(text, " \t")
+					text = strings.TrimPrefix(text, "*")
+				}
+				if strings.TrimSpace(text) == "" &&
+					(i == 0 || i == len(lines)-1) && len(lines) > 1 {
+					continue
+				}
+				d.addComment(start, end, text)
+			}
+			d.off = end + 2
+
+		default:
+			return
+		}
+	}
+}
+
+func (d *decoder) addComment(start, end int, text string) {
+	if text != "" && text[0] != ' ' && text[0] != '\t' {
+		text = " " + text
+	}
+	c := comment{
+		c:    &ast.Comment{Slash: d.pos(start), Text: "//" + text},
+		line: d.line(start),
+		end:  d.line(end),
+	}
+	d.prevLine = c.end
+	d.comments = append(d.comments, c)
+}
+
+// takeComments returns all pending comments as a single group.
+func (d *decoder) takeComments() *ast.CommentGroup {
+	if len(d.comments) == 0 {
+		return nil
+	}
+	cg := &ast.CommentGroup{}
+	for _, c := range d.comments {
+		cg.List = append(cg.List, c.c)
+	}
+	cg.Doc = d.comments[len(d.comments)-1].end+1 >= d.line(d.off)
+	d.comments = d.comments[:0]
+	return cg
+}
+
+// attachLineComment attaches the pending comment on the given line, if any,
+// to n as a line comment. This is only done if no other token follows on that
+// line, as a line comment extends to the end of the line.
+func (d *decoder) attachLineComment(n ast.Node, line int) {
+	if len(d.comments) == 0 || d.comments[0].line != line ||
+		d.comments[0].end == d.line(d.off) {
+		return
+	}
+	n.AddComment(&ast.CommentGroup{
+		Line:     true,
+		Position: 10,
+		List:     []*ast.Comment{d.comments[0].c},
+	})
+	d.comments = d.comments[1:]
+}
+
+// elements parses the elements of an object or array up to the closing
+// character, calling elem to parse each element. It returns the offset of
+// the closing character and, if there are no elements, any comments found.
+func (d *decoder) elements(close byte, elem func() ast.Node) (int, *ast.CommentGroup) {
+	var last ast.Node
+	for {
+		d.skipSpace()
+		if d.peek() == close {
+			break
+		}
+		doc := d.takeComments()
+		n := elem()
+		if doc != nil {
+			ast.AddComment(n, doc)
+		}
+		line := d.line(d.off - 1)
+
+		d.skipSpace()
+		switch d.peek() {
+		case ',':
+			d.off++
+			d.skipSpace()
+		case close:
+		default:
+			d.errorf(d.off, "expected ',' or %q, found %s", close, d.describe())
+		}
+		if f, ok := n.(*ast.Field); ok {
+			d.attachLineComment(f.Value, line)
+		} else {
+			d.attachLineComment(n, line)
+		}
+		last = n
+	}
+	foot := d.takeComments()
+	if foot != nil && last != nil {
+		foot.Position = 100
+		ast.AddComment(last, foot)
+		foot = nil
+	}
+	return d.expect(close), foot
+}
+
+func (d *decoder) value() ast.Expr {
+	switch ch := d.peek(); {
+	case ch == '{':
+		s := &ast.StructLit{Lbrace: d.pos(d.off)}
+		d.off++
+		d.open = true
+		end, cg := d.elements('}', func() ast.Node {
+			f := d.field()
+			s.Elts = append(s.Elts, f)
+			return f
+		})
+		if cg != nil {
+			cg.Position = 1
+			s.AddComment(cg)
+		}
+		s.Rbrace = d.pos(end)
+		if s.Rbrace.RelPos() == token.Blank {
+			s.Rbrace = s.Rbrace.WithRel(token.NoRelPos)
+		}
+		return s
+
+	case ch == '[':
+		l := &ast.ListLit{Lbrack: d.pos(d.off)}
+		d.off++
+		d.open = true
+		end, cg := d.elements(']', func() ast.Node {
+			x := d.value()
+			l.Elts = append(l.Elts, x)
+			return x
+		})
+		if cg != nil {
+			cg.Position = 1
+			l.AddComment(cg)
+		}
+		l.Rbrack = d.pos(end)
+		if l.Rbrack.RelPos() == token.Blank {
+			l.Rbrack = l.Rbrack.WithRel(token.NoRelPos)
+		}
+		return l
+
+	case ch == '"' || ch == '\'' && !d.cfg.JSONC:
+		pos := d.pos(d.off)
+		s := d.string()
+		d.prevLine = d.line(d.off - 1)
+		return &ast.BasicLit{
+			ValuePos: pos,
+			Kind:     token.STRING,
+			Value:    literal.String.Quote(s),
+		}
+
+	case ch == '-' || ch == '+' || ch == '.' || '0' <= ch && ch <= '9':
+		return d.number()
+
+	case isIdentStart(ch):
+		start := d.off
+		pos := d.pos(start)
+		switch name := d.ident(); name {
+		case "true":
+			return &ast.BasicLit{ValuePos: pos, Kind: token.TRUE, Value: name}
+		case "false":
+			return &ast.BasicLit{ValuePos: pos, Kind: token.FALSE, Value: name}
+		case "null":
+			return &ast.BasicLit{ValuePos: pos, Kind: token.NULL, Value: name}
+		case "Infinity", "NaN":
+			if !d.cfg.JSONC {
+				d.errorf(start, "cannot represent %s in CUE", name)
+			}
+		}
+		d.errorf(start, "unexpected identifier %q", string(d.src[start:d.off]))
+	}
+	d.errorf(d.off, "unexpected %s", d.describe())
+	return nil
+}
+
+func (d *decoder) field() *ast.Field {
+	start := d.off
+	var name string
+	pos := d.pos(start)
+	switch ch := d.peek(); {
+	case ch == '"' || ch == '\'' && !d.cfg.JSONC:
+		name = d.string()
+	case isIdentStart(ch) && !d.cfg.JSONC:
+		name = d.ident()
+	default:
+		d.errorf(d.off, "expected object key, found %s", d.describe())
+	}
+	d.skipSpace()
+	f := &ast.Field{Label: scalar.Label(name, pos)}
+	if cg := d.takeComments(); cg != nil {
+		cg.Position = 1
+		f.Label.AddComment(cg)
+	}
+	d.expect(':')
+	d.skipSpace()
+	doc := d.takeComments()
+	f.Value = d.value()
+	if doc != nil {
+		doc.Position = 0
+		ast.AddComment(f.Value, doc)
+	}
+	return f
+}
+
+func isIdentStart(ch byte) bool {
+	return ch == '_' || ch == '$' || 'a' <= ch && ch <= 'z' ||
+		'A' <= ch && ch <= 'Z' || ch >= utf8.RuneSelf
+}
+
+// ident scans an unquoted key or keyword.
+func (d *decoder) ident() string {
+	start := d.off
+	for d.off < len(d.src) {
+		r, size := utf8.DecodeRune(d.src[d.off:])
+		if r != '_' && r != '$' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		d.off += size
+	}
+	return string(d.src[start:d.off])
+}
+
+// string scans and unquotes a quoted string.
+func (d *decoder) string() string {
+	start := d.off
+	quote := d.src[d.off]
+	d.off++
+	for {
+		if d.off >= len(d.src) {
+			d.errorf(start, "string literal not terminated")
+		}
+		switch ch := d.src[d.off]; ch {
+		case quote:
+			d.off++
+			raw := d.src[start:d.off]
+			var s string
+			if quote == '"' && json.Unmarshal(raw, &s) == nil {
+				return s
+			}
+			if d.cfg.JSONC {
+				d.errorf(start, "invalid string literal %s", raw)
+			}
+			return d.unquote(start, string(raw[1:len(raw)-1]))
+		case '\\':
+			d.off += 2
+		case '\n', '\r':
+			d.errorf(d.off, "newline in string")
+		default:
+			d.off++
+		}
+	}
+}
+
+// unquote interprets the escape sequences allowed in JSON5 strings.
+func (d *decoder) unquote(start int, s string) string {
+	var b strings.Builder
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\\')
+		if i < 0 {
+			b.WriteString(s)
+			break
+		}
+		b.WriteString(s[:i])
+		s = s[i+1:]
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		switch r {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '0':
+			b.WriteByte(0)
+		case '\r':
+			s = strings.TrimPrefix(s, "\n")
+		case '\n', '\u2028', '\u2029':
+			// Line continuation.
+		case 'x', 'u':
+			n := 2
+			if r == 'u' {
+				n = 4
+			}
+			v, ok := hexValue(s, n)
+			if !ok {
+				d.errorf(start, "invalid escape sequence in string")
+			}
+			s = s[n:]
+			if r == 'u' && utf16.IsSurrogate(rune(v)) {
+				if lo, ok := hexValue(strings.TrimPrefix(s, `\u`), 4); ok &&
+					strings.HasPrefix(s, `\u`) {
+					s = s[6:]
+					v = uint64(utf16.DecodeRune(rune(v), rune(lo)))
+				}
+			}
+			b.WriteRune(rune(v))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func hexValue(s string, n int) (uint64, bool) {
+	if len(s) < n {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(s[:n], 16, 32)
+	return v, err == nil
+}
+
+// number scans a number, including an optional sign.
+func (d *decoder) number() ast.Expr {
+	start := d.off
+	pos := d.pos(start)
+	sign := d.peek()
+	if sign == '-' || sign == '+' {
+		if sign == '+' && d.cfg.JSONC {
+			d.errorf(start, "unexpected '+'")
+		}
+		d.off++
+	}
+	numStart := d.off
+	if isIdentStart(d.peek()) {
+		switch name := d.ident(); name {
+		case "Infinity", "NaN":
+			if !d.cfg.JSONC {
+				d.errorf(start, "cannot represent %s in CUE", name)
+			}
+		}
+		d.errorf(start, "invalid number %s", d.src[start:d.off])
+	}
+	for d.off < len(d.src) {
+		ch := d.src[d.off]
+		switch {
+		case '0' <= ch && ch <= '9', ch == '.', ch == 'x', ch == 'X',
+			'a' <= ch && ch <= 'f', 'A' <= ch && ch <= 'F':
+		case ch == '+' || ch == '-':
+			if prev := d.src[d.off-1]; prev != 'e' && prev != 'E' {
+				goto done
+			}
+		default:
+			goto done
+		}
+		d.off++
+	}
+done:
+	text := string(d.src[numStart:d.off])
+	lit := d.numLit(start, text)
+	lit.ValuePos = d.file.Pos(numStart, token.NoRelPos)
+	if sign != '-' {
+		lit.ValuePos = pos
+		return lit
+	}
+	return &ast.UnaryExpr{OpPos: pos, Op: token.SUB, X: lit}
+}
+
+func (d *decoder) numLit(start int, text string) *ast.BasicLit {
+	invalid := func() {
+		d.errorf(start, "invalid number %s", d.src[start:d.off])
+	}
+	if d.cfg.JSONC {
+		if !json.Valid([]byte(text)) {
+			invalid()
+		}
+	} else {
+		lower := strings.ToLower(text)
+		isHex := strings.HasPrefix(lower, "0x")
+		switch {
+		case isHex:
+		case strings.HasPrefix(text, "."):
+			text = "0" + text
+		case strings.HasSuffix(text, "."):
+			text += "0"
+		}
+		if !isHex {
+			text = strings.Replace(text, ".e", ".0e", 1)
+			text = strings.Replace(text, ".E", ".0E", 1)
+			if len(text) > 1 && text[0] == '0' && '0' <= text[1] && text[1] <= '9' {
+				invalid() // no octal
+			}
+		}
+	}
+	var info literal.NumInfo
+	if literal.ParseNum(text, &info) != nil {
+		invalid()
+	}
+	tok := token.FLOAT
+	if info.IsInt() {
+		tok = token.INT
+	}
+	return &ast.BasicLit{Kind: tok, Value: text}
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json5_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/encoding/json5"
+	"cuelang.org/go/internal"
+)
+
+func TestExtract(t *testing.T) {
+	testCases := []struct {
+		name  string
+		in    string
+		jsonc bool
+		want  string
+	}{{
+		name:  "jsonc",
+		jsonc: true,
+		in: `// Settings.
+{
+	/* Size in
+	 * pixels.
+	 */
+	"size": 14, // default
+
+	"list": [1, 2,],
+	"empty": {
+		// nothing
+	},
+}`,
+		want: `// Settings.
+
+// Size in
+// pixels.
+size: 14 // default
+
+list: [1, 2]
+empty: {
+	// nothing
+}
+`,
+	}, {
+		name: "json5",
+		in: `{
+	unquoted: 'single "quoted"',
+	hex: 0x1F, lead: .5, trail: 5., plus: +1, neg: -2,
+	escapes: '\x41é\
+b',
+}`,
+		want: `unquoted: "single \"quoted\""
+hex:      0x1F, lead: 0.5, trail: 5.0, plus: 1, neg: -2
+escapes:  "Aéb"
+`,
+	}, {
+		name: "list",
+		in:   `[1, /* two */ 2]`,
+		want: `[1, // two
+	2]
+`,
+	}, {
+		name:  "json5 in jsonc",
+		jsonc: true,
+		in:    "{\n\ta: 1\n}",
+		want: `invalid JSONC for file "test": expected object key, found 'a':
+    test:2:2
+`,
+	}, {
+		name: "infinity",
+		in:   `{"a": -Infinity}`,
+		want: `invalid JSON5 for file "test": cannot represent Infinity in CUE:
+    test:1:7
+`,
+	}, {
+		name: "unterminated comment",
+		in:   `{"a": 1} /* `,
+		want: `invalid JSON5 for file "test": comment not terminated:
+    test:1:10
+`,
+	}, {
+		name: "garbage",
+		in:   `{"a": 1} 2`,
+		want: `invalid JSON5 for file "test": unexpected '2' after top-level value:
+    test:1:10
+`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &json5.Config{JSONC: tc.jsonc}
+			d := json5.NewDecoder("test", strings.NewReader(tc.in), cfg)
+			expr, err := d.Extract()
+			var got string
+			if err != nil {
+				got = errors.Details(err, nil)
+			} else {
+				b, err := format.Node(internal.ToFile(expr))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = string(b)
+				if _, err := d.Extract(); err != io.EOF {
+					t.Errorf("got %v; want io.EOF", err)
+				}
+			}
+			if got != tc.want {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,549 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package msgpack converts MessagePack to and from CUE.
+//
+// The bin family maps to CUE bytes and the str family to CUE strings. Maps map
+// to structs; integer keys are converted to their decimal representation,
+// other non-string keys are not supported. MessagePack cannot represent
+// integers outside the 64-bit range, nor can CUE represent Infinity and NaN;
+// both result in an error. Extension types, including timestamps, are not
+// supported.
+//
+// A stream of values is decoded as consecutive MessagePack objects.
+package msgpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"unicode/utf8"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/literal"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/encoding/scalar"
+)
+
+// Format codes.
+const (
+	codeNil     = 0xc0
+	codeFalse   = 0xc2
+	codeTrue    = 0xc3
+	codeBin8    = 0xc4
+	codeBin16   = 0xc5
+	codeBin32   = 0xc6
+	codeFloat32 = 0xca
+	codeFloat64 = 0xcb
+	codeUint8   = 0xcc
+	codeUint16  = 0xcd
+	codeUint32  = 0xce
+	codeUint64  = 0xcf
+	codeInt8    = 0xd0
+	codeInt16   = 0xd1
+	codeInt32   = 0xd2
+	codeInt64   = 0xd3
+	codeStr8    = 0xd9
+	codeStr16   = 0xda
+	codeStr32   = 0xdb
+	codeArray16 = 0xdc
+	codeArray32 = 0xdd
+	codeMap16   = 0xde
+	codeMap32   = 0xdf
+
+	fixMap   = 0x80
+	fixArray = 0x90
+	fixStr   = 0xa0
+
+	// maxDepth limits the nesting of arrays and maps.
+	maxDepth = 1000
+)
+
+// Encode returns the MessagePack encoding of v, which must be concrete.
+func Encode(v cue.Value) ([]byte, error) {
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		return nil, err
+	}
+	e := &encoder{}
+	if err := e.encode(v); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+// write writes code followed by n in big-endian order using size bytes.
+func (e *encoder) write(code byte, n uint64, size int) {
+	var b [9]byte
+	b[0] = code
+	switch size {
+	case 1:
+		b[1] = byte(n)
+	case 2:
+		binary.BigEndian.PutUint16(b[1:], uint16(n))
+	case 4:
+		binary.BigEndian.PutUint32(b[1:], uint32(n))
+	case 8:
+		binary.BigEndian.PutUint64(b[1:], n)
+	}
+	e.buf.Write(b[:1+size])
+}
+
+// length writes the header of a value of variable length n, using the fixed
+// format for lengths up to fixMax, and the 8, 16 or 32-bit format otherwise.
+// A zero code indicates the format is not available.
+func (e *encoder) length(v cue.Value, n int, fix byte, fixMax int, code8, code16, code32 byte) error {
+	switch {
+	case n <= fixMax:
+		e.buf.WriteByte(fix | byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		e.write(code8, uint64(n), 1)
+	case n <= math.MaxUint16:
+		e.write(code16, uint64(n), 2)
+	case n <= math.MaxUint32:
+		e.write(code32, uint64(n), 4)
+	default:
+		return errors.Newf(v.Pos(), "msgpack: %v: length %d too large", v.Path(), n)
+	}
+	return nil
+}
+
+func (e *encoder) encode(v cue.Value) error {
+	switch v.Kind() {
+	case cue.NullKind:
+		e.buf.WriteByte(codeNil)
+
+	case cue.BoolKind:
+		b, err := v.Bool()
+		if err != nil {
+			return err
+		}
+		if b {
+			e.buf.WriteByte(codeTrue)
+		} else {
+			e.buf.WriteByte(codeFalse)
+		}
+
+	case cue.IntKind:
+		n, err := v.Int(nil)
+		if err != nil {
+			return err
+		}
+		switch {
+		case n.IsInt64():
+			e.int(n.Int64())
+		case n.IsUint64():
+			e.write(codeUint64, n.Uint64(), 8)
+		default:
+			return errors.Newf(v.Pos(),
+				"msgpack: %v: integer %v out of 64-bit range", v.Path(), n)
+		}
+
+	case cue.FloatKind:
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		e.write(codeFloat64, math.Float64bits(f), 8)
+
+	case cue.StringKind:
+		s, err := v.String()
+		if err != nil {
+			return err
+		}
+		if err := e.length(v, len(s), fixStr, 31, codeStr8, codeStr16, codeStr32); err != nil {
+			return err
+		}
+		e.buf.WriteString(s)
+
+	case cue.BytesKind:
+		b, err := v.Bytes()
+		if err != nil {
+			return err
+		}
+		if err := e.length(v, len(b), 0, -1, codeBin8, codeBin16, codeBin32); err != nil {
+			return err
+		}
+		e.buf.Write(b)
+
+	case cue.ListKind:
+		iter, err := v.List()
+		if err != nil {
+			return err
+		}
+		var a []cue.Value
+		for iter.Next() {
+			a = append(a, iter.Value())
+		}
+		if err := e.length(v, len(a), fixArray, 15, 0, codeArray16, codeArray32); err != nil {
+			return err
+		}
+		for _, x := range a {
+			if err := e.encode(x); err != nil {
+				return err
+			}
+		}
+
+	case cue.StructKind:
+		iter, err := v.Fields()
+		if err != nil {
+			return err
+		}
+		var names []string
+		var a []cue.Value
+		for iter.Next() {
+			names = append(names, iter.Label())
+			a = append(a, iter.Value())
+		}
+		if err := e.length(v, len(a), fixMap, 15, 0, codeMap16, codeMap32); err != nil {
+			return err
+		}
+		for i, x := range a {
+			name := names[i]
+			if err := e.length(x, len(name), fixStr, 31, codeStr8, codeStr16, codeStr32); err != nil {
+				return err
+			}
+			e.buf.WriteString(name)
+			if err := e.encode(x); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return errors.Newf(v.Pos(), "msgpack: cannot encode value of kind %s", v.Kind())
+	}
+	return nil
+}
+
+// int writes n using the smallest format.
+func (e *encoder) int(n int64) {
+	switch {
+	case n >= 0 && n <= 0x7f, n < 0 && n >= -32:
+		e.buf.WriteByte(byte(n))
+	case n >= 0 && n <= math.MaxUint8:
+		e.write(codeUint8, uint64(n), 1)
+	case n >= 0 && n <= math.MaxUint16:
+		e.write(codeUint16, uint64(n), 2)
+	case n >= 0 && n <= math.MaxUint32:
+		e.write(codeUint32, uint64(n), 4)
+	case n >= 0:
+		e.write(codeUint64, uint64(n), 8)
+	case n >= math.MinInt8:
+		e.write(codeInt8, uint64(n), 1)
+	case n >= math.MinInt16:
+		e.write(codeInt16, uint64(n), 2)
+	case n >= math.MinInt32:
+		e.write(codeInt32, uint64(n), 4)
+	default:
+		e.write(codeInt64, uint64(n), 8)
+	}
+}
+
+// A Decoder converts a stream of MessagePack objects to CUE.
+type Decoder struct {
+	path string
+	r    *bufio.Reader
+	file *token.File
+
+	off   int
+	depth int
+}
+
+// NewDecoder configures a MessagePack decoder. The path is used to associate
+// position information with each node, which reflects the byte offset within
+// the input.
+func NewDecoder(path string, src io.Reader) *Decoder {
+	return &Decoder{
+		path: path,
+		r:    bufio.NewReader(src),
+		file: token.NewFile(path, -1, math.MaxInt32),
+	}
+}
+
+// Extract converts the next value in the input to a CUE ast. It returns io.EOF
+// if the input has been exhausted.
+func (d *Decoder) Extract() (ast.Expr, error) {
+	if _, err := d.r.Peek(1); err == io.EOF {
+		return nil, io.EOF
+	}
+	return d.decode()
+}
+
+func (d *Decoder) pos(off int) token.Pos {
+	return d.file.Pos(off, token.NoRelPos)
+}
+
+func (d *Decoder) errorf(off int, format string, args ...interface{}) error {
+	return errors.Newf(d.pos(off), "invalid MessagePack for file %q: "+format,
+		append([]interface{}{d.path}, args...)...)
+}
+
+func (d *Decoder) readFull(n uint64) ([]byte, error) {
+	if n > math.MaxInt32 {
+		return nil, d.errorf(d.off, "length %d too large", n)
+	}
+	// Avoid large allocations for bogus lengths by growing the buffer as
+	// the data comes in.
+	buf := &bytes.Buffer{}
+	m, err := io.CopyN(buf, d.r, int64(n))
+	d.off += int(m)
+	if err == io.EOF {
+		return nil, d.errorf(d.off, "unexpected end of input")
+	}
+	return buf.Bytes(), err
+}
+
+// uint reads a big-endian unsigned integer of the given number of bytes.
+func (d *Decoder) uint(size int) (uint64, error) {
+	p, err := d.readFull(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, c := range p {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+func (d *Decoder) decode() (ast.Expr, error) {
+	start := d.off
+	pos := d.pos(start)
+	p, err := d.readFull(1)
+	if err != nil {
+		return nil, err
+	}
+	code := p[0]
+
+	switch {
+	case code <= 0x7f:
+		return intLit(pos, int64(code)), nil
+	case code >= 0xe0:
+		return intLit(pos, int64(int8(code))), nil
+	case code&0xf0 == fixMap:
+		return d.mapping(start, uint64(code&0x0f))
+	case code&0xf0 == fixArray:
+		return d.array(start, uint64(code&0x0f))
+	case code&0xe0 == fixStr:
+		return d.str(start, uint64(code&0x1f))
+	}
+
+	switch code {
+	case codeNil:
+		return &ast.BasicLit{ValuePos: pos, Kind: token.NULL, Value: "null"}, nil
+	case codeFalse:
+		return &ast.BasicLit{ValuePos: pos, Kind: token.FALSE, Value: "false"}, nil
+	case codeTrue:
+		return &ast.BasicLit{ValuePos: pos, Kind: token.TRUE, Value: "true"}, nil
+
+	case codeUint8, codeUint16, codeUint32, codeUint64:
+		n, err := d.uint(1 << (code - codeUint8))
+		if err != nil {
+			return nil, err
+		}
+		return &ast.BasicLit{
+			ValuePos: pos,
+			Kind:     token.INT,
+			Value:    strconv.FormatUint(n, 10),
+		}, nil
+
+	case codeInt8, codeInt16, codeInt32, codeInt64:
+		size := 1 << (code - codeInt8)
+		n, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign-extend.
+		shift := 64 - 8*size
+		return intLit(pos, int64(n<<shift)>>shift), nil
+
+	case codeFloat32, codeFloat64:
+		var f float64
+		if code == codeFloat32 {
+			n, err := d.uint(4)
+			if err != nil {
+				return nil, err
+			}
+			f = float64(math.Float32frombits(uint32(n)))
+		} else {
+			n, err := d.uint(8)
+			if err != nil {
+				return nil, err
+			}
+			f = math.Float64frombits(n)
+		}
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, d.errorf(start, "cannot represent %v in CUE", f)
+		}
+		return floatLit(pos, f), nil
+
+	case codeStr8, codeStr16, codeStr32:
+		n, err := d.uint(1 << (code - codeStr8))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(start, n)
+
+	case codeBin8, codeBin16, codeBin32:
+		n, err := d.uint(1 << (code - codeBin8))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.readFull(n)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.BasicLit{
+			ValuePos: pos,
+			Kind:     token.STRING,
+			Value:    literal.Bytes.Quote(string(b)),
+		}, nil
+
+	case codeArray16, codeArray32:
+		n, err := d.uint(2 << (code - codeArray16))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(start, n)
+
+	case codeMap16, codeMap32:
+		n, err := d.uint(2 << (code - codeMap16))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapping(start, n)
+	}
+	return nil, d.errorf(start, "unsupported format code 0x%x", code)
+}
+
+func (d *Decoder) str(start int, n uint64) (ast.Expr, error) {
+	b, err := d.readFull(n)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(b) {
+		return nil, d.errorf(start, "invalid UTF-8 in string")
+	}
+	return &ast.BasicLit{
+		ValuePos: d.pos(start),
+		Kind:     token.STRING,
+		Value:    literal.String.Quote(string(b)),
+	}, nil
+}
+
+func (d *Decoder) push(off int) error {
+	d.depth++
+	if d.depth > maxDepth {
+		return d.errorf(off, "exceeded max depth of %d", maxDepth)
+	}
+	return nil
+}
+
+func (d *Decoder) array(start int, n uint64) (ast.Expr, error) {
+	if err := d.push(start); err != nil {
+		return nil, err
+	}
+	defer func() { d.depth-- }()
+	list := &ast.ListLit{Lbrack: d.pos(start)}
+	for i := uint64(0); i < n; i++ {
+		x, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		list.Elts = append(list.Elts, x)
+	}
+	return list, nil
+}
+
+func (d *Decoder) mapping(start int, n uint64) (ast.Expr, error) {
+	if err := d.push(start); err != nil {
+		return nil, err
+	}
+	defer func() { d.depth-- }()
+	s := &ast.StructLit{Lbrace: d.pos(start)}
+	for i := uint64(0); i < n; i++ {
+		off := d.off
+		key, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		var name string
+		switch x := key.(type) {
+		case *ast.BasicLit:
+			if x.Kind == token.STRING && x.Value[0] == '"' {
+				name, _ = literal.Unquote(x.Value)
+			} else if x.Kind == token.INT {
+				name = x.Value
+			}
+		case *ast.UnaryExpr:
+			if lit := x.X.(*ast.BasicLit); lit.Kind == token.INT {
+				name = "-" + lit.Value
+			}
+		}
+		if name == "" && !isEmptyString(key) {
+			return nil, d.errorf(off, "unsupported map key")
+		}
+		x, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		s.Elts = append(s.Elts, &ast.Field{
+			Label: scalar.Label(name, d.pos(off).WithRel(token.Newline)),
+			Value: x,
+		})
+	}
+	return s, nil
+}
+
+func isEmptyString(x ast.Expr) bool {
+	lit, ok := x.(*ast.BasicLit)
+	return ok && lit.Kind == token.STRING && lit.Value == `""`
+}
+
+func intLit(pos token.Pos, n int64) ast.Expr {
+	if n >= 0 {
+		return &ast.BasicLit{ValuePos: pos, Kind: token.INT, Value: strconv.FormatInt(n, 10)}
+	}
+	return &ast.UnaryExpr{
+		OpPos: pos,
+		Op:    token.SUB,
+		X: &ast.BasicLit{
+			Kind:  token.INT,
+			Value: new(big.Int).Neg(big.NewInt(n)).String(),
+		},
+	}
+}
+
+func floatLit(pos token.Pos, f float64) ast.Expr {
+	neg := math.Signbit(f)
+	s := strconv.FormatFloat(math.Abs(f), 'g', -1, 64)
+	if !bytes.ContainsAny([]byte(s), ".e") {
+		s += ".0"
+	}
+	lit := &ast.BasicLit{ValuePos: pos, Kind: token.FLOAT, Value: s}
+	if !neg {
+		return lit
+	}
+	lit.ValuePos = token.NoPos
+	return &ast.UnaryExpr{OpPos: pos, Op: token.SUB, X: lit}
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package msgpack_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/encoding/msgpack"
+)
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{"00", "0"},
+		{"7f", "127"},
+		{"ff", "-1"},
+		{"e0", "-32"},
+		{"cc80", "128"},
+		{"cdffff", "65535"},
+		{"cfffffffffffffffff", "18446744073709551615"},
+		{"d080", "-128"},
+		{"d1ff00", "-256"},
+		{"d38000000000000000", "-9223372036854775808"},
+		{"ca3fc00000", "1.5"},
+		{"cbc010666666666666", "-4.1"},
+		{"c0", "null"},
+		{"c3", "true"},
+		{"a3666f6f", `"foo"`},
+		{"d903666f6f", `"foo"`},
+		{"c4020102", `'\x01\x02'`},
+		{"93010203", "[1, 2, 3]"},
+		{"dc0002c2c3", "[false, true]"},
+		{"82a16101a162920203", "{\n\ta: 1\n\tb: [2, 3]\n}"},
+		{"810102", "{\n\t\"1\": 2\n}"},
+		{"81ff02", "{\n\t\"-1\": 2\n}"},
+		{"0102", "1\n2"},
+
+		{"cb7ff0000000000000", `invalid MessagePack for file "test": cannot represent +Inf in CUE`},
+		{"a2", `invalid MessagePack for file "test": unexpected end of input`},
+		{"81c401000", `invalid MessagePack for file "test": unsupported map key`},
+		{"81ca3fc0000001", `invalid MessagePack for file "test": unsupported map key`},
+		{"81cbc01066666666666601", `invalid MessagePack for file "test": unsupported map key`},
+		{"d4", `invalid MessagePack for file "test": unsupported format code 0xd4`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			in := tc.in
+			if len(in)%2 == 1 {
+				in += "0"
+			}
+			b, err := hex.DecodeString(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := msgpack.NewDecoder("test", bytes.NewReader(b))
+			var a []string
+			for {
+				expr, err := d.Extract()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					a = append(a, errors.Details(err, nil))
+					break
+				}
+				out, err := format.Node(expr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				a = append(a, string(out))
+			}
+			got := strings.Join(a, "\n")
+			if !strings.HasPrefix(got, tc.want) {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{"0", "00"},
+		{"-32", "e0"},
+		{"-33", "d0df"},
+		{"200", "ccc8"},
+		{"70000", "ce00011170"},
+		{"-70000", "d2fffeee90"},
+		{"18446744073709551615", "cfffffffffffffffff"},
+		{"18446744073709551616", "error"},
+		{"1.5", "cb3ff8000000000000"},
+		{"false", "c2"},
+		{"null", "c0"},
+		{`'\x01\x02'`, "c4020102"},
+		{`"foo"`, "a3666f6f"},
+		{`"` + strings.Repeat("x", 32) + `"`, "d920" + strings.Repeat("78", 32)},
+		{`[1, [2, 3]]`, "9201920203"},
+		{`{a: 1, b: [2, 3], #c: 4}`, "82a16101a162920203"},
+		{`{a: int}`, "error"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			v := cuecontext.New().CompileString(tc.in)
+			b, err := msgpack.Encode(v)
+			got := hex.EncodeToString(b)
+			if err != nil {
+				got = "error"
+			}
+			if got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package properties converts Java properties files to and from CUE.
+//
+// The format is that of java.util.Properties: a key is separated from its
+// value by '=', ':' or white space, lines starting with '#' or '!' are
+// comments, a backslash at the end of a line continues the entry on the next
+// line, and escape sequences, including \uXXXX, are interpreted. The input
+// is read as UTF-8.
+//
+// Without a schema, all values map to strings. With a schema, values for
+// which the schema does not allow a string are converted to numbers, booleans
+// or null, as appropriate. With the Nest option, dotted keys map to nested
+// fields.
+//
+// Encoding is the reverse. All values must be scalars and structs are only
+// allowed with the Nest option. Non-ASCII characters are written as \uXXXX
+// escape sequences.
+package properties
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/internal/encoding/flat"
+)
+
+// Config defines options for decoding and encoding properties files.
+type Config struct {
+	// Nest indicates that keys are split at dots to map to nested fields.
+	Nest bool
+
+	// Schema, if it exists, is used to convert values to kinds other than
+	// string. It is only used for decoding.
+	Schema cue.Value
+}
+
+// A Decoder converts a properties file to CUE.
+type Decoder struct {
+	path string
+	r    io.Reader
+	cfg  Config
+	done bool
+}
+
+// NewDecoder configures a decoder. The path is used to associate position
+// information with each node. The config may be nil.
+func NewDecoder(path string, src io.Reader, c *Config) *Decoder {
+	d := &Decoder{path: path, r: src}
+	if c != nil {
+		d.cfg = *c
+	}
+	return d
+}
+
+// Extract converts the input to a CUE struct. As the input holds a single
+// value, it returns io.EOF on any subsequent call.
+func (d *Decoder) Extract() (ast.Expr, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	d.done = true
+	b, err := io.ReadAll(d.r)
+	if err != nil {
+		return nil, err
+	}
+	s := flat.NewSource("properties", d.path, b)
+
+	var entries []flat.Entry
+	err = s.Lines(func(off int, line []byte) (int, error) {
+		text := strings.TrimLeft(string(line), " \t\f")
+		off += len(line) - len(text)
+		switch {
+		case text == "":
+			return -1, nil
+		case text[0] == '#', text[0] == '!':
+			s.AddComment(off, text[1:])
+			return -1, nil
+		}
+		comments := s.Doc(off)
+		pos := s.Pos(off)
+
+		// Join continuation lines.
+		next := off + len(text) + 1
+		for continued(text) && next < len(s.Data) {
+			end := bytes.IndexByte(s.Data[next:], '\n')
+			if end < 0 {
+				end = len(s.Data)
+			} else {
+				end += next
+			}
+			cont := strings.TrimRight(string(s.Data[next:end]), "\r")
+			text = text[:len(text)-1] + strings.TrimLeft(cont, " \t\f")
+			next = end + 1
+		}
+		text = strings.TrimSuffix(text, "\r")
+		s.End(next - 1)
+
+		i := 0
+		for ; i < len(text); i++ {
+			if c := text[i]; c == '\\' {
+				i++
+			} else if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+				break
+			}
+		}
+		if i > len(text) {
+			i = len(text)
+		}
+		rawKey := text[:i]
+		rest := strings.TrimLeft(text[i:], " \t\f")
+		if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+			rest = strings.TrimLeft(rest[1:], " \t\f")
+		}
+		key, err := unescape(rawKey)
+		if err != nil {
+			return 0, s.Errorf(off, "%v", err)
+		}
+		value, err := unescape(rest)
+		if err != nil {
+			return 0, s.Errorf(off, "%v", err)
+		}
+
+		path := []string{key}
+		if d.cfg.Nest {
+			path = strings.Split(key, ".")
+		}
+		entries = append(entries, flat.Entry{
+			Path:     path,
+			Value:    value,
+			Pos:      pos,
+			ValuePos: s.ValuePos(off + len(rawKey)),
+			Comments: comments,
+		})
+		return next, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Build(entries, d.cfg.Schema)
+}
+
+// continued reports whether line ends with an odd number of backslashes.
+func continued(line string) bool {
+	line = strings.TrimSuffix(line, "\r")
+	n := len(line) - len(strings.TrimRight(line, `\`))
+	return n%2 == 1
+}
+
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 >= len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("malformed \\uxxxx encoding")
+			}
+			v, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\uxxxx encoding")
+			}
+			i += 4
+			r := rune(v)
+			if utf16.IsSurrogate(r) && strings.HasPrefix(s[i+1:], `\u`) && i+7 <= len(s) {
+				if lo, err := strconv.ParseUint(s[i+3:i+7], 16, 16); err == nil {
+					r = utf16.DecodeRune(r, rune(lo))
+					i += 6
+				}
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// Encode returns the properties encoding of v, which must be a struct. Only
+// the Nest option of c is used. The config may be nil.
+func Encode(v cue.Value, c *Config) ([]byte, error) {
+	depth := 0
+	if c != nil && c.Nest {
+		depth = -1
+	}
+	pairs, err := flat.Flatten(v, depth, "properties")
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, p := range pairs {
+		escape(&buf, strings.Join(p.Path, "."), true)
+		buf.WriteByte('=')
+		escape(&buf, p.Value, false)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// escape writes s with special characters escaped. All spaces are escaped
+// in keys, but only leading spaces in values.
+func escape(w *bytes.Buffer, s string, key bool) {
+	for i, r := range s {
+		switch r {
+		case ' ':
+			if key || i == 0 {
+				w.WriteByte('\\')
+			}
+			w.WriteByte(' ')
+		case '\t':
+			w.WriteString(`\t`)
+		case '\n':
+			w.WriteString(`\n`)
+		case '\r':
+			w.WriteString(`\r`)
+		case '\f':
+			w.WriteString(`\f`)
+		case '=', ':', '#', '!', '\\':
+			w.WriteByte('\\')
+			w.WriteRune(r)
+		default:
+			if r < 0x20 || r > 0x7e {
+				for _, u := range utf16.Encode([]rune{r}) {
+					fmt.Fprintf(w, `\u%04X`, u)
+				}
+			} else {
+				w.WriteRune(r)
+			}
+		}
+	}
+}
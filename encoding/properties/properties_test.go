@@ -0,0 +1,140 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package properties_test
+
+import (
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/encoding/properties"
+	"cuelang.org/go/internal"
+)
+
+func TestExtract(t *testing.T) {
+	testCases := []struct {
+		name   string
+		in     string
+		nest   bool
+		schema string
+		want   string
+	}{{
+		name: "values",
+		in: `# Database.
+! legacy comment
+db.url = jdbc\:pg
+db.user:admin
+greeting   Hello \
+           World
+name=café 😀
+key\ with\ space = v
+empty
+`,
+		want: `// Database.
+// legacy comment
+"db.url":         "jdbc:pg"
+"db.user":        "admin"
+greeting:         "Hello World"
+name:             "café 😀"
+"key with space": "v"
+empty:            ""
+`,
+	}, {
+		name:   "schema nest",
+		nest:   true,
+		in:     "server.port=8080\nserver.debug=false\n",
+		schema: "server: {port: int, debug: bool}",
+		want: `server: {
+	port:  8080
+	debug: false
+}
+`,
+	}, {
+		name: "bad escape",
+		in:   `a=\u12`,
+		want: `invalid properties for file "test": malformed \uxxxx encoding:
+    test:1:1
+`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var schema cue.Value
+			if tc.schema != "" {
+				schema = cuecontext.New().CompileString(tc.schema)
+			}
+			cfg := &properties.Config{Nest: tc.nest, Schema: schema}
+			expr, err := properties.NewDecoder("test", strings.NewReader(tc.in), cfg).Extract()
+			var got string
+			if err != nil {
+				got = errors.Details(err, nil)
+			} else {
+				b, err := format.Node(internal.ToFile(expr))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = string(b)
+			}
+			if got != tc.want {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		nest bool
+		want string
+	}{{
+		name: "values",
+		in:   `{"db.url": "jdbc:pg", "a key": " x=y ", name: "café", n: 1.5}`,
+		want: `db.url=jdbc\:pg
+a\ key=\ x\=y 
+name=caf\u00E9
+n=1.5
+`,
+	}, {
+		name: "nest",
+		nest: true,
+		in:   `server: {port: 8080, tls: enabled: true}`,
+		want: "server.port=8080\nserver.tls.enabled=true\n",
+	}, {
+		name: "nested struct",
+		in:   `server: port: 8080`,
+		want: "properties: cannot encode nested struct server as a flat key",
+	}, {
+		name: "not a struct",
+		in:   `"x"`,
+		want: "properties: cannot encode value of kind string: must be a struct",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := cuecontext.New().CompileString(tc.in)
+			b, err := properties.Encode(v, &properties.Config{Nest: tc.nest})
+			got := string(b)
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tc.want {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,433 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package xml converts XML to and from CUE.
+//
+// XML does not have a data model that maps one-to-one to CUE. This package
+// uses the following mapping:
+//
+//   - A document maps to a struct with a single field named after the root
+//     element.
+//   - An element maps to a field named after its (prefixed) name.
+//   - An attribute maps to a field named after the attribute prefixed with
+//     a `$`. For instance, `<a id="x"/>` maps to `a: $id: "x"`.
+//   - The character data of an element maps to a `$text` field. Leading and
+//     trailing white space is removed.
+//   - An element without attributes and child elements maps to its character
+//     data directly. For instance, `<a>x</a>` maps to `a: "x"`.
+//   - An element that occurs more than once within the same parent maps to a
+//     list of all such elements.
+//
+// When decoding with a schema, an element for which the schema only allows a
+// list is always mapped to a list, even if it occurs only once. Similarly,
+// character data for which the schema only allows numbers or booleans is
+// converted accordingly. Without a schema, all character data maps to strings.
+//
+// Encoding is the reverse of this mapping. Numbers and booleans are encoded
+// as character data; null maps to an empty element.
+package xml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/encoding/scalar"
+)
+
+const (
+	// attrPrefix is the prefix used for fields representing attributes.
+	attrPrefix = "$"
+
+	// textField is the field used for character data.
+	textField = "$text"
+)
+
+// Config defines options for decoding XML.
+type Config struct {
+	// Schema, if it exists, is used to determine whether elements should be
+	// mapped to lists and how to interpret character data. It should
+	// describe the document, that is, a struct with the root element as
+	// its only field.
+	Schema cue.Value
+}
+
+// Extract parses XML-encoded data to a CUE expression, using path for
+// position information.
+func Extract(path string, data []byte) (ast.Expr, error) {
+	return NewDecoder(path, bytes.NewReader(data), nil).Extract()
+}
+
+// A Decoder converts an XML document to CUE.
+type Decoder struct {
+	path   string
+	src    io.Reader
+	schema cue.Value
+	done   bool
+}
+
+// NewDecoder configures an XML decoder. The path is used to associate position
+// information with each node. The config may be nil.
+func NewDecoder(path string, src io.Reader, c *Config) *Decoder {
+	d := &Decoder{path: path, src: src}
+	if c != nil {
+		d.schema = c.Schema
+	}
+	return d
+}
+
+// Extract converts the XML document to a CUE ast. An XML source holds a single
+// document: Extract returns io.EOF on any subsequent call.
+func (d *Decoder) Extract() (ast.Expr, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	d.done = true
+
+	b, err := ioutil.ReadAll(d.src)
+	if err != nil {
+		return nil, err
+	}
+	file := token.NewFile(d.path, -1, len(b))
+	file.SetLinesForContent(b)
+
+	p := &parser{file: file, dec: xml.NewDecoder(bytes.NewReader(b))}
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return d.toStruct([]*element{root}, d.schema), nil
+}
+
+// An element is the intermediate representation of an XML element.
+type element struct {
+	name     string
+	pos      token.Pos
+	attrs    []xml.Attr
+	text     strings.Builder
+	children []*element
+}
+
+type parser struct {
+	file *token.File
+	dec  *xml.Decoder
+}
+
+func (p *parser) pos() token.Pos {
+	return p.file.Pos(int(p.dec.InputOffset()), token.NoRelPos)
+}
+
+func (p *parser) errorf(pos token.Pos, format string, args ...interface{}) error {
+	return errors.Newf(pos, "xml: "+format, args...)
+}
+
+// parse reads the root element of a document.
+func (p *parser) parse() (*element, error) {
+	var stack []*element
+	var root *element
+	for {
+		pos := p.pos()
+		// RawToken leaves namespace prefixes intact, which allows them to
+		// be mapped to and from CUE field names unmodified.
+		tok, err := p.dec.RawToken()
+		if err == io.EOF {
+			if len(stack) > 0 {
+				return nil, p.errorf(pos, "unexpected EOF in element %q",
+					stack[len(stack)-1].name)
+			}
+			if root == nil {
+				return nil, p.errorf(pos, "no root element")
+			}
+			return root, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, pos, "invalid XML for file %q", p.file.Name())
+		}
+
+		switch x := tok.(type) {
+		case xml.StartElement:
+			e := &element{name: qualifiedName(x.Name), pos: pos, attrs: x.Attr}
+			switch {
+			case len(stack) > 0:
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, e)
+			case root != nil:
+				return nil, p.errorf(pos, "multiple root elements")
+			default:
+				root = e
+			}
+			stack = append(stack, e)
+
+		case xml.EndElement:
+			name := qualifiedName(x.Name)
+			if len(stack) == 0 || stack[len(stack)-1].name != name {
+				return nil, p.errorf(pos, "unexpected end element %q", name)
+			}
+			stack = stack[:len(stack)-1]
+
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(x)
+			} else if len(bytes.TrimSpace(x)) > 0 {
+				return nil, p.errorf(pos, "character data outside root element")
+			}
+		}
+	}
+}
+
+func qualifiedName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// toStruct converts the given elements to a struct. Elements of the same name
+// are grouped into a list. The schema, if it exists, describes the struct.
+func (d *Decoder) toStruct(elems []*element, schema cue.Value) *ast.StructLit {
+	s := &ast.StructLit{}
+	index := map[string]int{}
+	var groups [][]*element
+	for _, e := range elems {
+		i, ok := index[e.name]
+		if !ok {
+			i = len(groups)
+			index[e.name] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], e)
+	}
+
+	for _, g := range groups {
+		name := g[0].name
+		sub := scalar.Lookup(schema, name)
+		var value ast.Expr
+		if len(g) > 1 || isList(sub) {
+			elem := scalar.LookupIndex(sub, -1)
+			list := &ast.ListLit{}
+			for _, e := range g {
+				list.Elts = append(list.Elts, d.toValue(e, elem))
+			}
+			value = list
+		} else {
+			value = d.toValue(g[0], sub)
+		}
+		s.Elts = append(s.Elts, &ast.Field{
+			Label: label(name, g[0].pos),
+			Value: value,
+		})
+	}
+	return s
+}
+
+// toValue converts a single element to a CUE expression.
+func (d *Decoder) toValue(e *element, schema cue.Value) ast.Expr {
+	text := strings.TrimSpace(e.text.String())
+	isStruct := schema.Exists() && schema.IncompleteKind() == cue.StructKind
+	if len(e.attrs) == 0 && len(e.children) == 0 {
+		switch {
+		case isStruct && text == "":
+			return &ast.StructLit{Lbrace: e.pos}
+		case !isStruct || !scalar.Lookup(schema, textField).Exists():
+			return scalar.Decode(text, e.pos, schema)
+		}
+		// The schema describes the text as a field of a struct.
+	}
+
+	s := &ast.StructLit{Lbrace: e.pos}
+	for _, a := range e.attrs {
+		name := attrPrefix + qualifiedName(a.Name)
+		s.Elts = append(s.Elts, &ast.Field{
+			Label: label(name, e.pos),
+			Value: scalar.Decode(a.Value, e.pos, scalar.Lookup(schema, name)),
+		})
+	}
+	s.Elts = append(s.Elts, d.toStruct(e.children, schema).Elts...)
+	if text != "" {
+		s.Elts = append(s.Elts, &ast.Field{
+			Label: label(textField, e.pos),
+			Value: scalar.Decode(text, e.pos, scalar.Lookup(schema, textField)),
+		})
+	}
+	return s
+}
+
+// label returns the label for a field. Each field is placed on its own
+// line, as attributes share the position of their element.
+func label(name string, pos token.Pos) ast.Label {
+	return scalar.Label(name, pos.WithRel(token.Newline))
+}
+
+func isList(v cue.Value) bool {
+	return v.Exists() && v.IncompleteKind() == cue.ListKind
+}
+
+// Encode returns the XML encoding of v. The value v must be a struct with
+// a single regular field, which is mapped to the root element.
+func Encode(v cue.Value) ([]byte, error) {
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		return nil, err
+	}
+	iter, err := v.Fields()
+	if err != nil {
+		return nil, err
+	}
+	if !iter.Next() {
+		return nil, errors.Newf(v.Pos(), "xml: document must have a root element")
+	}
+	name, root := iter.Label(), iter.Value()
+	if iter.Next() {
+		return nil, errors.Newf(iter.Value().Pos(),
+			"xml: document must have a single root element, found %q and %q",
+			name, iter.Label())
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	e := &encoder{enc: xml.NewEncoder(buf)}
+	e.enc.Indent("", "    ")
+	if root.IncompleteKind() == cue.ListKind {
+		return nil, errors.Newf(root.Pos(),
+			"xml: root element %q cannot be a list", name)
+	}
+	if err := e.encodeElement(name, root); err != nil {
+		return nil, err
+	}
+	if err := e.enc.Flush(); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+type encoder struct {
+	enc *xml.Encoder
+}
+
+// encodeField encodes a field as one or more elements.
+func (e *encoder) encodeField(name string, v cue.Value) error {
+	if v.Kind() != cue.ListKind {
+		return e.encodeElement(name, v)
+	}
+	iter, err := v.List()
+	if err != nil {
+		return err
+	}
+	for iter.Next() {
+		x := iter.Value()
+		if x.Kind() == cue.ListKind {
+			return errors.Newf(x.Pos(),
+				"xml: cannot encode nested list in element %q", name)
+		}
+		if err := e.encodeElement(name, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) encodeElement(name string, v cue.Value) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+
+	if v.Kind() != cue.StructKind {
+		text, err := scalar.Encode(v)
+		if err != nil {
+			return err
+		}
+		return e.write(start, text, nil)
+	}
+
+	type child struct {
+		name  string
+		value cue.Value
+	}
+	var (
+		text     string
+		children []child
+	)
+	iter, err := v.Fields()
+	if err != nil {
+		return err
+	}
+	for iter.Next() {
+		label, x := iter.Label(), iter.Value()
+		switch {
+		case label == textField:
+			if text, err = scalar.Encode(x); err != nil {
+				return err
+			}
+		case strings.HasPrefix(label, attrPrefix):
+			s, err := scalar.Encode(x)
+			if err != nil {
+				return err
+			}
+			start.Attr = append(start.Attr, xml.Attr{
+				Name:  xml.Name{Local: strings.TrimPrefix(label, attrPrefix)},
+				Value: s,
+			})
+		default:
+			children = append(children, child{label, x})
+		}
+	}
+	return e.write(start, text, func() error {
+		for _, c := range children {
+			if err := e.encodeField(c.name, c.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (e *encoder) write(start xml.StartElement, text string, body func() error) error {
+	if err := e.enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if text != "" {
+		if err := e.enc.EncodeToken(xml.CharData(text)); err != nil {
+			return err
+		}
+	}
+	if body != nil {
+		if err := body(); err != nil {
+			return err
+		}
+	}
+	return e.enc.EncodeToken(start.End())
+}
+
+// Validate validates the XML document b against the schema v. The schema
+// is also used to guide the decoding, as described in the package
+// documentation.
+func Validate(b []byte, v cue.Value) error {
+	expr, err := NewDecoder("xml.Validate", bytes.NewReader(b), &Config{Schema: v}).Extract()
+	if err != nil {
+		return err
+	}
+	x := v.Context().BuildExpr(expr)
+	if err := x.Err(); err != nil {
+		return err
+	}
+	x = v.Unify(x)
+	if err := x.Err(); err != nil {
+		return err
+	}
+	return x.Validate(cue.Concrete(true))
+}
@@ -0,0 +1,212 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xml_test
+
+import (
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/encoding/xml"
+)
+
+func TestExtract(t *testing.T) {
+	testCases := []struct {
+		name   string
+		xml    string
+		schema string
+		want   string
+		err    string
+	}{{
+		name: "text",
+		xml:  `<a>foo</a>`,
+		want: `a: "foo"`,
+	}, {
+		name: "empty",
+		xml:  `<a/>`,
+		want: `a: ""`,
+	}, {
+		name: "attributes and text",
+		xml:  `<a id="x" android:name="y">  foo  </a>`,
+		want: `a: {
+	$id:             "x"
+	"$android:name": "y"
+	$text:           "foo"
+}`,
+	}, {
+		name: "repeated",
+		xml: `<project>
+	<dep>a</dep>
+	<name>n</name>
+	<dep>b</dep>
+</project>`,
+		want: `project: {
+	dep: ["a", "b"]
+	name: "n"
+}`,
+	}, {
+		name: "schema",
+		xml: `<project version="2">
+	<dep><id>a</id></dep>
+	<enabled>true</enabled>
+	<empty/>
+</project>`,
+		schema: `project: {
+	$version: int
+	dep: [...{id: string}]
+	enabled: bool
+	empty: {}
+}`,
+		want: `project: {
+	$version: 2
+	dep: [{
+		id: "a"
+	}]
+	enabled: true
+	empty: {}
+}`,
+	}, {
+		name: "text field",
+		xml: `<project>
+	<dependency>junit</dependency>
+	<dependency scope="test">mockito</dependency>
+</project>`,
+		schema: `project: dependency: [...{$scope?: string, $text: string}]`,
+		want: `project: {
+	dependency: [{
+		$text: "junit"
+	}, {
+		$scope: "test"
+		$text:  "mockito"
+	}]
+}`,
+	}, {
+		name: "multiple roots",
+		xml:  `<a/><b/>`,
+		err:  "multiple root elements",
+	}, {
+		name: "no root",
+		xml:  ` `,
+		err:  "no root element",
+	}, {
+		name: "unclosed",
+		xml:  `<a><b></b>`,
+		err:  "unexpected EOF",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := cuecontext.New()
+			cfg := &xml.Config{}
+			if tc.schema != "" {
+				cfg.Schema = ctx.CompileString(tc.schema)
+			}
+			expr, err := xml.NewDecoder("test.xml", strings.NewReader(tc.xml), cfg).Extract()
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("got error %v; want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := format.Node(expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.TrimSpace(string(b))
+			want := "{\n" + indent(tc.want) + "\n}"
+			if got != want {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func indent(s string) string {
+	return "\t" + strings.ReplaceAll(s, "\n", "\n\t")
+}
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		want string
+		err  string
+	}{{
+		name: "nested",
+		in: `project: {
+	$id: "p"
+	dep: [{$v: 1, $text: "a"}, "b"]
+	opt: null
+	ok: true
+}`,
+		want: `<project id="p">
+    <dep v="1">a</dep>
+    <dep>b</dep>
+    <opt></opt>
+    <ok>true</ok>
+</project>`,
+	}, {
+		name: "no root",
+		in:   `{}`,
+		err:  "must have a root element",
+	}, {
+		name: "multiple roots",
+		in:   `a: 1, b: 2`,
+		err:  "single root element",
+	}, {
+		name: "incomplete",
+		in:   `a: int`,
+		err:  "incomplete",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := cuecontext.New().CompileString(tc.in)
+			b, err := xml.Encode(v)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("got error %v; want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.TrimPrefix(string(b), `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+			if got != tc.want+"\n" {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ctx := cuecontext.New()
+	schema := ctx.CompileString(`a: {n: <10, m: [...string]}`)
+
+	if err := xml.Validate([]byte(`<a><n>3</n><m>x</m></a>`), schema); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := xml.Validate([]byte(`<a><n>30</n></a>`), schema); err == nil {
+		t.Errorf("expected error")
+	}
+
+	schema = ctx.CompileString(`a: dep: [...{$scope?: string, $text: string}]`)
+	if err := xml.Validate([]byte(`<a><dep>x</dep></a>`), schema); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -65,6 +65,12 @@ func predeclared(n *ast.Ident) adt.Expr {
 	return nil
 }
 
+// IsPredeclared reports whether name is a predeclared identifier, such as
+// int or len.
+func IsPredeclared(name string) bool {
+	return predeclared(&ast.Ident{Name: name}) != nil
+}
+
 // LookupRange returns a CUE expressions for the given predeclared identifier
 // representing a range, such as uint8, int128, and float64.
 func LookupRange(name string) adt.Expr {
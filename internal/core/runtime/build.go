@@ -128,6 +128,12 @@ func (x *Runtime) buildSpec(cfg *Config, b *build.Instance, spec *ast.ImportSpec
 		return errors.Promote(err, "invalid import path")
 	}
 
+	if x.sandbox != nil {
+		if err := x.sandbox.CheckImport(spec.Pos(), info.ID, b.Module); err != nil {
+			return err
+		}
+	}
+
 	pkg := b.LookupImport(info.ID)
 	if pkg == nil {
 		if strings.Contains(info.ID, ".") {
@@ -23,6 +23,8 @@ type Runtime struct {
 	index *index
 
 	loaded map[*build.Instance]interface{}
+
+	sandbox *Sandbox
 }
 
 func (r *Runtime) SetBuildData(b *build.Instance, x interface{}) {
@@ -0,0 +1,75 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"strings"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+)
+
+// A Sandbox restricts the packages that may be imported, so that
+// configurations of untrusted origin can be evaluated hermetically.
+type Sandbox struct {
+	// AllowImports lists the import paths of packages outside of the main
+	// module that may be imported. An entry also allows the packages below
+	// it.
+	AllowImports []string
+}
+
+// SetSandbox restricts the packages that the instances built by r may
+// import. A nil Sandbox lifts the restrictions.
+func (r *Runtime) SetSandbox(s *Sandbox) {
+	r.sandbox = s
+}
+
+// CheckImport reports an error if the package with the given import path may
+// not be imported by a package of the given module.
+func (s *Sandbox) CheckImport(pos token.Pos, path, module string) errors.Error {
+	if path == "tool" || strings.HasPrefix(path, "tool/") {
+		return errors.Newf(pos,
+			"import of %q not allowed in sandbox mode: tool packages perform I/O", path)
+	}
+	if strings.IndexByte(strings.Split(path, "/")[0], '.') == -1 {
+		return nil // builtin package
+	}
+	if module != "" && hasPathPrefix(path, module) {
+		return nil
+	}
+	for _, p := range s.AllowImports {
+		if hasPathPrefix(path, p) {
+			return nil
+		}
+	}
+	return errors.Newf(pos,
+		"import of %q not allowed in sandbox mode: package is not part of the main module or the allowed imports", path)
+}
+
+// hasPathPrefix reports whether the import path s begins with the elements
+// in prefix.
+func hasPathPrefix(s, prefix string) bool {
+	switch {
+	default:
+		return false
+	case len(s) == len(prefix):
+		return s == prefix
+	case len(s) > len(prefix):
+		if prefix != "" && prefix[len(prefix)-1] == '/' {
+			return strings.HasPrefix(s, prefix)
+		}
+		return s[len(prefix)] == '/' && s[:len(prefix)] == prefix
+	}
+}
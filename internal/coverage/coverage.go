@@ -0,0 +1,505 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package coverage records which constraints of a schema are exercised by
+// data.
+//
+// The constraints of a schema are identified by their position in the
+// schema files. They are:
+//
+//   - field declarations, including optional fields;
+//   - pattern constraints, such as [=~"^x"]: int;
+//   - the disjuncts of disjunctions;
+//   - bounds and regular expressions, such as >=1 and =~"^[a-z]+$";
+//   - the element types of lists, such as ...#Item;
+//   - calls of validators, such as list.MinItems(1).
+//
+// A constraint is exercised by a data object if it is unified with a value of
+// that object. It accepts the value if the unification results in a valid
+// concrete value and rejects it otherwise. Validators are only known once
+// they are exercised, as they cannot be distinguished from other function
+// calls by their syntax.
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/token"
+)
+
+// A Kind indicates the kind of a constraint.
+type Kind int
+
+const (
+	Field Kind = iota
+	OptionalField
+	Pattern
+	Disjunct
+	Bound
+	Elements
+	Validator
+)
+
+var kindNames = [...]string{
+	Field:         "field",
+	OptionalField: "optional",
+	Pattern:       "pattern",
+	Disjunct:      "disjunct",
+	Bound:         "bound",
+	Elements:      "elements",
+	Validator:     "validator",
+}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// A Constraint records how often a constraint accepted or rejected values.
+type Constraint struct {
+	Pos  token.Pos
+	Kind Kind
+
+	// Source is a short description of the constraint.
+	Source string
+
+	// Group identifies the disjunction of a disjunct. Disjuncts of the same
+	// disjunction have the same group.
+	Group int
+
+	Accepted int
+	Rejected int
+}
+
+// Exercised reports whether the constraint was unified with any value.
+func (c *Constraint) Exercised() bool {
+	return c.Accepted+c.Rejected > 0
+}
+
+type key struct {
+	file   string
+	offset int
+}
+
+func keyOf(p token.Pos) key {
+	return key{p.Filename(), p.Offset()}
+}
+
+// A Profile accumulates the coverage of the constraints of a schema. It is
+// safe for concurrent use.
+type Profile struct {
+	files map[string]bool
+
+	// index holds the constraints found in the schema files. It is not
+	// modified after the profile is created.
+	index map[key]*Constraint
+
+	// values maps fields to the constraint that is their value, such as
+	// >=1 in n: >=1, which is not exercised separately from the field.
+	values map[key]key
+
+	mu         sync.Mutex
+	validators map[key]*Constraint
+}
+
+// New returns a Profile for the constraints defined in the given schema
+// files.
+func New(files ...*ast.File) *Profile {
+	p := &Profile{
+		files:      map[string]bool{},
+		index:      map[key]*Constraint{},
+		values:     map[key]key{},
+		validators: map[key]*Constraint{},
+	}
+	r := &registrar{p: p, seen: map[ast.Node]bool{}}
+	for _, f := range files {
+		p.files[f.Filename] = true
+		ast.Walk(f, r.before, nil)
+	}
+	for f, v := range p.values {
+		if _, ok := p.index[v]; !ok || p.index[f] == nil {
+			delete(p.values, f)
+		}
+	}
+	return p
+}
+
+type registrar struct {
+	p      *Profile
+	groups int
+	seen   map[ast.Node]bool
+}
+
+func (r *registrar) add(n ast.Node, k Kind, src string) *Constraint {
+	pos := n.Pos()
+	if !pos.IsValid() {
+		return nil
+	}
+	c := &Constraint{Pos: pos, Kind: k, Source: src}
+	if _, ok := r.p.index[keyOf(pos)]; !ok {
+		r.p.index[keyOf(pos)] = c
+	}
+	return c
+}
+
+func (r *registrar) before(n ast.Node) bool {
+	switch x := n.(type) {
+	case *ast.Field:
+		name, _, _ := ast.LabelName(x.Label)
+		switch {
+		case isList(x.Label):
+			r.add(x, Pattern, describe(x.Label))
+		case strings.HasPrefix(name, "#"), strings.HasPrefix(name, "_"):
+			// Definitions and hidden fields are not constraints on data.
+		case x.Optional != token.NoPos:
+			r.add(x, OptionalField, describe(x.Label)+"?")
+		default:
+			r.add(x, Field, describe(x.Label))
+		}
+		if u, ok := x.Value.(*ast.UnaryExpr); ok {
+			r.p.values[keyOf(x.Pos())] = keyOf(u.Pos())
+		}
+		// The constraints of labels, as in [=~"^x"]: int, apply to labels
+		// rather than data, so only walk the value.
+		ast.Walk(x.Value, r.before, nil)
+		return false
+
+	case *ast.BinaryExpr:
+		if x.Op != token.OR || r.seen[x] {
+			break
+		}
+		r.groups++
+		for _, d := range r.disjuncts(x, nil) {
+			// Evaluated disjuncts are positioned at the expression
+			// within parentheses or a default marker.
+			at := ast.Node(d)
+			for {
+				if p, ok := at.(*ast.ParenExpr); ok {
+					at = p.X
+				} else if u, ok := at.(*ast.UnaryExpr); ok && u.Op == token.MUL {
+					at = u.X
+				} else {
+					break
+				}
+			}
+			if c := r.add(at, Disjunct, describe(d)); c != nil {
+				c.Group = r.groups
+			}
+		}
+
+	case *ast.UnaryExpr:
+		switch x.Op {
+		case token.LSS, token.LEQ, token.GTR, token.GEQ,
+			token.NEQ, token.MAT, token.NMAT:
+			r.add(x, Bound, describe(x))
+		}
+
+	case *ast.ListLit:
+		for _, e := range x.Elts {
+			if e, ok := e.(*ast.Ellipsis); ok && e.Type != nil {
+				r.add(e, Elements, describe(e))
+			}
+		}
+	}
+	return true
+}
+
+// disjuncts returns the operands of a chain of disjunctions, marking the
+// nested disjunctions as seen.
+func (r *registrar) disjuncts(x ast.Expr, a []ast.Expr) []ast.Expr {
+	if b, ok := x.(*ast.BinaryExpr); ok && b.Op == token.OR {
+		r.seen[b] = true
+		a = r.disjuncts(b.X, a)
+		return r.disjuncts(b.Y, a)
+	}
+	return append(a, x)
+}
+
+func isList(l ast.Label) bool {
+	_, ok := l.(*ast.ListLit)
+	return ok
+}
+
+// describe returns a single-line description of a node of at most 40 runes.
+func describe(n ast.Node) string {
+	b, err := format.Node(n)
+	if err != nil {
+		return ""
+	}
+	s := strings.Join(strings.Fields(string(b)), " ")
+	if r := []rune(s); len(r) > 40 {
+		s = string(r[:37]) + "..."
+	}
+	return s
+}
+
+// Add records the constraints exercised by a data object. The value v must
+// be the result of unifying the data with the schema of the profile.
+func (p *Profile) Add(data, v cue.Value) {
+	r := &recorder{p: p, counts: map[key]*Constraint{}}
+	r.walk(data, v)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for k, c := range r.counts {
+		x, ok := p.index[k]
+		if !ok {
+			if x, ok = p.validators[k]; !ok {
+				x = &Constraint{Pos: c.Pos, Kind: c.Kind, Source: c.Source}
+				p.validators[k] = x
+			}
+		}
+		x.Accepted += c.Accepted
+		x.Rejected += c.Rejected
+	}
+}
+
+// recorder records the constraints exercised by a single data object.
+type recorder struct {
+	p      *Profile
+	counts map[key]*Constraint
+}
+
+func (r *recorder) walk(data, v cue.Value) {
+	if op, args := v.Expr(); op == cue.AndOp {
+		for _, c := range args {
+			r.constraint(c, data)
+		}
+	} else {
+		r.constraint(v, data)
+	}
+
+	switch data.Kind() {
+	case cue.StructKind:
+		iter, err := data.Fields()
+		if err != nil {
+			return
+		}
+		for iter.Next() {
+			p := cue.MakePath(iter.Selector())
+			r.walk(iter.Value(), v.LookupPath(p))
+		}
+
+	case cue.ListKind:
+		iter, err := data.List()
+		if err != nil {
+			return
+		}
+		for i := 0; iter.Next(); i++ {
+			r.walk(iter.Value(), v.LookupPath(cue.MakePath(cue.Index(i))))
+		}
+	}
+}
+
+// constraint records whether c accepts data, as well as the constraints of
+// which c is composed.
+func (r *recorder) constraint(c, data cue.Value) {
+	op, args := c.Expr()
+
+	pos := c.Pos()
+	k := keyOf(pos)
+	x, ok := r.counts[k]
+	if !ok {
+		src, known := r.p.index[k]
+		switch {
+		case known:
+		case op == cue.CallOp && r.p.files[pos.Filename()]:
+			src = &Constraint{Pos: pos, Kind: Validator}
+			if n := c.Source(); n != nil {
+				src.Source = describe(n)
+			}
+		}
+		if src != nil {
+			x = &Constraint{Pos: pos, Kind: src.Kind, Source: src.Source}
+			r.counts[k] = x
+		}
+	}
+	if x != nil {
+		accepted := c.Unify(data).Validate(cue.Concrete(true)) == nil
+		x.count(accepted)
+		if v, ok := r.p.values[k]; ok {
+			r.at(v).count(accepted)
+		}
+	}
+
+	switch op {
+	case cue.AndOp, cue.OrOp:
+		for _, a := range args {
+			r.constraint(a, data)
+		}
+	}
+}
+
+// at returns the counts for the indexed constraint with key k.
+func (r *recorder) at(k key) *Constraint {
+	x, ok := r.counts[k]
+	if !ok {
+		src := r.p.index[k]
+		x = &Constraint{Pos: src.Pos, Kind: src.Kind, Source: src.Source}
+		r.counts[k] = x
+	}
+	return x
+}
+
+func (c *Constraint) count(accepted bool) {
+	if accepted {
+		c.Accepted++
+	} else {
+		c.Rejected++
+	}
+}
+
+// Constraints returns the constraints of the profile ordered by position.
+func (p *Profile) Constraints() []*Constraint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	a := make([]*Constraint, 0, len(p.index)+len(p.validators))
+	for _, c := range p.index {
+		a = append(a, c)
+	}
+	for _, c := range p.validators {
+		a = append(a, c)
+	}
+	sort.Slice(a, func(i, j int) bool {
+		if fi, fj := a[i].Pos.Filename(), a[j].Pos.Filename(); fi != fj {
+			return fi < fj
+		}
+		return a[i].Pos.Offset() < a[j].Pos.Offset()
+	})
+	return a
+}
+
+// Config defines options for writing reports.
+type Config struct {
+	// Cwd, if set, is used to report file names relative to this directory.
+	Cwd string
+}
+
+func (c *Config) filename(pos token.Pos) string {
+	name := pos.Filename()
+	if c == nil || c.Cwd == "" {
+		return name
+	}
+	if rel, err := filepath.Rel(c.Cwd, name); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return name
+}
+
+// WriteText writes a report listing each constraint and how often it
+// accepted and rejected values, followed by a summary. The config may be nil.
+func (p *Profile) WriteText(w io.Writer, cfg *Config) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	a := p.Constraints()
+	exercised := 0
+	for _, c := range a {
+		counts := "not exercised"
+		if c.Exercised() {
+			exercised++
+			counts = fmt.Sprintf("accepted %d\trejected %d", c.Accepted, c.Rejected)
+		}
+		fmt.Fprintf(tw, "%s:%d:%d:\t%s\t%s\t%s\n",
+			cfg.filename(c.Pos), c.Pos.Line(), c.Pos.Column(),
+			c.Kind, c.Source, counts)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	percent := 100.0
+	if len(a) > 0 {
+		percent = 100 * float64(exercised) / float64(len(a))
+	}
+	_, err := fmt.Fprintf(w, "coverage: %d of %d constraints exercised (%.1f%%)\n",
+		exercised, len(a), percent)
+	return err
+}
+
+// WriteLCOV writes the profile in the LCOV tracefile format. The line count
+// of a line is the number of times its constraints were exercised. The
+// disjuncts of each disjunction are reported as branches, where the count of
+// a branch is the number of values the disjunct accepted. The config may be
+// nil.
+func (p *Profile) WriteLCOV(w io.Writer, cfg *Config) error {
+	bw := bufio.NewWriter(w)
+
+	var files []string
+	byFile := map[string][]*Constraint{}
+	for _, c := range p.Constraints() {
+		f := c.Pos.Filename()
+		if _, ok := byFile[f]; !ok {
+			files = append(files, f)
+		}
+		byFile[f] = append(byFile[f], c)
+	}
+
+	fmt.Fprintln(bw, "TN:")
+	for _, f := range files {
+		a := byFile[f]
+		fmt.Fprintf(bw, "SF:%s\n", cfg.filename(a[0].Pos))
+
+		var lines []int
+		counts := map[int]int{}
+		for _, c := range a {
+			l := c.Pos.Line()
+			if _, ok := counts[l]; !ok {
+				lines = append(lines, l)
+			}
+			counts[l] += c.Accepted + c.Rejected
+		}
+		sort.Ints(lines)
+
+		branches, branchesHit := 0, 0
+		branch := map[int]int{}
+		for _, c := range a {
+			if c.Kind != Disjunct {
+				continue
+			}
+			taken := "-"
+			if c.Exercised() {
+				taken = fmt.Sprint(c.Accepted)
+			}
+			if c.Accepted > 0 {
+				branchesHit++
+			}
+			branches++
+			fmt.Fprintf(bw, "BRDA:%d,%d,%d,%s\n",
+				c.Pos.Line(), c.Group, branch[c.Group], taken)
+			branch[c.Group]++
+		}
+		if branches > 0 {
+			fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", branches, branchesHit)
+		}
+
+		hit := 0
+		for _, l := range lines {
+			if counts[l] > 0 {
+				hit++
+			}
+			fmt.Fprintf(bw, "DA:%d,%d\n", l, counts[l])
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\n", len(lines), hit)
+		fmt.Fprintln(bw, "end_of_record")
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coverage_test
+
+import (
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/internal/coverage"
+)
+
+func TestProfile(t *testing.T) {
+	testCases := []struct {
+		name   string
+		schema string
+		data   []string
+		text   string
+		lcov   string
+	}{{
+		name: "fields",
+		schema: `#D: {
+	a:  int
+	b?: string
+	_h: int
+}`,
+		data: []string{`{a: 1}`, `{a: "x", b: "y"}`},
+		text: `schema.cue:2:2:  field     a   accepted 1  rejected 1
+schema.cue:3:2:  optional  b?  accepted 1  rejected 0
+coverage: 2 of 2 constraints exercised (100.0%)
+`,
+	}, {
+		name: "disjunctions",
+		schema: `#D: {
+	kind:  "a" | "b" | ("c")
+	proto: *"tcp" | "udp"
+	s:     {x: int} | {y: int}
+}`,
+		data: []string{`{kind: "a", proto: "udp", s: {x: 1}}`, `{kind: "a", proto: "udp", s: {x: 2}}`},
+		text: `schema.cue:2:2:   field     kind      accepted 2  rejected 0
+schema.cue:2:9:   disjunct  "a"       accepted 2  rejected 0
+schema.cue:2:15:  disjunct  "b"       accepted 0  rejected 2
+schema.cue:2:22:  disjunct  ("c")     accepted 0  rejected 2
+schema.cue:3:2:   field     proto     accepted 2  rejected 0
+schema.cue:3:10:  disjunct  *"tcp"    accepted 0  rejected 2
+schema.cue:3:18:  disjunct  "udp"     accepted 2  rejected 0
+schema.cue:4:2:   field     s         accepted 2  rejected 0
+schema.cue:4:9:   disjunct  {x: int}  accepted 2  rejected 0
+schema.cue:4:10:  field     x         accepted 2  rejected 0
+schema.cue:4:20:  disjunct  {y: int}  accepted 0  rejected 2
+schema.cue:4:21:  field     y         not exercised
+coverage: 11 of 12 constraints exercised (91.7%)
+`,
+		lcov: `TN:
+SF:schema.cue
+BRDA:2,1,0,2
+BRDA:2,1,1,0
+BRDA:2,1,2,0
+BRDA:3,2,0,0
+BRDA:3,2,1,2
+BRDA:4,3,0,2
+BRDA:4,3,1,0
+BRF:7
+BRH:3
+DA:2,8
+DA:3,6
+DA:4,8
+LF:3
+LH:3
+end_of_record
+`,
+	}, {
+		name: "bounds",
+		schema: `#D: {
+	n:  int & >=1 & <=5
+	id: =~"^[a-z]+$"
+	m: [=~"^x"]: int
+}`,
+		data: []string{`{n: 3, id: "x", m: {xa: 1}}`, `{n: 7, id: "X", m: {}}`},
+		text: `schema.cue:2:2:   field    n             accepted 1  rejected 1
+schema.cue:2:12:  bound    >=1           accepted 2  rejected 0
+schema.cue:2:18:  bound    <=5           accepted 1  rejected 1
+schema.cue:3:2:   field    id            accepted 1  rejected 1
+schema.cue:3:6:   bound    =~"^[a-z]+$"  accepted 1  rejected 1
+schema.cue:4:2:   field    m             accepted 2  rejected 0
+schema.cue:4:5:   pattern  [=~"^x"]      accepted 1  rejected 0
+coverage: 7 of 7 constraints exercised (100.0%)
+`,
+	}, {
+		name: "lists",
+		schema: `import "list"
+
+#D: {
+	l: [...#E] & list.MinItems(1)
+}
+#E: {x: int}`,
+		data: []string{`{l: [{x: 1}, {x: 2}]}`, `{l: []}`},
+		text: `schema.cue:4:2:   field      l                 accepted 1  rejected 1
+schema.cue:4:6:   elements   ...#E             accepted 2  rejected 0
+schema.cue:4:15:  validator  list.MinItems(1)  accepted 1  rejected 1
+schema.cue:6:6:   field      x                 accepted 2  rejected 0
+coverage: 4 of 4 constraints exercised (100.0%)
+`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := cuecontext.New()
+			f, err := parser.ParseFile("schema.cue", tc.schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			schema := ctx.BuildFile(f).LookupPath(cue.ParsePath("#D"))
+			if err := schema.Err(); err != nil {
+				t.Fatal(err)
+			}
+			p := coverage.New(f)
+			for _, d := range tc.data {
+				data := ctx.CompileString(d, cue.Filename("data.cue"))
+				p.Add(data, data.Unify(schema))
+			}
+
+			var text strings.Builder
+			if err := p.WriteText(&text, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := text.String(); got != tc.text {
+				t.Errorf("text: got:\n%s\nwant:\n%s", got, tc.text)
+			}
+			if tc.lcov == "" {
+				return
+			}
+			var lcov strings.Builder
+			if err := p.WriteLCOV(&lcov, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := lcov.String(); got != tc.lcov {
+				t.Errorf("lcov: got:\n%s\nwant:\n%s", got, tc.lcov)
+			}
+		})
+	}
+}
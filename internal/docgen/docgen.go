@@ -0,0 +1,480 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package docgen generates reference documentation for the definitions of
+// CUE packages.
+//
+// The documentation of a definition lists its fields, including the fields
+// of nested structs and pattern constraints, with their types as formatted
+// by internal/core/export, defaults, enumerated values, whether they are
+// required, and their doc comments. References to definitions are recorded
+// so that they can be rendered as links.
+package docgen
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal"
+	"cuelang.org/go/internal/core/adt"
+	"cuelang.org/go/internal/core/export"
+	"cuelang.org/go/internal/core/runtime"
+	"cuelang.org/go/internal/core/walk"
+	"cuelang.org/go/internal/value"
+)
+
+// A Package documents the definitions of a package.
+type Package struct {
+	ImportPath string
+	Name       string
+	Doc        string
+
+	// Definitions holds the definitions of the package, sorted by name.
+	Definitions []*Definition
+}
+
+// A Definition documents a definition.
+type Definition struct {
+	// Name is the name of the definition, including its #. Definitions
+	// nested in other definitions are qualified by the names of the
+	// enclosing definitions, as in #A.#B.
+	Name string
+	Doc  string
+
+	// Type is the value of a definition that is not a struct. For a struct
+	// it holds the embedded values, if any.
+	Type *Type
+
+	// Open reports whether the definition allows fields other than the
+	// listed ones.
+	Open bool
+
+	Fields []*Field
+}
+
+// A Field documents a regular field or pattern constraint of a
+// definition.
+type Field struct {
+	// Name is the path of the field relative to the definition, such as
+	// spec.replicas. Pattern constraints are written as their label, as in
+	// labels.[string].
+	Name string
+	Doc  string
+	Type *Type
+
+	// Default is the default value of the field, if any.
+	Default string
+
+	// Enum lists the values of a field that is a disjunction of literals.
+	Enum []string
+
+	Optional bool
+	Pattern  bool
+}
+
+// A Type is a formatted CUE expression and the references to definitions
+// it contains.
+type Type struct {
+	Text string
+	Refs []Ref
+}
+
+// A Ref is a reference to a definition within the text of a Type.
+type Ref struct {
+	// Text is the reference as it appears in the text, such as #Container
+	// or k8s.#Deployment.
+	Text string
+
+	ImportPath string
+	Name       string
+}
+
+// Extract returns the documentation for the definitions of an instance.
+// The instance must have been loaded with comments, and v must be its
+// value.
+func Extract(inst *build.Instance, v cue.Value) *Package {
+	p := &Package{
+		ImportPath: inst.ImportPath,
+		Name:       inst.PkgName,
+	}
+	x := &extractor{
+		pkg:   p,
+		pkgID: inst.ID(),
+		value: v,
+		defs:  map[string]*Definition{},
+		exprs: map[ast.Node]adt.Expr{},
+	}
+	x.compiled()
+	for _, f := range inst.Files {
+		if p.Doc == "" {
+			if cg := internal.FileComment(f); cg != nil {
+				p.Doc = cg.Text()
+			}
+		}
+		x.imports = imports(f)
+		for _, d := range f.Decls {
+			if f, ok := d.(*ast.Field); ok {
+				x.decl(nil, "", cue.Path{}, f)
+			}
+		}
+	}
+	sort.Slice(p.Definitions, func(i, j int) bool {
+		return p.Definitions[i].Name < p.Definitions[j].Name
+	})
+	return p
+}
+
+// imports maps the names of the imports of a file to their import paths.
+func imports(f *ast.File) map[string]string {
+	m := map[string]string{}
+	for _, spec := range f.Imports {
+		p, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		var name string
+		switch {
+		case spec.Name != nil:
+			name = spec.Name.Name
+		case strings.Contains(p, ":"):
+			name = p[strings.LastIndex(p, ":")+1:]
+		default:
+			name = path.Base(p)
+			if i := strings.LastIndex(name, "@"); i > 0 {
+				name = name[:i]
+			}
+		}
+		m[name] = p
+	}
+	return m
+}
+
+type extractor struct {
+	pkg     *Package
+	pkgID   string
+	value   cue.Value
+	runtime *runtime.Runtime
+	exprs   map[ast.Node]adt.Expr // compiled expressions by source
+	defs    map[string]*Definition
+	imports map[string]string
+}
+
+// decl documents the declaration of a field. The field is nested in
+// definition d, if non-nil, at the given relative name and absolute path.
+func (x *extractor) decl(d *Definition, prefix string, p cue.Path, f *ast.Field) {
+	name, _, err := ast.LabelName(f.Label)
+	switch {
+	case isPattern(f.Label):
+		if d == nil {
+			return
+		}
+		label, _ := format.Node(f.Label)
+		fp := appendPath(p, cue.AnyString)
+		fd := x.field(d, prefix+string(label), f, fp)
+		fd.Pattern = true
+		fd.Optional = true
+		x.fields(d, fd.Name+".", fp, f.Value)
+
+	case err != nil, strings.HasPrefix(name, "_"):
+
+	case strings.HasPrefix(name, "#"):
+		if d != nil {
+			if prefix != "" {
+				// Definitions nested in fields are not documented.
+				return
+			}
+			name = d.Name + "." + name
+		}
+		d := x.def(name, f)
+		x.fields(d, "", appendPath(p, cue.Def(name[strings.LastIndex(name, "#"):])), f.Value)
+
+	case d != nil:
+		sel := cue.Str(name)
+		fp := appendPath(p, sel)
+		fd := x.field(d, prefix+sel.String(), f, fp)
+		x.fields(d, fd.Name+".", fp, f.Value)
+	}
+}
+
+func appendPath(p cue.Path, sel cue.Selector) cue.Path {
+	if p.Err() != nil {
+		return p
+	}
+	return cue.MakePath(append(p.Selectors(), sel)...)
+}
+
+func isPattern(l ast.Label) bool {
+	_, ok := l.(*ast.ListLit)
+	return ok
+}
+
+// def returns the documentation for the definition with the given name,
+// adding it if it does not yet exist.
+func (x *extractor) def(name string, f *ast.Field) *Definition {
+	d := x.defs[name]
+	if d == nil {
+		d = &Definition{Name: name}
+		x.defs[name] = d
+		x.pkg.Definitions = append(x.pkg.Definitions, d)
+	}
+	d.Doc = joinDoc(d.Doc, docComment(f))
+	if _, ok := unparen(f.Value).(*ast.StructLit); !ok {
+		d.Type = x.join(d.Type, f.Value)
+	}
+	return d
+}
+
+// fields documents the fields of expr if it is a struct.
+func (x *extractor) fields(d *Definition, prefix string, p cue.Path, expr ast.Expr) {
+	s, ok := unparen(expr).(*ast.StructLit)
+	if !ok {
+		return
+	}
+	for _, e := range s.Elts {
+		switch e := e.(type) {
+		case *ast.Field:
+			x.decl(d, prefix, p, e)
+		case *ast.EmbedDecl:
+			if _, ok := unparen(e.Expr).(*ast.StructLit); ok {
+				x.fields(d, prefix, p, e.Expr)
+			} else if prefix == "" {
+				d.Type = x.join(d.Type, e.Expr)
+			}
+		case *ast.Ellipsis:
+			if prefix == "" {
+				d.Open = true
+			}
+		}
+	}
+}
+
+// field returns the documentation for the field with the given name,
+// adding it if it does not yet exist. The value at path p, if non-empty,
+// is used to determine the default.
+func (x *extractor) field(d *Definition, name string, f *ast.Field, p cue.Path) *Field {
+	var fd *Field
+	for _, g := range d.Fields {
+		if g.Name == name {
+			fd = g
+		}
+	}
+	if fd == nil {
+		fd = &Field{Name: name, Optional: true}
+		d.Fields = append(d.Fields, fd)
+	}
+	if f.Optional == token.NoPos {
+		fd.Optional = false
+	}
+	fd.Doc = joinDoc(fd.Doc, docComment(f))
+
+	if _, ok := unparen(f.Value).(*ast.StructLit); ok {
+		if fd.Type == nil {
+			fd.Type = &Type{Text: "struct"}
+		}
+	} else {
+		fd.Type = x.join(fd.Type, f.Value)
+		if enum := x.enumValues(f.Value); fd.Enum == nil {
+			fd.Enum = enum
+		}
+	}
+
+	if fd.Default == "" {
+		fd.Default = x.astDefault(f.Value)
+	}
+	if fd.Default == "" && len(p.Selectors()) > 0 {
+		if dv, ok := x.value.LookupPath(p).Default(); ok && dv.IsConcrete() {
+			switch dv.Kind() {
+			case cue.StructKind, cue.ListKind:
+			default:
+				fd.Default = formatNode(dv.Syntax(cue.Final()))
+			}
+		}
+	}
+	return fd
+}
+
+// compiled records the compiled expressions of the value by their source.
+func (x *extractor) compiled() {
+	r, v := value.ToInternal(x.value)
+	if v == nil {
+		return
+	}
+	x.runtime = r
+	w := walk.Visitor{Before: func(n adt.Node) bool {
+		if e, ok := n.(adt.Expr); ok && e.Source() != nil {
+			if _, ok := x.exprs[e.Source()]; !ok {
+				x.exprs[e.Source()] = e
+			}
+		}
+		return true
+	}}
+	for _, c := range v.Conjuncts {
+		w.Elem(c.Elem())
+	}
+}
+
+// join returns the type of t and expr combined, recording the references
+// to definitions in expr.
+func (x *extractor) join(t *Type, expr ast.Expr) *Type {
+	text := x.exportText(expr)
+	if t == nil {
+		t = &Type{Text: text}
+	} else {
+		t.Text += " & " + text
+	}
+	ast.Walk(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			if strings.HasPrefix(n.Name, "#") {
+				t.addRef(Ref{
+					Text:       n.Name,
+					ImportPath: x.pkg.ImportPath,
+					Name:       n.Name,
+				})
+			}
+		case *ast.SelectorExpr:
+			pkg, ok := n.X.(*ast.Ident)
+			if !ok {
+				break
+			}
+			sel, _, _ := ast.LabelName(n.Sel)
+			if p, ok := x.imports[pkg.Name]; ok && strings.HasPrefix(sel, "#") {
+				t.addRef(Ref{
+					Text:       pkg.Name + "." + sel,
+					ImportPath: p,
+					Name:       sel,
+				})
+				return false
+			}
+		}
+		return true
+	}, nil)
+	return t
+}
+
+// exportText returns expr as formatted by internal/core/export, or as
+// written if it was not compiled.
+func (x *extractor) exportText(expr ast.Expr) string {
+	if e, ok := x.exprs[expr]; ok {
+		if n, err := export.Expr(x.runtime, x.pkgID, e); err == nil {
+			return formatNode(n)
+		}
+	}
+	return formatNode(expr)
+}
+
+func (t *Type) addRef(r Ref) {
+	for _, s := range t.Refs {
+		if s == r {
+			return
+		}
+	}
+	t.Refs = append(t.Refs, r)
+}
+
+// disjuncts returns the disjuncts of expr.
+func disjuncts(expr ast.Expr) []ast.Expr {
+	expr = unparen(expr)
+	if b, ok := expr.(*ast.BinaryExpr); ok && b.Op == token.OR {
+		return append(disjuncts(b.X), disjuncts(b.Y)...)
+	}
+	return []ast.Expr{expr}
+}
+
+// enumValues returns the values of expr if it is a disjunction of at least
+// two literals.
+func (x *extractor) enumValues(expr ast.Expr) []string {
+	a := disjuncts(expr)
+	if len(a) < 2 {
+		return nil
+	}
+	var values []string
+	for _, d := range a {
+		if u, ok := d.(*ast.UnaryExpr); ok && u.Op == token.MUL {
+			d = unparen(u.X)
+		}
+		if _, ok := d.(*ast.BasicLit); !ok {
+			return nil
+		}
+		values = append(values, x.exportText(d))
+	}
+	return values
+}
+
+// astDefault returns the default marked in the disjunction expr, if any.
+func (x *extractor) astDefault(expr ast.Expr) string {
+	for _, d := range disjuncts(expr) {
+		if u, ok := d.(*ast.UnaryExpr); ok && u.Op == token.MUL {
+			return x.exportText(u.X)
+		}
+	}
+	return ""
+}
+
+func unparen(expr ast.Expr) ast.Expr {
+	for {
+		p, ok := expr.(*ast.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = p.X
+	}
+}
+
+// docComment returns the text of the doc comments of n.
+func docComment(n ast.Node) string {
+	var a []string
+	for _, cg := range ast.Comments(n) {
+		if cg.Doc {
+			a = append(a, cg.Text())
+		}
+	}
+	return strings.Join(a, "\n")
+}
+
+func joinDoc(a, b string) string {
+	switch {
+	case a == "", a == b:
+		return b
+	case b == "":
+		return a
+	}
+	return a + "\n" + b
+}
+
+// formatNode formats n on a single line.
+func formatNode(n ast.Node) string {
+	b, err := format.Node(n, format.Simplify())
+	if err != nil {
+		return ""
+	}
+	s := string(b)
+	if !strings.Contains(s, "\n") {
+		return s
+	}
+	var lines []string
+	for _, l := range strings.Split(s, "\n") {
+		lines = append(lines, strings.TrimSpace(l))
+	}
+	s = strings.Join(lines, " ")
+	s = strings.ReplaceAll(s, "{ ", "{")
+	return strings.ReplaceAll(s, " }", "}")
+}
@@ -0,0 +1,190 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docgen_test
+
+import (
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/internal/docgen"
+)
+
+func extract(t *testing.T, src string) *docgen.Package {
+	t.Helper()
+	f, err := parser.ParseFile("schema.cue", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst := build.NewContext().NewInstance("", nil)
+	if err := inst.AddSyntax(f); err != nil {
+		t.Fatal(err)
+	}
+	inst.ImportPath = "example.com/schema"
+	v := cuecontext.New().BuildInstance(inst)
+	if err := v.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return docgen.Extract(inst, v)
+}
+
+func TestMarkdown(t *testing.T) {
+	// The expected output uses ' for backquotes.
+	testCases := []struct {
+		name string
+		src  string
+		out  string
+	}{{
+		name: "fields",
+		src: `
+// Package schema defines services.
+package schema
+
+// A Service is a network service.
+#Service: {
+	// name is the DNS name.
+	name:      =~"^[a-z]+$"
+	port:      int & >=1024 | *8080
+	protocol?: "tcp" | "udp"
+	spec: replicas: *1 | int
+	size?: <=0x10 | *0x08 | 0x20
+}
+`,
+		out: `# <a id="example.com-schema"></a>Package schema
+
+'''
+import "example.com/schema"
+'''
+
+Package schema defines services.
+
+## Index
+
+- [#Service](#example.com-schema.Service)
+
+## <a id="example.com-schema.Service"></a>#Service
+
+A Service is a network service.
+
+| Field | Type | Default | Required | Description |
+| --- | --- | --- | --- | --- |
+| 'name' | '=~"^[a-z]+$"' |  | yes | name is the DNS name. |
+| 'port' | 'int & >=1024 \| *8080' | '8080' | yes |  |
+| 'protocol' | '"tcp" \| "udp"' |  | no | One of: '"tcp"', '"udp"'. |
+| 'spec' | 'struct' |  | yes |  |
+| 'spec.replicas' | '*1 \| int' | '1' | yes |  |
+| 'size' | '<=16 \| *8 \| 32' | '8' | no |  |
+`,
+	}, {
+		name: "references",
+		src: `
+package schema
+
+#A: {
+	b: [...#B]
+	c: [string]: #C
+	#B
+	...
+}
+#A: d: #Policy
+
+#B: {x: int}
+#C: string
+#Policy: *"a" | "b"
+`,
+		out: `# <a id="example.com-schema"></a>Package schema
+
+'''
+import "example.com/schema"
+'''
+
+## Index
+
+- [#A](#example.com-schema.A)
+- [#B](#example.com-schema.B)
+- [#C](#example.com-schema.C)
+- [#Policy](#example.com-schema.Policy)
+
+## <a id="example.com-schema.A"></a>#A
+
+Embeds: ['#B'](#example.com-schema.B)
+
+Fields other than those listed are allowed.
+
+| Field | Type | Default | Required | Description |
+| --- | --- | --- | --- | --- |
+| 'b' | '[...'['#B'](#example.com-schema.B)']' |  | yes |  |
+| 'c' | 'struct' |  | yes |  |
+| 'c.[string]' | ['#C'](#example.com-schema.C) |  | no |  |
+| 'd' | ['#Policy'](#example.com-schema.Policy) | '"a"' | yes |  |
+
+## <a id="example.com-schema.B"></a>#B
+
+| Field | Type | Default | Required | Description |
+| --- | --- | --- | --- | --- |
+| 'x' | 'int' |  | yes |  |
+
+## <a id="example.com-schema.C"></a>#C
+
+Type: 'string'
+
+## <a id="example.com-schema.Policy"></a>#Policy
+
+Type: '*"a" | "b"'
+`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := docgen.WriteMarkdown(&b, []*docgen.Package{extract(t, tc.src)}); err != nil {
+				t.Fatal(err)
+			}
+			want := strings.ReplaceAll(tc.out, "'", "`")
+			if got := b.String(); got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	p := extract(t, `
+package schema
+
+// A is <important>.
+#A: {
+	b: #B
+	c: "x" | "y"
+}
+#B: int
+`)
+	var b strings.Builder
+	if err := docgen.WriteHTML(&b, []*docgen.Package{p}); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		`<h2 id="example.com-schema.A">#A</h2>`,
+		`<p>A is &lt;important&gt;.</p>`,
+		`<td><code><a href="#example.com-schema.B">#B</a></code></td>`,
+		`<p>One of: <code>&#34;x&#34;</code>, <code>&#34;y&#34;</code>.</p>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+}
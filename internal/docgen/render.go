@@ -0,0 +1,351 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docgen
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteMarkdown writes the documentation of the given packages as a single
+// Markdown document. References to definitions of these packages are
+// rendered as links.
+func WriteMarkdown(w io.Writer, pkgs []*Package) error {
+	r := newRenderer(pkgs)
+	b := bufio.NewWriter(w)
+	for i, p := range pkgs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(b, "# <a id=%q></a>Package %s\n", r.pkgAnchor(p), p.Name)
+		if p.ImportPath != "" {
+			fmt.Fprintf(b, "\n```\nimport %q\n```\n", p.ImportPath)
+		}
+		if p.Doc != "" {
+			fmt.Fprintf(b, "\n%s", p.Doc)
+		}
+		if len(p.Definitions) == 0 {
+			continue
+		}
+
+		b.WriteString("\n## Index\n\n")
+		for _, d := range p.Definitions {
+			fmt.Fprintf(b, "- [%s](#%s)\n", d.Name, r.anchor(p.ImportPath, d.Name))
+		}
+
+		for _, d := range p.Definitions {
+			fmt.Fprintf(b, "\n## <a id=%q></a>%s\n", r.anchor(p.ImportPath, d.Name), d.Name)
+			if d.Doc != "" {
+				fmt.Fprintf(b, "\n%s", d.Doc)
+			}
+			switch {
+			case d.Type == nil:
+			case len(d.Fields) == 0:
+				fmt.Fprintf(b, "\nType: %s\n", r.markdownType(d.Type))
+			default:
+				fmt.Fprintf(b, "\nEmbeds: %s\n", r.markdownType(d.Type))
+			}
+			if d.Open {
+				b.WriteString("\nFields other than those listed are allowed.\n")
+			}
+			if len(d.Fields) == 0 {
+				continue
+			}
+			b.WriteString("\n| Field | Type | Default | Required | Description |\n")
+			b.WriteString("| --- | --- | --- | --- | --- |\n")
+			for _, f := range d.Fields {
+				def := ""
+				if f.Default != "" {
+					def = markdownCode(f.Default)
+				}
+				fmt.Fprintf(b, "| %s | %s | %s | %s | %s |\n",
+					cell(markdownCode(f.Name)),
+					cell(r.markdownType(f.Type)),
+					cell(def),
+					required(f),
+					cell(markdownDescription(f)))
+			}
+		}
+	}
+	return b.Flush()
+}
+
+// WriteHTML writes the documentation of the given packages as a single
+// HTML page. References to definitions of these packages are rendered as
+// links.
+func WriteHTML(w io.Writer, pkgs []*Package) error {
+	r := newRenderer(pkgs)
+	t := template.Must(template.New("doc").Funcs(template.FuncMap{
+		"pkgAnchor":  r.pkgAnchor,
+		"anchor":     r.anchor,
+		"type":       r.htmlType,
+		"paragraphs": htmlParagraphs,
+		"required":   required,
+		"enum":       htmlEnum,
+	}).Parse(htmlTemplate))
+	return t.Execute(w, pkgs)
+}
+
+const htmlTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{range $i, $p := .}}{{if $i}}, {{end}}{{$p.Name}}{{end}}</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: auto; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+</style>
+</head>
+<body>
+{{- range $p := .}}
+<h1 id="{{pkgAnchor $p}}">Package {{$p.Name}}</h1>
+{{- if $p.ImportPath}}
+<pre>import "{{$p.ImportPath}}"</pre>
+{{- end}}
+{{- if $p.Doc}}
+{{paragraphs $p.Doc}}
+{{- end}}
+{{- if $p.Definitions}}
+<h2>Index</h2>
+<ul>
+{{- range $p.Definitions}}
+<li><a href="#{{anchor $p.ImportPath .Name}}">{{.Name}}</a></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- range $d := $p.Definitions}}
+<h2 id="{{anchor $p.ImportPath $d.Name}}">{{$d.Name}}</h2>
+{{- if $d.Doc}}
+{{paragraphs $d.Doc}}
+{{- end}}
+{{- if $d.Type}}
+<p>{{if $d.Fields}}Embeds{{else}}Type{{end}}: {{type $d.Type}}</p>
+{{- end}}
+{{- if $d.Open}}
+<p>Fields other than those listed are allowed.</p>
+{{- end}}
+{{- if $d.Fields}}
+<table>
+<tr><th>Field</th><th>Type</th><th>Default</th><th>Required</th><th>Description</th></tr>
+{{- range $d.Fields}}
+<tr><td><code>{{.Name}}</code></td><td>{{type .Type}}</td><td>{{if .Default}}<code>{{.Default}}</code>{{end}}</td><td>{{required .}}</td><td>{{paragraphs .Doc}}{{enum .Enum}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`
+
+type renderer struct {
+	// anchors maps import paths and names of definitions to their anchors.
+	anchors map[[2]string]string
+}
+
+func newRenderer(pkgs []*Package) *renderer {
+	r := &renderer{anchors: map[[2]string]string{}}
+	for _, p := range pkgs {
+		for _, d := range p.Definitions {
+			r.anchors[[2]string{p.ImportPath, d.Name}] = r.anchor(p.ImportPath, d.Name)
+		}
+	}
+	return r
+}
+
+func (r *renderer) pkgAnchor(p *Package) string {
+	if p.ImportPath == "" {
+		return slug(p.Name)
+	}
+	return slug(p.ImportPath)
+}
+
+func (r *renderer) anchor(importPath, name string) string {
+	name = strings.ReplaceAll(name, "#", "")
+	if importPath == "" {
+		return name
+	}
+	return slug(importPath) + "." + name
+}
+
+// slug replaces the characters of s that are not safe in anchors.
+func slug(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9',
+			r == '_', r == '.':
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+// A segment is a part of the text of a type, which links to anchor if it
+// is a reference to a known definition.
+type segment struct {
+	text   string
+	anchor string
+}
+
+// segments splits the text of t at the references to known definitions.
+func (r *renderer) segments(t *Type) []segment {
+	refs := append([]Ref(nil), t.Refs...)
+	sort.Slice(refs, func(i, j int) bool { return len(refs[i].Text) > len(refs[j].Text) })
+
+	var a []segment
+	text := t.Text
+	start := 0
+	for i := 0; i < len(text); i++ {
+		if i > 0 && isIdentByte(text[i-1]) {
+			continue
+		}
+		// Skip selectors, but not ellipses.
+		if i > 1 && text[i-1] == '.' && isIdentByte(text[i-2]) {
+			continue
+		}
+		for _, ref := range refs {
+			j := i + len(ref.Text)
+			if !strings.HasPrefix(text[i:], ref.Text) ||
+				j < len(text) && (isIdentByte(text[j]) || text[j] == '.') {
+				continue
+			}
+			anchor, ok := r.anchors[[2]string{ref.ImportPath, ref.Name}]
+			if !ok {
+				continue
+			}
+			if start < i {
+				a = append(a, segment{text: text[start:i]})
+			}
+			a = append(a, segment{text: ref.Text, anchor: anchor})
+			start = j
+			i = j - 1
+			break
+		}
+	}
+	if start < len(text) {
+		a = append(a, segment{text: text[start:]})
+	}
+	return a
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '#' || c == '$' ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func (r *renderer) markdownType(t *Type) string {
+	if t == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, s := range r.segments(t) {
+		if s.anchor != "" {
+			fmt.Fprintf(&b, "[%s](#%s)", markdownCode(s.text), s.anchor)
+			continue
+		}
+		// Keep surrounding spaces outside the code span, as they would
+		// otherwise be stripped.
+		text := strings.TrimSpace(s.text)
+		if text == "" {
+			b.WriteString(s.text)
+			continue
+		}
+		i := strings.Index(s.text, text)
+		b.WriteString(s.text[:i])
+		b.WriteString(markdownCode(text))
+		b.WriteString(s.text[i+len(text):])
+	}
+	return b.String()
+}
+
+func (r *renderer) htmlType(t *Type) template.HTML {
+	if t == nil {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<code>")
+	for _, s := range r.segments(t) {
+		if s.anchor == "" {
+			b.WriteString(html.EscapeString(s.text))
+		} else {
+			fmt.Fprintf(&b, `<a href="#%s">%s</a>`,
+				html.EscapeString(s.anchor), html.EscapeString(s.text))
+		}
+	}
+	b.WriteString("</code>")
+	return template.HTML(b.String())
+}
+
+// markdownCode returns s as a code span.
+func markdownCode(s string) string {
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+func markdownDescription(f *Field) string {
+	s := strings.Join(strings.Fields(f.Doc), " ")
+	if len(f.Enum) > 0 {
+		var a []string
+		for _, e := range f.Enum {
+			a = append(a, markdownCode(e))
+		}
+		if s != "" {
+			s += " "
+		}
+		s += "One of: " + strings.Join(a, ", ") + "."
+	}
+	return s
+}
+
+// cell escapes the pipes in the contents of a table cell.
+func cell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func htmlParagraphs(doc string) template.HTML {
+	var b strings.Builder
+	for _, p := range strings.Split(strings.TrimSpace(doc), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			fmt.Fprintf(&b, "<p>%s</p>", html.EscapeString(p))
+		}
+	}
+	return template.HTML(b.String())
+}
+
+func htmlEnum(values []string) template.HTML {
+	if len(values) == 0 {
+		return ""
+	}
+	var a []string
+	for _, v := range values {
+		a = append(a, "<code>"+html.EscapeString(v)+"</code>")
+	}
+	return template.HTML("<p>One of: " + strings.Join(a, ", ") + ".</p>")
+}
+
+func required(f *Field) string {
+	if f.Optional {
+		return "no"
+	}
+	return "yes"
+}
@@ -29,10 +29,19 @@ import (
 	"cuelang.org/go/cue/errors"
 	"cuelang.org/go/cue/format"
 	"cuelang.org/go/cue/token"
+	"cuelang.org/go/encoding/cbor"
+	"cuelang.org/go/encoding/csv"
+	"cuelang.org/go/encoding/dotenv"
+	"cuelang.org/go/encoding/hcl"
+	"cuelang.org/go/encoding/ini"
+	"cuelang.org/go/encoding/msgpack"
 	"cuelang.org/go/encoding/openapi"
+	"cuelang.org/go/encoding/properties"
 	"cuelang.org/go/encoding/protobuf/jsonpb"
 	"cuelang.org/go/encoding/protobuf/textproto"
+	"cuelang.org/go/encoding/xml"
 	"cuelang.org/go/internal"
+	cueyaml "cuelang.org/go/internal/encoding/yaml"
 	"cuelang.org/go/internal/filetypes"
 	"cuelang.org/go/pkg/encoding/yaml"
 )
@@ -175,6 +184,10 @@ func NewEncoder(f *build.File, cfg *Config) (*Encoder, error) {
 		}
 
 	case build.YAML:
+		fi, err := filetypes.FromFile(f, cfg.Mode)
+		if err != nil {
+			return nil, err
+		}
 		e.concrete = true
 		streamed := false
 		e.encValue = func(v cue.Value) error {
@@ -183,11 +196,21 @@ func NewEncoder(f *build.File, cfg *Config) (*Encoder, error) {
 			}
 			streamed = true
 
-			str, err := yaml.Marshal(v)
+			if !fi.Docs {
+				str, err := yaml.Marshal(v)
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprint(w, str)
+				return err
+			}
+			// Comments are only retained when encoding the syntax directly.
+			n := v.Syntax(cue.Final(), cue.Concrete(true), cue.Docs(true))
+			b, err := cueyaml.Encode(n)
 			if err != nil {
 				return err
 			}
-			_, err = fmt.Fprint(w, str)
+			_, err = w.Write(b)
 			return err
 		}
 
@@ -205,6 +228,70 @@ func NewEncoder(f *build.File, cfg *Config) (*Encoder, error) {
 			return err
 		}
 
+	case build.XML:
+		e.concrete = true
+		e.encValue = func(v cue.Value) error {
+			b, err := xml.Encode(v)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(b)
+			return err
+		}
+
+	case build.CSV, build.TSV:
+		e.concrete = true
+		c := csvConfig(f)
+		e.encValue = func(v cue.Value) error {
+			b, err := csv.Encode(v, c)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(b)
+			return err
+		}
+
+	case build.DotEnv, build.Properties, build.INI:
+		e.concrete = true
+		var encode func(v cue.Value) ([]byte, error)
+		switch f.Encoding {
+		case build.DotEnv:
+			c := &dotenv.Config{Nest: nest(f)}
+			encode = func(v cue.Value) ([]byte, error) { return dotenv.Encode(v, c) }
+		case build.Properties:
+			c := &properties.Config{Nest: nest(f)}
+			encode = func(v cue.Value) ([]byte, error) { return properties.Encode(v, c) }
+		case build.INI:
+			c := &ini.Config{Nest: nest(f)}
+			encode = func(v cue.Value) ([]byte, error) { return ini.Encode(v, c) }
+		}
+		e.encValue = func(v cue.Value) error {
+			b, err := encode(v)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(b)
+			return err
+		}
+
+	case build.CBOR, build.MsgPack, build.HCL:
+		e.concrete = true
+		encode := cbor.Encode
+		switch f.Encoding {
+		case build.MsgPack:
+			encode = msgpack.Encode
+		case build.HCL:
+			encode = hcl.Encode
+		}
+		e.encValue = func(v cue.Value) error {
+			b, err := encode(v)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(b)
+			return err
+		}
+
 	case build.Text:
 		e.concrete = true
 		e.encValue = func(v cue.Value) error {
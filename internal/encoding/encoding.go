@@ -34,12 +34,21 @@ import (
 	"cuelang.org/go/cue/literal"
 	"cuelang.org/go/cue/parser"
 	"cuelang.org/go/cue/token"
+	"cuelang.org/go/encoding/cbor"
+	"cuelang.org/go/encoding/csv"
+	"cuelang.org/go/encoding/dotenv"
+	"cuelang.org/go/encoding/hcl"
+	"cuelang.org/go/encoding/ini"
 	"cuelang.org/go/encoding/json"
+	"cuelang.org/go/encoding/json5"
 	"cuelang.org/go/encoding/jsonschema"
+	"cuelang.org/go/encoding/msgpack"
 	"cuelang.org/go/encoding/openapi"
+	"cuelang.org/go/encoding/properties"
 	"cuelang.org/go/encoding/protobuf"
 	"cuelang.org/go/encoding/protobuf/jsonpb"
 	"cuelang.org/go/encoding/protobuf/textproto"
+	"cuelang.org/go/encoding/xml"
 	"cuelang.org/go/internal"
 	"cuelang.org/go/internal/filetypes"
 	"cuelang.org/go/internal/third_party/yaml"
@@ -194,13 +203,17 @@ func NewDecoder(f *build.File, cfg *Config) *Decoder {
 		return i
 	}
 
-	// For now we assume that all encodings require UTF-8. This will not be the
-	// case for some binary protocols. We need to exempt those explicitly here
-	// once we introduce them.
+	// For now we assume that all encodings require UTF-8, except for the
+	// binary protocols, which are exempted explicitly here.
 	// TODO: this code also allows UTF16, which is too permissive for some
 	// encodings. Switch to unicode.UTF8Sig once available.
-	t := unicode.BOMOverride(unicode.UTF8.NewDecoder())
-	r := transform.NewReader(rc, t)
+	var r io.Reader = rc
+	switch f.Encoding {
+	case build.CBOR, build.MsgPack:
+	default:
+		t := unicode.BOMOverride(unicode.UTF8.NewDecoder())
+		r = transform.NewReader(rc, t)
+	}
 
 	switch f.Interpretation {
 	case "":
@@ -244,6 +257,19 @@ func NewDecoder(f *build.File, cfg *Config) *Decoder {
 	case build.JSON, build.JSONL:
 		i.next = json.NewDecoder(nil, path, r).Extract
 		i.Next()
+	case build.JSONC, build.JSON5:
+		c := &json5.Config{JSONC: f.Encoding == build.JSONC}
+		i.next = json5.NewDecoder(path, r, c).Extract
+		i.Next()
+	case build.CBOR:
+		i.next = cbor.NewDecoder(path, r).Extract
+		i.Next()
+	case build.MsgPack:
+		i.next = msgpack.NewDecoder(path, r).Extract
+		i.Next()
+	case build.HCL:
+		i.next = hcl.NewDecoder(path, r).Extract
+		i.Next()
 	case build.YAML:
 		d, err := yaml.NewDecoder(path, r)
 		i.err = err
@@ -271,6 +297,27 @@ func NewDecoder(f *build.File, cfg *Config) *Decoder {
 			d := textproto.NewDecoder()
 			i.expr, i.err = d.Parse(cfg.Schema, path, b)
 		}
+	case build.XML:
+		d := xml.NewDecoder(path, r, &xml.Config{Schema: cfg.Schema})
+		i.next = d.Extract
+		i.Next()
+	case build.CSV, build.TSV:
+		c := csvConfig(f)
+		c.Schema = cfg.Schema
+		i.next = csv.NewDecoder(path, r, c).Extract
+		i.Next()
+	case build.DotEnv:
+		c := &dotenv.Config{Nest: nest(f), Schema: cfg.Schema}
+		i.next = dotenv.NewDecoder(path, r, c).Extract
+		i.Next()
+	case build.Properties:
+		c := &properties.Config{Nest: nest(f), Schema: cfg.Schema}
+		i.next = properties.NewDecoder(path, r, c).Extract
+		i.Next()
+	case build.INI:
+		c := &ini.Config{Nest: nest(f), Schema: cfg.Schema}
+		i.next = ini.NewDecoder(path, r, c).Extract
+		i.Next()
 	default:
 		i.err = fmt.Errorf("unsupported encoding %q", f.Encoding)
 	}
@@ -278,6 +325,23 @@ func NewDecoder(f *build.File, cfg *Config) *Decoder {
 	return i
 }
 
+// csvConfig returns the CSV settings for the given file. The first row is
+// interpreted as a header unless the header tag is set to false, as in
+// csv+header=false:data.csv.
+func csvConfig(f *build.File) *csv.Config {
+	c := &csv.Config{NoHeader: f.Tags["header"] == "false"}
+	if f.Encoding == build.TSV {
+		c.Comma = '\t'
+	}
+	return c
+}
+
+// nest reports whether dotted keys of a flat format map to nested fields, as
+// requested with properties+nest=true:app.properties.
+func nest(f *build.File) bool {
+	return f.Tags["nest"] == "true"
+}
+
 func jsonSchemaFunc(cfg *Config, f *build.File) interpretFunc {
 	return func(i *cue.Instance) (file *ast.File, id string, err error) {
 		id = f.Tags["id"]
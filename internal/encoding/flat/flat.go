@@ -0,0 +1,186 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flat converts between the key-value pairs of flat formats, like
+// dotenv, Java properties or INI, and CUE structs.
+package flat
+
+import (
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/encoding/scalar"
+)
+
+// An Entry is a key-value pair read from a flat format.
+type Entry struct {
+	// Path holds the components of the key. It has more than one element
+	// for keys that map to nested fields.
+	Path []string
+
+	// Value holds the text of the value. It is ignored for sections.
+	Value string
+
+	// Section indicates that the entry only declares a struct at Path,
+	// such as an INI section.
+	Section bool
+
+	Pos      token.Pos // position of the key
+	ValuePos token.Pos // position of the value
+	Comments []*ast.CommentGroup
+}
+
+type node struct {
+	name     string
+	pos      token.Pos // position of the first key for this node
+	entry    *Entry    // last entry for a value
+	comments []*ast.CommentGroup
+	fields   []*node
+	index    map[string]*node // nil for values
+}
+
+func (n *node) isStruct() bool {
+	return n.index != nil
+}
+
+// Build converts the given entries read from s to a struct. Values are
+// interpreted according to schema, if it exists, as described in package
+// scalar. A later entry for the same key replaces an earlier one. Any
+// remaining comments of s are attached to the end of the struct.
+func (s *Source) Build(entries []Entry, schema cue.Value) (*ast.StructLit, error) {
+	root := &node{index: map[string]*node{}}
+	for i := range entries {
+		e := &entries[i]
+		n := root
+		for j, name := range e.Path {
+			last := j == len(e.Path)-1
+			isStruct := !last || e.Section
+			child := n.index[name]
+			switch {
+			case child == nil:
+				child = &node{name: name, pos: e.Pos}
+				if isStruct {
+					child.index = map[string]*node{}
+				}
+				n.index[name] = child
+				n.fields = append(n.fields, child)
+			case child.isStruct() != isStruct:
+				return nil, s.errorf(e.Pos, "key %s conflicts with an earlier key",
+					strings.Join(e.Path, "."))
+			}
+			if last {
+				child.comments = append(child.comments, e.Comments...)
+				if !e.Section {
+					child.entry = e
+				}
+			}
+			n = child
+		}
+	}
+	st := root.build(schema)
+	s.finish(st)
+	return st, nil
+}
+
+// last returns the position of the last key within n. It is used to close
+// nested structs, as the end of a decoded value may lie beyond the input.
+func (n *node) last() token.Pos {
+	pos := n.pos
+	if len(n.fields) > 0 {
+		pos = n.fields[len(n.fields)-1].last()
+	}
+	return pos
+}
+
+func (n *node) build(schema cue.Value) *ast.StructLit {
+	s := &ast.StructLit{}
+	for _, c := range n.fields {
+		f := &ast.Field{Label: scalar.Label(c.name, c.pos)}
+		sub := scalar.Lookup(schema, c.name)
+		if c.isStruct() {
+			st := c.build(sub)
+			st.Lbrace = c.pos.WithRel(token.Blank)
+			if len(c.fields) > 0 {
+				st.Rbrace = c.last().WithRel(token.Newline)
+			}
+			f.Value = st
+		} else {
+			f.Value = scalar.Decode(c.entry.Value, c.entry.ValuePos, sub)
+		}
+		for _, cg := range c.comments {
+			ast.AddComment(f, cg)
+		}
+		s.Elts = append(s.Elts, f)
+	}
+	return s
+}
+
+// A Pair is a key-value pair to be written to a flat format.
+type Pair struct {
+	Path  []string
+	Value string
+}
+
+// Flatten returns the key-value pairs for the regular fields of v, which must
+// be a struct. Structs nested within v may be at most depth levels deep,
+// where a negative depth allows any depth. Other values must be scalars. The
+// name of the format is used in error messages.
+func Flatten(v cue.Value, depth int, format string) ([]Pair, error) {
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		return nil, err
+	}
+	if k := v.Kind(); k != cue.StructKind {
+		return nil, errors.Newf(v.Pos(),
+			"%s: cannot encode value of kind %s: must be a struct", format, k)
+	}
+	var pairs []Pair
+	err := flatten(&pairs, nil, v, depth, format)
+	return pairs, err
+}
+
+func flatten(pairs *[]Pair, path []string, v cue.Value, depth int, format string) error {
+	iter, err := v.Fields()
+	if err != nil {
+		return err
+	}
+	for iter.Next() {
+		p := append(path[:len(path):len(path)], iter.Label())
+		f := iter.Value()
+		switch k := f.Kind(); k {
+		case cue.StructKind:
+			if depth == 0 {
+				return errors.Newf(f.Pos(),
+					"%s: cannot encode nested struct %s as a flat key",
+					format, f.Path())
+			}
+			if err := flatten(pairs, p, f, depth-1, format); err != nil {
+				return err
+			}
+		case cue.ListKind, cue.BytesKind:
+			return errors.Newf(f.Pos(),
+				"%s: cannot encode %s: values of kind %s are not supported",
+				format, f.Path(), k)
+		default:
+			s, err := scalar.Encode(f)
+			if err != nil {
+				return err
+			}
+			*pairs = append(*pairs, Pair{Path: p, Value: s})
+		}
+	}
+	return nil
+}
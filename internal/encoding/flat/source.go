@@ -0,0 +1,148 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flat
+
+import (
+	"bytes"
+	"fmt"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+)
+
+// A Source tracks positions and comments while scanning the lines of a flat
+// format.
+type Source struct {
+	Name string // name of the format
+	Path string
+	Data []byte
+	File *token.File
+
+	prevLine int // last line holding an entry or comment
+	comments []*ast.CommentGroup
+}
+
+// NewSource returns a Source for the given data, where name is the name of
+// the format.
+func NewSource(name, path string, data []byte) *Source {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	f := token.NewFile(path, -1, len(data))
+	f.SetLinesForContent(data)
+	return &Source{Name: name, Path: path, Data: data, File: f}
+}
+
+// Lines calls fn for each line of the input, passing the offset of the line
+// and the line itself without the line terminator. If fn returns a
+// non-negative offset, scanning continues at that offset.
+func (s *Source) Lines(fn func(off int, line []byte) (next int, err error)) error {
+	for off := 0; off < len(s.Data); {
+		end := bytes.IndexByte(s.Data[off:], '\n')
+		if end < 0 {
+			end = len(s.Data)
+		} else {
+			end += off
+		}
+		next, err := fn(off, bytes.TrimSuffix(s.Data[off:end], []byte("\r")))
+		if err != nil {
+			return err
+		}
+		if next < 0 {
+			next = end + 1
+		}
+		off = next
+	}
+	return nil
+}
+
+// Errorf returns an error for the input at the given offset.
+func (s *Source) Errorf(off int, format string, args ...interface{}) error {
+	return s.errorf(s.File.Pos(off, token.NoRelPos), format, args...)
+}
+
+func (s *Source) errorf(pos token.Pos, format string, args ...interface{}) error {
+	return errors.Newf(pos, "invalid %s for file %q: %s",
+		s.Name, s.Path, fmt.Sprintf(format, args...))
+}
+
+func (s *Source) line(off int) int {
+	return s.File.Position(s.File.Pos(off, token.NoRelPos)).Line
+}
+
+// Pos returns the position of an entry or comment starting at the given
+// offset, with a relative position reflecting any blank lines preceding it.
+func (s *Source) Pos(off int) token.Pos {
+	line := s.line(off)
+	rel := token.Newline
+	switch {
+	case s.prevLine == 0:
+		rel = token.NoRelPos
+	case line-s.prevLine >= 2:
+		rel = token.NewSection
+	}
+	s.prevLine = line
+	return s.File.Pos(off, rel)
+}
+
+// ValuePos returns the position of a value at the given offset.
+func (s *Source) ValuePos(off int) token.Pos {
+	return s.File.Pos(off, token.Blank)
+}
+
+// AddComment records a comment at the given offset, to be attached to the
+// next entry. Text holds the comment without its comment marker.
+func (s *Source) AddComment(off int, text string) {
+	if text != "" && text[0] != ' ' && text[0] != '\t' {
+		text = " " + text
+	}
+	prev := s.prevLine
+	c := &ast.Comment{Slash: s.Pos(off), Text: "//" + text}
+	if n := len(s.comments); n > 0 && s.prevLine-prev < 2 {
+		cg := s.comments[n-1]
+		cg.List = append(cg.List, c)
+		return
+	}
+	s.comments = append(s.comments, &ast.CommentGroup{List: []*ast.Comment{c}})
+}
+
+// Doc returns the pending comments for an entry at the given offset. A
+// group of comments directly preceding the entry is marked as its doc
+// comment.
+func (s *Source) Doc(off int) []*ast.CommentGroup {
+	cgs := s.comments
+	if n := len(cgs); n > 0 {
+		cgs[n-1].Doc = s.prevLine+1 >= s.line(off)
+	}
+	s.comments = nil
+	return cgs
+}
+
+// End records that the last entry ended at the given offset.
+func (s *Source) End(off int) {
+	s.prevLine = s.line(off)
+}
+
+// finish attaches any remaining comments to the end of st.
+func (s *Source) finish(st *ast.StructLit) {
+	for _, cg := range s.comments {
+		cg.Position = 100
+		if len(st.Elts) == 0 {
+			st.AddComment(cg)
+		} else {
+			ast.AddComment(st.Elts[len(st.Elts)-1], cg)
+		}
+	}
+	s.comments = nil
+}
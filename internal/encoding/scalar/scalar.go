@@ -0,0 +1,117 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scalar converts untyped text, as found in formats like XML or CSV,
+// to CUE literals and back.
+package scalar
+
+import (
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/literal"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal"
+)
+
+// Decode converts text to a CUE literal. Without a schema, or if the schema
+// allows strings, the text is returned as a string. Otherwise it is
+// converted to a number, boolean or null if the schema allows it and the
+// text is a valid representation of such a value.
+func Decode(text string, pos token.Pos, schema cue.Value) ast.Expr {
+	if schema.Exists() {
+		k := schema.IncompleteKind()
+		if k&cue.StringKind == 0 {
+			switch {
+			case k&cue.NumberKind != 0:
+				var info literal.NumInfo
+				if literal.ParseNum(text, &info) == nil {
+					tok := token.FLOAT
+					if info.IsInt() {
+						tok = token.INT
+					}
+					return &ast.BasicLit{ValuePos: pos, Kind: tok, Value: text}
+				}
+			case k&cue.BoolKind != 0:
+				switch text {
+				case "true":
+					return &ast.BasicLit{ValuePos: pos, Kind: token.TRUE, Value: text}
+				case "false":
+					return &ast.BasicLit{ValuePos: pos, Kind: token.FALSE, Value: text}
+				}
+			case k&cue.NullKind != 0 && text == "":
+				return &ast.BasicLit{ValuePos: pos, Kind: token.NULL, Value: "null"}
+			}
+		}
+	}
+	return &ast.BasicLit{
+		ValuePos: pos,
+		Kind:     token.STRING,
+		Value:    literal.String.Quote(text),
+	}
+}
+
+// Encode returns the text representation of a concrete scalar value.
+// Null maps to the empty string.
+func Encode(v cue.Value) (string, error) {
+	switch v.Kind() {
+	case cue.StringKind:
+		return v.String()
+	case cue.NullKind:
+		return "", nil
+	case cue.BoolKind, cue.IntKind, cue.FloatKind, cue.NumberKind:
+		b, err := v.MarshalJSON()
+		return string(b), err
+	}
+	return "", errors.Newf(v.Pos(), "cannot encode value of kind %s as text", v.Kind())
+}
+
+// Label returns the label for a field with the given name, using an
+// identifier where possible.
+func Label(name string, pos token.Pos) ast.Label {
+	if ast.IsValidIdent(name) && !internal.IsDefOrHidden(name) {
+		return &ast.Ident{NamePos: pos, Name: name}
+	}
+	return &ast.BasicLit{
+		ValuePos: pos,
+		Kind:     token.STRING,
+		Value:    literal.Label.Quote(name),
+	}
+}
+
+// Lookup returns the schema for the field with the given name, which may be
+// optional. It returns a non-existing value if schema does not exist.
+func Lookup(schema cue.Value, name string) cue.Value {
+	if !schema.Exists() {
+		return schema
+	}
+	if v := schema.LookupPath(cue.MakePath(cue.Str(name))); v.Exists() {
+		return v
+	}
+	return schema.LookupPath(cue.MakePath(cue.Str(name).Optional()))
+}
+
+// LookupIndex returns the schema for the list element at index i. If i is
+// negative, it returns the schema for any element.
+func LookupIndex(schema cue.Value, i int) cue.Value {
+	if !schema.Exists() {
+		return schema
+	}
+	if i >= 0 {
+		if v := schema.LookupPath(cue.MakePath(cue.Index(i))); v.Exists() {
+			return v
+		}
+	}
+	return schema.LookupPath(cue.MakePath(cue.AnyIndex))
+}
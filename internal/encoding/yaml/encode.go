@@ -226,6 +226,7 @@ func encodeExprs(exprs []ast.Expr) (n *yaml.Node, err error) {
 		if err != nil {
 			return nil, err
 		}
+		addDocs(elem, e, e)
 		n.Content = append(n.Content, e)
 	}
 	return n, nil
@@ -250,6 +250,27 @@ func TestFromFile(t *testing.T) {
 			Docs:         true,
 			Attributes:   true,
 		},
+	}, {
+		name: "YAMLDocs",
+		in: build.File{
+			Filename: "foo.yaml",
+			Encoding: build.YAML,
+			Tags:     map[string]string{"docs": "true"},
+		},
+		mode: Export,
+		out: &FileInfo{
+			File: &build.File{
+				Filename: "foo.yaml",
+				Encoding: "yaml",
+				Form:     "graph",
+				Tags:     map[string]string{"docs": "true"},
+			},
+			Data:       true,
+			References: true,
+			Cycles:     true,
+			Stream:     true,
+			Docs:       true,
+		},
 	}}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -38,7 +38,7 @@ package build
 }
 
 // A FileInfo defines how a file is encoded and interpreted.
-#FileInfo: {
+#FileInfo: X={
 	#File
 
 	// For each of these fields it is explained what a true value means
@@ -56,6 +56,11 @@ package build
 	stream:       bool          // permit streaming
 	docs:         bool          // show/allow docs
 	attributes:   bool          // include/allow attributes
+
+	// The docs tag requests docs in the output.
+	if X.tags.docs != _|_ {
+		docs: X.tags.docs == "true"
+	}
 }
 
 // modes sets defaults for different operational modes.
@@ -139,19 +144,33 @@ modes: def: {
 
 // Extension maps file extensions to default file properties.
 extensions: {
-	"":           _
-	".cue":       tags.cue
-	".json":      tags.json
-	".jsonl":     tags.jsonl
-	".ldjson":    tags.jsonl
-	".ndjson":    tags.jsonl
-	".yaml":      tags.yaml
-	".yml":       tags.yaml
-	".txt":       tags.text
-	".go":        tags.go
-	".proto":     tags.proto
-	".textproto": tags.textproto
-	".textpb":    tags.textproto // perhaps also pbtxt
+	"":            _
+	".cue":        tags.cue
+	".json":       tags.json
+	".jsonc":      tags.jsonc
+	".json5":      tags.json5
+	".jsonl":      tags.jsonl
+	".ldjson":     tags.jsonl
+	".ndjson":     tags.jsonl
+	".yaml":       tags.yaml
+	".yml":        tags.yaml
+	".txt":        tags.text
+	".go":         tags.go
+	".proto":      tags.proto
+	".textproto":  tags.textproto
+	".textpb":     tags.textproto // perhaps also pbtxt
+	".xml":        tags.xml
+	".csv":        tags.csv
+	".tsv":        tags.tsv
+	".cbor":       tags.cbor
+	".msgpack":    tags.msgpack
+	".mpk":        tags.msgpack
+	".hcl":        tags.hcl
+	".tf":         tags.hcl
+	".tfvars":     tags.hcl
+	".env":        tags.dotenv
+	".properties": tags.properties
+	".ini":        tags.ini
 
 	// TODO: jsonseq,
 	// ".pb":        tags.binpb // binarypb
@@ -181,13 +200,28 @@ tags: {
 
 	cue: encoding: "cue"
 
-	json: encoding:      "json"
-	jsonl: encoding:     "jsonl"
-	yaml: encoding:      "yaml"
-	proto: encoding:     "proto"
-	textproto: encoding: "textproto"
+	json: encoding:       "json"
+	jsonc: encoding:      "jsonc"
+	json5: encoding:      "json5"
+	jsonl: encoding:      "jsonl"
+	yaml: encoding:       "yaml"
+	proto: encoding:      "proto"
+	textproto: encoding:  "textproto"
+	xml: encoding:        "xml"
+	csv: encoding:        "csv"
+	tsv: encoding:        "tsv"
+	cbor: encoding:       "cbor"
+	msgpack: encoding:    "msgpack"
+	hcl: encoding:        "hcl"
+	dotenv: encoding:     "dotenv"
+	properties: encoding: "properties"
+	ini: encoding:        "ini"
 	// "binpb":  encodings.binproto
 
+	// docs requests that comments be included in the output, for encodings
+	// that support them.
+	docs: tags: docs: "true"
+
 	// pb is used either to indicate binary encoding, or to indicate
 	pb: *{
 		encoding:       "binarypb"
@@ -299,6 +333,43 @@ encodings: json: {
 	attributes: false
 }
 
+// jsonc and json5 are input-only encodings that, unlike JSON, may include
+// comments.
+encodings: jsonc: {
+	forms.data
+	stream:     false
+	attributes: false
+}
+
+encodings: json5: encodings.jsonc
+
+// cbor and msgpack are binary encodings. A stream of values is encoded as
+// consecutive values.
+encodings: cbor: {
+	forms.data
+	stream:     *false | true
+	docs:       false
+	attributes: false
+}
+
+encodings: msgpack: encodings.cbor
+
+encodings: hcl: {
+	forms.data
+	stream: false
+}
+
+// dotenv, properties and ini accept a "nest" tag: a value of "true"
+// indicates that dotted keys map to nested fields.
+encodings: dotenv: {
+	forms.data
+	stream: false
+}
+
+encodings: properties: encodings.dotenv
+
+encodings: ini: encodings.dotenv
+
 encodings: yaml: {
 	forms.graph
 	stream: false | *true
@@ -335,6 +406,23 @@ encodings: textproto: {
 	stream:   false
 }
 
+encodings: xml: {
+	forms.data
+	stream: false
+}
+
+// csv and tsv accept a "header" tag: a value of "false" indicates that
+// the first row is data, in which case rows map to lists.
+encodings: csv: {
+	forms.data
+	stream: true
+}
+
+encodings: tsv: {
+	forms.data
+	stream: true
+}
+
 encodings: binarypb: {
 	forms.data
 	encoding: "binarypb"
@@ -41,5 +41,5 @@ func cuegenMake(name string, x interface{}) cue.Value {
 	return v
 }
 
-// Data size: 1708 bytes.
-var cuegenInstanceData = []byte("\x01\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xc4X\xddo\xe4\xb6\x11\x97|W\xa0R\xd3>\xe6\xad\xc0\x9c\xae\bR\xe3*#\x1f@\x81\x05\x8cC\u047b+\xee\xa5)\x8a\xf4)\b\f\xae4\xdae#\x91*I%6\xe2}h\x9b\xa6\xfd\xab\xb3\u0150\x94(je\xfb\f\xa4\xa8_\xbc;?\xcepf8\x9f\xfb\xf3\xe3\xbf\xcf\u04b3\xe3\x7f\x92\xf4\xf8\x8f$\xf9\xed\xf1\xefO\xd2\xf4=.\xb4a\xa2\xc2W\xcc0\xa2\xa7O\u04a7\x7f\x96\u04a4gI\xfa\xf4O\xcc\xec\xd3\xf7\x92\xf4'ox\x8b:=~\x9f$\xc9/\x8f\xff:K\xd3_|\xf1e5`\xd9\xf0\xd6s~\x9f\xa4\xc7\xef\x92\xe4\xc3\xe3?\x9f\xa4\xe9O\x03\xfd\xbb$=K\x9f\xfe\x91uH\x82\x9eZb\x9e$\xc9\x0f\xef\xff\x8a4I\u04f34\xcd\xccM\x8f\xba\xac\x06L\x7fx\xffg=\xab\xbeb;\x84\xed\xc0\xdb:\xcf/.\xe0w@\xf7C%\x95B\xddKQk0\x12\x18\xfcA\xbaC%\xc1e\xfe\x9c\xfem\xe0\xdb<\xa3\xeb\x05\xebp\x03\xfeO\x1b\xc5\xc5.\xcfPT\xb2\xe6b7\x01\xcf_{J\x9eqaP\xf5\n\r3\\\x8a\x97\x1bx\xfe6\xa2\xe4Y#U\xf7rb%\xee7Ruyf\xd8N\xbf\xb4\x17g_\xb8\x9b\xbe\xdcLW\x1e\xf2\x835\xe2\x156lh\rp\rf\x8f@*\u00a0\xb1\x86F*\u0426\xe6\x02\x98\xa8\xe9\x93\x1cL\t\x9f\xef\x114\x1a\xc3\xc5NC\x8d=\x8a\x9a\xa4H\x11\xb8;Y\x93\xd5^\xf0\x06\xac\xfd\xf0A\xec\x80\xf3\xe27\x05\u070e\xda\x1cf\xfe|+\x1a\t56\\\xa0\x86\xbd\xfc\x06\x98\x13\xcb5X7am\x15\x9a\u0702\xb5w11Zk\xed\xb7<\xab\x99a\xc1+\xe7F\r\b\xb7\u0430Vc\x9e)lP\xa1\xa8PoN\xc1\xea\xa6j\x1d\xb0\xc2iU\xe3\xe4y:\xb1\x95\xb2\xcd3\xd9\xd3w\xd6:\x16G\xab\xa4\xd0F1.L8\xf7\x15b\xef\xfd\xa27\x9e\xc6E%\xbb\xbeEc\xc3\xc2\u04fa^*3j\xe0h\xda(d\u0768\x94\xa3\u0572\xd2\xc1DGc\xc6(\xbe\x1d\x8c3\xc0\u049c{\xe9]4=\x1e=\x9c\xd3\xc1>r\xcd\x1b\xeb\v\x03\xb2G\u015c%\xeet\x99_\\\x10\xeb\xe7{\xd4\b\x06\xbb\xbee\x0650\x85\xf6\x01\x04\xbd\x86\x91\xb0E\x18\x04o8\u04bb\x0036\x18\x94\x94\x06d\x03f\xcf5\t\xa9\xa4h\xf8np7\x94\xb9\xbd\xc0\xbe\x17\x17\xfd`\\\x9c\xb6h\xe0\x1a.\xed\xe7\u023a\xc5#d\x91\x99K\xf0\x90gY\x88?++d\xd8yQ\rH\xb1wE\xf4\xb2,G\x86\x10C\xd7y`\xd0^@5P\xd4R\xaa\xe9RW{\xec\x98\x17A\xbcxmPh\x17\x12\xf6tQ\xfeUKQ\xf8o\x8b\x1c&\x1d\xd8`\xe4\xa4\xc4\xc1\xb1\u0730\xae},\xcb\xe38\x0e\x94\xf7\x19^St\xcd\x1c~\xf5\u045a\u02fdS\xcfW]\xbe\x04\x1fp\xb9\xf5\xc6\xfd>\xbf\xfa\xe8\x01\xafS>\a\x9f\x1f\xf2L\x0e\xbd\x89\x02\xe7\xea\xe3\x1f\u01ce\xb9V\x1f?V+\xfc\x9a\xea@\xd0\xe9\x93\xff\xb5o\x1f\x0e\xe7\xabO\x1e0\xa2\xe1\x94\xf2s+jl\xe6F|\xfa\xff\xcf\u026bO\x1f\x99\x95c\x87{=&'t\xac\u05ee\x99\x84\x84\xa5\xf2\xe5\u02e1\x83zEe\xd0p\xaa~\x8b\xbc.\x8ay\x97\xbd\u02b3\x82\x86\x83\x89H\xfd\x96\byH\xff@'\xc2\b\xb4\x1e\x99\x80\x96\x90\xb6\x0eL1\"\xeeD|\xc9\b\u0488\x90O\x85a\x050\xd7&\x06\f^\x1b\x02v2Xg\x81\x9d$r\xaf\xa4\x91s}-\xc1J\xc2k3\xa2\x93\xa4\x18\xdd\xcet\x0eh\x9eQK\xf9\xec\xd5g\x1b C4\xfe\xed\x85%\x15\xe5\xc801m\xb9\xe8\xb7pq\x01[.\x98\xba\xe9\xb7\u04e80\x0eH\xc0E\xcd+\u05d5\xdc\x03R40c[\x9b\xc2^\xa1FA\xe3\n0z\u069db]\x99O\xe3\xd5\x06\x9e]\x16\x85\x13) \x1e\xac\xa0F\x83\xaa\x9b\xcd!\x15*\u00f8\x18\xe5\x80\xde\u02e1\xad\xa9\xfbE\xd3\xc8\xc5\x05\xbc\x91\n\xc6\x11\xf6\x05\xd8\x1a\u0471\x9b\xc5I`\u0509u\xa5\xf8\xd6\xe9\xe7\"\xf8\x05|\xb3\xe7\xd5\x1e\xb8\xd1\xd86\xb6s2A\xac\x95\x14_\xa32\xae\xe52\xf8\xfd_^{\x8e2_\u0304\u04d8g'\xc1y\xd0zzcG\xd2hd\x1cG\xaf\u0160V4R\xbahv\x83\xa6\xe3*\xdc\u0145\x7f\x0ez+\x97]\x95\xec:\x1a\xcfZ.\u0411\x8d<\xcd+\x02lF91.\x99\x9d\xf4I2\xa5\xf0N\xb1~\x1f\xa1\x96\xe2\xc0\x9a\xed\"\xa8f\xbb\x110l\x81\x18/\xd0\u058bo\xf3y\xed\xb1\xa5\u01c2d\xe5\t\xeaM\xf7p\xbb\x8a\xb7\xee\x00\xa5\xd8\tn3\xd4\xc26\xf8Op\x97A\xf6\xc0\x94!'\x87B\xaa\xd1A\x9b,\xfd\x96&b;\xa9#7{T\xe4\xe81\x17|\xba\xc0(\xe2\x05\xc8\b\u03f3~\xbb\x81\xf3\xf8\x16\xf7W\x8c\x99V\xe4\xa7#EA\xf7\xc3-\xac1>\xbb\xbc\x9f\u0552\xbd\x95\xab\x06\x16\u04c3Y=\u00a39\xb1'<\x8e|'\xd7\xee\u010d\xde@\xda!\xee2.\x9b\"3\xcbZf\xaf\u0651\xd3}W$\xd6\x1fE\uae05y\xb94\xa89\xfc\x84\xdd\xcep+\x17F3\x95\x8f\xcey6\x9d\b\n\a\xdeE\x9c\xecQ\xb0\x9e\xdf!\u02e3\xef \xc8\xd5\a\u06e0\xa7\xa5\xce7j*\u042cm\x1dX\xc2[\x03\xb5D\rB\x1a\xe0\xa2j\x87\x1a\xddN)U\ao_\x95\xb9=g\x15\xb2\x1b-\xed\xee\x97\xd3Z;\xd5/\xab=5\uaaf5\xea\x02\x93\x96\xde\x15p\v\x85\x9d~\uc9f1\xba,\x96\xad\xe5@\x16\xafl\xcbI'^\x10\x97h\xbc*~\x18\xc1\xbf\x86\x0f\x96\x94<[,\x92Ky\xf1J\xb9D\xe3Er\x81\x1e\xa8\u038bqZ\x9d\x0fQ'\xfe\xf2>:\xb9o\u076a \xff\xa4\x80\x87\ap\xbe&\xafS\xe1v\xffm\xee.\x16w\xd2\xf9\xc4\xe7\ubfbeW\x9b\x85\x1f\xd7\xfd\xb7\xee\xb7`O\xd4stim\x98\xd9\xf6\xec2\x84\xd0\xf8#\u009cy\u0797hu\xd8-\xfd\xf2\xec\u04b7\xb1X\xdbQ\xad\xe8W\x8b\u026e\xf9\xaf\x15\xab\x06\xac\xfae\xd2\xeb\x90\xc7S\xf5\xd4#\xc7$\b\x16\x84\x0e\x19\x96\x9fE\xb6\xb8$\x81\xdb\xf1\xdd\xe6\v\u00e8\xc7|O\b\xc2C\xfb\x8c\x9d\x1b\xa9Ai\xe8$\xc7\x1dyU\x9f\xe9`\xe89\xab\xe7\x82\x0e\xf3V\xf3\xc0Q#\xbb\xfb\xee\x0e\ag-}\x91c\xef4\x06D\xd2\xef\x98\tf/\xb0\xb4\x85\x1a\xfd}b\xe6={MJhy\v\xe5O\f=\xe4q\x9fxD\xad\xb6\u02d4k\x82\xf1-\u02eev\xa7\x03\xef\xed_\xef\u0335\xea\xace4\x1d\xf2$\xf9o\x00\x00\x00\xff\xff\x85i\xbeS\xb4\x16\x00\x00")
+// Data size: 2043 bytes.
+var cuegenInstanceData = []byte("\x01\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xc4X_\x8b\xe4\xc6\x11\x1f\xad/\x10\t'_\xc0\x04ju`\xce\xcbE\xc3\xd9\xde<\f\fG\xc8\u0745{\xb1CH `\xcc\xd0#\x95f:'u\xcb\u076d\xf5,\xb7C\x12\xc7\xc9[\xbeF>\xa67Tw\xeb\xbfvg\x17l2/\xb7W\xbf\xfa\xd7?UWW\xf7/n\xffu\x16\x9c\xdd\xfe{\x11\xdc\xfe}\xb1\xf8\xcd\xdf>\b\x82\x0f\xb9\u0406\x89\x14_1\xc3H\x1c|\x10<\xf9\xa3\x94&8[\x04O\xfe\xc0\xcc>\xf8p\x11\xfc\xec\r/P\a\xb7\xdf/\x16\x8b_\xdd\xfe\xf3,\b~\xf9\xd5\xd7i\x8dI\xce\vo\xf9\xfd\"\xb8\xfdn\xb1xv\xfb\x8f\x0f\x82\xe0\xe7\x9d\xfc\xbbEp\x16<\xf9\x82\x95H\x8e\x9eXa\xb4X,~\xf8\u8fd4H\x10\x9c\x05Ah\xae+\xd4IZc\xf0\xc3G\xff\xa9X\xfa\x8e\xed\x10\xb65/\xb2(Z.\xe1\xb7@\xf1!\x95J\xa1\xae\xa4\xc84\x18\t\f~/\x9dRBp\x12=\xa5\x7fV\xf0>\n)\xbc`%\xae\xc0\xff\xb4Q\\\xec\xa2\x10E*3.v-\xf0\xf4\xb5\x97D!\x17\x06U\xa5\xd00\u00e5x\xb9\x82\xa7o\a\x92(\u0325*_\xb6\xa6d\xfdF\xaa2\n\r\xdb\xe9\x976p\xf8\x95\x8b\xf4\xf5\xaa\ry\x8c\x8ev\x11\xaf0gua\x80k0{\x04J\x11j\x8d\x19\xe4R\x816\x19\x17\xc0DF\x7f\xc9\xda$\xf0\xa7=\x82Fc\xb8\xd8i\u0230B\x91\x91\x17):\xebRf\xb4j\xefx\x05v\xfd\xf0\U0005000b\xf8\xd71\xdc4\xd9\x1c{|\xbe\x15\xb9\x84\fs.P\xc3^~\v\u0339\xe5\x1a,M\x98\u0644ZZ0\xf3\x14\x93\xe1\n\xfe\xb2y\xb1~\x1f\x85V\x12\x85\x193\xacc\xe6\u00a8\x1a\xe1\x06rVh\x8cB\x859*\x14)\xea\xd5\x14L\xaf\xd3\xc2\x013\x966=N\xec\x93\xc6V\xca\"\neE\xffg\x853q\xb2T\nm\x14\xe3\xc2tz\xef\x10+\u03cd^y\x19\x17\xa9,\xab\x02\x8d-\r/++\xa9L\x93\x81\x93i\xa3\x90\x95MRN\x96\xc9TwKt2f\x8c\xe2\xdb\u06b8\x05XY\x14.\x97\xf6\xeb\x91>\x18\xb6\x03\x85\xdf\u0528\x8dv\x12\uefa0\xacMU\x9b$\nyNT&TC\x89U8_\xc3\xe6f\x03\xcb%\xe0\xa1*x\xca\r\xa0RR\xc13\x12\x17\u0720b\xc5'\xe4G\xcbZ\xa5\x18\x85Tx.\xbb\xa1\xa7\xf5\x1ab\xe23\uea90jFSaQQ9nl\x01f<\xb7\xdf\u0200\xacP1\u01f0\xd3N\xa2\xe52rk\xd2\b\x06\u02ea`\x0650\x85\xb68\x04U\x8a\x91\xb0E\xa8\x05\xcf9R\xcd\x003v\x99JJ\x032\a\xb3\u769c\xa4R\xe4|W\xbb\bId\x03\u061d\xc3EU\x1b\xb7\x87\n4p\x80\xb5\xfd{\xc0\xfa\xa88\xc2\x01\xfdc\xf0\x18\x85a\xb77\xac\xafn\xf7_\xc4i\x8d\xb4/6$O\x92\xa41\xe8\xea\xfb\x10u\x06\xda;Hk\xdaQ\xd4\x06t\xa2\xd3=\x96\u033b [<\x18\x14\u0695\xaa\u054e\x93\xbfj)b\xff\xbfQ\x7f\xa1\x1cXmd\x9b\xc4\u0459\\\xb3\xb2x\xac\xc9\xe3,\x8eT\r!\x1e\xa8\xea{\x84o^\xccQ\xeeI\xbd\x98\xa5|\f\x9e\xa0\u0732q?\xe7\x9b\x17'X\xa7>\xd3q~\x8cBYWfP8\x9bO\x7f\x9cu\xf4\xb3\xfa\xf4\xb1Y\xe1\x15\xf5\xa7.\xa7\xcf~jnO\x97\xf3\xe6\xb3\x13\x8b\xc89m\xf9\xfe*2\xcc\xfb\x8b\xf8\xfc\xff\xbf'7\x9f?rW6}\xefu\xb39\xa1d\x95v\a]\xb7a\xa9}\xf9v\xe8\xa0JQ\x1b4\x9c\xba\xdfh_\xc7q\x7f\x02\xa081M.\x9d\xd4\xf6\u07f4\u01a8k\x00=\x80$\r\x926P\x8b\xa4\rt9\x81.\x1b\xa8\x98@\x05AE\xd6\v6\x84\xc4\u0750o7=\x87$\x89\u06ae2\x87\x98\x83\x19!\x06\x0f\x86\x90\x9d\xec\x91c\x91\x9d$y\xa5\xa4\x91\x83\xb4\xad\xc4:\u00c3i\xe1\xd6\xd9\x10\xde\xf6S\x1f\xc0\x87I\x92\a\x97c\xaa\xaf\xc6_D_Y\x87\x13\xc08 \xddJ5d\x82$\x84\x94zG3\xa9\x03-\xe2%\x16\xac\u078d\xfc\xf5\xc0}:No\x9f:\n\xf31Q-p\u0154\xee\xaf\xd7\x03(\xc6yg\u04a0\xb8\xf2\xf4\xfaj\x8dW-\xbd^B8\x17|d\xcb\x05\xf7\x83\u0297\xaf\xbe\\\x01\x95\x83\xc6o\x9e[Q\x9c\xb4\x847\xea[.\xaa-M$[.\x98\xba\xae\xb6\xed \u064c\xcf\xc0E\xc6S7\x17\xb8-D\xfb\x91\x19;\\(\xac\x14j\x144\xcc\x02\xa3\u0375S\xacL\xa2v\xf8^\xc1\xf9:\x8e\x9dK\x01\u00f1\x1b24\xa8\xca\u0794\x9a\xa22\x8c\x8b\xc6\x0f\u8f6c\x8b\x8c\xe6\x8f\xc1\xac\xba\\\xc2\x1b\xa9\xa0\xb9\xe0<\a\u06e5Kv=\xd2\x04F\xb3\x90N\x15\u07fa\xfc\\\x0fy\x0e\xdf\xeey\xba\an4\x16\xb9\x9d]\x98 \xd3T\x8a+T\xc6\r=\f~\xf7\xe7\xd7\xde\"\x89F7\x86\xf6\x12`\xef\t\xfd\xb6\xe1\u5e7d\xb0\xf4~m\xaf\x1b\x8f\xf1q.\xa5\xeb&\xee\x1a\xe2\xacb\x178\xf6\x9f\x83\xbe\x95\xebo\xa9,K\x1a\xde\v.\u0409\x8d\x9cv6\x02lOsn\\;u\xde[\xcf\xd4Dw\x8aU\xfb\x01j%\x0e\xcc\xd8n\x00el\xd7\x00\x86\x8d\x10\xe3\x1d\u068e\xfd>\xeaw\x7f\xdb\xfc-H\xab\x9c\xa0~\xe9\x1eNg\xf1\xb4S\xb8\x9cU\xb8\xec\x14\x8aY\x85\xc2)P\xa3\x9b\xe0\xb6SZ\xd8v\x9f\t\ue698Uh[\xd4D\xa9\xebvV\xf10\x13\xe7\u0404I\xf5\u0554$}\xe5C\u0300\xa6\x01\xa9qMM\xa9\xbfY\xd87\xa8\x89F\xd3\xe7\xac\xd2>\x9d\xa6F\xed\xcc}Z\xdbz&\xb8\x13\xb7$\xf9:\x9bc\xaaiWV\x95\v>\u0461\x96\xe5C\xa5\xdeC[\xad\xcd\x00\xd2\xdcm\xdc9o{W\xb5\xa5\ubafdV#7{TT\xf7Mk\xf2\xdd\v\x9a0\xcfA\x0e\xf0(\xac\xb6+\xb8\x18f\xe2~q\xd3\xf8(\xdcx\u018e)S\xb8\x819\xc3\xf3\xf5\xfd\xa6V\xecKf\xb6Z\xe2v\xff\xd8<\xba=\xe4\xdcNl\x9c\xf8N\xab\u0764&\xfd\x02\xe9\xc2\x7f\xd7\xe2\xfa\xd4\x17\u0306\xd9\u0256\xf8\x90L\x7f\x14\xaf\u0353\x89\xf7K7\x17\x87O\xcc\xed\xa5f&\xe0\xe0\x92\xe1\xb7z\xbf\xb9M\x1cu\n\x0fq'+\x14\xac\xe2w\xf8\xf2\xe8\x03\x1c\xb9vm'\xd6\xf6\x05\xc6O\xaet^\xb2\xa2p`\x02o\rd\x125\bi\x80\x8b\xb4\xa83t\x0f@R\x95\xf0\xf6U\x12Y=\x9b\x90}~\xfa\x82\x95\xb8n\u07e0\xda\xe3\xc4fO\x93\xebf\xae\xd9C\x9b\xa5\xa7\x02n \xb6\xd7\x01\xfbW\xd3\xecG\xaf\"\xe3\x1b\xca\xf0me<\xfa\x0f_r\xc6\xe8\xf0M\xe7\xd9\x00\xfe\x04>\x1eK\xa2p\xf4\xe23\xf67|\xfb\x19\xa3\xc3\x17\x9f\x11z\xa4cW4\u05f7\xfe\xadb\u0097\xe7h\x12o~U\x9d\xff\xc9y\xda}\x00\xc75\xb1N\xe7\xa8\xfb\xd7\xee\xdd\xd1\v\x1b\xe5<\xe1|\x9e\xeb{\xb3\x19\xf18\xcf\xdf<o\xddz\x06#\x80N\xec\x1azk;_w%\u053c\xf6\xf5\x8d\xfbc\x02\u0765wc^\xce\xd7~\xaa\x18f\u06e45x^l\xd7\xd5\x7fV\x9c]\xc0,/m^\xc7hx\xcdlG\x96f\x13t+\xe8\x06\x96\xee5`\xb4[\xdc&\x81\x9b\xe6\xbb\xf5o\xd0M\x1e\xfd\x8b\xf3\xd0yz\xc2\xfbi\x0f\x97\xab\xf6\xd4k/\x9a\u0754\xf0\x13\xa4\xdd\xce\x18]Xw\x9dj\xe7\x8a\u0660\xbd\xb2\xe8\x8d\x18'T\xfb\xa3F\x17\xae\xb9\x1e\xd9\xe9\xc2\xff\xa6h7\xea\rkw\x10\x85\xba\x9cc`8=\xce\xe6\xd5*vG\xfa\x89\xfc\xfb'\xf9\tU#\u02c7\xd1\xd7\x1b?G-\xecA#\xeb\xc0\xfb\x1d\xf3k\xafR\xba\xb8\x87\a\xe6\xd7\x0e\xb7'8|\x98Z3b\u075f|\x7f\x10\x9b\u02fd\x9bcF\x94M\xd2?F\xc3\xc3\xff\x11\a\xb0}1r\x93\xcd0\xcaxT\xb9\xf3\xb3\xdd;\x94<\xd8j\x96\xac1\xb1\xc7h\xb1\xf8_\x00\x00\x00\xff\xffrt\x13\xcf5\x1c\x00\x00")
@@ -0,0 +1,222 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package graph computes the import graph of packages and the reference
+// graph of values, and writes them in the DOT, mermaid or JSON format.
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/internal/core/dep"
+	"cuelang.org/go/internal/core/eval"
+	"cuelang.org/go/internal/value"
+)
+
+// A Graph is a directed graph with nodes identified by name.
+type Graph struct {
+	nodes map[string]bool
+	edges map[Edge]bool
+}
+
+// An Edge is an edge from one node to another.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// New returns an empty graph.
+func New() *Graph {
+	return &Graph{nodes: map[string]bool{}, edges: map[Edge]bool{}}
+}
+
+// AddNode adds a node, if it does not already exist.
+func (g *Graph) AddNode(name string) {
+	g.nodes[name] = true
+}
+
+// AddEdge adds an edge and its nodes, if they do not already exist.
+func (g *Graph) AddEdge(from, to string) {
+	g.AddNode(from)
+	g.AddNode(to)
+	g.edges[Edge{from, to}] = true
+}
+
+// Nodes returns the sorted names of the nodes of g.
+func (g *Graph) Nodes() []string {
+	a := make([]string, 0, len(g.nodes))
+	for n := range g.nodes {
+		a = append(a, n)
+	}
+	sort.Strings(a)
+	return a
+}
+
+// Edges returns the edges of g, sorted by their nodes.
+func (g *Graph) Edges() []Edge {
+	a := make([]Edge, 0, len(g.edges))
+	for e := range g.edges {
+		a = append(a, e)
+	}
+	sort.Slice(a, func(i, j int) bool {
+		if a[i].From != a[j].From {
+			return a[i].From < a[j].From
+		}
+		return a[i].To < a[j].To
+	})
+	return a
+}
+
+// Imports returns the import graph of the given instances and their
+// transitive imports. Nodes are import paths, and an edge from one package
+// to another indicates that the former imports the latter. Builtin
+// packages are included as nodes without imports.
+func Imports(insts []*build.Instance) *Graph {
+	g := New()
+	seen := map[*build.Instance]bool{}
+	var add func(inst *build.Instance)
+	add = func(inst *build.Instance) {
+		if seen[inst] {
+			return
+		}
+		seen[inst] = true
+		from := name(inst)
+		g.AddNode(from)
+		for _, p := range inst.ImportPaths {
+			g.AddEdge(from, p)
+		}
+		for _, imp := range inst.Imports {
+			add(imp)
+		}
+	}
+	for _, inst := range insts {
+		add(inst)
+	}
+	return g
+}
+
+func name(inst *build.Instance) string {
+	if inst.ImportPath != "" {
+		return inst.ImportPath
+	}
+	return inst.DisplayPath
+}
+
+// References returns the reference graph of v. Nodes are paths of values,
+// and an edge from one value to another indicates that the former refers
+// to the latter. The graph includes the references of v and all its
+// fields, including definitions, hidden fields and list elements, to
+// values anywhere in the configuration. The references of a field include
+// those of the values unified into it, such as the fields of a definition
+// it is unified with. Only values that refer to or are referred to by
+// other values are included as nodes. Values in imported packages are
+// named by their import path followed by a dot and their path, as in
+// example.com/k8s.#Deployment.
+func References(v cue.Value) *Graph {
+	g := New()
+	var walk func(v cue.Value)
+	walk = func(v cue.Value) {
+		from := v.Path().String()
+		r, n := value.ToInternal(v)
+		ctx := eval.NewContext(r, n)
+		dep.Visit(ctx, n, func(d dep.Dependency) error {
+			to := value.Make(ctx, d.Node).Path().String()
+			if imp := d.Import(); imp != nil {
+				path := imp.ImportPath.StringValue(ctx)
+				if to == "" {
+					to = path
+				} else {
+					to = path + "." + to
+				}
+			}
+			g.AddEdge(from, to)
+			return nil
+		})
+
+		switch v.IncompleteKind() {
+		case cue.StructKind:
+			iter, err := v.Fields(cue.All())
+			if err != nil {
+				return
+			}
+			for iter.Next() {
+				walk(iter.Value())
+			}
+		case cue.ListKind:
+			iter, err := v.List()
+			if err != nil {
+				return
+			}
+			for iter.Next() {
+				walk(iter.Value())
+			}
+		}
+	}
+	walk(v)
+	return g
+}
+
+// WriteDOT writes g in the DOT language of Graphviz.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph {\n")
+	for _, n := range g.Nodes() {
+		fmt.Fprintf(&b, "\t%s;\n", strconv.Quote(n))
+	}
+	for _, e := range g.Edges() {
+		fmt.Fprintf(&b, "\t%s -> %s;\n", strconv.Quote(e.From), strconv.Quote(e.To))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidQuote escapes quotes in labels, per
+// https://mermaid-js.github.io/mermaid/#/flowchart?id=entity-codes-to-escape-characters
+// This also requires that we escape the quoting character #.
+var mermaidQuote = strings.NewReplacer("#", "#35;", `"`, "#quot;")
+
+// WriteMermaid writes g as a mermaid flowchart.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	ids := map[string]int{}
+	for i, n := range g.Nodes() {
+		ids[n] = i
+		fmt.Fprintf(&b, "  n%d(\"%s\")\n", i, mermaidQuote.Replace(n))
+	}
+	for _, e := range g.Edges() {
+		fmt.Fprintf(&b, "  n%d-->n%d\n", ids[e.From], ids[e.To])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes g as a JSON object with the fields nodes and edges.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	e.SetEscapeHTML(false)
+	return e.Encode(struct {
+		Nodes []string `json:"nodes"`
+		Edges []Edge   `json:"edges"`
+	}{g.Nodes(), g.Edges()})
+}
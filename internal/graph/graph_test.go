@@ -0,0 +1,154 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph_test
+
+import (
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/internal/graph"
+)
+
+func TestReferences(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		path string
+		out  string
+	}{{
+		name: "fields",
+		in: `
+#Port: int & >=1024
+#Service: {
+	name: string
+	port: #Port
+	url:  "http://\(name):\(port)"
+}
+defaults: port: 8080
+services: web: #Service & {
+	name: "web"
+	port: defaults.port
+}
+`,
+		path: "services",
+		out: `services.web -> #Service
+services.web.port -> #Port
+services.web.port -> defaults.port
+services.web.url -> services.web.name
+services.web.url -> services.web.port
+`,
+	}, {
+		name: "lets and lists",
+		in: `
+a: 1
+let x = a
+b: [x, c]
+c: {d: a}
+`,
+		out: `b[0] -> a
+b[1] -> c
+b[1].d -> a
+c.d -> a
+`,
+	}, {
+		name: "imports",
+		in: `
+import "strings"
+
+a: strings.ToUpper(b)
+b: "x"
+`,
+		out: `a -> b
+a -> strings.ToUpper
+`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := cuecontext.New().CompileString(tc.in)
+			if err := v.Err(); err != nil {
+				t.Fatal(err)
+			}
+			g := graph.References(v.LookupPath(cue.ParsePath(tc.path)))
+			var b strings.Builder
+			for _, e := range g.Edges() {
+				b.WriteString(e.From + " -> " + e.To + "\n")
+			}
+			if got := b.String(); got != tc.out {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.out)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	g := graph.New()
+	g.AddEdge("a", `#B."x"`)
+	g.AddNode("c")
+
+	testCases := []struct {
+		name  string
+		write func(*graph.Graph, *strings.Builder) error
+		out   string
+	}{{
+		name:  "dot",
+		write: func(g *graph.Graph, b *strings.Builder) error { return g.WriteDOT(b) },
+		out: `digraph {
+	"#B.\"x\"";
+	"a";
+	"c";
+	"a" -> "#B.\"x\"";
+}
+`,
+	}, {
+		name:  "mermaid",
+		write: func(g *graph.Graph, b *strings.Builder) error { return g.WriteMermaid(b) },
+		out: `graph LR
+  n0("#35;B.#quot;x#quot;")
+  n1("a")
+  n2("c")
+  n1-->n0
+`,
+	}, {
+		name:  "json",
+		write: func(g *graph.Graph, b *strings.Builder) error { return g.WriteJSON(b) },
+		out: `{
+  "nodes": [
+    "#B.\"x\"",
+    "a",
+    "c"
+  ],
+  "edges": [
+    {
+      "from": "a",
+      "to": "#B.\"x\""
+    }
+  ]
+}
+`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := tc.write(g, &b); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tc.out {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package infer infers a CUE schema from sample data.
+//
+// The inferred schema allows every sample and describes:
+//
+//   - the kinds of each value, as a disjunction if a value takes on more than
+//     one kind;
+//   - for struct fields, whether they are present in all samples or are
+//     optional;
+//   - for strings with few distinct values, of which at least one is
+//     repeated, a disjunction of these values;
+//   - for lists, the type of their elements;
+//   - for map-like structs, a pattern constraint for all of their fields.
+//
+// A struct is considered map-like if any of its field names is not a valid
+// identifier, or if the samples have different sets of fields of which none
+// are present in all samples.
+package infer
+
+import (
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+)
+
+// DefaultMaxEnum is the default value for Config.MaxEnum.
+const DefaultMaxEnum = 5
+
+// Config defines options for inferring a schema.
+type Config struct {
+	// MaxEnum is the maximum number of distinct values of a string for which
+	// a disjunction of these values is inferred, rather than string. If it is
+	// zero, DefaultMaxEnum is used. A negative value disables the inference
+	// of disjunctions.
+	MaxEnum int
+}
+
+// An Inferrer accumulates samples from which it infers a schema.
+type Inferrer struct {
+	cfg  Config
+	root *node
+}
+
+// New returns an Inferrer without any samples. The config may be nil.
+func New(c *Config) *Inferrer {
+	i := &Inferrer{root: &node{}}
+	if c != nil {
+		i.cfg = *c
+	}
+	if i.cfg.MaxEnum == 0 {
+		i.cfg.MaxEnum = DefaultMaxEnum
+	}
+	return i
+}
+
+// Add adds the given sample, which must be concrete.
+func (i *Inferrer) Add(v cue.Value) error {
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		return err
+	}
+	return i.root.add(v, &i.cfg)
+}
+
+// Schema returns an expression for the schema inferred from the samples added
+// so far. It returns _ if no samples were added.
+func (i *Inferrer) Schema() ast.Expr {
+	return i.root.expr(&i.cfg)
+}
+
+type node struct {
+	count int // number of values
+	kinds cue.Kind
+
+	// strings holds the distinct values of strings in order of appearance,
+	// as long as there are no more than MaxEnum of them. Otherwise it is
+	// nil and tooMany is set.
+	strings  []string
+	nstrings int // number of strings
+	tooMany  bool
+
+	nstructs  int // number of structs
+	maxFields int // maximum number of fields of a single struct
+	fields    []*field
+	index     map[string]*field
+
+	elem *node // elements of all lists
+}
+
+type field struct {
+	name  string
+	count int // number of structs in which the field is present
+	value *node
+}
+
+func (n *node) add(v cue.Value, c *Config) error {
+	n.count++
+	k := v.Kind()
+	n.kinds |= k
+
+	switch k {
+	case cue.StringKind:
+		s, _ := v.String()
+		n.addString(s, c)
+
+	case cue.StructKind:
+		n.nstructs++
+		iter, err := v.Fields()
+		if err != nil {
+			return err
+		}
+		nfields := 0
+		for iter.Next() {
+			nfields++
+			f := n.field(iter.Label())
+			f.count++
+			if err := f.value.add(iter.Value(), c); err != nil {
+				return err
+			}
+		}
+		if nfields > n.maxFields {
+			n.maxFields = nfields
+		}
+
+	case cue.ListKind:
+		if n.elem == nil {
+			n.elem = &node{}
+		}
+		iter, err := v.List()
+		if err != nil {
+			return err
+		}
+		for iter.Next() {
+			if err := n.elem.add(iter.Value(), c); err != nil {
+				return err
+			}
+		}
+
+	case cue.NullKind, cue.BoolKind, cue.IntKind, cue.FloatKind, cue.BytesKind:
+
+	default:
+		return errors.Newf(v.Pos(), "cannot infer schema for value of kind %s", k)
+	}
+	return nil
+}
+
+func (n *node) addString(s string, c *Config) {
+	n.nstrings++
+	n.addDistinct(s, c)
+}
+
+func (n *node) addDistinct(s string, c *Config) {
+	if n.tooMany {
+		return
+	}
+	for _, x := range n.strings {
+		if x == s {
+			return
+		}
+	}
+	if len(n.strings) >= c.MaxEnum {
+		n.strings = nil
+		n.tooMany = true
+		return
+	}
+	n.strings = append(n.strings, s)
+}
+
+func (n *node) field(name string) *field {
+	if f, ok := n.index[name]; ok {
+		return f
+	}
+	if n.index == nil {
+		n.index = map[string]*field{}
+	}
+	f := &field{name: name, value: &node{}}
+	n.index[name] = f
+	n.fields = append(n.fields, f)
+	return f
+}
+
+// merge adds the values of m to n. It is used to compute the type of the
+// fields of map-like structs.
+func (n *node) merge(m *node, c *Config) {
+	n.count += m.count
+	n.kinds |= m.kinds
+
+	n.nstrings += m.nstrings
+	if m.tooMany {
+		n.strings = nil
+		n.tooMany = true
+	}
+	for _, s := range m.strings {
+		n.addDistinct(s, c)
+	}
+
+	n.nstructs += m.nstructs
+	if m.maxFields > n.maxFields {
+		n.maxFields = m.maxFields
+	}
+	for _, mf := range m.fields {
+		f := n.field(mf.name)
+		f.count += mf.count
+		f.value.merge(mf.value, c)
+	}
+
+	if m.elem != nil {
+		if n.elem == nil {
+			n.elem = &node{}
+		}
+		n.elem.merge(m.elem, c)
+	}
+}
+
+// isMap reports whether the structs of n are map-like.
+func (n *node) isMap() bool {
+	if len(n.fields) == 0 {
+		return false
+	}
+	for _, f := range n.fields {
+		if !ast.IsValidIdent(f.name) {
+			return true
+		}
+	}
+	if n.nstructs < 2 || len(n.fields) <= n.maxFields {
+		return false
+	}
+	for _, f := range n.fields {
+		if f.count == n.nstructs {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *node) expr(c *Config) ast.Expr {
+	if n.count == 0 {
+		return ast.NewIdent("_")
+	}
+	var a []ast.Expr
+
+	if n.kinds&cue.StructKind != 0 {
+		a = append(a, n.structExpr(c))
+	}
+	if n.kinds&cue.ListKind != 0 {
+		elem := n.elem.expr(c)
+		a = append(a, ast.NewList(&ast.Ellipsis{Type: elem}))
+	}
+	if n.kinds&cue.StringKind != 0 {
+		// Only infer a disjunction if at least one value was repeated.
+		if !n.tooMany && n.nstrings > len(n.strings) {
+			for _, s := range n.strings {
+				a = append(a, ast.NewString(s))
+			}
+		} else {
+			a = append(a, ast.NewIdent("string"))
+		}
+	}
+	switch {
+	case n.kinds&cue.FloatKind != 0:
+		a = append(a, ast.NewIdent("number"))
+	case n.kinds&cue.IntKind != 0:
+		a = append(a, ast.NewIdent("int"))
+	}
+	if n.kinds&cue.BoolKind != 0 {
+		a = append(a, ast.NewIdent("bool"))
+	}
+	if n.kinds&cue.BytesKind != 0 {
+		a = append(a, ast.NewIdent("bytes"))
+	}
+	if n.kinds&cue.NullKind != 0 {
+		a = append(a, ast.NewNull())
+	}
+	return ast.NewBinExpr(token.OR, a...)
+}
+
+func (n *node) structExpr(c *Config) ast.Expr {
+	if n.isMap() {
+		elem := &node{}
+		for _, f := range n.fields {
+			elem.merge(f.value, c)
+		}
+		return ast.NewStruct(&ast.Field{
+			Label: ast.NewList(ast.NewIdent("string")),
+			Value: elem.expr(c),
+		})
+	}
+	s := &ast.StructLit{}
+	for _, f := range n.fields {
+		field := &ast.Field{
+			Label: ast.NewString(f.name),
+			Value: f.value.expr(c),
+		}
+		if f.count < n.nstructs {
+			field.Optional = token.Blank.Pos()
+		}
+		s.Elts = append(s.Elts, field)
+	}
+	return s
+}
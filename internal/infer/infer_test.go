@@ -0,0 +1,155 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infer_test
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/internal/infer"
+)
+
+func TestSchema(t *testing.T) {
+	testCases := []struct {
+		name    string
+		samples []string
+		maxEnum int
+		want    string
+	}{{
+		name:    "empty",
+		samples: nil,
+		want:    `_`,
+	}, {
+		name: "kinds",
+		samples: []string{
+			`{a: 1, b: "x", c: true, d: null, e: 1.5, f: 1}`,
+			`{a: 2, b: "y", c: false, d: "z", e: 2, f: null}`,
+		},
+		want: `{
+	a: int
+	b: string
+	c: bool
+	d: string | null
+	e: number
+	f: int | null
+}`,
+	}, {
+		name: "optional",
+		samples: []string{
+			`{id: 1, name: "a"}`,
+			`{id: 2, email: "b@example.com"}`,
+			`{id: 3, name: "c", email: "c@example.com"}`,
+		},
+		want: `{
+	id:     int
+	name?:  string
+	email?: string
+}`,
+	}, {
+		name: "enum",
+		samples: []string{
+			`{kind: "click", user: "ann"}`,
+			`{kind: "view", user: "bob"}`,
+			`{kind: "click", user: "cid"}`,
+		},
+		want: `{
+	kind: "click" | "view"
+	user: string
+}`,
+	}, {
+		name:    "too many values for enum",
+		maxEnum: 2,
+		samples: []string{
+			`{kind: "a"}`, `{kind: "b"}`, `{kind: "c"}`, `{kind: "a"}`,
+		},
+		want: `{
+	kind: string
+}`,
+	}, {
+		name:    "enum disabled",
+		maxEnum: -1,
+		samples: []string{`{kind: "a"}`, `{kind: "a"}`},
+		want: `{
+	kind: string
+}`,
+	}, {
+		name: "lists",
+		samples: []string{
+			`{tags: ["a", "b"], ports: [{port: 80}, {port: 443, name: "https"}], empty: []}`,
+			`{tags: [], ports: [], empty: []}`,
+		},
+		want: `{
+	tags: [...string]
+	ports: [...{
+		port:  int
+		name?: string
+	}]
+	empty: [...]
+}`,
+	}, {
+		name: "map with non-identifier keys",
+		samples: []string{
+			`{labels: {"app.kubernetes.io/name": "web", tier: "frontend"}}`,
+			`{labels: {"app.kubernetes.io/name": "db"}}`,
+		},
+		want: `{
+	labels: [string]: string
+}`,
+	}, {
+		name: "map with varying keys",
+		samples: []string{
+			`{users: {alice: {age: 30}, bob: {age: 40}}}`,
+			`{users: {carol: {age: 50}}}`,
+			`{users: {dave: {age: 60, admin: true}}}`,
+		},
+		want: `{
+	users: [string]: {
+		age:    int
+		admin?: bool
+	}
+}`,
+	}, {
+		name:    "non-struct",
+		samples: []string{`1`, `"a"`, `[1]`},
+		want:    `[...int] | string | int`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := cuecontext.New()
+			i := infer.New(&infer.Config{MaxEnum: tc.maxEnum})
+			for _, s := range tc.samples {
+				if err := i.Add(ctx.CompileString(s)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			b, err := format.Node(i.Schema(), format.Simplify())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tc.want {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAddIncomplete(t *testing.T) {
+	i := infer.New(nil)
+	v := cuecontext.New().CompileString(`{a: int}`)
+	if err := i.Add(v); err == nil {
+		t.Error("expected error for incomplete value")
+	}
+}
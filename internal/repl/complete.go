@@ -0,0 +1,78 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repl
+
+import (
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+)
+
+// Complete returns the candidates for completing text, which replace text
+// from the byte offset start. Commands are completed at the start of a
+// line. Otherwise the path at the end of text is completed with the names
+// of the fields of the value it selects from, or of the loaded value if it
+// has no selectors.
+func (r *REPL) Complete(text string) (start int, candidates []string) {
+	if strings.HasPrefix(text, ":") && !strings.ContainsAny(text, " \t") {
+		for _, c := range commands {
+			if strings.HasPrefix(c.name, text) {
+				candidates = append(candidates, c.name)
+			}
+		}
+		sort.Strings(candidates)
+		return 0, candidates
+	}
+
+	start = len(text)
+	for start > 0 && isPathByte(text[start-1]) {
+		start--
+	}
+	word := text[start:]
+
+	v := r.value
+	prefix, partial := "", word
+	if i := strings.LastIndexByte(word, '.'); i >= 0 {
+		prefix, partial = word[:i+1], word[i+1:]
+		if prefix == "." {
+			return start, nil
+		}
+		var err error
+		v, err = r.lookup(word[:i])
+		if err != nil || v.Err() != nil {
+			return start, nil
+		}
+	}
+
+	iter, err := v.Fields(cue.Definitions(true), cue.Optional(true), cue.Hidden(true))
+	if err != nil {
+		return start, nil
+	}
+	for iter.Next() {
+		name := iter.Selector().String()
+		if strings.HasPrefix(name, partial) {
+			candidates = append(candidates, prefix+name)
+		}
+	}
+	sort.Strings(candidates)
+	return start, candidates
+}
+
+// isPathByte reports whether c may be part of a path of identifiers.
+func isPathByte(c byte) bool {
+	return c == '.' || c == '_' || c == '#' || c == '$' ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
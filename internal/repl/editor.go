@@ -0,0 +1,305 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxHistory is the maximum number of lines kept in the history.
+const maxHistory = 1000
+
+// An editor reads lines from a terminal in raw mode, supporting the usual
+// editing keys, a history of previous lines and completion.
+type editor struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	history []string
+
+	// complete returns the candidates for completing text, which replace
+	// text from the byte offset start.
+	complete func(text string) (start int, candidates []string)
+}
+
+// Control characters.
+const (
+	ctrlA     = 1
+	ctrlB     = 2
+	ctrlC     = 3
+	ctrlD     = 4
+	ctrlE     = 5
+	ctrlF     = 6
+	ctrlH     = 8
+	tab       = 9
+	ctrlK     = 11
+	ctrlL     = 12
+	enter     = 13
+	ctrlN     = 14
+	ctrlP     = 16
+	ctrlU     = 21
+	ctrlW     = 23
+	esc       = 27
+	backspace = 127
+)
+
+// addHistory adds line to the history, unless it is empty or repeats the
+// previous line.
+func (e *editor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+}
+
+// readLine reads a line after showing prompt. It returns io.EOF if the
+// input ends or Ctrl-D is pressed on an empty line.
+func (e *editor) readLine(prompt string) (string, error) {
+	var (
+		buf []rune
+		pos int
+
+		// hist is the index of the history entry being edited, where
+		// len(e.history) denotes the new line, which is saved in current
+		// while browsing the history.
+		hist    = len(e.history)
+		current []rune
+
+		// listed reports whether the candidates for the current text
+		// were listed by the previous key.
+		listed bool
+	)
+
+	refresh := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if n := len(buf) - pos; n > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", n)
+		}
+	}
+	insert := func(r ...rune) {
+		buf = append(buf[:pos], append(r, buf[pos:]...)...)
+		pos += len(r)
+	}
+	setHistory := func(i int) {
+		if i < 0 || i > len(e.history) || i == hist {
+			return
+		}
+		if hist == len(e.history) {
+			current = buf
+		}
+		hist = i
+		if i == len(e.history) {
+			buf = current
+		} else {
+			buf = []rune(e.history[i])
+		}
+		pos = len(buf)
+	}
+
+	refresh()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(buf) > 0 {
+				fmt.Fprint(e.out, "\r\n")
+				break
+			}
+			return "", err
+		}
+		wasListed := listed
+		listed = false
+
+		switch r {
+		case enter, '\n':
+			fmt.Fprint(e.out, "\r\n")
+			line := string(buf)
+			e.addHistory(line)
+			return line, nil
+
+		case ctrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			buf, pos, hist = nil, 0, len(e.history)
+
+		case ctrlD:
+			if len(buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+
+		case backspace, ctrlH:
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+
+		case ctrlA:
+			pos = 0
+		case ctrlE:
+			pos = len(buf)
+		case ctrlB:
+			if pos > 0 {
+				pos--
+			}
+		case ctrlF:
+			if pos < len(buf) {
+				pos++
+			}
+		case ctrlK:
+			buf = buf[:pos]
+		case ctrlU:
+			buf = append([]rune(nil), buf[pos:]...)
+			pos = 0
+		case ctrlW:
+			i := pos
+			for i > 0 && unicode.IsSpace(buf[i-1]) {
+				i--
+			}
+			for i > 0 && !unicode.IsSpace(buf[i-1]) {
+				i--
+			}
+			buf = append(buf[:i], buf[pos:]...)
+			pos = i
+		case ctrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case ctrlP:
+			setHistory(hist - 1)
+		case ctrlN:
+			setHistory(hist + 1)
+
+		case tab:
+			if e.complete == nil {
+				break
+			}
+			text := string(buf[:pos])
+			start, candidates := e.complete(text)
+			if len(candidates) == 0 {
+				fmt.Fprint(e.out, "\a")
+				break
+			}
+			word := text[start:]
+			prefix := commonPrefix(candidates)
+			if len(candidates) == 1 {
+				prefix = candidates[0]
+			}
+			if prefix != word {
+				buf = append([]rune(text[:start]+prefix), buf[pos:]...)
+				pos = len([]rune(text[:start] + prefix))
+				break
+			}
+			if !wasListed {
+				fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+			}
+			listed = true
+
+		case esc:
+			switch e.readEscape() {
+			case 'A':
+				setHistory(hist - 1)
+			case 'B':
+				setHistory(hist + 1)
+			case 'C':
+				if pos < len(buf) {
+					pos++
+				}
+			case 'D':
+				if pos > 0 {
+					pos--
+				}
+			case 'H':
+				pos = 0
+			case 'F':
+				pos = len(buf)
+			case '~':
+				// Delete.
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			}
+
+		default:
+			if unicode.IsPrint(r) {
+				insert(r)
+			}
+		}
+		refresh()
+	}
+	line := string(buf)
+	e.addHistory(line)
+	return line, nil
+}
+
+// readEscape reads the remainder of an escape sequence and returns its
+// final byte, or 0 if it is not recognized. The sequences for Home and End
+// are mapped to H and F, and that for Delete to ~.
+func (e *editor) readEscape() byte {
+	b, err := e.in.ReadByte()
+	if err != nil || b != '[' && b != 'O' {
+		return 0
+	}
+	var param []byte
+	for {
+		c, err := e.in.ReadByte()
+		if err != nil {
+			return 0
+		}
+		if '0' <= c && c <= '9' || c == ';' {
+			param = append(param, c)
+			continue
+		}
+		if c != '~' {
+			return c
+		}
+		switch string(param) {
+		case "1", "7":
+			return 'H'
+		case "4", "8":
+			return 'F'
+		case "3":
+			return '~'
+		}
+		return 0
+	}
+}
+
+// commonPrefix returns the longest common prefix of a.
+func commonPrefix(a []string) string {
+	if len(a) == 0 {
+		return ""
+	}
+	p := a[0]
+	for _, s := range a[1:] {
+		for !strings.HasPrefix(s, p) {
+			p = p[:len(p)-1]
+		}
+	}
+	for !utf8.ValidString(p) {
+		p = p[:len(p)-1]
+	}
+	return p
+}
@@ -0,0 +1,466 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package repl implements an interactive read-eval-print loop that
+// evaluates CUE expressions in the scope of a package.
+//
+// Lines starting with a colon are commands:
+//
+//	:load [packages]         load packages, or reload the current ones
+//	:def [expr]              print the schema of a value
+//	:export format [expr]    export a value, as in cue export --out format
+//	:explain expr            explain where a value comes from
+//	:help                    list the commands
+//	:quit                    exit
+//
+// Any other line is evaluated as an expression. When reading from a
+// terminal, lines can be edited, previous lines are available with the up
+// and down keys and field names are completed with the tab key.
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal"
+	"cuelang.org/go/internal/encoding"
+	"cuelang.org/go/internal/filetypes"
+	"cuelang.org/go/internal/value"
+)
+
+// Config configures a REPL.
+type Config struct {
+	// Load loads the packages named by args, which are the arguments of
+	// the :load command or Args, and returns the value in whose scope
+	// expressions are evaluated.
+	Load func(args []string) (cue.Value, error)
+
+	// Args are the arguments with which packages are loaded initially.
+	Args []string
+
+	// Dir is the directory relative to which positions are reported.
+	Dir string
+
+	// HistoryFile is the file in which the history of lines entered in a
+	// terminal is kept, if not empty.
+	HistoryFile string
+
+	// Stderr is the writer to which errors are written.
+	Stderr io.Writer
+}
+
+// A REPL evaluates expressions in the scope of a value.
+type REPL struct {
+	cfg   *Config
+	args  []string
+	value cue.Value
+}
+
+// New returns a REPL for the packages loaded with cfg.Args.
+func New(cfg *Config) (*REPL, error) {
+	r := &REPL{cfg: cfg}
+	if err := r.load(cfg.Args); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *REPL) load(args []string) error {
+	v, err := r.cfg.Load(args)
+	if err != nil {
+		return err
+	}
+	r.args = args
+	r.value = v
+	return nil
+}
+
+// errQuit is returned by Exec for the :quit command.
+var errQuit = errors.New("quit")
+
+const prompt = "> "
+
+// Run reads lines from in and executes them until in ends or the :quit
+// command is given. Results are written to out and errors to the Stderr
+// writer of the configuration. If in is a terminal, lines are read with
+// line editing, history and completion.
+func (r *REPL) Run(in io.Reader, out io.Writer) error {
+	read := bufio.NewScanner(in)
+	readLine := func() (string, error) {
+		if !read.Scan() {
+			if err := read.Err(); err != nil {
+				return "", err
+			}
+			return "", io.EOF
+		}
+		return read.Text(), nil
+	}
+	if f, ok := in.(*os.File); ok && isTerminal(int(f.Fd())) {
+		e := &editor{
+			in:       bufio.NewReader(f),
+			out:      out,
+			history:  r.readHistory(),
+			complete: r.Complete,
+		}
+		readLine = func() (string, error) {
+			restore, err := makeRaw(int(f.Fd()))
+			if err != nil {
+				return "", err
+			}
+			line, err := e.readLine(prompt)
+			restore()
+			if err == nil {
+				r.writeHistory(line)
+			}
+			return line, err
+		}
+	}
+
+	for {
+		line, err := readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch err := r.Exec(out, line); err {
+		case nil:
+		case errQuit:
+			return nil
+		default:
+			r.printError(err)
+		}
+	}
+}
+
+func (r *REPL) readHistory() []string {
+	if r.cfg.HistoryFile == "" {
+		return nil
+	}
+	b, err := os.ReadFile(r.cfg.HistoryFile)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+	}
+	return lines
+}
+
+// writeHistory appends line to the history file. The history is kept on a
+// best-effort basis, so errors are ignored.
+func (r *REPL) writeHistory(line string) {
+	if r.cfg.HistoryFile == "" || strings.TrimSpace(line) == "" {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(r.cfg.HistoryFile), 0777)
+	f, err := os.OpenFile(r.cfg.HistoryFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+func (r *REPL) printError(err error) {
+	w := r.cfg.Stderr
+	if w == nil {
+		w = os.Stderr
+	}
+	errors.Print(w, err, &errors.Config{Cwd: r.cfg.Dir})
+}
+
+// commands lists the commands with a short description.
+var commands = []struct{ name, args, doc string }{
+	{":load", "[packages]", "load packages, or reload the current ones"},
+	{":def", "[expr]", "print the schema of a value"},
+	{":export", "format [expr]", "export a value, as in cue export --out format"},
+	{":explain", "expr", "explain where a value comes from"},
+	{":help", "", "list the commands"},
+	{":quit", "", "exit"},
+}
+
+// Exec executes a line, writing the result to w.
+func (r *REPL) Exec(w io.Writer, line string) error {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, ":") {
+		if line == "" {
+			return nil
+		}
+		v, err := r.eval(line)
+		if err != nil {
+			return err
+		}
+		return r.print(w, v)
+	}
+
+	cmd, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	switch cmd {
+	case ":load":
+		args := r.args
+		if arg != "" {
+			args = strings.Fields(arg)
+		}
+		return r.load(args)
+
+	case ":def":
+		v, err := r.eval(arg)
+		if err != nil {
+			return err
+		}
+		b, err := formatDef(v)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+
+	case ":export":
+		out, expr := arg, ""
+		if i := strings.IndexAny(arg, " \t"); i >= 0 {
+			out, expr = arg[:i], strings.TrimSpace(arg[i+1:])
+		}
+		if out == "" {
+			return errors.Newf(token.NoPos, "usage: :export format [expr]")
+		}
+		v, err := r.eval(expr)
+		if err != nil {
+			return err
+		}
+		return r.encode(w, out, filetypes.Export, v)
+
+	case ":explain":
+		if arg == "" {
+			return errors.Newf(token.NoPos, "usage: :explain expr")
+		}
+		return r.explain(w, arg)
+
+	case ":help":
+		for _, c := range commands {
+			fmt.Fprintf(w, "%-25s %s\n", strings.TrimSpace(c.name+" "+c.args), c.doc)
+		}
+		return nil
+
+	case ":quit", ":q":
+		return errQuit
+	}
+	return errors.Newf(token.NoPos, "unknown command %s; use :help to list the commands", cmd)
+}
+
+// eval evaluates expr in the scope of the loaded value. An empty expression
+// denotes the loaded value itself.
+func (r *REPL) eval(expr string) (cue.Value, error) {
+	if expr == "" {
+		return r.value, nil
+	}
+	x, err := parser.ParseExpr("repl", expr)
+	if err != nil {
+		return cue.Value{}, err
+	}
+	v := r.build(x)
+	return v, v.Err()
+}
+
+func (r *REPL) build(x ast.Expr) cue.Value {
+	return r.value.Context().BuildExpr(x,
+		cue.Scope(r.value),
+		cue.InferBuiltins(true))
+}
+
+// lookup returns the value of expr, which is looked up as a path in the
+// loaded value if possible, so that it retains the expressions from which it
+// was unified. Unlike eval, it only fails if expr is not a valid expression.
+func (r *REPL) lookup(expr string) (cue.Value, error) {
+	x, err := parser.ParseExpr("repl", expr)
+	if err != nil {
+		return cue.Value{}, err
+	}
+	if p := cue.ParsePath(expr); p.Err() == nil {
+		if v := r.value.LookupPath(p); v.Exists() {
+			return v, nil
+		}
+	}
+	return r.build(x), nil
+}
+
+// print prints v as cue eval does.
+func (r *REPL) print(w io.Writer, v cue.Value) error {
+	if err := v.Validate(); err != nil {
+		return err
+	}
+	b, err := formatValue(v, evalOptions...)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// evalOptions are the options with which values are printed, as by cue
+// eval. defOptions are those with which schemas are printed, as by cue def:
+// references are kept so that pattern constraints and closedness are
+// retained. explainOptions are those with which :explain prints the
+// optional fields and the constraints of fields after evaluation.
+var (
+	evalOptions = []cue.Option{cue.Final(), cue.Definitions(true)}
+	defOptions  = []cue.Option{
+		cue.Definitions(true),
+		cue.Optional(true),
+		cue.Docs(true),
+		cue.Attributes(true),
+		cue.Concrete(false),
+		cue.ResolveReferences(false),
+	}
+	explainOptions = []cue.Option{
+		cue.Definitions(true),
+		cue.Optional(true),
+		cue.Docs(true),
+		cue.Attributes(true),
+		cue.ResolveReferences(true),
+	}
+)
+
+func formatValue(v cue.Value, opts ...cue.Option) ([]byte, error) {
+	n := v.Syntax(opts...)
+	if f, ok := n.(*ast.File); ok && len(f.Decls) == 1 {
+		if e, ok := f.Decls[0].(*ast.EmbedDecl); ok {
+			n = e.Expr
+		}
+	}
+	return formatNode(n)
+}
+
+// formatDef formats the schema of v as a file, as cue def does.
+func formatDef(v cue.Value) ([]byte, error) {
+	return formatNode(internal.ToFile(v.Syntax(defOptions...)))
+}
+
+func formatNode(n ast.Node) ([]byte, error) {
+	b, err := format.Node(n, format.UseSpaces(4), format.TabIndent(false), format.Simplify())
+	return bytes.TrimSpace(b), err
+}
+
+// encode writes v in the given output format, such as json or yaml, using
+// the conventions of the given mode.
+func (r *REPL) encode(w io.Writer, out string, mode filetypes.Mode, v cue.Value) error {
+	f, err := filetypes.ParseFile(out+":-", mode)
+	if err != nil {
+		return err
+	}
+	e, err := encoding.NewEncoder(f, &encoding.Config{
+		Mode: mode,
+		Out:  w,
+		Format: []format.Option{
+			format.UseSpaces(4),
+			format.TabIndent(false),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := e.Encode(v); err != nil {
+		return err
+	}
+	return e.Close()
+}
+
+// explain writes the value of expr, its kind and default, any errors and
+// the expressions from which it was unified with their positions.
+func (r *REPL) explain(w io.Writer, expr string) error {
+	v, err := r.lookup(expr)
+	if err != nil {
+		return err
+	}
+
+	b, _ := formatValue(v, explainOptions...)
+	fmt.Fprintf(w, "value: %s\n", b)
+	fmt.Fprintf(w, "kind: %s\n", v.IncompleteKind())
+	if d, ok := v.Default(); ok && !v.IsConcrete() {
+		b, _ := formatValue(d, evalOptions...)
+		fmt.Fprintf(w, "default: %s\n", b)
+	}
+
+	if err := v.Validate(); err != nil {
+		var buf bytes.Buffer
+		errors.Print(&buf, err, &errors.Config{Cwd: r.cfg.Dir})
+		fmt.Fprintf(w, "errors:\n    %s\n", indent(strings.TrimSpace(buf.String())))
+	}
+
+	_, vx := value.ToInternal(v)
+	var lines []string
+	for _, c := range vx.Conjuncts {
+		src := c.Source()
+		if src == nil {
+			continue
+		}
+		b, err := format.Node(src, format.Simplify())
+		if err != nil {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", r.pos(src.Pos()), shorten(string(b))))
+	}
+	if len(lines) > 0 {
+		fmt.Fprintf(w, "conjuncts:\n")
+		for _, l := range lines {
+			fmt.Fprintf(w, "    %s\n", l)
+		}
+	}
+	return nil
+}
+
+// pos formats a position relative to the configured directory, like
+// errors are.
+func (r *REPL) pos(p token.Pos) string {
+	if !p.IsValid() {
+		return "-"
+	}
+	pos := p.Position()
+	name := pos.Filename
+	if r.cfg.Dir != "" && filepath.IsAbs(name) {
+		if rel, err := filepath.Rel(r.cfg.Dir, name); err == nil && !strings.HasPrefix(rel, "..") {
+			name = "." + string(filepath.Separator) + rel
+		}
+	}
+	return fmt.Sprintf("%s:%d:%d", name, pos.Line, pos.Column)
+}
+
+// indent indents all but the first line of s.
+func indent(s string) string {
+	return strings.ReplaceAll(s, "\n", "\n    ")
+}
+
+// shorten returns s on a single line, truncated if it is long.
+func shorten(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > 60 {
+		s = string(r[:57]) + "..."
+	}
+	return s
+}
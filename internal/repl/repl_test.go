@@ -0,0 +1,280 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+)
+
+const schema = `
+import "strings"
+
+#Service: {
+	name: string
+	port: int & >0 | *80
+	tags?: [...string]
+}
+
+services: web: #Service & {
+	name: "web"
+	port: 8080
+}
+services: db: #Service & {name: "db"}
+
+upper: strings.ToUpper(services.web.name)
+
+labels: [string]: string
+`
+
+func newREPL(t *testing.T) *REPL {
+	r, err := New(&Config{
+		Load: func(args []string) (cue.Value, error) {
+			if len(args) > 0 {
+				return cue.Value{}, errors.Newf(token.NoPos, "cannot load %s", args[0])
+			}
+			return cuecontext.New().CompileString(schema, cue.Filename("schema.cue")), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestExec(t *testing.T) {
+	testCases := []struct {
+		line string
+		out  string
+		err  string
+	}{{
+		line: "services.web.port * 2",
+		out:  "16160\n",
+	}, {
+		line: "services.db",
+		out: `{
+    name: "db"
+    port: 80
+}
+`,
+	}, {
+		line: "[for k, _ in services {k}]",
+		out:  "[\"web\", \"db\"]\n",
+	}, {
+		line: "upper",
+		out:  "\"WEB\"\n",
+	}, {
+		line: "services.web.port + \"x\"",
+		err:  `invalid operands 8080 and "x" to '+' (type int and string)`,
+	}, {
+		line: "missing",
+		err:  `reference "missing" not found`,
+	}, {
+		line: ":def #Service",
+		out: `_#def
+_#def: {
+    name: string
+    port: int & >0 | *80
+    tags?: [...string]
+}
+`,
+	}, {
+		line: ":def labels",
+		out:  "[string]: string\n",
+	}, {
+		line: ":def services.db",
+		out: `_#def
+_#def: {
+    name: string
+    port: int & >0 | *80
+    tags?: [...string]
+} & {
+    name: "db"
+}
+`,
+	}, {
+		line: ":export json services.web",
+		out: `{
+    "name": "web",
+    "port": 8080
+}
+`,
+	}, {
+		line: ":export yaml services.db",
+		out:  "name: db\nport: 80\n",
+	}, {
+		line: ":export json #Service",
+		err:  "#Service.name: incomplete value string",
+	}, {
+		line: ":export",
+		err:  "usage: :export format [expr]",
+	}, {
+		line: ":explain services.web.port",
+		out: `value: 8080
+kind: int
+conjuncts:
+    schema.cue:6:2: port: int & >0 | *80
+    schema.cue:12:2: port: 8080
+`,
+	}, {
+		line: ":explain services.db.port",
+		out: `value: *80 | >0 & int
+kind: int
+default: 80
+conjuncts:
+    schema.cue:6:2: port: int & >0 | *80
+`,
+	}, {
+		line: ":explain services.web.name + 1",
+		out: `value: _|_ // invalid operands "web" and 1 to '+' (type string and int)
+kind: _|_
+errors:
+    invalid operands "web" and 1 to '+' (type string and int):
+        repl:1:1
+        repl:1:21
+        schema.cue:11:8
+`,
+	}, {
+		line: ":load",
+	}, {
+		line: ":load ./other",
+		err:  "cannot load ./other",
+	}, {
+		line: ":nope",
+		err:  "unknown command :nope; use :help to list the commands",
+	}, {
+		line: ":quit",
+		err:  "quit",
+	}}
+	r := newREPL(t)
+	for _, tc := range testCases {
+		t.Run(tc.line, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := r.Exec(&buf, tc.line)
+			if got := buf.String(); got != tc.out {
+				t.Errorf("output:\ngot:  %q\nwant: %q", got, tc.out)
+			}
+			switch {
+			case err == nil && tc.err != "":
+				t.Errorf("got no error; want %q", tc.err)
+			case err != nil && !strings.Contains(err.Error(), tc.err):
+				t.Errorf("error:\ngot:  %v\nwant: %s", err, tc.err)
+			case err != nil && tc.err == "":
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	r := newREPL(t)
+	var out, errs bytes.Buffer
+	r.cfg.Stderr = &errs
+	in := "services.web.port\nmissing\n\n:quit\nupper\n"
+	if err := r.Run(strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "8080\n"; got != want {
+		t.Errorf("output: got %q; want %q", got, want)
+	}
+	if got := errs.String(); !strings.Contains(got, `reference "missing" not found`) {
+		t.Errorf("errors: got %q", got)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	testCases := []struct {
+		text  string
+		start int
+		want  []string
+	}{
+		{":e", 0, []string{":explain", ":export"}},
+		{":export js", 8, nil},
+		{"ser", 0, []string{"services"}},
+		{"#", 0, []string{"#Service"}},
+		{"services.", 0, []string{"services.db", "services.web"}},
+		{"services.web.p", 0, []string{"services.web.port"}},
+		{"#Service.", 0, []string{"#Service.name", "#Service.port", "#Service.tags"}},
+		{"1 + services.w", 4, []string{"services.web"}},
+		{"services.web.port.", 0, nil},
+		{"missing.", 0, nil},
+	}
+	r := newREPL(t)
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			start, got := r.Complete(tc.text)
+			if start != tc.start || !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %d, %q; want %d, %q", start, got, tc.start, tc.want)
+			}
+		})
+	}
+}
+
+func TestEditor(t *testing.T) {
+	complete := func(text string) (int, []string) {
+		var a []string
+		for _, s := range []string{"services", "server", "upper"} {
+			if strings.HasPrefix(s, text) {
+				a = append(a, s)
+			}
+		}
+		return 0, a
+	}
+	testCases := []struct {
+		name    string
+		history []string
+		in      string
+		want    string
+		err     error
+	}{
+		{name: "enter", in: "abc\r", want: "abc"},
+		{name: "backspace", in: "abd\x7fc\r", want: "abc"},
+		{name: "move", in: "bc\x01a\x05d\r", want: "abcd"},
+		{name: "arrows", in: "ac\x1b[Db\x1b[Cd\r", want: "abcd"},
+		{name: "delete", in: "abxc\x1b[D\x1b[D\x1b[3~\r", want: "abc"},
+		{name: "kill", in: "abc def\x17x\r", want: "abc x"},
+		{name: "killLine", in: "abc\x15x\r", want: "x"},
+		{name: "history", history: []string{"a", "b"}, in: "\x1b[A\x1b[A\r", want: "a"},
+		{name: "historyBack", history: []string{"a"}, in: "x\x1b[A\x1b[B\r", want: "x"},
+		{name: "complete", in: "u\t\r", want: "upper"},
+		{name: "completePrefix", in: "s\t\r", want: "serv"},
+		{name: "interrupt", in: "abc\x03x\r", want: "x"},
+		{name: "eof", in: "\x04", err: io.EOF},
+		{name: "unterminated", in: "abc", want: "abc"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &editor{
+				in:       bufio.NewReader(strings.NewReader(tc.in)),
+				out:      io.Discard,
+				history:  tc.history,
+				complete: complete,
+			}
+			got, err := e.readLine("> ")
+			if got != tc.want || err != tc.err {
+				t.Errorf("got %q, %v; want %q, %v", got, err, tc.want, tc.err)
+			}
+		})
+	}
+}
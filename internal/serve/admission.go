@@ -0,0 +1,286 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serve
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	cuejson "cuelang.org/go/encoding/json"
+)
+
+// The types below define the subset of the Kubernetes admission.k8s.io/v1
+// AdmissionReview protocol used by admission webhooks.
+
+type admissionReview struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Request    *admissionRequest  `json:"request,omitempty"`
+	Response   *admissionResponse `json:"response,omitempty"`
+}
+
+type admissionRequest struct {
+	UID    string           `json:"uid"`
+	Kind   groupVersionKind `json:"kind"`
+	Object json.RawMessage  `json:"object"`
+}
+
+type groupVersionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// apiVersion returns the apiVersion of objects of this kind.
+func (k groupVersionKind) apiVersion() string {
+	if k.Group == "" {
+		return k.Version
+	}
+	return k.Group + "/" + k.Version
+}
+
+type admissionResponse struct {
+	UID       string           `json:"uid"`
+	Allowed   bool             `json:"allowed"`
+	Status    *admissionStatus `json:"status,omitempty"`
+	PatchType string           `json:"patchType,omitempty"`
+	Patch     []byte           `json:"patch,omitempty"`
+}
+
+type admissionStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// A patchOp is a JSON Patch (RFC 6902) operation.
+type patchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+const defaultAdmissionVersion = "admission.k8s.io/v1"
+
+// admit handles an AdmissionReview request. The object of the request is
+// unified with the value that the admission mapping defines for its
+// apiVersion and kind. The request is denied if the result is not valid.
+// If mutate is true, an allowed response includes a JSON Patch that adds
+// the defaults and other values filled in by the unification.
+//
+// Objects of kinds not in the mapping, and requests without an object,
+// such as deletions, are allowed unchanged.
+func (s *Server) admit(w http.ResponseWriter, r *http.Request, mutate bool) {
+	if !s.method(w, r, http.MethodPost) {
+		return
+	}
+	if s.cfg.Admission == "" {
+		s.error(w, http.StatusNotFound, errors.Newf(token.NoPos,
+			"admission requests not enabled"))
+		return
+	}
+	mapping, status, err := s.lookup(s.cfg.Admission, r.URL.Query().Get("package"))
+	if err != nil {
+		s.error(w, status, err)
+		return
+	}
+
+	var review admissionReview
+	b, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, s.cfg.MaxBodySize))
+	if err == nil {
+		err = json.Unmarshal(b, &review)
+	}
+	if err == nil && review.Request == nil {
+		err = errors.Newf(token.NoPos, "AdmissionReview has no request")
+	}
+	if err != nil {
+		s.error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := review.Request
+	resp := &admissionResponse{UID: req.UID, Allowed: true}
+	if review.APIVersion == "" {
+		review.APIVersion = defaultAdmissionVersion
+	}
+	review.Kind = "AdmissionReview"
+	review.Request = nil
+	review.Response = resp
+
+	object := bytes.TrimSpace(req.Object)
+	if len(object) == 0 || string(object) == "null" {
+		s.writeJSON(w, http.StatusOK, &review)
+		return
+	}
+	schema := mapping.LookupPath(cue.MakePath(
+		cue.Str(req.Kind.apiVersion()), cue.Str(req.Kind.Kind)))
+	if !schema.Exists() {
+		s.writeJSON(w, http.StatusOK, &review)
+		return
+	}
+
+	expr, err := cuejson.Extract("object.json", object)
+	if err != nil {
+		s.error(w, http.StatusBadRequest, err)
+		return
+	}
+	v := schema.Context().BuildExpr(expr).Unify(schema)
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		resp.Allowed = false
+		resp.Status = &admissionStatus{
+			Code:    http.StatusForbidden,
+			Message: s.message(err),
+		}
+		s.writeJSON(w, http.StatusOK, &review)
+		return
+	}
+
+	if mutate {
+		ops, err := s.patch(object, v)
+		if err != nil {
+			s.error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if len(ops) > 0 {
+			resp.PatchType = "JSONPatch"
+			resp.Patch, _ = json.Marshal(ops)
+		}
+	}
+	s.writeJSON(w, http.StatusOK, &review)
+}
+
+// message formats err as a single message for the status of a denied
+// request.
+func (s *Server) message(err error) string {
+	var a []string
+	for _, e := range s.errors(err) {
+		msg := strings.TrimSuffix(e.Message, ":")
+		if e.Path != "" {
+			msg = e.Path + ": " + msg
+		}
+		a = append(a, msg)
+	}
+	return strings.Join(a, "; ")
+}
+
+// patch computes the JSON Patch that transforms the original object into
+// the value v.
+func (s *Server) patch(object []byte, v cue.Value) ([]patchOp, error) {
+	b, err := v.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var from, to interface{}
+	if err := unmarshalJSON(object, &from); err != nil {
+		return nil, err
+	}
+	if err := unmarshalJSON(b, &to); err != nil {
+		return nil, err
+	}
+	var ops []patchOp
+	diffJSON(&ops, "", from, to)
+	return ops, nil
+}
+
+func unmarshalJSON(b []byte, x interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	return d.Decode(x)
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// diffJSON appends the operations to transform the decoded JSON value from
+// into to. Objects are compared field by field, and arrays of the same
+// length element by element. Other values are replaced as a whole.
+func diffJSON(ops *[]patchOp, path string, from, to interface{}) {
+	switch x := from.(type) {
+	case map[string]interface{}:
+		y, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(x)+len(y))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		for k := range y {
+			if _, ok := x[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := path + "/" + pointerEscaper.Replace(k)
+			a, inFrom := x[k]
+			b, inTo := y[k]
+			switch {
+			case !inTo:
+				*ops = append(*ops, patchOp{Op: "remove", Path: p})
+			case !inFrom:
+				*ops = append(*ops, newPatchOp("add", p, b))
+			default:
+				diffJSON(ops, p, a, b)
+			}
+		}
+		return
+
+	case []interface{}:
+		y, ok := to.([]interface{})
+		if !ok || len(x) != len(y) {
+			break
+		}
+		for i := range x {
+			diffJSON(ops, path+"/"+strconv.Itoa(i), x[i], y[i])
+		}
+		return
+	}
+	if !equalJSON(from, to) {
+		*ops = append(*ops, newPatchOp("replace", path, to))
+	}
+}
+
+func newPatchOp(op, path string, value interface{}) patchOp {
+	b, _ := json.Marshal(value)
+	return patchOp{Op: op, Path: path, Value: b}
+}
+
+// equalJSON reports whether two decoded scalar JSON values are equal.
+// Numbers are compared by value, as their representation may differ.
+func equalJSON(a, b interface{}) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		f, err1 := x.Float64()
+		g, err2 := y.Float64()
+		return err1 == nil && err2 == nil && f == g
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return a == b
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serve_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/txtar"
+
+	"cuelang.org/go/internal/cuetest"
+	"cuelang.org/go/internal/serve"
+)
+
+// TestAdmission reads the testdata/admission/*.txtar files, each of which
+// holds a schema.cue file defining an admission mapping and a recorded
+// AdmissionReview request.json. It compares the responses of the validate
+// and mutate endpoints against validate.json and mutate.json, and the
+// decoded patch of the latter against patch.json.
+//
+// Set CUE_UPDATE=1 to update test files with the corresponding output.
+func TestAdmission(t *testing.T) {
+	files, err := filepath.Glob("testdata/admission/*.txtar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".txtar"), func(t *testing.T) {
+			a, err := txtar.ParseFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			archive := map[string][]byte{}
+			for _, f := range a.Files {
+				archive[f.Name] = f.Data
+			}
+
+			s, err := serve.New(&serve.Config{
+				Load:      load(string(archive["schema.cue"])),
+				Admission: "admission",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			updated := false
+			check := func(name string, got []byte) {
+				if want, ok := archive[name]; ok && bytes.Equal(got, want) {
+					return
+				}
+				if !cuetest.UpdateGoldenFiles {
+					t.Errorf("%s: %s", name, cmp.Diff(string(archive[name]), string(got)))
+					return
+				}
+				updated = true
+				for i, f := range a.Files {
+					if f.Name == name {
+						a.Files[i].Data = got
+						return
+					}
+				}
+				a.Files = append(a.Files, txtar.File{Name: name, Data: got})
+			}
+
+			for _, mode := range []string{"validate", "mutate"} {
+				req := httptest.NewRequest("POST", "/admission/"+mode,
+					bytes.NewReader(archive["request.json"]))
+				w := httptest.NewRecorder()
+				s.ServeHTTP(w, req)
+				if w.Code != http.StatusOK {
+					t.Errorf("%s: status %d: %s", mode, w.Code, w.Body)
+				}
+				got := w.Body.Bytes()
+				check(mode+".json", got)
+
+				if mode != "mutate" {
+					continue
+				}
+				var review struct {
+					Response struct {
+						Patch []byte `json:"patch"`
+					} `json:"response"`
+				}
+				if err := json.Unmarshal(got, &review); err != nil {
+					t.Fatal(err)
+				}
+				if patch := review.Response.Patch; patch != nil {
+					var b bytes.Buffer
+					if err := json.Indent(&b, patch, "", "  "); err != nil {
+						t.Fatal(err)
+					}
+					b.WriteByte('\n')
+					check("patch.json", b.Bytes())
+				}
+			}
+
+			if updated {
+				if err := os.WriteFile(file, txtar.Format(a), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestAdmissionErrors(t *testing.T) {
+	post := func(s *serve.Server, body string) (int, string) {
+		req := httptest.NewRequest("POST", "/admission/validate", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	s, err := serve.New(&serve.Config{Load: load(schema)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := post(s, `{}`); code != http.StatusNotFound {
+		t.Errorf("not enabled: got %d; want %d", code, http.StatusNotFound)
+	}
+
+	s, err = serve.New(&serve.Config{Load: load(schema), Admission: "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := post(s, `{}`); code != http.StatusNotFound {
+		t.Errorf("missing mapping: got %d; want %d", code, http.StatusNotFound)
+	}
+
+	s, err = serve.New(&serve.Config{Load: load(schema + `admission: {}`), Admission: "admission"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{`{`, `{"kind": "AdmissionReview"}`} {
+		if code, out := post(s, body); code != http.StatusBadRequest {
+			t.Errorf("%s: got %d; want %d: %s", body, code, http.StatusBadRequest, out)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package serve implements an HTTP server that validates and exports data
+// using the schemas of CUE packages.
+//
+// The server handles the following requests:
+//
+//	POST /validate/<path>   validate the body against the value at path
+//	POST /export/<path>     return the unification of the body with the
+//	                        value at path as JSON
+//	GET  /definitions       list the definitions of each package
+//	GET  /healthz           report whether the packages were loaded
+//	POST /admission/validate
+//	POST /admission/mutate  handle a Kubernetes AdmissionReview, if
+//	                        Config.Admission is set
+//
+// The path is a CUE path, such as #Service or k8s.#Deployment, selecting a
+// value within a package. As # must be escaped in URLs, the # of a
+// definition may be omitted: /validate/Service selects #Service if the
+// package has no field Service. If more than one package is served, the
+// package query parameter selects the package by its import path.
+//
+// The body of a request is JSON, unless its Content-Type indicates YAML.
+// Errors are reported as a JSON object with an errors field, which lists
+// the message, path and positions of each error.
+//
+// The admission endpoints implement Kubernetes validating and mutating
+// admission webhooks using the admission.k8s.io/v1 AdmissionReview
+// protocol. The value at Config.Admission maps API versions and kinds to
+// the values, typically definitions, that objects of that kind must
+// satisfy:
+//
+//	admission: "apps/v1": Deployment: #Deployment
+//	admission: v1: Service:           #Service
+//
+// A request is denied if its object does not unify with the value for its
+// kind. The mutating endpoint additionally responds with a JSON Patch that
+// adds the defaults and other values filled in by the unification. Objects
+// of kinds not in the mapping are allowed unchanged.
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	cuejson "cuelang.org/go/encoding/json"
+	"cuelang.org/go/encoding/yaml"
+	"cuelang.org/go/internal"
+)
+
+// An Instance is a package served by a Server.
+type Instance struct {
+	// ID identifies the instance, typically by its import path.
+	ID    string
+	Value cue.Value
+}
+
+// Config defines the packages served by a Server.
+type Config struct {
+	// Load loads the instances to serve. It is called when the server is
+	// created and on each call to Reload.
+	Load func() ([]Instance, error)
+
+	// Dir, if set, is used to report file names relative to this
+	// directory.
+	Dir string
+
+	// MaxBodySize limits the size of request bodies. It defaults to 10MB.
+	MaxBodySize int64
+
+	// Admission, if set, is the path of the value mapping API versions and
+	// kinds to the values used by the admission endpoints.
+	Admission string
+}
+
+const defaultMaxBodySize = 10 << 20
+
+// A Server serves requests for the packages loaded by its config. It
+// implements http.Handler.
+//
+// Requests are evaluated one at a time.
+type Server struct {
+	cfg Config
+
+	mu        sync.Mutex
+	instances []Instance
+	loadErr   error
+}
+
+// New returns a Server for the instances loaded by the given config.
+func New(cfg *Config) (*Server, error) {
+	s := &Server{cfg: *cfg}
+	if s.cfg.MaxBodySize == 0 {
+		s.cfg.MaxBodySize = defaultMaxBodySize
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reloads the instances. If loading fails, the server continues to
+// serve the previously loaded instances, and reports the error as part of
+// its health status.
+func (s *Server) Reload() error {
+	a, err := s.cfg.Load()
+	if err == nil {
+		for _, inst := range a {
+			if err = inst.Value.Validate(); err != nil {
+				break
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadErr = err
+	if err == nil {
+		s.instances = a
+	}
+	return err
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := r.URL.Path
+	switch {
+	case p == "/healthz":
+		s.health(w, r)
+	case p == "/definitions":
+		s.definitions(w, r)
+	case strings.HasPrefix(p, "/validate/"):
+		s.eval(w, r, strings.TrimPrefix(p, "/validate/"), false)
+	case strings.HasPrefix(p, "/export/"):
+		s.eval(w, r, strings.TrimPrefix(p, "/export/"), true)
+	case p == "/admission/validate":
+		s.admit(w, r, false)
+	case p == "/admission/mutate":
+		s.admit(w, r, true)
+	default:
+		s.error(w, http.StatusNotFound, errors.Newf(token.NoPos,
+			"unknown endpoint %q", p))
+	}
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	if !s.method(w, r, http.MethodGet) {
+		return
+	}
+	if s.loadErr != nil {
+		s.writeJSON(w, http.StatusInternalServerError, &response{
+			Status: "error",
+			Errors: s.errors(s.loadErr),
+		})
+		return
+	}
+	s.writeJSON(w, http.StatusOK, &response{Status: "ok"})
+}
+
+func (s *Server) definitions(w http.ResponseWriter, r *http.Request) {
+	if !s.method(w, r, http.MethodGet) {
+		return
+	}
+	type instance struct {
+		ID          string   `json:"id"`
+		Definitions []string `json:"definitions"`
+	}
+	a := []instance{}
+	for _, inst := range s.instances {
+		x := instance{ID: inst.ID, Definitions: []string{}}
+		iter, err := inst.Value.Fields(cue.Definitions(true))
+		if err != nil {
+			s.error(w, http.StatusInternalServerError, err)
+			return
+		}
+		for iter.Next() {
+			if iter.Selector().IsDefinition() {
+				x.Definitions = append(x.Definitions, iter.Selector().String())
+			}
+		}
+		a = append(a, x)
+	}
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{"instances": a})
+}
+
+func (s *Server) eval(w http.ResponseWriter, r *http.Request, path string, export bool) {
+	if !s.method(w, r, http.MethodPost) {
+		return
+	}
+	schema, status, err := s.lookup(path, r.URL.Query().Get("package"))
+	if err != nil {
+		s.error(w, status, err)
+		return
+	}
+
+	data, err := s.decode(schema.Context(), r)
+	if err != nil {
+		s.error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	v := data.Unify(schema)
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		s.writeJSON(w, http.StatusUnprocessableEntity, &response{
+			Valid:  new(bool),
+			Errors: s.errors(err),
+		})
+		return
+	}
+	if !export {
+		valid := true
+		s.writeJSON(w, http.StatusOK, &response{Valid: &valid})
+		return
+	}
+	b, err := v.MarshalJSON()
+	if err != nil {
+		s.error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+	io.WriteString(w, "\n")
+}
+
+// lookup returns the value for the given path in the selected instance.
+func (s *Server) lookup(path, pkg string) (v cue.Value, status int, err error) {
+	var inst *Instance
+	switch {
+	case pkg != "":
+		for i := range s.instances {
+			if s.instances[i].ID == pkg {
+				inst = &s.instances[i]
+			}
+		}
+		if inst == nil {
+			return v, http.StatusNotFound, errors.Newf(token.NoPos,
+				"unknown package %q", pkg)
+		}
+	case len(s.instances) == 1:
+		inst = &s.instances[0]
+	default:
+		return v, http.StatusBadRequest, errors.Newf(token.NoPos,
+			"package parameter required to select one of %d packages",
+			len(s.instances))
+	}
+
+	p := cue.ParsePath(path)
+	if err := p.Err(); err != nil {
+		return v, http.StatusBadRequest, err
+	}
+	v = inst.Value.LookupPath(p)
+	if !v.Exists() && path != "" && !strings.HasPrefix(path, "#") {
+		if p := cue.ParsePath("#" + path); p.Err() == nil {
+			v = inst.Value.LookupPath(p)
+		}
+	}
+	if !v.Exists() {
+		return v, http.StatusNotFound, errors.Newf(token.NoPos,
+			"path %q not found in package %q", path, inst.ID)
+	}
+	return v, 0, nil
+}
+
+// decode decodes the body of the request as JSON or YAML.
+func (s *Server) decode(ctx *cue.Context, r *http.Request) (cue.Value, error) {
+	b, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, s.cfg.MaxBodySize))
+	if err != nil {
+		return cue.Value{}, err
+	}
+
+	var expr ast.Expr
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		var f *ast.File
+		f, err = yaml.Extract("request.yaml", b)
+		if err == nil {
+			expr = internal.ToExpr(f)
+		}
+	case "", "application/json", "text/json":
+		expr, err = cuejson.Extract("request.json", b)
+	default:
+		return cue.Value{}, errors.Newf(token.NoPos,
+			"unsupported content type %q", mediaType)
+	}
+	if err != nil {
+		return cue.Value{}, err
+	}
+	v := ctx.BuildExpr(expr)
+	return v, v.Err()
+}
+
+func (s *Server) method(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	s.error(w, http.StatusMethodNotAllowed, errors.Newf(token.NoPos,
+		"method %s not allowed", r.Method))
+	return false
+}
+
+// response is the JSON response of requests other than successful exports.
+type response struct {
+	Status string   `json:"status,omitempty"`
+	Valid  *bool    `json:"valid,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+// An Error is the JSON representation of a CUE error.
+type Error struct {
+	Message   string     `json:"message"`
+	Path      string     `json:"path,omitempty"`
+	Positions []Position `json:"positions,omitempty"`
+}
+
+// A Position is the JSON representation of a source position.
+type Position struct {
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+func (s *Server) errors(err error) []*Error {
+	var a []*Error
+	for _, e := range errors.Errors(err) {
+		format, args := e.Msg()
+		x := &Error{
+			Message: fmt.Sprintf(format, args...),
+			Path:    strings.Join(e.Path(), "."),
+		}
+		for _, p := range errors.Positions(e) {
+			x.Positions = append(x.Positions, Position{
+				Filename: s.filename(p.Filename()),
+				Line:     p.Line(),
+				Column:   p.Column(),
+			})
+		}
+		a = append(a, x)
+	}
+	return a
+}
+
+func (s *Server) filename(name string) string {
+	if s.cfg.Dir == "" || !filepath.IsAbs(name) {
+		return name
+	}
+	if rel, err := filepath.Rel(s.cfg.Dir, name); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return name
+}
+
+func (s *Server) error(w http.ResponseWriter, status int, err error) {
+	s.writeJSON(w, status, &response{Errors: s.errors(err)})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, x interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	e.SetEscapeHTML(false)
+	e.Encode(x)
+}
@@ -0,0 +1,327 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serve_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/internal/serve"
+)
+
+const schema = `
+#Service: {
+	name:     =~"^[a-z]+$"
+	port:     int & >=1024
+	protocol: *"tcp" | "udp"
+}
+`
+
+func load(src ...string) func() ([]serve.Instance, error) {
+	return func() ([]serve.Instance, error) {
+		ctx := cuecontext.New()
+		var a []serve.Instance
+		for i, s := range src {
+			v := ctx.CompileString(s, cue.Filename("schema.cue"))
+			if err := v.Err(); err != nil {
+				return nil, err
+			}
+			a = append(a, serve.Instance{ID: fmt.Sprint("pkg", i), Value: v})
+		}
+		return a, nil
+	}
+}
+
+func TestServer(t *testing.T) {
+	testCases := []struct {
+		name        string
+		method      string
+		url         string
+		contentType string
+		body        string
+		status      int
+		out         string
+	}{{
+		name:   "validate",
+		method: "POST",
+		url:    "/validate/%23Service",
+		body:   `{"name": "web", "port": 8080}`,
+		status: 200,
+		out: `{
+  "valid": true
+}
+`,
+	}, {
+		name:   "validate without #",
+		method: "POST",
+		url:    "/validate/Service",
+		body:   `{"name": "web", "port": 8080}`,
+		status: 200,
+		out: `{
+  "valid": true
+}
+`,
+	}, {
+		name:   "invalid",
+		method: "POST",
+		url:    "/validate/Service",
+		body:   `{"name": "web", "port": 80}`,
+		status: 422,
+		out: `{
+  "valid": false,
+  "errors": [
+    {
+      "message": "invalid value 80 (out of bound >=1024)",
+      "path": "port",
+      "positions": [
+        {
+          "filename": "schema.cue",
+          "line": 4,
+          "column": 18
+        },
+        {
+          "filename": "request.json",
+          "line": 1,
+          "column": 25
+        }
+      ]
+    }
+  ]
+}
+`,
+	}, {
+		name:   "incomplete",
+		method: "POST",
+		url:    "/validate/Service",
+		body:   `{"name": "web"}`,
+		status: 422,
+		out: `{
+  "valid": false,
+  "errors": [
+    {
+      "message": "incomplete value >=1024 & int",
+      "path": "port"
+    }
+  ]
+}
+`,
+	}, {
+		name:        "export yaml",
+		method:      "POST",
+		url:         "/export/Service",
+		contentType: "application/yaml",
+		body:        "name: web\nport: 8080\n",
+		status:      200,
+		out: `{"name":"web","port":8080,"protocol":"tcp"}
+`,
+	}, {
+		name:   "bad json",
+		method: "POST",
+		url:    "/export/Service",
+		body:   `{"name": `,
+		status: 400,
+		out: `{
+  "errors": [
+    {
+      "message": "invalid JSON for file \"request.json\"",
+      "positions": [
+        {
+          "filename": "request.json",
+          "line": 1,
+          "column": 10
+        }
+      ]
+    }
+  ]
+}
+`,
+	}, {
+		name:        "unsupported content type",
+		method:      "POST",
+		url:         "/export/Service",
+		contentType: "text/plain",
+		body:        `x`,
+		status:      400,
+		out: `{
+  "errors": [
+    {
+      "message": "unsupported content type \"text/plain\""
+    }
+  ]
+}
+`,
+	}, {
+		name:   "unknown path",
+		method: "POST",
+		url:    "/validate/Foo",
+		body:   `{}`,
+		status: 404,
+		out: `{
+  "errors": [
+    {
+      "message": "path \"Foo\" not found in package \"pkg0\""
+    }
+  ]
+}
+`,
+	}, {
+		name:   "method",
+		method: "GET",
+		url:    "/validate/Service",
+		status: 405,
+		out: `{
+  "errors": [
+    {
+      "message": "method GET not allowed"
+    }
+  ]
+}
+`,
+	}, {
+		name:   "definitions",
+		method: "GET",
+		url:    "/definitions",
+		status: 200,
+		out: `{
+  "instances": [
+    {
+      "id": "pkg0",
+      "definitions": [
+        "#Service"
+      ]
+    }
+  ]
+}
+`,
+	}, {
+		name:   "health",
+		method: "GET",
+		url:    "/healthz",
+		status: 200,
+		out: `{
+  "status": "ok"
+}
+`,
+	}}
+
+	s, err := serve.New(&serve.Config{Load: load(schema)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, srv.URL+tc.url, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Errorf("status: got %d; want %d", resp.StatusCode, tc.status)
+			}
+			if got := string(b); got != tc.out {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.out)
+			}
+		})
+	}
+}
+
+func TestMultiplePackages(t *testing.T) {
+	s, err := serve.New(&serve.Config{Load: load(schema, `#Other: int`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(url, body string) int {
+		req := httptest.NewRequest("POST", url, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w.Code
+	}
+	if got := post("/validate/Other", `1`); got != http.StatusBadRequest {
+		t.Errorf("without package: got %d; want %d", got, http.StatusBadRequest)
+	}
+	if got := post("/validate/Other?package=pkg1", `1`); got != http.StatusOK {
+		t.Errorf("with package: got %d; want %d", got, http.StatusOK)
+	}
+	if got := post("/validate/Other?package=pkg2", `1`); got != http.StatusNotFound {
+		t.Errorf("unknown package: got %d; want %d", got, http.StatusNotFound)
+	}
+}
+
+func TestReload(t *testing.T) {
+	src := schema
+	s, err := serve.New(&serve.Config{Load: func() ([]serve.Instance, error) {
+		return load(src)()
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validate := func() int {
+		req := httptest.NewRequest("POST", "/validate/Service", strings.NewReader(`{"name": "web", "port": 80}`))
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w.Code
+	}
+	health := func() int {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+		return w.Code
+	}
+
+	if got := validate(); got != http.StatusUnprocessableEntity {
+		t.Fatalf("got %d; want %d", got, http.StatusUnprocessableEntity)
+	}
+
+	src = strings.Replace(schema, ">=1024", ">=1", 1)
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := validate(); got != http.StatusOK {
+		t.Errorf("after reload: got %d; want %d", got, http.StatusOK)
+	}
+
+	// A failed reload keeps serving the previous instances.
+	src = "#Service: {"
+	if err := s.Reload(); err == nil {
+		t.Fatal("expected error")
+	}
+	if got := validate(); got != http.StatusOK {
+		t.Errorf("after failed reload: got %d; want %d", got, http.StatusOK)
+	}
+	if got := health(); got != http.StatusInternalServerError {
+		t.Errorf("health after failed reload: got %d; want %d", got, http.StatusInternalServerError)
+	}
+}
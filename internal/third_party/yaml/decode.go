@@ -202,6 +202,10 @@ func (p *parser) document() *node {
 	p.doc = n
 	p.expect(yaml_DOCUMENT_START_EVENT)
 	n.children = append(n.children, p.parse())
+	if p.peek() == yaml_DOCUMENT_END_EVENT {
+		// Include trailing comments in the document.
+		n.endPos = p.event.end_mark
+	}
 	p.expect(yaml_DOCUMENT_END_EVENT)
 	return n
 }
@@ -320,6 +324,18 @@ func (d *decoder) unmarshal(n *node) (node ast.Expr) {
 }
 
 func (d *decoder) attachDocComments(m yaml_mark_t, pos int8, expr ast.Node) {
+	if cg := d.comments(m, pos); cg != nil {
+		expr.AddComment(cg)
+	}
+}
+
+// docComments consumes the comments preceding m, so that they are not
+// consumed by any of the nodes contained in the node starting at m.
+func (d *decoder) docComments(m yaml_mark_t) *ast.CommentGroup {
+	return d.comments(m, 0)
+}
+
+func (d *decoder) comments(m yaml_mark_t, pos int8) *ast.CommentGroup {
 	comments := []*ast.Comment{}
 	line := 0
 	for len(d.p.parser.comments) > 0 {
@@ -334,12 +350,13 @@ func (d *decoder) attachDocComments(m yaml_mark_t, pos int8, expr ast.Node) {
 		d.p.parser.comments = d.p.parser.comments[1:]
 		line = c.mark.line
 	}
-	if len(comments) > 0 {
-		expr.AddComment(&ast.CommentGroup{
-			Doc:      pos == 0 && line+1 == m.line,
-			Position: pos,
-			List:     comments,
-		})
+	if len(comments) == 0 {
+		return nil
+	}
+	return &ast.CommentGroup{
+		Doc:      pos == 0 && line+1 == m.line,
+		Position: pos,
+		List:     comments,
 	}
 }
 
@@ -361,6 +378,27 @@ func (d *decoder) attachLineComment(m yaml_mark_t, pos int8, expr ast.Node) {
 	}
 }
 
+// attachTrailingComment attaches a comment following m on the same line as a
+// line comment to expr.
+func (d *decoder) attachTrailingComment(m yaml_mark_t, expr ast.Node) {
+	if len(d.p.parser.comments) == 0 {
+		return
+	}
+	c := d.p.parser.comments[0]
+	if c.mark.index < m.index || c.mark.line != m.line {
+		return
+	}
+	expr.AddComment(&ast.CommentGroup{
+		Line:     true,
+		Position: 10,
+		List: []*ast.Comment{{
+			Slash: d.pos(c.mark),
+			Text:  "//" + c.text[1:],
+		}},
+	})
+	d.p.parser.comments = d.p.parser.comments[1:]
+}
+
 func (d *decoder) pos(m yaml_mark_t) token.Pos {
 	pos := d.p.info.Pos(m.index+1, token.NoRelPos)
 
@@ -410,7 +448,16 @@ func (d *decoder) ident(n *node, name string) *ast.Ident {
 func (d *decoder) document(n *node) ast.Expr {
 	if len(n.children) == 1 {
 		d.doc = n
-		return d.unmarshal(n.children[0])
+		expr := d.unmarshal(n.children[0])
+		// Attach trailing comments to the last field, if any, so that they
+		// are retained when the struct is converted to a file.
+		var last ast.Node = expr
+		if s, ok := expr.(*ast.StructLit); ok && len(s.Elts) > 0 {
+			last = s.Elts[len(s.Elts)-1]
+		}
+		d.comments(n.children[0].endPos, 0) // discard stray comments
+		d.attachDocComments(n.endPos, 100, last)
+		return expr
 	}
 	return &ast.BottomLit{} // TODO: more informatives
 }
@@ -630,7 +677,12 @@ func (d *decoder) sequence(n *node) ast.Expr {
 	single := d.isOneLiner(n.startPos, n.endPos)
 	for _, c := range n.children {
 		d.forceNewline = !single
+		comments := d.docComments(c.startPos)
 		elem := d.unmarshal(c)
+		if comments != nil {
+			elem.AddComment(comments)
+		}
+		d.attachTrailingComment(c.endPos, elem)
 		list.Elts = append(list.Elts, elem)
 		_, noNewline = elem.(*ast.StructLit)
 	}
@@ -674,7 +726,17 @@ outer:
 	for i := 0; i < l; i += 2 {
 		if isMerge(n.children[i]) {
 			merge = true
+			// Attach comments preceding the merge key to the first merged
+			// field.
+			comments := d.docComments(n.children[i].startPos)
+			first := len(m.Elts)
 			d.merge(n.children[i+1], m)
+			if comments != nil && first < len(m.Elts) {
+				m.Elts[first].AddComment(comments)
+			}
+			// Merged fields may originate elsewhere in the document.
+			// Position subsequent fields relative to the merge key.
+			d.prev = d.absPos(n.children[i].startPos)
 			continue
 		}
 		switch n.children[i].kind {
@@ -712,6 +712,11 @@ a:
 		"---\nhello\n...\n}not yaml",
 		`"hello"`,
 	},
+	// Comments in sequences and at the end of a document.
+	{
+		"a:\n  # first\n  - 1 # one\n  # second\n  - 2\n# end\n",
+		"a: [\n\t// first\n\t1, // one\n\t// second\n\t2,\n]\n// end",
+	},
 }
 
 type M map[interface{}]interface{}
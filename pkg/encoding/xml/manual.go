@@ -0,0 +1,53 @@
+// Copyright 2022 The CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xml
+
+import (
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	cuexml "cuelang.org/go/encoding/xml"
+	"cuelang.org/go/pkg/internal"
+)
+
+// Marshal returns the XML encoding of v. The value must be a struct with
+// a single field, which is mapped to the root element. Fields starting with
+// a `$` map to attributes, where the field `$text` holds character data.
+func Marshal(v cue.Value) (string, error) {
+	b, err := cuexml.Encode(v)
+	return string(b), err
+}
+
+// Unmarshal parses the XML to a CUE expression. As no schema is available,
+// all character data maps to strings and only repeated elements map to
+// lists.
+func Unmarshal(data []byte) (ast.Expr, error) {
+	return cuexml.Extract("", data)
+}
+
+// Validate validates XML and confirms it is an instance of the schema
+// specified by v. The schema is also used to determine which elements
+// map to lists and to convert character data to numbers and booleans.
+func Validate(b []byte, v cue.Value) (bool, error) {
+	if err := cuexml.Validate(b, v); err != nil {
+		// Strip error codes: incomplete errors are terminal in this case.
+		var b internal.Bottomer
+		if errors.As(err, &b) {
+			err = b.Bottom().Err
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,55 @@
+// Code generated by cuelang.org/go/pkg/gen. DO NOT EDIT.
+
+package xml
+
+import (
+	"cuelang.org/go/internal/core/adt"
+	"cuelang.org/go/pkg/internal"
+)
+
+func init() {
+	internal.Register("encoding/xml", pkg)
+}
+
+var _ = adt.TopKind // in case the adt package isn't used
+
+var pkg = &internal.Package{
+	Native: []*internal.Builtin{{
+		Name: "Marshal",
+		Params: []internal.Param{
+			{Kind: adt.TopKind},
+		},
+		Result: adt.StringKind,
+		Func: func(c *internal.CallCtxt) {
+			v := c.Value(0)
+			if c.Do() {
+				c.Ret, c.Err = Marshal(v)
+			}
+		},
+	}, {
+		Name: "Unmarshal",
+		Params: []internal.Param{
+			{Kind: adt.BytesKind | adt.StringKind},
+		},
+		Result: adt.TopKind,
+		Func: func(c *internal.CallCtxt) {
+			data := c.Bytes(0)
+			if c.Do() {
+				c.Ret, c.Err = Unmarshal(data)
+			}
+		},
+	}, {
+		Name: "Validate",
+		Params: []internal.Param{
+			{Kind: adt.BytesKind | adt.StringKind},
+			{Kind: adt.TopKind},
+		},
+		Result: adt.BoolKind,
+		Func: func(c *internal.CallCtxt) {
+			b, v := c.Bytes(0), c.Value(1)
+			if c.Do() {
+				c.Ret, c.Err = Validate(b, v)
+			}
+		},
+	}},
+}
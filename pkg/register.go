@@ -25,6 +25,7 @@ import (
 	_ "cuelang.org/go/pkg/encoding/csv"
 	_ "cuelang.org/go/pkg/encoding/hex"
 	_ "cuelang.org/go/pkg/encoding/json"
+	_ "cuelang.org/go/pkg/encoding/xml"
 	_ "cuelang.org/go/pkg/encoding/yaml"
 	_ "cuelang.org/go/pkg/html"
 
@@ -0,0 +1,697 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package example generates example data that satisfies a schema.
+//
+// Generate walks a schema, typically a definition, and fills in a concrete
+// value wherever the schema leaves one open:
+//
+//   - default values are used where they exist;
+//   - the first disjunct that yields a valid value is chosen;
+//   - numbers are chosen to satisfy bounds, such as >=1 & <10;
+//   - strings are chosen to match regular expressions, using a simple
+//     witness of the expression, and to satisfy strings.MinRunes and
+//     strings.MaxRunes;
+//   - lists get as many elements as required by fixed elements and
+//     list.MinItems;
+//   - structs get their regular fields and, if required by struct.MinFields,
+//     optional fields or fields matching pattern constraints.
+//
+// Fields that are computed from other fields, like y: x+1, are left to
+// evaluation once the fields they depend on are filled in. Each generated
+// value is validated against the schema.
+//
+// Configured with a source of randomness, Generate instead picks disjuncts,
+// values within bounds and list lengths at random and, if optional fields are
+// requested, includes each of them at random. This is useful for
+// property-based testing of consumers of the data.
+package example
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/errors"
+)
+
+// Config defines options for generating examples.
+type Config struct {
+	// Optional indicates that optional fields are included in structs. If
+	// Rand is also set, each optional field is included at random.
+	Optional bool
+
+	// Rand, if set, is used to make random choices. Otherwise the generated
+	// values are as simple as possible.
+	Rand *rand.Rand
+}
+
+// maxDepth limits the nesting of generated values, which matters for
+// recursive schemas with optional fields or lists.
+const maxDepth = 16
+
+// Generate returns a concrete value that is an instance of v. The config
+// may be nil.
+func Generate(v cue.Value, c *Config) (cue.Value, error) {
+	g := &generator{}
+	if c != nil {
+		g.cfg = *c
+	}
+	x, err := g.value(v, 0)
+	if err != nil {
+		return x, err
+	}
+	if err := x.Validate(cue.Concrete(true)); err != nil {
+		return x, err
+	}
+	return x, nil
+}
+
+type generator struct {
+	cfg Config
+}
+
+func (g *generator) random() bool {
+	return g.cfg.Rand != nil
+}
+
+// coin returns true with a probability of one half in random mode and false
+// otherwise.
+func (g *generator) coin() bool {
+	return g.random() && g.cfg.Rand.Intn(2) == 0
+}
+
+func (g *generator) intn(n int) int {
+	if !g.random() || n <= 0 {
+		return 0
+	}
+	return g.cfg.Rand.Intn(n)
+}
+
+func errorf(v cue.Value, format string, args ...interface{}) error {
+	return errors.Newf(v.Pos(), "cannot generate example for %s: %s",
+		v.Path(), fmt.Sprintf(format, args...))
+}
+
+// value returns a concrete instance of v.
+func (g *generator) value(v cue.Value, depth int) (cue.Value, error) {
+	// Validators like list.MinItems make Err report an error for values that
+	// are not yet complete, so use Validate instead.
+	if err := v.Validate(); err != nil {
+		return v, err
+	}
+	d, useDefault := v.Default()
+	useDefault = useDefault && d.Validate(cue.Concrete(true)) == nil
+	if useDefault && !g.coin() {
+		return d, nil
+	}
+	if isConcrete(v) {
+		return v, nil
+	}
+	if depth > maxDepth {
+		return v, errorf(v, "value nested too deeply")
+	}
+
+	x, err := g.nonDefault(v, useDefault, depth)
+	if err != nil && useDefault {
+		return d, nil
+	}
+	return x, err
+}
+
+// nonDefault returns a concrete instance of v without picking its concrete
+// default, if it has one.
+func (g *generator) nonDefault(v cue.Value, hasDefault bool, depth int) (cue.Value, error) {
+	// Expr omits the default of a disjunction and reports the remaining
+	// disjunct without an operator if only one is left. As it reports other
+	// values with a default, like lists with fixed elements, as is, the
+	// depth is increased to guarantee progress.
+	switch op, args := v.Expr(); {
+	case op == cue.OrOp:
+		return g.disjunction(v, args, depth)
+	case hasDefault && op == cue.NoOp && len(args) == 1:
+		return g.disjunction(v, args, depth+1)
+	}
+
+	c := newConstraints()
+	c.collect(v)
+	var err error
+	for _, k := range g.kinds(c.kind) {
+		var x cue.Value
+		switch k {
+		case cue.StructKind:
+			x, err = g.structValue(v, c, depth)
+		case cue.ListKind:
+			x, err = g.list(v, c, depth)
+		default:
+			x, err = g.scalar(v, k, c)
+		}
+		if err != nil {
+			continue
+		}
+		if x = x.Unify(v); isConcrete(x) {
+			return x, nil
+		}
+		err = x.Validate(cue.Concrete(true))
+	}
+	if err == nil {
+		err = errorf(v, "no value satisfies %v", v)
+	}
+	return v, err
+}
+
+// isConcrete reports whether v is a valid concrete value. Values with
+// validators, like [...int] & list.MinItems(1), have no concrete kind until
+// they are resolved, even though they may validate.
+func isConcrete(v cue.Value) bool {
+	return v.Kind() != cue.BottomKind && v.Validate(cue.Concrete(true)) == nil
+}
+
+func (g *generator) disjunction(v cue.Value, disjuncts []cue.Value, depth int) (cue.Value, error) {
+	order := make([]int, len(disjuncts))
+	for i := range order {
+		order[i] = i
+	}
+	if g.random() {
+		g.cfg.Rand.Shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
+		})
+	}
+	var err error
+	for _, i := range order {
+		var x cue.Value
+		x, err = g.value(disjuncts[i], depth)
+		if err != nil {
+			continue
+		}
+		if x = x.Unify(v); isConcrete(x) {
+			return x, nil
+		}
+		err = x.Validate(cue.Concrete(true))
+	}
+	return v, err
+}
+
+// kinds returns the kinds to try for a value of kind k, in order of
+// preference.
+func (g *generator) kinds(k cue.Kind) []cue.Kind {
+	if k == cue.TopKind {
+		return []cue.Kind{cue.NullKind}
+	}
+	var a []cue.Kind
+	for _, x := range []cue.Kind{
+		cue.StructKind, cue.ListKind, cue.StringKind, cue.IntKind,
+		cue.FloatKind, cue.BoolKind, cue.BytesKind, cue.NullKind,
+	} {
+		if k&x != 0 {
+			a = append(a, x)
+		}
+	}
+	if g.random() {
+		g.cfg.Rand.Shuffle(len(a), func(i, j int) { a[i], a[j] = a[j], a[i] })
+	}
+	return a
+}
+
+// constraints holds the constraints of a value that determine how an
+// example is generated.
+type constraints struct {
+	kind cue.Kind
+
+	lo, hi         float64
+	loExcl, hiExcl bool
+
+	match    []string
+	notMatch []string
+
+	minItems, maxItems   int
+	minFields, maxFields int
+	minRunes, maxRunes   int
+
+	lists []cue.Value
+}
+
+func newConstraints() *constraints {
+	return &constraints{
+		kind:      cue.TopKind,
+		lo:        math.Inf(-1),
+		hi:        math.Inf(1),
+		maxItems:  -1,
+		maxFields: -1,
+		maxRunes:  -1,
+	}
+}
+
+func (c *constraints) collect(v cue.Value) {
+	op, args := v.Expr()
+	switch op {
+	case cue.AndOp:
+		for _, a := range args {
+			c.collect(a)
+		}
+
+	case cue.GreaterThanOp, cue.GreaterThanEqualOp:
+		if f, ok := toFloat(args[0]); ok && f >= c.lo {
+			c.lo, c.loExcl = f, op == cue.GreaterThanOp
+		}
+		c.kind &= args[0].IncompleteKind() | cue.NumberKind
+
+	case cue.LessThanOp, cue.LessThanEqualOp:
+		if f, ok := toFloat(args[0]); ok && f <= c.hi {
+			c.hi, c.hiExcl = f, op == cue.LessThanOp
+		}
+		c.kind &= args[0].IncompleteKind() | cue.NumberKind
+
+	case cue.RegexMatchOp, cue.NotRegexMatchOp:
+		s, _ := args[0].String()
+		if op == cue.RegexMatchOp {
+			c.match = append(c.match, s)
+		} else {
+			c.notMatch = append(c.notMatch, s)
+		}
+		c.kind &= cue.StringKind | cue.BytesKind
+
+	case cue.NotEqualOp:
+		c.kind &= args[0].IncompleteKind() | cue.NullKind
+
+	case cue.CallOp:
+		c.call(args)
+
+	default:
+		if k := v.IncompleteKind(); k != cue.BottomKind {
+			c.kind &= k
+		}
+		if v.IncompleteKind() == cue.ListKind {
+			c.lists = append(c.lists, v)
+		}
+	}
+}
+
+func toFloat(v cue.Value) (float64, bool) {
+	if v.Kind() == cue.IntKind {
+		i, err := v.Int64()
+		return float64(i), err == nil
+	}
+	f, err := v.Float64()
+	return f, err == nil
+}
+
+// call records the constraints of calls to validators of the builtin list,
+// struct and strings packages.
+func (c *constraints) call(args []cue.Value) {
+	op, sel := args[0].Expr()
+	if op != cue.SelectorOp || len(sel) != 2 || len(args) != 2 {
+		return
+	}
+	name, _ := sel[1].String()
+	n64, err := args[1].Int64()
+	if err != nil {
+		return
+	}
+	n := int(n64)
+	switch name {
+	case "MinItems":
+		c.minItems = n
+		c.kind &= cue.ListKind
+	case "MaxItems":
+		c.maxItems = n
+		c.kind &= cue.ListKind
+	case "MinFields":
+		c.minFields = n
+		c.kind &= cue.StructKind
+	case "MaxFields":
+		c.maxFields = n
+		c.kind &= cue.StructKind
+	case "MinRunes":
+		c.minRunes = n
+		c.kind &= cue.StringKind
+	case "MaxRunes":
+		c.maxRunes = n
+		c.kind &= cue.StringKind
+	}
+}
+
+func (g *generator) scalar(v cue.Value, k cue.Kind, c *constraints) (cue.Value, error) {
+	ctx := v.Context()
+	switch k {
+	case cue.NullKind:
+		return ctx.CompileString("null"), nil
+	case cue.BoolKind:
+		return ctx.Encode(g.coin()), nil
+	case cue.BytesKind:
+		return ctx.Encode([]byte{}), nil
+	case cue.StringKind:
+		s, err := g.str(v, c)
+		return ctx.Encode(s), err
+	case cue.IntKind:
+		return g.number(v, c, true)
+	case cue.FloatKind:
+		return g.number(v, c, false)
+	}
+	return v, errorf(v, "unsupported kind %s", k)
+}
+
+// number returns a number within the bounds of c, trying successive
+// candidates to avoid excluded values.
+func (g *generator) number(v cue.Value, c *constraints, isInt bool) (cue.Value, error) {
+	lo, hi := c.lo, c.hi
+	step := 1.0
+	if isInt {
+		lo, hi = math.Ceil(lo), math.Floor(hi)
+		if c.loExcl && lo == c.lo {
+			lo++
+		}
+		if c.hiExcl && hi == c.hi {
+			hi--
+		}
+	} else if c.loExcl || c.hiExcl {
+		// Stay away from exclusive bounds.
+		switch {
+		case !math.IsInf(lo, 0) && !math.IsInf(hi, 0):
+			step = (hi - lo) / 4
+			lo += step
+			hi -= step
+		case c.loExcl:
+			lo += step
+		default:
+			hi -= step
+		}
+	}
+	if lo > hi {
+		return v, errorf(v, "no number satisfies %v", v)
+	}
+
+	// Pick 0 if possible, and the bound closest to 0 otherwise.
+	x := math.Max(lo, math.Min(hi, 0))
+	if g.random() {
+		const width = 100
+		l, h := lo, hi
+		switch {
+		case math.IsInf(l, -1) && math.IsInf(h, 1):
+			l, h = 0, width
+		case math.IsInf(l, -1):
+			l = h - width
+		case math.IsInf(h, 1):
+			h = l + width
+		}
+		x = l + g.cfg.Rand.Float64()*(h-l)
+		if isInt {
+			x = math.Floor(x)
+		} else {
+			x = math.Round(x*100) / 100
+		}
+		x = math.Max(lo, math.Min(hi, x))
+	}
+
+	ctx := v.Context()
+	for i := 0; i < 10 && x <= hi; i++ {
+		var n cue.Value
+		if isInt {
+			n = ctx.Encode(int64(x))
+		} else {
+			n = ctx.CompileString(formatFloat(x))
+		}
+		if n.Unify(v).Validate(cue.Concrete(true)) == nil {
+			return n, nil
+		}
+		x += step
+	}
+	return v, errorf(v, "no number satisfies %v", v)
+}
+
+func formatFloat(f float64) string {
+	s := fmt.Sprint(f)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
+
+func (g *generator) str(v cue.Value, c *constraints) (string, error) {
+	var candidates []string
+	for _, re := range c.match {
+		s, err := g.witness(re)
+		if err != nil {
+			return "", errorf(v, "%v", err)
+		}
+		candidates = append(candidates, s)
+	}
+	if len(candidates) == 0 {
+		s := "example"
+		if g.random() {
+			s = g.word(1 + g.intn(8))
+		}
+		candidates = append(candidates, s, "")
+	}
+	for _, s := range candidates {
+		n := len([]rune(s))
+		if n < c.minRunes {
+			s += strings.Repeat("x", c.minRunes-n)
+		}
+		if c.maxRunes >= 0 && n > c.maxRunes {
+			s = string([]rune(s)[:c.maxRunes])
+		}
+		for i := 0; i < 10; i++ {
+			x := s
+			if i > 0 {
+				x = fmt.Sprint(s, i)
+			}
+			if v.Context().Encode(x).Unify(v).Validate(cue.Concrete(true)) == nil {
+				return x, nil
+			}
+		}
+	}
+	return "", errorf(v, "no string satisfies %v", v)
+}
+
+// word returns a random lowercase word of n letters.
+func (g *generator) word(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('a' + g.intn(26))
+	}
+	return string(b)
+}
+
+// witness returns a string matching the regular expression re.
+func (g *generator) witness(re string) (string, error) {
+	r, err := syntax.Parse(re, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	g.writeWitness(&b, r.Simplify())
+	return b.String(), nil
+}
+
+func (g *generator) writeWitness(b *strings.Builder, r *syntax.Regexp) {
+	switch r.Op {
+	case syntax.OpLiteral:
+		for _, c := range r.Rune {
+			if r.Flags&syntax.FoldCase != 0 {
+				c = unicode.ToLower(c)
+			}
+			b.WriteRune(c)
+		}
+	case syntax.OpCharClass:
+		b.WriteRune(g.classRune(r.Rune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteRune(rune('a' + g.intn(26)))
+	case syntax.OpCapture:
+		g.writeWitness(b, r.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range r.Sub {
+			g.writeWitness(b, sub)
+		}
+	case syntax.OpAlternate:
+		g.writeWitness(b, r.Sub[g.intn(len(r.Sub))])
+	case syntax.OpStar, syntax.OpQuest:
+		for n := g.intn(3); n > 0; n-- {
+			g.writeWitness(b, r.Sub[0])
+			if r.Op == syntax.OpQuest {
+				break
+			}
+		}
+	case syntax.OpPlus:
+		for n := 1 + g.intn(3); n > 0; n-- {
+			g.writeWitness(b, r.Sub[0])
+		}
+	case syntax.OpRepeat:
+		n := r.Min
+		if r.Max > r.Min {
+			n += g.intn(r.Max - r.Min + 1)
+		}
+		for ; n > 0; n-- {
+			g.writeWitness(b, r.Sub[0])
+		}
+	}
+}
+
+// classRune returns a rune from the given character class, which holds
+// pairs of rune ranges. Letters and digits are preferred.
+func (g *generator) classRune(ranges []rune) rune {
+	var pairs [][2]rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		pairs = append(pairs, [2]rune{ranges[i], ranges[i+1]})
+	}
+	if len(pairs) == 0 {
+		return 'a'
+	}
+	for _, want := range []rune{'a', 'A', '0'} {
+		for _, p := range pairs {
+			if p[0] <= want && want <= p[1] && !g.random() {
+				return want
+			}
+		}
+	}
+	// Pick a random rune, or the first printable one if it is not printable,
+	// to avoid control characters in the example.
+	for _, p := range pairs {
+		if p[0] > unicode.MaxASCII && len(pairs) > 1 {
+			continue
+		}
+		if r := p[0] + rune(g.intn(int(p[1]-p[0])+1)); unicode.IsPrint(r) {
+			return r
+		}
+		for r := p[0]; r <= p[1]; r++ {
+			if unicode.IsPrint(r) {
+				return r
+			}
+		}
+	}
+	return pairs[0][0]
+}
+
+func (g *generator) list(v cue.Value, c *constraints, depth int) (cue.Value, error) {
+	var fixed []cue.Value
+	elem := cue.Value{}
+	for _, l := range c.lists {
+		if iter, err := l.List(); err == nil {
+			var a []cue.Value
+			for iter.Next() {
+				a = append(a, iter.Value())
+			}
+			if len(a) > len(fixed) {
+				fixed = a
+			}
+		}
+		if e := l.LookupPath(cue.MakePath(cue.AnyIndex)); e.Exists() {
+			elem = e
+		}
+	}
+
+	n := len(fixed)
+	if c.minItems > n {
+		n = c.minItems
+	}
+	if elem.Exists() && depth < maxDepth/2 {
+		extra := g.intn(4)
+		if c.maxItems >= 0 && n+extra > c.maxItems {
+			extra = c.maxItems - n
+		}
+		if extra > 0 {
+			n += extra
+		}
+	}
+
+	elems := make([]cue.Value, 0, n)
+	for i := 0; i < n; i++ {
+		e := elem
+		if i < len(fixed) {
+			e = fixed[i]
+		}
+		if !e.Exists() {
+			return v, errorf(v, "list does not allow %d elements", n)
+		}
+		// Use the constraints of the list itself for the element, so that
+		// fixed elements are unified with any element constraints.
+		if x := v.LookupPath(cue.MakePath(cue.Index(i))); x.Exists() {
+			e = x
+		}
+		x, err := g.value(e, depth+1)
+		if err != nil {
+			return v, err
+		}
+		elems = append(elems, x)
+	}
+	return v.Context().NewList(elems...), nil
+}
+
+func (g *generator) structValue(v cue.Value, c *constraints, depth int) (cue.Value, error) {
+	iter, err := v.Fields(cue.Optional(true))
+	if err != nil {
+		return v, err
+	}
+	var labels, optional []string
+	for iter.Next() {
+		switch {
+		case !iter.IsOptional():
+			labels = append(labels, iter.Label())
+		case depth < maxDepth/2 && g.cfg.Optional && (!g.random() || g.coin()):
+			labels = append(labels, iter.Label())
+		default:
+			optional = append(optional, iter.Label())
+		}
+	}
+
+	// Add optional fields, and then fields matching pattern constraints,
+	// to satisfy struct.MinFields.
+	for len(labels) < c.minFields && len(optional) > 0 {
+		labels = append(labels, optional[0])
+		optional = optional[1:]
+	}
+	for i := 0; len(labels) < c.minFields && i < 100; i++ {
+		label := fmt.Sprint("key", i)
+		if !v.Allows(cue.Str(label)) || contains(labels, label) {
+			continue
+		}
+		labels = append(labels, label)
+	}
+	if len(labels) < c.minFields {
+		return v, errorf(v, "cannot generate %d fields", c.minFields)
+	}
+
+	x := v
+	for _, label := range labels {
+		p := cue.MakePath(cue.Str(label))
+		f := x.LookupPath(p)
+		if !f.Exists() {
+			f = x.LookupPath(cue.MakePath(cue.Str(label).Optional()))
+		}
+		if !f.Exists() {
+			// A field matching a pattern constraint.
+			f = x.FillPath(p, cue.Value{}).LookupPath(p)
+			f = x.Context().CompileString("_").Unify(f)
+		}
+		fx, err := g.value(f, depth+1)
+		if err != nil {
+			return v, err
+		}
+		x = x.FillPath(p, fx)
+	}
+	return x, nil
+}
+
+func contains(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,278 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package example_test
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/tools/example"
+)
+
+func TestGenerate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		in       string
+		optional bool
+		out      string
+	}{{
+		name: "scalars",
+		in: `#D: {
+			a: int
+			b: string
+			c: bool
+			d: null
+			e: number
+			f: bytes
+		}`,
+		out: `{
+	a: 0
+	b: "example"
+	c: false
+	d: null
+	e: 0
+	f: ''
+}`,
+	}, {
+		name: "defaults",
+		in: `#D: {
+			a: int | *3
+			b: *"x" | "y"
+		}`,
+		out: `{
+	a: 3
+	b: "x"
+}`,
+	}, {
+		name: "bounds",
+		in: `#D: {
+			a: int & >=10 & <20
+			b: >5
+			c: float & >1.5 & <2.5
+			d: int & < -3
+			e: int & !=0
+		}`,
+		out: `{
+	a: 10
+	b: 6
+	c: 1.75
+	d: -4
+	e: 1
+}`,
+	}, {
+		name: "regexp",
+		in: `#D: {
+			a: =~"^[a-z]+-[0-9]{3}$"
+			b: string & =~"^v(1|2)\\.x?$"
+			c: =~"^(?i)AB$"
+		}`,
+		out: `{
+	a: "a-000"
+	b: "v1."
+	c: "ab"
+}`,
+	}, {
+		name: "disjunctions",
+		in: `#D: {
+			a: "x" | "y"
+			b: {n: int} | string
+			c: 1 | 2 & >1
+		}`,
+		out: `{
+	a: "x"
+	b: {
+		n: 0
+	}
+	c: 1
+}`,
+	}, {
+		name: "lists",
+		in: `import "list"
+
+		#D: {
+			a: [...int]
+			b: [...string] & list.MinItems(2)
+			c: [int, string, ...bool]
+		}`,
+		out: `{
+	a: []
+	b: ["example", "example"]
+	c: [0, "example"]
+}`,
+	}, {
+		name: "structs",
+		in: `import "struct"
+
+		#D: {
+			a:  int
+			b?: string
+			c: {[string]: int} & struct.MinFields(2)
+			d: {x?: int, y?: int, ...} & struct.MinFields(1)
+		}`,
+		out: `{
+	a: 0
+	c: {
+		key0: 0
+		key1: 0
+	}
+	d: {
+		x: 0
+	}
+}`,
+	}, {
+		name:     "optional",
+		optional: true,
+		in: `#D: {
+			a:  int
+			b?: string
+		}`,
+		out: `{
+	a: 0
+	b: "example"
+}`,
+	}, {
+		name: "references",
+		in: `#D: {
+			x: int & >2
+			y: x + 1
+			z: "n\(x)"
+		}`,
+		out: `{
+	x: 3
+	y: 4
+	z: "n3"
+}`,
+	}, {
+		name: "strings",
+		in: `import "strings"
+
+		#D: {
+			a: strings.MinRunes(10)
+			b: string & strings.MaxRunes(3)
+		}`,
+		out: `{
+	a: "examplexxx"
+	b: "exa"
+}`,
+	}, {
+		name: "recursive",
+		in: `#D: {
+			name: string
+			children?: [...#D]
+		}`,
+		out: `{
+	name: "example"
+}`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := cuecontext.New()
+			v := ctx.CompileString(tc.in).LookupPath(cue.ParsePath("#D"))
+			if err := v.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			x, err := example.Generate(v, &example.Config{Optional: tc.optional})
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := format.Node(x.Syntax(cue.Final(), cue.Concrete(true)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tc.out {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.out)
+			}
+		})
+	}
+}
+
+func TestGenerateRandom(t *testing.T) {
+	ctx := cuecontext.New()
+	v := ctx.CompileString(`
+	import "list"
+
+	#D: {
+		id:   =~"^[A-Z]{2}[0-9]+$"
+		kind: "a" | "b" | "c"
+		n:    int & >=1 & <=5
+		tags: [...string] & list.MinItems(1)
+		opt?: {x: bool}
+		m: {[string]: number}
+		port: int & >=1024 & <65536 | *8080
+		code: =~"^[^a-z]+$"
+	}`).LookupPath(cue.ParsePath("#D"))
+
+	// Optional fields are only included, at random, if requested.
+	ports := map[int64]bool{}
+	for _, optional := range []bool{false, true} {
+		withOpt := 0
+		for seed := int64(0); seed < 50; seed++ {
+			c := &example.Config{
+				Optional: optional,
+				Rand:     rand.New(rand.NewSource(seed)),
+			}
+			x, err := example.Generate(v, c)
+			if err != nil {
+				t.Fatalf("seed %d: %v", seed, err)
+			}
+			if err := x.Unify(v).Validate(cue.Concrete(true)); err != nil {
+				t.Errorf("seed %d: %v", seed, err)
+			}
+			if x.LookupPath(cue.ParsePath("opt")).Exists() {
+				withOpt++
+			}
+			port, _ := x.LookupPath(cue.ParsePath("port")).Int64()
+			ports[port] = true
+			code, _ := x.LookupPath(cue.ParsePath("code")).String()
+			if strings.IndexFunc(code, func(r rune) bool { return !unicode.IsPrint(r) }) >= 0 {
+				t.Errorf("seed %d: code %q is not printable", seed, code)
+			}
+		}
+		switch {
+		case !optional && withOpt > 0:
+			t.Errorf("optional field generated %d times without Optional", withOpt)
+		case optional && (withOpt == 0 || withOpt == 50):
+			t.Errorf("optional field generated %d out of 50 times; want some", withOpt)
+		}
+	}
+
+	if len(ports) < 2 {
+		t.Errorf("only generated ports %v; want the default and others", ports)
+	}
+
+	// The same seed yields the same value.
+	gen := func() string {
+		c := &example.Config{Rand: rand.New(rand.NewSource(1))}
+		x, _ := example.Generate(v, c)
+		return fmt.Sprint(x)
+	}
+	if a, b := gen(), gen(); a != b {
+		t.Errorf("same seed generated different values:\n%s\n%s", a, b)
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	ctx := cuecontext.New()
+	v := ctx.CompileString(`#D: int & >1 & <2`).LookupPath(cue.ParsePath("#D"))
+	if _, err := example.Generate(v, nil); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -24,6 +24,7 @@ import (
 
 	"cuelang.org/go/cue/ast"
 	"cuelang.org/go/cue/ast/astutil"
+	"cuelang.org/go/cue/errors"
 	"cuelang.org/go/cue/token"
 )
 
@@ -32,6 +33,8 @@ type Option func(*options)
 type options struct {
 	simplify   bool
 	deprecated bool
+	rules      []*Rule
+	scope      map[string]bool // names declared by other files
 }
 
 // Simplify enables fixes that simplify the code, but are not strictly
@@ -41,7 +44,16 @@ func Simplify() Option {
 }
 
 // File applies fixes to f and returns it. It alters the original f.
+// Matches of rewrite rules whose replacement would refer to undefined
+// identifiers are left unchanged.
 func File(f *ast.File, o ...Option) *ast.File {
+	f, _ = file(f, o...)
+	return f
+}
+
+// file is like File, but also reports the matches of rewrite rules that
+// were left unchanged.
+func file(f *ast.File, o ...Option) (*ast.File, errors.Error) {
 	var options options
 	for _, f := range o {
 		f(&options)
@@ -137,5 +149,12 @@ func File(f *ast.File, o ...Option) *ast.File {
 		f = simplify(f)
 	}
 
-	return f
+	var errs errors.Error
+	for _, r := range options.rules {
+		var err errors.Error
+		f, err = rewrite(f, r, options.scope)
+		errs = errors.Append(errs, err)
+	}
+
+	return f, errs
 }
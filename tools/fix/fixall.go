@@ -34,11 +34,48 @@ func Instances(a []*build.Instance, o ...Option) errors.Error {
 		cwd:       cwd,
 	}
 
-	p.visitAll(func(f *ast.File) { File(f, o...) })
+	scopes := map[*build.Instance]map[string]bool{}
+	p.visitAll(func(b *build.Instance, f *ast.File) {
+		if scopes[b] == nil {
+			scopes[b] = topLevelNames(b.Files)
+		}
+		_, err := file(f, append(o[:len(o):len(o)], packageScope(scopes[b]))...)
+		p.err = errors.Append(p.err, err)
+	})
 
 	return p.err
 }
 
+// packageScope sets the names declared at the top level of the files of the
+// package, to which the replacements of rewrite rules may refer.
+func packageScope(names map[string]bool) Option {
+	return func(o *options) { o.scope = names }
+}
+
+// topLevelNames returns the names declared at the top level of files.
+func topLevelNames(files []*ast.File) map[string]bool {
+	names := map[string]bool{}
+	for _, f := range files {
+		for _, d := range f.Decls {
+			x, ok := d.(*ast.Field)
+			if !ok {
+				continue
+			}
+			label := x.Label
+			if a, ok := label.(*ast.Alias); ok {
+				names[a.Ident.Name] = true
+				if label, ok = a.Expr.(ast.Label); !ok {
+					continue
+				}
+			}
+			if name, _, err := ast.LabelName(label); err == nil {
+				names[name] = true
+			}
+		}
+	}
+	return names
+}
+
 type processor struct {
 	instances []*build.Instance
 	cwd       string
@@ -46,7 +83,7 @@ type processor struct {
 	err errors.Error
 }
 
-func (p *processor) visitAll(fn func(f *ast.File)) {
+func (p *processor) visitAll(fn func(b *build.Instance, f *ast.File)) {
 	if p.err != nil {
 		return
 	}
@@ -59,7 +96,7 @@ func (p *processor) visitAll(fn func(f *ast.File)) {
 				continue
 			}
 			done[f] = true
-			fn(f)
+			fn(b, f)
 		}
 	}
 }
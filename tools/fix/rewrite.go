@@ -0,0 +1,587 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fix
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/ast/astutil"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/cue/scanner"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal/core/compile"
+)
+
+// A Rule rewrites expressions that match a pattern. Rules are written as
+//
+//	pattern -> replacement
+//
+// where pattern and replacement are CUE expressions. As with gofmt -r,
+// single-character lowercase identifiers serve as wildcards: in the pattern
+// they match any expression, and in the replacement they are substituted by
+// the matched expression. A wildcard that occurs multiple times in the
+// pattern must match the same expression each time.
+//
+// The package of a selector may be given as a quoted import path, as in
+//
+//	"example.com/schema".#Foo
+//
+// In the pattern, this matches references to #Foo of that package
+// regardless of the name under which it is imported. In the replacement,
+// the package is added to the imports of the file if needed. Imports that
+// are no longer used after a rewrite are removed.
+//
+// Other identifiers in the replacement must refer to a declaration that is
+// visible where the match is replaced, such as a field, a let binding or an
+// imported package, or to a predeclared identifier. Matches for which this
+// is not the case are left unchanged and reported as errors.
+type Rule struct {
+	src         string
+	pattern     ast.Expr
+	replacement ast.Expr
+}
+
+// ParseRule parses a rule of the form pattern -> replacement.
+func ParseRule(s string) (*Rule, error) {
+	arrows := ruleArrows(s)
+	if len(arrows) != 1 {
+		return nil, errors.Newf(token.NoPos,
+			"rewrite rule %q must be of the form 'pattern -> replacement'", s)
+	}
+	lhs, rhs := s[:arrows[0]], s[arrows[0]+len("->"):]
+	pattern, err := parser.ParseExpr("pattern", lhs)
+	if err != nil {
+		return nil, err
+	}
+	replacement, err := parser.ParseExpr("replacement", rhs)
+	if err != nil {
+		return nil, err
+	}
+	return &Rule{
+		src:         strings.TrimSpace(lhs) + " -> " + strings.TrimSpace(rhs),
+		pattern:     pattern,
+		replacement: replacement,
+	}, nil
+}
+
+// ruleArrows returns the offsets of the -> separators in s. An arrow is a
+// '-' token immediately followed by a '>' token, so that arrows in string
+// literals and comments are not separators.
+func ruleArrows(s string) []int {
+	var sc scanner.Scanner
+	src := []byte(s)
+	sc.Init(token.NewFile("rule", -1, len(src)), src, nil, 0)
+	var arrows []int
+	prev, prevTok := -1, token.ILLEGAL
+	for {
+		pos, tok, _ := sc.Scan()
+		if tok == token.EOF {
+			return arrows
+		}
+		if tok == token.GTR && prevTok == token.SUB && pos.Offset() == prev+1 {
+			arrows = append(arrows, prev)
+		}
+		prev, prevTok = pos.Offset(), tok
+	}
+}
+
+func (r *Rule) String() string { return r.src }
+
+// Rewrite applies the given rules, in order, after the other fixes.
+func Rewrite(rules ...*Rule) Option {
+	return func(o *options) { o.rules = append(o.rules, rules...) }
+}
+
+// rewrite replaces all expressions in f that match the pattern of r.
+// Expressions are rewritten bottom-up, so the subexpressions of a match
+// have already been rewritten. Names in scope are declared in other files
+// of the package. Matches whose replacement would refer to undefined
+// identifiers are not rewritten and reported as errors.
+func rewrite(f *ast.File, r *Rule, scope map[string]bool) (*ast.File, errors.Error) {
+	astutil.Resolve(f, func(token.Pos, string, ...interface{}) {})
+
+	used := map[*ast.ImportSpec]bool{}
+	ast.Walk(f, func(n ast.Node) bool {
+		if x, ok := n.(*ast.Ident); ok {
+			if spec, ok := x.Node.(*ast.ImportSpec); ok {
+				used[spec] = true
+			}
+		}
+		return true
+	}, nil)
+
+	s := &substituter{
+		imports: map[*ast.Ident]string{},
+		created: map[*ast.Ident]bool{},
+	}
+	changed := false
+	var errs errors.Error
+	f = astutil.Apply(f, nil, func(c astutil.Cursor) bool {
+		x, ok := c.Node().(ast.Expr)
+		if !ok || isDecl(c) {
+			return true
+		}
+		s.m = map[string]reflect.Value{}
+		s.failed = false
+		if !match(s.m, reflect.ValueOf(r.pattern), reflect.ValueOf(x)) {
+			return true
+		}
+		repl := s.subst(reflect.ValueOf(r.replacement)).Interface().(ast.Expr)
+		if s.failed {
+			return true
+		}
+		if name := s.undefined(c, repl, scope); name != "" {
+			errs = errors.Append(errs, errors.Newf(x.Pos(),
+				"cannot apply rewrite rule %q: reference %q not found", r, name))
+			return true
+		}
+		astutil.CopyMeta(repl, x)
+		c.Replace(repl)
+		changed = true
+		return true
+	}).(*ast.File)
+
+	if !changed {
+		return f, errs
+	}
+	for id, path := range s.imports {
+		spec := addImport(f, path)
+		info, _ := astutil.ParseImportSpec(spec)
+		id.Name = info.Ident
+		id.Node = spec
+	}
+	removeImports(f, used)
+	return f, errs
+}
+
+// undefined returns the name of an identifier of repl, the replacement for
+// the node at c, that does not refer to a declaration, or "" if there is
+// none. Only the identifiers of the replacement of the rule, and not those
+// matched by wildcards, are checked.
+func (s *substituter) undefined(c astutil.Cursor, repl ast.Expr, scope map[string]bool) string {
+	// Resolve the references to declarations within the replacement.
+	astutil.ResolveExpr(repl, func(token.Pos, string, ...interface{}) {})
+
+	name := ""
+	astutil.Apply(repl, func(rc astutil.Cursor) bool {
+		x, ok := rc.Node().(*ast.Ident)
+		switch {
+		case name != "":
+			return false
+		case !ok || !s.created[x] || x.Node != nil || isDecl(rc):
+		case x.Name == "_" || compile.IsPredeclared(x.Name) || scope[x.Name]:
+		case !visible(c, x.Name):
+			name = x.Name
+		}
+		return true
+	}, nil)
+	return name
+}
+
+// visible reports whether name is declared in a scope enclosing the node at
+// c.
+func visible(c astutil.Cursor, name string) bool {
+	for p := c.Parent(); p != nil; p = p.Parent() {
+		var decls []ast.Decl
+		switch x := p.Node().(type) {
+		case *ast.File:
+			for _, spec := range x.Imports {
+				if info, _ := astutil.ParseImportSpec(spec); info.Ident == name {
+					return true
+				}
+			}
+			decls = x.Decls
+		case *ast.StructLit:
+			decls = x.Elts
+		case *ast.Comprehension:
+			for _, cl := range x.Clauses {
+				switch cl := cl.(type) {
+				case *ast.ForClause:
+					if cl.Key != nil && cl.Key.Name == name || cl.Value.Name == name {
+						return true
+					}
+				case *ast.LetClause:
+					if cl.Ident.Name == name {
+						return true
+					}
+				}
+			}
+		}
+		for _, d := range decls {
+			if declares(d, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// declares reports whether d declares name.
+func declares(d ast.Decl, name string) bool {
+	switch x := d.(type) {
+	case *ast.Field:
+		label := x.Label
+		if a, ok := label.(*ast.Alias); ok {
+			if a.Ident.Name == name {
+				return true
+			}
+			if label, ok = a.Expr.(ast.Label); !ok {
+				return false
+			}
+		}
+		s, _, err := ast.LabelName(label)
+		return err == nil && s == name
+	case *ast.LetClause:
+		return x.Ident.Name == name
+	case *ast.Alias:
+		return x.Ident.Name == name
+	}
+	return false
+}
+
+// isDecl reports whether the node at c declares a name rather than being
+// an expression that may be rewritten.
+func isDecl(c astutil.Cursor) bool {
+	if c.Parent() == nil {
+		return false
+	}
+	n := c.Node()
+	switch p := c.Parent().Node().(type) {
+	case *ast.Field:
+		return n == p.Label
+	case *ast.SelectorExpr:
+		return n == p.Sel
+	case *ast.LetClause:
+		return n == p.Ident
+	case *ast.ForClause:
+		return n == p.Key || n == p.Value
+	case *ast.Alias, *ast.ImportSpec, *ast.Package:
+		return true
+	}
+	return false
+}
+
+var (
+	identType    = reflect.TypeOf((*ast.Ident)(nil))
+	selectorType = reflect.TypeOf((*ast.SelectorExpr)(nil))
+	posType      = reflect.TypeOf(token.NoPos)
+)
+
+func isWildcard(s string) bool {
+	return len(s) == 1 && unicode.IsLower(rune(s[0]))
+}
+
+// qualifier reports the import path of a selector of the form
+// "path".Sel.
+func qualifier(x *ast.SelectorExpr) (string, bool) {
+	lit, ok := x.X.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	path, err := strconv.Unquote(lit.Value)
+	return path, err == nil
+}
+
+// match reports whether val matches pattern, recording the expressions
+// matched by wildcards in m. Positions, comments and resolved references
+// are ignored. If m is nil, wildcards are compared as regular identifiers.
+func match(m map[string]reflect.Value, pattern, val reflect.Value) bool {
+	if !pattern.IsValid() || !val.IsValid() {
+		return !pattern.IsValid() && !val.IsValid()
+	}
+
+	switch pattern.Type() {
+	case identType:
+		p := pattern.Interface().(*ast.Ident)
+		if m != nil && p != nil && isWildcard(p.Name) {
+			if _, ok := val.Interface().(ast.Expr); ok && !val.IsNil() {
+				if old, ok := m[p.Name]; ok {
+					return match(nil, old, val)
+				}
+				m[p.Name] = val
+				return true
+			}
+		}
+		if val.Type() != identType {
+			return false
+		}
+		v := val.Interface().(*ast.Ident)
+		if p == nil || v == nil {
+			return p == nil && v == nil
+		}
+		return p.Name == v.Name
+
+	case selectorType:
+		p := pattern.Interface().(*ast.SelectorExpr)
+		path, ok := qualifier(p)
+		if !ok {
+			break
+		}
+		if val.Type() != selectorType {
+			return false
+		}
+		id, ok := val.Interface().(*ast.SelectorExpr).X.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		spec, ok := id.Node.(*ast.ImportSpec)
+		if !ok {
+			return false
+		}
+		if info, _ := astutil.ParseImportSpec(spec); info.ID != path {
+			return false
+		}
+		return match(m, reflect.ValueOf(p.Sel), reflect.ValueOf(val.Interface().(*ast.SelectorExpr).Sel))
+	}
+
+	if pattern.Type() != val.Type() {
+		return false
+	}
+
+	switch pattern.Kind() {
+	case reflect.Slice:
+		if pattern.Len() != val.Len() {
+			return false
+		}
+		for i := 0; i < pattern.Len(); i++ {
+			if !match(m, pattern.Index(i), val.Index(i)) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Struct:
+		if pattern.Type() == posType {
+			return true
+		}
+		for i := 0; i < pattern.NumField(); i++ {
+			f := pattern.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if f.Name == "Optional" && f.Type == posType {
+				p, v := pattern.Field(i).Interface().(token.Pos), val.Field(i).Interface().(token.Pos)
+				if p.IsValid() != v.IsValid() {
+					return false
+				}
+				continue
+			}
+			if !match(m, pattern.Field(i), val.Field(i)) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Ptr, reflect.Interface:
+		if pattern.IsNil() || val.IsNil() {
+			return pattern.IsNil() && val.IsNil()
+		}
+		return match(m, pattern.Elem(), val.Elem())
+	}
+
+	return pattern.Interface() == val.Interface()
+}
+
+// A substituter creates replacements from the replacement of a rule.
+type substituter struct {
+	// m holds the expressions matched by wildcards.
+	m map[string]reflect.Value
+
+	// imports maps the identifiers referring to a package given by its import
+	// path to that path. They are named once the imports of the file are
+	// updated.
+	imports map[*ast.Ident]string
+
+	// created holds the identifiers of the replacement of the rule, as
+	// opposed to the expressions matched by wildcards.
+	created map[*ast.Ident]bool
+
+	// failed is set if the replacement could not be created.
+	failed bool
+}
+
+// subst returns a copy of the replacement pattern v with the wildcards
+// substituted and positions cleared.
+func (s *substituter) subst(v reflect.Value) reflect.Value {
+	if !v.IsValid() {
+		return v
+	}
+
+	switch v.Type() {
+	case identType:
+		if x := v.Interface().(*ast.Ident); x != nil {
+			if old, ok := s.m[x.Name]; ok && isWildcard(x.Name) {
+				ast.SetRelPos(old.Interface().(ast.Node), token.NoRelPos)
+				return old
+			}
+			id := &ast.Ident{Name: x.Name}
+			s.created[id] = true
+			return reflect.ValueOf(id)
+		}
+
+	case selectorType:
+		x := v.Interface().(*ast.SelectorExpr)
+		if x == nil {
+			break
+		}
+		if path, ok := qualifier(x); ok {
+			id := &ast.Ident{}
+			s.imports[id] = path
+			return reflect.ValueOf(&ast.SelectorExpr{
+				X:   id,
+				Sel: s.subst(reflect.ValueOf(x.Sel)).Interface().(ast.Label),
+			})
+		}
+
+	case posType:
+		return reflect.ValueOf(token.NoPos)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		for i := 0; i < v.Elem().NumField(); i++ {
+			f := c.Elem().Field(i)
+			if !v.Elem().Type().Field(i).IsExported() {
+				continue
+			}
+			x := s.subst(v.Elem().Field(i))
+			if !x.Type().AssignableTo(f.Type()) {
+				// A wildcard matched an expression that is not allowed
+				// here, such as a non-identifier used as a label.
+				s.failed = true
+				continue
+			}
+			f.Set(x)
+		}
+		return c
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(s.subst(v.Index(i)))
+		}
+		return c
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		return s.subst(v.Elem())
+	}
+
+	return v
+}
+
+// addImport returns the import of path in f, adding it if it does not exist.
+// An added import is named if its default name is already in use.
+func addImport(f *ast.File, path string) *ast.ImportSpec {
+	names := map[string]bool{}
+	for _, spec := range f.Imports {
+		info, _ := astutil.ParseImportSpec(spec)
+		if info.ID == path {
+			return spec
+		}
+		names[info.Ident] = true
+	}
+
+	spec := &ast.ImportSpec{Path: ast.NewString(path)}
+	if name := astutil.ImportPathName(path); names[name] {
+		alias := name
+		for i := 2; names[alias]; i++ {
+			alias = fmt.Sprintf("%s%d", name, i)
+		}
+		spec.Name = ast.NewIdent(alias)
+	}
+	f.Imports = append(f.Imports, spec)
+
+	p := 0
+	for i, d := range f.Decls {
+		switch x := d.(type) {
+		case *ast.Package, *ast.CommentGroup, *ast.Attribute:
+			p = i + 1
+			continue
+		case *ast.ImportDecl:
+			x.Specs = append(x.Specs, spec)
+			ast.SetRelPos(x.Specs[0], token.NoRelPos)
+			return spec
+		}
+		break
+	}
+	decls := append(f.Decls[:p:p], &ast.ImportDecl{Specs: []*ast.ImportSpec{spec}})
+	f.Decls = append(decls, f.Decls[p:]...)
+	return spec
+}
+
+// removeImports removes the imports in specs that are no longer referred to
+// by name in f.
+func removeImports(f *ast.File, specs map[*ast.ImportSpec]bool) {
+	names := map[string]bool{}
+	ast.Walk(f, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.ImportDecl:
+			return false
+		case *ast.Ident:
+			names[x.Name] = true
+		}
+		return true
+	}, nil)
+
+	unused := func(spec *ast.ImportSpec) bool {
+		info, _ := astutil.ParseImportSpec(spec)
+		return specs[spec] && !names[info.Ident]
+	}
+
+	k := 0
+	for _, d := range f.Decls {
+		if x, ok := d.(*ast.ImportDecl); ok {
+			j := 0
+			for _, spec := range x.Specs {
+				if !unused(spec) {
+					x.Specs[j] = spec
+					j++
+				}
+			}
+			if j == 0 && len(x.Specs) > 0 {
+				continue
+			}
+			x.Specs = x.Specs[:j]
+		}
+		f.Decls[k] = d
+		k++
+	}
+	f.Decls = f.Decls[:k]
+
+	j := 0
+	for _, spec := range f.Imports {
+		if !unused(spec) {
+			f.Imports[j] = spec
+			j++
+		}
+	}
+	f.Imports = f.Imports[:j]
+}
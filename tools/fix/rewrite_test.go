@@ -0,0 +1,267 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fix
+
+import (
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/parser"
+)
+
+func TestRewrite(t *testing.T) {
+	testCases := []struct {
+		name  string
+		rules []string
+		in    string
+		out   string
+		err   string
+	}{{
+		name:  "wildcards",
+		rules: []string{`x & x -> x`},
+		in: `a: int & int
+b: int & string
+c: {x: 1} & {x: 1} // keep comment
+`,
+		out: `a: int
+b: int & string
+c: {x: 1} // keep comment
+`,
+	}, {
+		name:  "nested",
+		rules: []string{`len(x) > 0 -> x != _|_`},
+		in: `a: len(b) > 0
+b: [len(a) > 0]
+`,
+		out: `a: b != _|_
+b: [a != _|_]
+`,
+	}, {
+		name:  "import paths",
+		rules: []string{`"example.com/old".#Foo & x -> "example.com/new".#Bar & x`},
+		in: `package foo
+
+import (
+	"strings"
+	o "example.com/old"
+)
+
+a: o.#Foo & {name: strings.ToUpper("a")}
+b: old.#Foo & {}
+`,
+		out: `package foo
+
+import (
+	"strings"
+	"example.com/new"
+)
+
+a: new.#Bar & {name: strings.ToUpper("a")}
+b: old.#Foo & {}
+`,
+	}, {
+		name:  "keep used imports",
+		rules: []string{`"example.com/old".#Foo -> "example.com/new:other".#Foo`},
+		in: `import "example.com/old"
+
+a: old.#Foo
+b: old.#Baz
+`,
+		out: `import (
+	"example.com/old"
+	"example.com/new:other"
+)
+
+a: other.#Foo
+b: old.#Baz
+`,
+	}, {
+		name:  "name conflict",
+		rules: []string{`foo -> "example.com/new".#A`},
+		in: `import new "example.com/other"
+
+x: foo
+y: new.b
+`,
+		out: `import (
+	new "example.com/other"
+	new2 "example.com/new"
+)
+
+x: new2.#A
+y: new.b
+`,
+	}, {
+		name: "rules in order",
+		rules: []string{
+			`foo(x, y) -> bar(y, x)`,
+			`bar(x, 1) -> baz(x)`,
+		},
+		in: `a: foo(1, 2)
+b: foo(3, c)
+
+foo: _
+bar: _
+baz: _
+`,
+		out: `a: baz(2)
+b: bar(c, 3)
+
+foo: _
+bar: _
+baz: _
+`,
+	}, {
+		name:  "undefined package name",
+		rules: []string{`old.#Foo & x -> new.#Bar & x`},
+		in: `import "example.com/old"
+
+a: old.#Foo & {}
+`,
+		out: `import "example.com/old"
+
+a: old.#Foo & {}
+`,
+		err: `cannot apply rewrite rule "old.#Foo & x -> new.#Bar & x": reference "new" not found`,
+	}, {
+		name:  "defined package name",
+		rules: []string{`old.#Foo & x -> new.#Bar & x`},
+		in: `import (
+	"example.com/old"
+	"example.com/new"
+)
+
+a: old.#Foo & {}
+b: new.#Baz
+`,
+		out: `import (
+	"example.com/new"
+)
+
+a: new.#Bar & {}
+b: new.#Baz
+`,
+	}, {
+		name:  "scopes",
+		rules: []string{`f(x) -> g(x) + len(h)`},
+		in: `a: {
+	g: _
+	b: f(1)
+	let h = [1]
+}
+c: [for h in [] {f(2)}]
+d: {
+	g: _
+	e: f(3)
+}
+`,
+		out: `a: {
+	g: _
+	b: g(1) + len(h)
+	let h = [1]
+}
+c: [ for h in [] {f(2)}]
+d: {
+	g: _
+	e: f(3)
+}
+`,
+		err: `cannot apply rewrite rule "f(x) -> g(x) + len(h)": reference "g" not found`,
+	}, {
+		name:  "declarations in replacement",
+		rules: []string{`f(x) -> {let y = x, z: y}`},
+		in: `a: f(1)
+`,
+		out: `a: {
+	let y = 1
+	z: y
+}
+`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := parser.ParseFile("", tc.in, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var rules []*Rule
+			for _, s := range tc.rules {
+				r, err := ParseRule(s)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rules = append(rules, r)
+			}
+			n, errs := file(f, Rewrite(rules...))
+			if got := errors.Details(errs, nil); !strings.Contains(got, tc.err) || (got == "") != (tc.err == "") {
+				t.Errorf("error: got %q; want %q", got, tc.err)
+			}
+
+			b, err := format.Node(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tc.out {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.out)
+			}
+		})
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	testCases := []struct {
+		in  string
+		out string
+		err string
+	}{{
+		in:  `a&b->b &  a`,
+		out: `a&b -> b &  a`,
+	}, {
+		in:  `a & b`,
+		err: `rewrite rule "a & b" must be of the form 'pattern -> replacement'`,
+	}, {
+		in:  `a -> b -> c`,
+		err: `rewrite rule "a -> b -> c" must be of the form 'pattern -> replacement'`,
+	}, {
+		in:  `x + "->" -> "a->b" + x // -> is not a separator here`,
+		out: `x + "->" -> "a->b" + x // -> is not a separator here`,
+	}, {
+		in:  `a - >b -> b`,
+		out: `a - >b -> b`,
+	}, {
+		in:  `a & -> b`,
+		err: `expected operand, found 'EOF'`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			r, err := ParseRule(tc.in)
+			if err != nil {
+				if got := err.Error(); got != tc.err {
+					t.Errorf("error: got %q; want %q", got, tc.err)
+				}
+				return
+			}
+			if tc.err != "" {
+				t.Fatalf("expected error %q", tc.err)
+			}
+			if got := r.String(); got != tc.out {
+				t.Errorf("got %q; want %q", got, tc.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,381 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/ast/astutil"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/token"
+)
+
+// Unused reports imports, let bindings and hidden definitions that are not
+// used. Other definitions are only reported if the package is not imported
+// by other packages, as they may otherwise be used by those.
+var Unused = &Analyzer{
+	Name: "unused",
+	Doc:  "report unused imports, let bindings and definitions",
+	Run:  runUnused,
+}
+
+func runUnused(pass *Pass) {
+	// Hidden definitions may be referenced from other files of the package,
+	// which astutil.Resolve does not resolve. A name that is referenced
+	// anywhere without being resolved is therefore considered used.
+	used := map[ast.Node]bool{}
+	names := map[string]bool{}
+	for _, f := range pass.Files {
+		decls := declIdents(f)
+		ast.Walk(f, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.Ident:
+				if x.Node != nil {
+					used[x.Node] = true
+				} else if !decls[x] {
+					names[x.Name] = true
+				}
+			case *ast.SelectorExpr:
+				if name, _, err := ast.LabelName(x.Sel); err == nil {
+					names[name] = true
+				}
+			}
+			return true
+		}, nil)
+	}
+
+	for _, f := range pass.Files {
+		for _, spec := range f.Imports {
+			if !used[spec] {
+				pass.Reportf(spec.Pos(), "imported and not used: %s", spec.Path.Value)
+			}
+		}
+		ast.Walk(f, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.LetClause:
+				if !used[x] {
+					pass.Reportf(x.Ident.Pos(), "let %s declared and not used", x.Ident.Name)
+				}
+			case *ast.Field:
+				name, _, err := ast.LabelName(x.Label)
+				if err != nil || used[x.Value] || used[x] || names[name] {
+					break
+				}
+				switch {
+				case strings.HasPrefix(name, "_#"):
+					pass.Reportf(x.Label.Pos(), "hidden definition %s is not used", name)
+				case strings.HasPrefix(name, "#") && pass.NotImported:
+					pass.Reportf(x.Label.Pos(), "definition %s is not used", name)
+				}
+			}
+			return true
+		}, nil)
+	}
+}
+
+// declIdents returns the identifiers in f that declare a name rather than
+// refer to one.
+func declIdents(f *ast.File) map[*ast.Ident]bool {
+	decls := map[*ast.Ident]bool{}
+	add := func(x ast.Node) {
+		if id, ok := x.(*ast.Ident); ok && id != nil {
+			decls[id] = true
+		}
+	}
+	ast.Walk(f, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.Field:
+			add(x.Label)
+			if a, ok := x.Label.(*ast.Alias); ok {
+				add(a.Ident)
+				add(a.Expr)
+			}
+		case *ast.Alias:
+			add(x.Ident)
+		case *ast.LetClause:
+			add(x.Ident)
+		case *ast.ForClause:
+			if x.Key != nil {
+				add(x.Key)
+			}
+			add(x.Value)
+		case *ast.ImportSpec:
+			if x.Name != nil {
+				add(x.Name)
+			}
+		}
+		return true
+	}, nil)
+	return decls
+}
+
+// Shadow reports declarations that hide a declaration of the same name in
+// an enclosing scope, as references within the inner scope can no longer
+// refer to the outer one. Let bindings, aliases and comprehension variables
+// are reported if they shadow any declaration, fields only if they shadow
+// an import, let binding, alias or comprehension variable, as fields
+// shadowing fields are common.
+var Shadow = &Analyzer{
+	Name: "shadow",
+	Doc:  "report declarations that shadow declarations in enclosing scopes",
+	Run:  runShadow,
+}
+
+type shadowScope struct {
+	outer *shadowScope
+	decls map[string]shadowDecl
+}
+
+type shadowDecl struct {
+	kind string
+	pos  token.Pos
+}
+
+func (s *shadowScope) lookup(name string) (shadowDecl, bool) {
+	for ; s != nil; s = s.outer {
+		if d, ok := s.decls[name]; ok {
+			return d, true
+		}
+	}
+	return shadowDecl{}, false
+}
+
+type shadowChecker struct {
+	pass *Pass
+}
+
+func runShadow(pass *Pass) {
+	c := &shadowChecker{pass: pass}
+	for _, f := range pass.Files {
+		s := &shadowScope{decls: map[string]shadowDecl{}}
+		for _, spec := range f.Imports {
+			name := astutil.ImportPathName(unquote(spec.Path.Value))
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			s.decls[name] = shadowDecl{"import", spec.Pos()}
+		}
+		c.decls(f.Decls, s)
+	}
+}
+
+// declare adds a declaration to s, reporting whether it shadows one in an
+// enclosing scope.
+func (c *shadowChecker) declare(s *shadowScope, id *ast.Ident, kind string) {
+	if id == nil || id.Name == "_" {
+		return
+	}
+	if outer, ok := s.outer.lookup(id.Name); ok && (kind != "field" || outer.kind != "field") {
+		c.pass.Reportf(id.Pos(), "%s %s shadows %s on line %d",
+			kind, id.Name, outer.kind, outer.pos.Line())
+	}
+	if _, ok := s.decls[id.Name]; !ok {
+		s.decls[id.Name] = shadowDecl{kind, id.Pos()}
+	}
+}
+
+// decls checks the declarations of a struct or file in scope s.
+func (c *shadowChecker) decls(decls []ast.Decl, s *shadowScope) {
+	for _, d := range decls {
+		switch x := d.(type) {
+		case *ast.Field:
+			switch l := x.Label.(type) {
+			case *ast.Ident:
+				c.declare(s, l, "field")
+			case *ast.Alias:
+				c.declare(s, l.Ident, "alias")
+				if id, ok := l.Expr.(*ast.Ident); ok {
+					c.declare(s, id, "field")
+				}
+			}
+		case *ast.LetClause:
+			c.declare(s, x.Ident, "let")
+		}
+	}
+	for _, d := range decls {
+		switch x := d.(type) {
+		case *ast.Field:
+			c.walk(x.Value, s)
+		case *ast.LetClause:
+			c.walk(x.Expr, s)
+		case *ast.Comprehension:
+			c.comprehension(x, s)
+		case *ast.EmbedDecl:
+			c.walk(x.Expr, s)
+		}
+	}
+}
+
+func (c *shadowChecker) comprehension(x *ast.Comprehension, s *shadowScope) {
+	for _, clause := range x.Clauses {
+		switch y := clause.(type) {
+		case *ast.ForClause:
+			c.walk(y.Source, s)
+			s = &shadowScope{outer: s, decls: map[string]shadowDecl{}}
+			c.declare(s, y.Key, "variable")
+			c.declare(s, y.Value, "variable")
+		case *ast.LetClause:
+			c.walk(y.Expr, s)
+			s = &shadowScope{outer: s, decls: map[string]shadowDecl{}}
+			c.declare(s, y.Ident, "let")
+		case *ast.IfClause:
+			c.walk(y.Condition, s)
+		}
+	}
+	c.walk(x.Value, s)
+}
+
+// walk checks the structs and comprehensions within n.
+func (c *shadowChecker) walk(n ast.Node, s *shadowScope) {
+	if n == nil {
+		return
+	}
+	ast.Walk(n, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.StructLit:
+			c.decls(x.Elts, &shadowScope{outer: s, decls: map[string]shadowDecl{}})
+			return false
+		case *ast.Comprehension:
+			c.comprehension(x, s)
+			return false
+		}
+		return true
+	}, nil)
+}
+
+// Redundant reports operands of a unification that are repeated, as in
+// int & >0 & >0.
+var Redundant = &Analyzer{
+	Name: "redundant",
+	Doc:  "report repeated operands of a unification",
+	Run:  runRedundant,
+}
+
+func runRedundant(pass *Pass) {
+	for _, f := range pass.Files {
+		inner := map[ast.Node]bool{}
+		ast.Walk(f, func(n ast.Node) bool {
+			x, ok := n.(*ast.BinaryExpr)
+			if !ok || x.Op != token.AND || inner[x] {
+				return true
+			}
+			var operands []ast.Expr
+			var flatten func(e ast.Expr)
+			flatten = func(e ast.Expr) {
+				switch b := e.(type) {
+				case *ast.BinaryExpr:
+					if b.Op == token.AND {
+						inner[b] = true
+						flatten(b.X)
+						flatten(b.Y)
+						return
+					}
+				case *ast.ParenExpr:
+					if x, ok := b.X.(*ast.BinaryExpr); ok && x.Op == token.AND {
+						flatten(x)
+						return
+					}
+				}
+				operands = append(operands, e)
+			}
+			flatten(x)
+
+			seen := map[string]bool{}
+			for _, e := range operands {
+				b, err := format.Node(e)
+				if err != nil {
+					continue
+				}
+				s := string(b)
+				if seen[s] {
+					pass.Reportf(e.Pos(), "redundant constraint %s", s)
+				}
+				seen[s] = true
+			}
+			return true
+		}, nil)
+	}
+}
+
+// Defaults reports default markers that are not an operand of a
+// disjunction, where they have no effect, as in [*1, 2].
+var Defaults = &Analyzer{
+	Name: "defaults",
+	Doc:  "report default markers outside of disjunctions",
+	Run:  runDefaults,
+}
+
+func runDefaults(pass *Pass) {
+	for _, f := range pass.Files {
+		var stack []ast.Node
+		ast.Walk(f, func(n ast.Node) bool {
+			if x, ok := n.(*ast.UnaryExpr); ok && x.Op == token.MUL && !inDisjunction(stack) {
+				pass.Reportf(x.Pos(), "default marker has no effect outside of a disjunction")
+			}
+			stack = append(stack, n)
+			return true
+		}, func(ast.Node) {
+			stack = stack[:len(stack)-1]
+		})
+	}
+}
+
+// inDisjunction reports whether the top of stack, ignoring parentheses, is a
+// disjunction.
+func inDisjunction(stack []ast.Node) bool {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch x := stack[i].(type) {
+		case *ast.ParenExpr:
+			continue
+		case *ast.BinaryExpr:
+			return x.Op == token.OR
+		}
+		return false
+	}
+	return false
+}
+
+// Deprecated reports syntax that cue fix rewrites. Block comments, which
+// cue fix also rewrites, are rejected by the parser and thus never reach
+// the analyzers.
+var Deprecated = &Analyzer{
+	Name: "deprecated",
+	Doc:  "report deprecated syntax that cue fix rewrites",
+	Run:  runDeprecated,
+}
+
+func runDeprecated(pass *Pass) {
+	for _, f := range pass.Files {
+		ast.Walk(f, func(n ast.Node) bool {
+			if x, ok := n.(*ast.BinaryExpr); ok {
+				switch x.Op {
+				case token.IDIV, token.IMOD, token.IQUO, token.IREM:
+					pass.Reportf(x.OpPos,
+						"operator %s is deprecated: use %s(x, y) or run cue fix", x.Op, x.Op)
+				}
+			}
+			return true
+		}, nil)
+	}
+}
+
+func unquote(s string) string {
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return s
+}
@@ -0,0 +1,212 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint reports likely mistakes in CUE files.
+//
+// The checks are implemented by analyzers, which inspect the syntax of the
+// files of a package and, if they need to, the evaluated package. Analyzers
+// lists the built-in ones.
+//
+// A diagnostic can be suppressed with a comment of the form
+//
+//	// lint:ignore name[,name] reason
+//
+// on the line of the diagnostic or the line above it, where name is the name
+// of the analyzer that reported it.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/ast/astutil"
+	"cuelang.org/go/cue/token"
+)
+
+// An Analyzer checks the files of a package for one kind of problem.
+type Analyzer struct {
+	// Name identifies the analyzer in diagnostics and suppression comments.
+	Name string
+
+	// Doc documents the analyzer.
+	Doc string
+
+	// Run reports the problems found in the files of pass.
+	Run func(pass *Pass)
+}
+
+// A Pass provides an analyzer with a package to check.
+type Pass struct {
+	Analyzer *Analyzer
+
+	// Files holds the files of the package. Identifiers are resolved.
+	Files []*ast.File
+
+	// Load evaluates the package with the given tags, as set with -t on the
+	// command line. It is nil if the package cannot be evaluated, in which
+	// case analyzers needing evaluation report nothing.
+	Load func(tags []string) (cue.Value, error)
+
+	// NotImported reports that the package is not imported by other
+	// packages. See Config.NotImported.
+	NotImported bool
+
+	diags *[]Diagnostic
+}
+
+// Reportf reports a problem at pos.
+func (p *Pass) Reportf(pos token.Pos, format string, args ...interface{}) {
+	*p.diags = append(*p.diags, Diagnostic{
+		Pos:      pos,
+		Analyzer: p.Analyzer.Name,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// A Diagnostic is a problem reported by an analyzer.
+type Diagnostic struct {
+	Pos      token.Pos
+	Analyzer string
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	if !d.Pos.IsValid() {
+		return fmt.Sprintf("%s (%s)", d.Message, d.Analyzer)
+	}
+	return fmt.Sprintf("%s: %s (%s)", d.Pos, d.Message, d.Analyzer)
+}
+
+// Analyzers lists the built-in analyzers.
+var Analyzers = []*Analyzer{
+	Unused,
+	Shadow,
+	TagBottom,
+	Redundant,
+	Defaults,
+	Deprecated,
+}
+
+// Lookup returns the built-in analyzer with the given name or nil if there
+// is none.
+func Lookup(name string) *Analyzer {
+	for _, a := range Analyzers {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// A Config configures a lint run.
+type Config struct {
+	// Analyzers lists the analyzers to run. If it is nil, all of Analyzers
+	// are run.
+	Analyzers []*Analyzer
+
+	// Load is passed to the analyzers. See Pass.Load.
+	Load func(tags []string) (cue.Value, error)
+
+	// NotImported reports that the package is not imported by other
+	// packages, as when no other package of its module imports it, so that
+	// its definitions are only used within the package.
+	NotImported bool
+}
+
+// Files runs the analyzers on the files of a single package. It returns the
+// diagnostics that are not suppressed, sorted by position.
+func Files(files []*ast.File, cfg *Config) []Diagnostic {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	analyzers := cfg.Analyzers
+	if analyzers == nil {
+		analyzers = Analyzers
+	}
+
+	for _, f := range files {
+		astutil.Resolve(f, func(token.Pos, string, ...interface{}) {})
+	}
+
+	var diags []Diagnostic
+	for _, a := range analyzers {
+		a.Run(&Pass{
+			Analyzer:    a,
+			Files:       files,
+			Load:        cfg.Load,
+			NotImported: cfg.NotImported,
+			diags:       &diags,
+		})
+	}
+
+	diags = suppress(files, diags)
+	sort.SliceStable(diags, func(i, j int) bool {
+		pi, pj := diags[i].Pos.Position(), diags[j].Pos.Position()
+		switch {
+		case pi.Filename != pj.Filename:
+			return pi.Filename < pj.Filename
+		case pi.Line != pj.Line:
+			return pi.Line < pj.Line
+		}
+		return pi.Column < pj.Column
+	})
+	return diags
+}
+
+const ignoreDirective = "lint:ignore"
+
+// suppress removes the diagnostics suppressed by a lint:ignore comment.
+func suppress(files []*ast.File, diags []Diagnostic) []Diagnostic {
+	type line struct {
+		file string
+		line int
+	}
+	ignored := map[line][]string{}
+	for _, f := range files {
+		ast.Walk(f, func(n ast.Node) bool {
+			c, ok := n.(*ast.Comment)
+			if !ok {
+				return true
+			}
+			text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+			if !strings.HasPrefix(text, ignoreDirective+" ") {
+				return true
+			}
+			names := strings.Fields(text[len(ignoreDirective):])[0]
+			pos := c.Pos().Position()
+			for _, l := range []int{pos.Line, pos.Line + 1} {
+				key := line{pos.Filename, l}
+				ignored[key] = append(ignored[key], strings.Split(names, ",")...)
+			}
+			return true
+		}, nil)
+	}
+
+	k := 0
+outer:
+	for _, d := range diags {
+		pos := d.Pos.Position()
+		for _, name := range ignored[line{pos.Filename, pos.Line}] {
+			if name == d.Analyzer {
+				continue outer
+			}
+		}
+		diags[k] = d
+		k++
+	}
+	return diags[:k]
+}
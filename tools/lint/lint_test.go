@@ -0,0 +1,256 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/load"
+	"cuelang.org/go/cue/parser"
+)
+
+func TestFiles(t *testing.T) {
+	testCases := []struct {
+		name     string
+		analyzer *Analyzer
+		in       string
+		other    string // another file of the package
+		notImp   bool   // the package is not imported
+		out      string
+	}{{
+		name:     "unused",
+		analyzer: Unused,
+		in: `package foo
+
+import (
+	"strings"
+	"list"
+)
+
+let L = 1
+let M = 2
+_#A: 1
+_#B: 2
+_#C: 3
+#D: 4
+a: M + _#A + strings.MinRunes
+b: {
+	for x in [] let y = x {}
+}
+`,
+		other: `package foo
+
+c: _#C
+`,
+		out: `in.cue:5:2: imported and not used: "list" (unused)
+in.cue:8:5: let L declared and not used (unused)
+in.cue:11:1: hidden definition _#B is not used (unused)
+in.cue:16:18: let y declared and not used (unused)
+`,
+	}, {
+		name:     "unused definitions",
+		analyzer: Unused,
+		notImp:   true,
+		in: `package foo
+
+#A: 1
+#B: {
+	#C: 2
+	#D: 3
+}
+_#E: 4
+#F: 5
+a: #B.#C
+`,
+		other: `package foo
+
+b: #F
+`,
+		out: `in.cue:3:1: definition #A is not used (unused)
+in.cue:6:2: definition #D is not used (unused)
+in.cue:8:1: hidden definition _#E is not used (unused)
+`,
+	}, {
+		name:     "shadow",
+		analyzer: Shadow,
+		in: `import "strings"
+
+let x = 1
+a: {
+	let x = 2
+	strings: "a"
+	b: x
+}
+c: {
+	a: 1
+	for k, v in {} {
+		k: v
+	}
+}
+`,
+		out: `in.cue:5:6: let x shadows let on line 3 (shadow)
+in.cue:6:2: field strings shadows import on line 1 (shadow)
+in.cue:12:3: field k shadows variable on line 11 (shadow)
+`,
+	}, {
+		name:     "redundant",
+		analyzer: Redundant,
+		in: `a: int & >0 & >0
+b: (int & string) & int
+c: >0 & <10
+d: {x: 1} & {x: 1}
+`,
+		out: `in.cue:1:15: redundant constraint >0 (redundant)
+in.cue:2:21: redundant constraint int (redundant)
+in.cue:4:13: redundant constraint {x: 1} (redundant)
+`,
+	}, {
+		name:     "defaults",
+		analyzer: Defaults,
+		in: `a: *1 | 2
+b: (*1 | 2) + 1
+c: [*1, 2]
+d: *"x"
+e: int | (*1)
+`,
+		out: `in.cue:3:5: default marker has no effect outside of a disjunction (defaults)
+in.cue:4:4: default marker has no effect outside of a disjunction (defaults)
+`,
+	}, {
+		name:     "deprecated",
+		analyzer: Deprecated,
+		in: `a: 7 div 2
+b: 7 mod 2
+c: div(7, 2)
+`,
+		out: `in.cue:1:6: operator div is deprecated: use div(x, y) or run cue fix (deprecated)
+in.cue:2:6: operator mod is deprecated: use mod(x, y) or run cue fix (deprecated)
+`,
+	}, {
+		name:     "suppress",
+		analyzer: Unused,
+		in: `let a = 1 // lint:ignore unused kept for documentation
+// lint:ignore shadow,unused
+let b = 2
+// lint:ignore shadow
+let c = 3
+let d = 4
+
+// lint:ignore unused
+x: 1
+let e = 5
+`,
+		out: `in.cue:5:5: let c declared and not used (unused)
+in.cue:6:5: let d declared and not used (unused)
+in.cue:10:5: let e declared and not used (unused)
+`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			files := []*ast.File{parse(t, "in.cue", tc.in)}
+			if tc.other != "" {
+				files = append(files, parse(t, "other.cue", tc.other))
+			}
+			diags := Files(files, &Config{
+				Analyzers:   []*Analyzer{tc.analyzer},
+				NotImported: tc.notImp,
+			})
+			b := &strings.Builder{}
+			for _, d := range diags {
+				b.WriteString(d.String())
+				b.WriteByte('\n')
+			}
+			if got := b.String(); got != tc.out {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.out)
+			}
+		})
+	}
+}
+
+func TestTagBottom(t *testing.T) {
+	const src = `package foo
+
+env:      *"dev" | string @tag(env,short=dev|prod)
+debug:    *false | bool   @tag(debug,type=bool)
+logLevel: string
+
+if env == "prod" {
+	logLevel: "warn"
+}
+if debug {
+	logLevel: "debug"
+}
+if !debug {
+	logLevel: "info"
+}
+
+level: "y" | *"z"
+
+// lint:ignore tags only set for debugging
+ignored: "y" | *"z"
+
+if debug {
+	level:   "x"
+	ignored: "x"
+}
+`
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "in.cue"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loadTags := func(tags []string) (cue.Value, error) {
+		inst := load.Instances([]string{"."}, &load.Config{Dir: dir, Tags: tags})[0]
+		if inst.Err != nil {
+			return cue.Value{}, inst.Err
+		}
+		return cuecontext.New().BuildInstance(inst), nil
+	}
+
+	inst := load.Instances([]string{"."}, &load.Config{Dir: dir})[0]
+	if inst.Err != nil {
+		t.Fatal(inst.Err)
+	}
+	diags := Files(inst.Files, &Config{
+		Analyzers: []*Analyzer{TagBottom},
+		Load:      loadTags,
+	})
+	var got []string
+	for _, d := range diags {
+		got = append(got, fmt.Sprintf("%d: %s", d.Pos.Line(), d.Message))
+	}
+	want := []string{
+		"7: logLevel is invalid with -t prod: conflicting values \"info\" and \"warn\"",
+		"17: level is invalid with -t debug=true: 2 errors in empty disjunction:",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func parse(t *testing.T, name, src string) *ast.File {
+	t.Helper()
+	f, err := parser.ParseFile(name, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
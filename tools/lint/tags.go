@@ -0,0 +1,138 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/internal"
+)
+
+// TagBottom reports fields that are valid without tags, but evaluate to an
+// error when one of the tags of the package is set. The package is
+// evaluated for each shorthand of a @tag attribute and for both values of
+// boolean tags. Each invalid field is reported once per tag. Tags used only
+// in @if attributes are not considered, and errors that prevent the package
+// from being evaluated, such as unused imports, may hide the ones that
+// depend on tags.
+var TagBottom = &Analyzer{
+	Name: "tags",
+	Doc:  "report fields that are only invalid when a tag is set",
+	Run:  runTagBottom,
+}
+
+func runTagBottom(pass *Pass) {
+	if pass.Load == nil {
+		return
+	}
+	variants := tagVariants(pass.Files)
+	if len(variants) == 0 {
+		return
+	}
+
+	v, err := pass.Load(nil)
+	if err != nil {
+		return
+	}
+	base := map[string]bool{}
+	for _, e := range errors.Errors(v.Validate()) {
+		base[strings.Join(e.Path(), ".")] = true
+	}
+
+	for _, tag := range variants {
+		w, err := pass.Load([]string{tag})
+		if err != nil {
+			pass.Reportf(errors.Errors(err)[0].Position(), "invalid with -t %s: %v", tag, err)
+			continue
+		}
+		// Report the first error of each path: disjunctions report the
+		// errors of their disjuncts as well.
+		reported := map[string]bool{}
+		for _, e := range errors.Errors(w.Validate()) {
+			path := strings.Join(e.Path(), ".")
+			if base[path] || reported[path] {
+				continue
+			}
+			reported[path] = true
+			format, args := e.Msg()
+			pass.Reportf(errorPos(v, path, e), "%s is invalid with -t %s: %s",
+				path, tag, fmt.Sprintf(format, args...))
+		}
+	}
+}
+
+// errorPos returns the position of e, an error for the given path, or the
+// position of the field at path in v if e has none.
+func errorPos(v cue.Value, path string, e errors.Error) token.Pos {
+	if pos := e.Position(); pos.IsValid() {
+		return pos
+	}
+	if ps := errors.Positions(e); len(ps) > 0 {
+		return ps[0]
+	}
+	p := cue.ParsePath(path)
+	if p.Err() != nil {
+		return token.NoPos
+	}
+	return v.LookupPath(p).Pos()
+}
+
+// tagVariants returns the -t values to evaluate a package with: the
+// shorthands of its tags and both values of its boolean tags.
+func tagVariants(files []*ast.File) []string {
+	var variants []string
+	seen := map[string]bool{}
+	add := func(s string) {
+		if !seen[s] {
+			seen[s] = true
+			variants = append(variants, s)
+		}
+	}
+	for _, f := range files {
+		ast.Walk(f, func(n ast.Node) bool {
+			field, ok := n.(*ast.Field)
+			if !ok {
+				return true
+			}
+			for _, a := range field.Attrs {
+				key, body := a.Split()
+				if key != "tag" {
+					continue
+				}
+				attr := internal.ParseAttrBody(a.Pos(), body)
+				name, err := attr.String(0)
+				if err != nil {
+					continue
+				}
+				if s, ok, _ := attr.Lookup(1, "short"); ok {
+					for _, s := range strings.Split(s, "|") {
+						add(s)
+					}
+				}
+				if s, ok, _ := attr.Lookup(1, "type"); ok && s == "bool" {
+					add(name + "=true")
+					add(name + "=false")
+				}
+			}
+			return true
+		}, nil)
+	}
+	return variants
+}
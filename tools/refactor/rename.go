@@ -0,0 +1,426 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package refactor implements refactorings that span the packages of a
+// module.
+//
+// Refactorings do not modify files. Instead, they return the edits to apply
+// to the sources of the files, so that they can be used both by tools that
+// rewrite files and by editors.
+package refactor
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/ast/astutil"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+)
+
+// An Edit replaces the text between the byte offsets Start and End of the
+// file Filename with New.
+type Edit struct {
+	Filename string
+	Start    int
+	End      int
+	New      string
+}
+
+// Apply applies edits, which must be sorted by offset and must not overlap,
+// to src, the source of a single file.
+func Apply(src []byte, edits []Edit) ([]byte, error) {
+	var b []byte
+	last := 0
+	for _, e := range edits {
+		if e.Start < last || e.End < e.Start || e.End > len(src) {
+			return nil, errors.Newf(token.NoPos, "%s: invalid edit at offset %d", e.Filename, e.Start)
+		}
+		b = append(b, src[last:e.Start]...)
+		b = append(b, e.New...)
+		last = e.End
+	}
+	return append(b, src[last:]...), nil
+}
+
+// Rename returns the edits that rename the top-level field name of the
+// package with the given import path to newName, along with all references
+// to it. References are updated in the files of all instances, which must
+// include the package itself.
+//
+// References are found by resolving identifiers within each file. Other
+// packages refer to the field with selectors on an import of the package,
+// either directly or through let bindings, aliases and fields that refer to
+// the import. Labels and selectors may be quoted if the field is a regular
+// field: a quoted "#Name" is a regular field distinct from the definition
+// #Name.
+//
+// Rename fails if newName is not a valid identifier, if it is already
+// declared at the top level of the package, or if a declaration of newName
+// in an enclosing scope would hide the renamed field from one of its
+// references, or if a regular field that is referred to by a quoted label
+// or selector is renamed to a hidden name.
+func Rename(instances []*build.Instance, path, name, newName string) ([]Edit, error) {
+	if !ast.IsValidIdent(newName) {
+		return nil, errors.Newf(token.NoPos, "%q is not a valid identifier", newName)
+	}
+	if strings.HasPrefix(name, "#") != strings.HasPrefix(newName, "#") {
+		return nil, errors.Newf(token.NoPos,
+			"cannot rename %s to %s: definitions can only be renamed to definitions", name, newName)
+	}
+
+	r := &renamer{
+		path:    path,
+		name:    name,
+		newName: newName,
+		edits:   map[Edit]bool{},
+	}
+	if i := strings.LastIndexByte(path, ':'); i >= 0 {
+		r.path = path[:i]
+	}
+
+	var pkgFiles, files []*ast.File
+	done := map[*ast.File]bool{}
+	for _, inst := range instances {
+		target := r.isTarget(inst.ImportPath, inst.PkgName)
+		if target {
+			r.pkgName = inst.PkgName
+		}
+		for _, f := range inst.Files {
+			if done[f] {
+				continue
+			}
+			done[f] = true
+			files = append(files, f)
+			if target {
+				pkgFiles = append(pkgFiles, f)
+			}
+		}
+	}
+	if len(pkgFiles) == 0 {
+		return nil, errors.Newf(token.NoPos, "package %q not found", path)
+	}
+
+	for _, f := range files {
+		astutil.Resolve(f, func(token.Pos, string, ...interface{}) {})
+	}
+
+	found := false
+	for _, f := range pkgFiles {
+		ok, err := r.declarations(f)
+		if err != nil {
+			return nil, err
+		}
+		found = found || ok
+	}
+	if !found {
+		return nil, errors.Newf(token.NoPos, "%s is not declared in package %q", name, path)
+	}
+
+	for _, f := range pkgFiles {
+		if err := r.references(f); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range files {
+		if err := r.selectors(f); err != nil {
+			return nil, err
+		}
+	}
+
+	edits := make([]Edit, 0, len(r.edits))
+	for e := range r.edits {
+		edits = append(edits, e)
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		if edits[i].Filename != edits[j].Filename {
+			return edits[i].Filename < edits[j].Filename
+		}
+		return edits[i].Start < edits[j].Start
+	})
+	return edits, nil
+}
+
+type renamer struct {
+	path    string // import path without package qualifier
+	pkgName string
+	name    string
+	newName string
+
+	edits map[Edit]bool
+}
+
+// isTarget reports whether the import path and package name refer to the
+// package in which the field is renamed.
+func (r *renamer) isTarget(path, pkgName string) bool {
+	if i := strings.LastIndexByte(path, ':'); i >= 0 {
+		path, pkgName = path[:i], path[i+1:]
+	}
+	return path == r.path && (r.pkgName == "" || pkgName == r.pkgName)
+}
+
+// edit records replacing the label or identifier n with the new name. It
+// fails if n is quoted and the new name is not that of a regular field, as
+// the quoted name would then refer to a different field.
+func (r *renamer) edit(n ast.Node) error {
+	pos := n.Pos().Position()
+	e := Edit{Filename: pos.Filename, Start: pos.Offset}
+	switch x := n.(type) {
+	case *ast.Ident:
+		e.End = e.Start + len(x.Name)
+		e.New = r.newName
+	case *ast.BasicLit:
+		if !isRegular(r.newName) {
+			return errors.Newf(n.Pos(),
+				"cannot rename %s to %s: quoted reference cannot refer to a hidden field",
+				r.name, r.newName)
+		}
+		e.End = e.Start + len(x.Value)
+		e.New = strconv.Quote(r.newName)
+	default:
+		return nil
+	}
+	r.edits[e] = true
+	return nil
+}
+
+// declarations records the edits for the top-level declarations of the
+// field in f. It reports whether f declares the field and fails if f already
+// declares the new name at the top level.
+func (r *renamer) declarations(f *ast.File) (found bool, err error) {
+	for _, spec := range f.Imports {
+		info, _ := astutil.ParseImportSpec(spec)
+		if info.Ident == r.newName {
+			return false, r.conflict(spec.Pos(), "import")
+		}
+	}
+	for _, d := range f.Decls {
+		switch x := d.(type) {
+		case *ast.Field:
+			label := x.Label
+			if a, ok := label.(*ast.Alias); ok {
+				if a.Ident.Name == r.newName {
+					return false, r.conflict(a.Pos(), "alias")
+				}
+				label, _ = a.Expr.(ast.Label)
+			}
+			switch labelName(label) {
+			case r.name:
+				if err := r.edit(label); err != nil {
+					return false, err
+				}
+				found = true
+			case r.newName:
+				return false, r.conflict(label.Pos(), "field")
+			}
+		case *ast.LetClause:
+			if x.Ident.Name == r.newName {
+				return false, r.conflict(x.Pos(), "let")
+			}
+		}
+	}
+	return found, nil
+}
+
+func (r *renamer) conflict(pos token.Pos, kind string) error {
+	return errors.Newf(pos, "cannot rename %s to %s: %s %s already declared in package",
+		r.name, r.newName, kind, r.newName)
+}
+
+// references records the edits for the identifiers in f, a file of the
+// package, that refer to the field.
+func (r *renamer) references(f *ast.File) error {
+	var err error
+	var stack []ast.Node
+	ast.Walk(f, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		parent := ast.Node(f)
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+		}
+		stack = append(stack, n)
+
+		x, ok := n.(*ast.Ident)
+		if !ok || x.Name != r.name || isDecl(parent, x) {
+			return true
+		}
+		// A reference to a top-level field of another file of the package
+		// is not resolved within f.
+		if x.Scope != f && (x.Node != nil || x.Scope != nil) {
+			return true
+		}
+		if pos, ok := r.shadowed(stack); ok {
+			err = errors.Newf(x.Pos(),
+				"cannot rename %s to %s: reference would refer to declaration at %s",
+				r.name, r.newName, pos)
+			return false
+		}
+		err = r.edit(x)
+		return err == nil
+	}, func(ast.Node) {
+		stack = stack[:len(stack)-1]
+	})
+	return err
+}
+
+// shadowed reports the position of a declaration of the new name in one of
+// the scopes enclosing the top of stack, excluding the top-level scope.
+func (r *renamer) shadowed(stack []ast.Node) (token.Pos, bool) {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch x := stack[i].(type) {
+		case *ast.StructLit:
+			for _, d := range x.Elts {
+				switch y := d.(type) {
+				case *ast.Field:
+					if a, ok := y.Label.(*ast.Alias); ok {
+						if a.Ident.Name == r.newName {
+							return a.Pos(), true
+						}
+						if l, ok := a.Expr.(ast.Label); ok && labelName(l) == r.newName {
+							return l.Pos(), true
+						}
+					} else if labelName(y.Label) == r.newName {
+						return y.Label.Pos(), true
+					}
+				case *ast.LetClause:
+					if y.Ident.Name == r.newName {
+						return y.Ident.Pos(), true
+					}
+				}
+			}
+		case *ast.Comprehension:
+			for _, c := range x.Clauses {
+				switch y := c.(type) {
+				case *ast.ForClause:
+					for _, id := range []*ast.Ident{y.Key, y.Value} {
+						if id != nil && id.Name == r.newName {
+							return id.Pos(), true
+						}
+					}
+				case *ast.LetClause:
+					if y.Ident.Name == r.newName {
+						return y.Ident.Pos(), true
+					}
+				}
+			}
+		case *ast.Field:
+			if a, ok := x.Label.(*ast.Alias); ok && a.Ident.Name == r.newName {
+				return a.Pos(), true
+			}
+		}
+	}
+	return token.NoPos, false
+}
+
+// selectors records the edits for the selectors in f that select the field
+// from the package.
+func (r *renamer) selectors(f *ast.File) error {
+	var err error
+	ast.Walk(f, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		x, ok := n.(*ast.SelectorExpr)
+		if ok && labelName(x.Sel) == r.name && r.isPackage(x.X) {
+			err = r.edit(x.Sel)
+		}
+		return err == nil
+	}, nil)
+	return err
+}
+
+// isPackage reports whether e refers to an import of the package, either
+// directly or through let bindings, aliases and fields.
+func (r *renamer) isPackage(e ast.Expr) bool {
+	// Limit the number of references followed to guard against cycles.
+	for i := 0; i < 100; i++ {
+		switch x := e.(type) {
+		case *ast.ParenExpr:
+			e = x.X
+			continue
+		case *ast.Ident:
+			switch n := x.Node.(type) {
+			case *ast.ImportSpec:
+				info, err := astutil.ParseImportSpec(n)
+				return err == nil && r.isTarget(info.Dir, info.PkgName)
+			case *ast.LetClause:
+				e = n.Expr
+				continue
+			case *ast.Field:
+				e = n.Value
+				continue
+			case *ast.Alias:
+				e = n.Expr
+				continue
+			case ast.Expr:
+				e = n
+				continue
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// isDecl reports whether the identifier x, a child of parent, declares a
+// name rather than refers to one.
+func isDecl(parent ast.Node, x *ast.Ident) bool {
+	switch p := parent.(type) {
+	case *ast.Field:
+		return p.Label == ast.Label(x)
+	case *ast.Alias:
+		return true
+	case *ast.SelectorExpr:
+		return p.Sel == ast.Label(x)
+	case *ast.LetClause:
+		return p.Ident == x
+	case *ast.ForClause:
+		return p.Key == x || p.Value == x
+	case *ast.ImportSpec, *ast.Package:
+		return true
+	}
+	return false
+}
+
+// labelName returns the name of an identifier or string label, or "" for
+// other labels. A string label only has a name if it is that of a regular
+// field: "#Name" and "_name" are regular fields unrelated to the definition
+// #Name and the hidden field _name.
+func labelName(l ast.Label) string {
+	switch x := l.(type) {
+	case *ast.Ident:
+		return x.Name
+	case *ast.BasicLit:
+		if x.Kind != token.STRING {
+			return ""
+		}
+		s, err := strconv.Unquote(x.Value)
+		if err != nil || !isRegular(s) {
+			return ""
+		}
+		return s
+	}
+	return ""
+}
+
+// isRegular reports whether name is the name of a regular field, rather
+// than that of a definition or hidden field.
+func isRegular(name string) bool {
+	return !strings.HasPrefix(name, "#") && !strings.HasPrefix(name, "_")
+}
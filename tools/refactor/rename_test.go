@@ -0,0 +1,279 @@
+// Copyright 2022 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package refactor
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/load"
+)
+
+func TestRename(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		path  string
+		old   string
+		new   string
+		out   map[string]string // changed files
+		err   string
+	}{{
+		name: "references",
+		files: map[string]string{
+			"schema/a.cue": `package schema
+
+#Old: {name: string}
+X=#Old: {x: X.name}
+#List: [...#Old]
+`,
+			"schema/b.cue": `package schema
+
+a: #Old & {#Old: 1, b: #Old}
+`,
+			"app/app.cue": `package app
+
+import (
+	s "mod.test/schema"
+	other "mod.test/other"
+)
+
+let S = s
+a: s.#Old & {name: "a"}
+b: S.#Old
+c: other.#Old
+d: [s.#List, s.#Old.name]
+`,
+			"other/other.cue": `package other
+
+#Old: 1
+`,
+		},
+		path: "mod.test/schema",
+		old:  "#Old",
+		new:  "#New",
+		out: map[string]string{
+			"schema/a.cue": `package schema
+
+#New: {name: string}
+X=#New: {x: X.name}
+#List: [...#New]
+`,
+			"schema/b.cue": `package schema
+
+a: #New & {#Old: 1, b: #Old}
+`,
+			"app/app.cue": `package app
+
+import (
+	s "mod.test/schema"
+	other "mod.test/other"
+)
+
+let S = s
+a: s.#New & {name: "a"}
+b: S.#New
+c: other.#Old
+d: [s.#List, s.#New.name]
+`,
+		},
+	}, {
+		name: "quoted labels",
+		files: map[string]string{
+			"schema/a.cue": `package schema
+
+"old": 1
+b: old + 1
+`,
+			"app/app.cue": `package app
+
+import "mod.test/schema"
+
+a: schema."old"
+b: schema.old
+`,
+		},
+		path: "mod.test/schema",
+		old:  "old",
+		new:  "new",
+		out: map[string]string{
+			"schema/a.cue": `package schema
+
+"new": 1
+b: new + 1
+`,
+			"app/app.cue": `package app
+
+import "mod.test/schema"
+
+a: schema."new"
+b: schema.new
+`,
+		},
+	}, {
+		name: "quoted definition names",
+		files: map[string]string{
+			"schema/a.cue": `package schema
+
+#Old: 1
+"#Old"?: _
+"#New": 2
+`,
+			"app/app.cue": `package app
+
+import L "mod.test/schema"
+
+a: L.#Old
+b: L["#Old"]
+c: L."#Old"
+`,
+		},
+		path: "mod.test/schema",
+		old:  "#Old",
+		new:  "#New",
+		out: map[string]string{
+			"schema/a.cue": `package schema
+
+#New: 1
+"#Old"?: _
+"#New": 2
+`,
+			"app/app.cue": `package app
+
+import L "mod.test/schema"
+
+a: L.#New
+b: L["#Old"]
+c: L."#Old"
+`,
+		},
+	}, {
+		name: "quoted hidden",
+		files: map[string]string{
+			"schema/a.cue": "package schema\n\n\"old\": 1\n",
+		},
+		path: "mod.test/schema",
+		old:  "old",
+		new:  "_old",
+		err:  "cannot rename old to _old: quoted reference cannot refer to a hidden field",
+	}, {
+		name: "declared",
+		files: map[string]string{
+			"schema/a.cue": "package schema\n\n#Old: 1\n",
+			"schema/b.cue": "package schema\n\n#New: 2\n",
+		},
+		path: "mod.test/schema",
+		old:  "#Old",
+		new:  "#New",
+		err:  "cannot rename #Old to #New: field #New already declared in package",
+	}, {
+		name: "shadowed",
+		files: map[string]string{
+			"schema/a.cue": `package schema
+
+#Old: 1
+a: {
+	#New: 2
+	b: #Old
+}
+`,
+		},
+		path: "mod.test/schema",
+		old:  "#Old",
+		new:  "#New",
+		err:  "cannot rename #Old to #New: reference would refer to declaration at ",
+	}, {
+		name: "not declared",
+		files: map[string]string{
+			"schema/a.cue": "package schema\n\n#Old: 1\n",
+		},
+		path: "mod.test/schema",
+		old:  "#Foo",
+		new:  "#Bar",
+		err:  `#Foo is not declared in package "mod.test/schema"`,
+	}, {
+		name: "definition",
+		files: map[string]string{
+			"schema/a.cue": "package schema\n\n#Old: 1\n",
+		},
+		path: "mod.test/schema",
+		old:  "#Old",
+		new:  "New",
+		err:  "cannot rename #Old to New: definitions can only be renamed to definitions",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tc.files["cue.mod/module.cue"] = `module: "mod.test"`
+			for name, src := range tc.files {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			instances := load.Instances([]string{"./..."}, &load.Config{Dir: dir})
+			for _, inst := range instances {
+				if inst.Err != nil {
+					t.Fatal(inst.Err)
+				}
+			}
+
+			edits, err := Rename(instances, tc.path, tc.old, tc.new)
+			if err != nil {
+				if tc.err == "" || !strings.HasPrefix(err.Error(), tc.err) {
+					t.Fatalf("error: got %q; want %q", err, tc.err)
+				}
+				return
+			}
+			if tc.err != "" {
+				t.Fatalf("expected error %q", tc.err)
+			}
+
+			byFile := map[string][]Edit{}
+			for _, e := range edits {
+				byFile[e.Filename] = append(byFile[e.Filename], e)
+			}
+			var got []string
+			for filename, edits := range byFile {
+				rel, _ := filepath.Rel(dir, filename)
+				rel = filepath.ToSlash(rel)
+				got = append(got, rel)
+				b, err := Apply([]byte(tc.files[rel]), edits)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != tc.out[rel] {
+					t.Errorf("%s: got:\n%s\nwant:\n%s", rel, b, tc.out[rel])
+				}
+			}
+			sort.Strings(got)
+			var want []string
+			for name := range tc.out {
+				want = append(want, name)
+			}
+			sort.Strings(want)
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("changed files: got %v; want %v", got, want)
+			}
+		})
+	}
+}